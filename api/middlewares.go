@@ -1,12 +1,20 @@
 package api
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/vultisig/vultiserver-plugin/internal/idempotency"
 	"github.com/vultisig/vultiserver-plugin/internal/jwt"
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/service"
 )
 
 func (s *Server) statsdMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -52,20 +60,293 @@ func (s *Server) userAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// adminMiddleware requires the user authenticated by userAuthMiddleware to be
+// an admin.
+func (s *Server) adminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := c.Get("user").(*types.User)
+		if !ok || !user.IsAdmin() {
+			return c.JSON(http.StatusForbidden, echo.Map{"error": "Admin role required"})
+		}
+		return next(c)
+	}
+}
+
+// AuthMiddleware accepts vault tokens only, the routes behind it serve vault
+// data.
 func (s *Server) AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return s.tokenAuthMiddleware("")(next)
+}
+
+// tokenAuthMiddleware accepts vault tokens and, with a non-empty pluginScope,
+// plugin service tokens that grant it.
+func (s *Server) tokenAuthMiddleware(pluginScope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing Authorization header"})
+			}
+
+			tokenStr := authHeader[len("Bearer "):]
+			claims, err := s.authService.ValidateToken(tokenStr)
+			if err != nil {
+				s.logger.Warnf("fail to validate token, err: %v", err)
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			}
+			// tokens identify either a plugin with its scopes or a vault, those
+			// issued before carry neither and are no longer accepted
+			switch {
+			case claims.PluginID != "":
+				if len(claims.Scopes) == 0 {
+					s.logger.Warnf("fail to validate plugin token, token %s has no scopes", claims.Id)
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+				}
+				if err := s.checkPluginToken(c, claims); err != nil {
+					s.logger.Warnf("fail to validate plugin token, err: %v", err)
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+				}
+				if pluginScope == "" || !claims.HasScope(pluginScope) {
+					s.logger.Warnf("fail to validate plugin token, token %s doesn't grant %q", claims.Id, pluginScope)
+					return c.JSON(http.StatusForbidden, map[string]string{"error": "Plugin tokens are not accepted"})
+				}
+			case claims.PublicKey == "":
+				s.logger.Warn("fail to validate token, token carries no identity")
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			}
+			c.Set("claims", claims)
+			s.logger.Info("Token validated successfully")
+			return next(c)
+		}
+	}
+}
+
+// readOnlyRoutes are the non-GET routes a read-only server still serves, none
+// of them writes to the database.
+var readOnlyRoutes = map[string]bool{
+	"/auth":                           true,
+	"/auth/refresh":                   true,
+	"/login":                          true,
+	"/plugin/policy/schedule/preview": true,
+}
+
+// readOnlyMiddleware refuses requests that would write while the server is in
+// read-only mode.
+func (s *Server) readOnlyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing Authorization header"})
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		if readOnlyRoutes[c.Path()] {
+			return next(c)
+		}
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Server is in read-only mode"})
+	}
+}
+
+// idempotencyMiddleware answers a request retried with the same
+// Idempotency-Key with the recorded response, so retried writes are applied
+// once. Keys are scoped to the route and the calling plugin, and reusing one
+// for a different request is refused.
+func (s *Server) idempotencyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		idempotencyKey := c.Request().Header.Get(idempotency.Header)
+		if idempotencyKey == "" {
+			return next(c)
 		}
 
-		tokenStr := authHeader[len("Bearer "):]
-		_, err := s.authService.ValidateToken(tokenStr)
+		req := c.Request()
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		// keys are scoped to the caller, another caller reusing one gets
+		// neither the recorded response nor a conflict
+		key := req.Method + " " + c.Path()
+		if plugin, ok := c.Get("plugin").(*types.Plugin); ok {
+			key += " plugin:" + plugin.ID
+		}
+		if claims, ok := c.Get("claims").(*service.Claims); ok {
+			if claims.PluginID != "" {
+				key += " plugin:" + claims.PluginID
+			} else {
+				key += " vault:" + claims.PublicKey
+			}
+		}
+		key += " " + idempotencyKey
+		fingerprint := idempotency.Fingerprint([]byte(req.Method), []byte(req.URL.Path), []byte(req.URL.RawQuery), body)
+
+		ctx := req.Context()
+		recorded, err := s.idempotency.Begin(ctx, key, fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused):
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		case errors.Is(err, idempotency.ErrInProgress):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case err != nil:
+			// the store is down, serve the request without replay protection
+			s.logger.Errorf("fail to check idempotency key, err: %v", err)
+			return next(c)
+		case recorded != nil:
+			s.logger.Infof("Replaying request with idempotency key %s", idempotencyKey)
+			c.Response().Header().Set("Idempotent-Replayed", "true")
+			if len(recorded.Body) == 0 {
+				return c.NoContent(recorded.StatusCode)
+			}
+			return c.Blob(recorded.StatusCode, recorded.ContentType, recorded.Body)
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+		err = next(c)
+
+		statusCode := c.Response().Status
+		if err != nil || !c.Response().Committed || !idempotency.Replayable(statusCode) {
+			if err := s.idempotency.Release(ctx, key); err != nil {
+				s.logger.Errorf("fail to release idempotency key, err: %v", err)
+			}
+			return err
+		}
+		response := idempotency.Response{
+			StatusCode:  statusCode,
+			ContentType: c.Response().Header().Get(echo.HeaderContentType),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := s.idempotency.Complete(ctx, key, fingerprint, response); err != nil {
+			s.logger.Errorf("fail to record idempotency response, err: %v", err)
+		}
+		return nil
+	}
+}
+
+// responseRecorder keeps a copy of the response body written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// pluginAuthMiddleware authenticates a plugin server by the Ed25519 signature
+// over its request, checked against the key registered for the plugin.
+func (s *Server) pluginAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		pluginID := req.Header.Get(sigutil.HeaderPluginID)
+		signature := req.Header.Get(sigutil.HeaderSignature)
+		if pluginID == "" || signature == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing plugin signature"})
+		}
+
+		plugin, err := s.db.FindPluginById(req.Context(), pluginID)
 		if err != nil {
-			s.logger.Warnf("fail to validate token, err: %v", err)
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			s.logger.Warnf("fail to find plugin %s, err: %v", pluginID, err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unknown plugin"})
 		}
-		s.logger.Info("Token validated successfully")
+		if plugin.PublicKey == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Plugin has no registered key"})
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		err = sigutil.VerifyRequestSignature(plugin.PublicKey, req.Method, req.URL.RequestURI(), req.Header.Get(sigutil.HeaderTimestamp), body, signature)
+		if err != nil {
+			s.logger.Warnf("fail to verify request of plugin %s, err: %v", pluginID, err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid plugin signature"})
+		}
+
+		if ok, err := s.pluginReplays.SetNX(req.Context(), signature, pluginID, 2*sigutil.RequestMaxAge); err != nil {
+			s.logger.Errorf("fail to store plugin request signature, err: %v", err)
+		} else if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Signature already used"})
+		}
+
+		c.Set("plugin", plugin)
 		return next(c)
 	}
 }
+
+// tokenVault returns the public key of the vault the request's token was
+// issued to. Vault data is only served to its vault, a public_key in the
+// request must match the token.
+func tokenVault(c echo.Context, publicKey string) (string, error) {
+	claims, ok := c.Get("claims").(*service.Claims)
+	if !ok || claims.PluginID != "" || claims.PublicKey == "" {
+		return "", fmt.Errorf("token is not issued to a vault")
+	}
+	if publicKey != "" && publicKey != claims.PublicKey {
+		return "", fmt.Errorf("public key doesn't match the token")
+	}
+	return claims.PublicKey, nil
+}
+
+// checkCallingPlugin rejects syncs of a policy that does not belong to the
+// plugin authenticated by pluginAuthMiddleware. Policies are matched on the
+// plugin ID only, other plugins of the same type don't share them.
+func (s *Server) checkCallingPlugin(c echo.Context, policyPluginID string) error {
+	plugin, ok := c.Get("plugin").(*types.Plugin)
+	if !ok {
+		return nil
+	}
+	if policyPluginID != plugin.ID {
+		return fmt.Errorf("policy belongs to plugin %s, not %s", policyPluginID, plugin.ID)
+	}
+	return nil
+}
+
+// pluginTokenMiddleware requires a service token of the calling plugin that
+// grants scope. It runs after pluginAuthMiddleware, which identifies the plugin.
+func (s *Server) pluginTokenMiddleware(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing Authorization header"})
+			}
+
+			claims, err := s.authService.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+			if err != nil || claims.PluginID == "" {
+				s.logger.Warnf("fail to validate plugin token, err: %v", err)
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			}
+			if err := s.checkPluginToken(c, claims); err != nil {
+				s.logger.Warnf("fail to validate plugin token, err: %v", err)
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			}
+			if !claims.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("Missing scope %s", scope)})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// checkPluginToken rejects revoked plugin tokens and tokens of another plugin
+// than the one that signed the request.
+func (s *Server) checkPluginToken(c echo.Context, claims *service.Claims) error {
+	token, err := s.db.GetPluginToken(c.Request().Context(), claims.Id)
+	if err != nil {
+		return fmt.Errorf("unknown token: %w", err)
+	}
+	if token.RevokedAt != nil {
+		return fmt.Errorf("token %s is revoked", token.ID)
+	}
+	if token.PluginID != claims.PluginID {
+		return fmt.Errorf("token %s was not issued to plugin %s", token.ID, claims.PluginID)
+	}
+	if plugin, ok := c.Get("plugin").(*types.Plugin); ok && plugin.ID != claims.PluginID {
+		return fmt.Errorf("token of plugin %s used by plugin %s", claims.PluginID, plugin.ID)
+	}
+	return nil
+}
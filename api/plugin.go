@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -14,16 +15,29 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/attestation"
 	"github.com/vultisig/vultiserver-plugin/internal/jwt"
 	"github.com/vultisig/vultiserver-plugin/internal/password"
+	"github.com/vultisig/vultiserver-plugin/internal/reconcile"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+	"github.com/vultisig/vultiserver-plugin/internal/rules"
+	"github.com/vultisig/vultiserver-plugin/internal/scheduler"
 	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
 	"github.com/vultisig/vultiserver-plugin/internal/tasks"
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
 	"github.com/vultisig/vultiserver-plugin/plugin"
 	"github.com/vultisig/vultiserver-plugin/plugin/dca"
 	"github.com/vultisig/vultiserver-plugin/plugin/payroll"
+	"github.com/vultisig/vultiserver-plugin/service"
+	"github.com/vultisig/vultiserver-plugin/storage"
 
+	"github.com/ethereum/go-ethereum"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/hibiken/asynq"
 	"github.com/labstack/echo/v4"
 )
@@ -47,9 +61,19 @@ func (s *Server) SignPluginMessages(c echo.Context) error {
 		return fmt.Errorf("failed to get policy from database: %w", err)
 	}
 
-	// Validate policy matches plugin
-	if policy.PluginID != req.PluginID {
-		return fmt.Errorf("policy plugin ID mismatch")
+	// Validate policy matches plugin, on the verifier the plugin that signed
+	// the request rather than the one it names
+	pluginID := req.PluginID
+	if plugin, ok := c.Get("plugin").(*types.Plugin); ok {
+		pluginID = plugin.ID
+	}
+	if policy.PluginID != pluginID {
+		return echo.NewHTTPError(http.StatusForbidden, "policy plugin ID mismatch")
+	}
+
+	// Deleted policies are never signed for again
+	if policy.IsDeleted() {
+		return echo.NewHTTPError(http.StatusGone, service.ErrPolicyDeleted.Error())
 	}
 
 	// We re-init plugin as verification server doesn't have plugin defined
@@ -59,10 +83,34 @@ func (s *Server) SignPluginMessages(c echo.Context) error {
 		return fmt.Errorf("failed to initialize plugin: %w", err)
 	}
 
+	// Limits are kept per transaction type, so the type is read from the
+	// transaction itself rather than taken from the plugin's label
+	proposedTx, err := txdecoder.ParseTransaction(req.Transaction)
+	if err != nil {
+		return fmt.Errorf("fail to parse transaction: %w", err)
+	}
+	txType, err := txdecoder.TransactionType(proposedTx)
+	if err != nil {
+		return fmt.Errorf("fail to determine transaction type: %w", err)
+	}
+	if txType != req.TransactionType {
+		return fmt.Errorf("transaction type mismatch, proposed as %s but is %s", req.TransactionType, txType)
+	}
+
 	if err := plg.ValidateProposedTransactions(policy, []types.PluginKeysignRequest{req}); err != nil {
 		return fmt.Errorf("failed to validate transaction proposal: %w", err)
 	}
 
+	// Rebuild the transaction ourselves rather than trusting the plugin's chain view
+	if err := s.verifyReconstruction(c.Request().Context(), plg, policy, req); err != nil {
+		return fmt.Errorf("failed to verify transaction reconstruction: %w", err)
+	}
+
+	// Event triggered policies must still satisfy their condition at signing time
+	if err := s.verifyTriggerCondition(c.Request().Context(), policy); err != nil {
+		return fmt.Errorf("failed to verify trigger condition: %w", err)
+	}
+
 	// Validate message hash matches transaction
 	txHash, err := calculateTransactionHash(req.Transaction)
 	if err != nil {
@@ -73,14 +121,23 @@ func (s *Server) SignPluginMessages(c echo.Context) error {
 	}
 
 	// Reuse existing signing logic
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 30*time.Minute); err != nil {
+	// a session is only started once, retries of the request are acknowledged.
+	// The claim is released if the request is refused, so that a retry is
+	// checked again rather than acknowledged without a keysign task.
+	if ok, err := s.sessions.SetNX(c.Request().Context(), req.SessionID, req.SessionID, 30*time.Minute); err != nil {
 		s.logger.Errorf("fail to set session, err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusOK)
 	}
+	sessionStarted := false
+	defer func() {
+		if sessionStarted {
+			return
+		}
+		if err := s.sessions.Delete(c.Request().Context(), req.SessionID); err != nil {
+			s.logger.Errorf("fail to release session, err: %v", err)
+		}
+	}()
 
 	filePathName := common.GetVaultBackupFilename(req.PublicKey)
 	content, err := s.blockStorage.GetFile(filePathName)
@@ -113,6 +170,26 @@ func (s *Server) SignPluginMessages(c echo.Context) error {
 		return fmt.Errorf("fail to get transaction by hash: %w", err)
 	}
 
+	// Aggregate risk limits of the vault apply across all of its plugins, and
+	// the policy cadence is enforced from our own records, whatever the plugin
+	// claims
+	decision, allowed, err := s.authorizeSigning(c.Request().Context(), plg, policy, txToSign, req.Transaction, txHash, txType)
+	if err != nil {
+		return fmt.Errorf("fail to authorize signing: %w", err)
+	}
+	if !decision.Allowed {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"message": "transaction rejected by spending rules",
+			"error":   strings.Join(decision.Reasons, "; "),
+		})
+	}
+	if !allowed {
+		return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+			"message": "policy execution rate limit exceeded",
+			"error":   fmt.Sprintf("too many %s transactions for policy %s", txType, policy.ID),
+		})
+	}
+
 	s.logger.Debug("PLUGIN SERVER: KEYSIGN TASK")
 
 	ti, err := s.client.EnqueueContext(c.Request().Context(),
@@ -123,21 +200,91 @@ func (s *Server) SignPluginMessages(c echo.Context) error {
 		asynq.Queue(tasks.QUEUE_NAME))
 
 	if err != nil {
-		txToSign.Metadata["error"] = err.Error()
-		if updateErr := s.db.UpdateTransactionStatus(c.Request().Context(), txToSign.ID, types.StatusSigningFailed, txToSign.Metadata); updateErr != nil {
+		errorMessage := err.Error()
+		update := types.TransactionUpdate{Status: types.StatusSigningFailed, ErrorMessage: &errorMessage}
+		if updateErr := s.db.UpdateTransactionStatus(c.Request().Context(), txToSign.ID, update); updateErr != nil {
 			s.logger.Errorf("Failed to update transaction status: %v", updateErr)
 		}
+		if releaseErr := s.db.ReleaseTransactionSigning(c.Request().Context(), txToSign.ID); releaseErr != nil {
+			s.logger.Errorf("Failed to release transaction signing: %v", releaseErr)
+		}
 		return fmt.Errorf("fail to enqueue keysign task: %w", err)
 	}
+	sessionStarted = true
 
-	txToSign.Metadata["task_id"] = ti.ID
-	if err := s.db.UpdateTransactionStatus(c.Request().Context(), txToSign.ID, types.StatusSigned, txToSign.Metadata); err != nil {
+	update := types.TransactionUpdate{Status: types.StatusSigned, SigningTaskID: ti.ID}
+	if err := s.db.UpdateTransactionStatus(c.Request().Context(), txToSign.ID, update); err != nil {
 		s.logger.Errorf("Failed to update transaction with task ID: %v", err)
 	}
 
 	s.logger.Infof("Created transaction history for tx from plugin: %s...", req.Transaction[:min(20, len(req.Transaction))])
 
-	return c.JSON(http.StatusOK, ti.ID)
+	receipt, err := s.attestApproval(c.Request().Context(), policy, txToSign.ID, req.Transaction, txHash)
+	if err != nil {
+		s.logger.Errorf("Failed to attest transaction approval: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"task_id":     ti.ID,
+		"attestation": receipt,
+	})
+}
+
+// attestApproval signs and stores a receipt stating that the transaction was
+// checked against the policy. It returns nil without an attestation key.
+func (s *Server) attestApproval(ctx context.Context, policy types.PluginPolicy, txID uuid.UUID, txHex string, txHash string) (*attestation.Attestation, error) {
+	if s.attestor == nil {
+		return nil, nil
+	}
+
+	intent, err := txdecoder.Decode(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	policyHash, err := sigutil.PolicyDocumentHash(policy.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := s.attestor.Sign(attestation.Receipt{
+		PolicyID:      policy.ID,
+		PolicyVersion: policy.PolicyVersion,
+		PolicyHash:    hexutil.Encode(policyHash),
+		PluginID:      policy.PluginID,
+		PublicKey:     policy.PublicKey,
+		TxHash:        txHash,
+		Intent:        intent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.SetTransactionAttestation(ctx, txID, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (s *Server) GetAttestationKey(c echo.Context) error {
+	if s.attestor == nil {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "attestations are not enabled"})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"public_key": s.attestor.PublicKeyHex()})
+}
+
+func (s *Server) GetTransactionAttestation(c echo.Context) error {
+	txHash := c.Param("txHash")
+
+	receipt, err := s.db.GetTransactionAttestation(c.Request().Context(), txHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{"message": "attestation not found"})
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to get attestation"})
+	}
+
+	return c.JSONBlob(http.StatusOK, receipt)
 }
 
 func (s *Server) GetPluginPolicyById(c echo.Context) error {
@@ -164,6 +311,12 @@ func (s *Server) GetPluginPolicyById(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, message)
 	}
 
+	// plugins only read their own policies
+	if claims, ok := c.Get("claims").(*service.Claims); ok && claims.PluginID != "" && claims.PluginID != policy.PluginID {
+		s.logger.Warnf("plugin %s can't read policy %s of plugin %s", claims.PluginID, policyID, policy.PluginID)
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	}
+
 	return c.JSON(http.StatusOK, policy)
 }
 
@@ -241,20 +394,42 @@ func (s *Server) CreatePluginPolicy(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, message)
 	}
 
+	if err := s.checkCallingPlugin(c, policy.PluginID); err != nil {
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
 	if policy.ID == "" {
 		policy.ID = uuid.NewString()
 	}
 
-	if !s.verifyPolicySignature(policy, false) {
-		s.logger.Error("invalid policy signature")
+	if err := s.verifyPolicySignature(policy, sigutil.PolicyActionCreate); err != nil {
+		s.logger.Error(err)
 		message := map[string]interface{}{
 			"message": "Authorization failed",
-			"error":   "Invalid policy signature",
+			"error":   err.Error(),
 		}
 		return c.JSON(http.StatusForbidden, message)
 	}
 
 	newPolicy, err := s.policyService.CreatePolicyWithSync(c.Request().Context(), policy)
+	if errors.Is(err, service.ErrPolicyDeleted) {
+		return c.JSON(http.StatusGone, map[string]interface{}{
+			"message": "failed to create policy",
+			"error":   err.Error(),
+		})
+	}
+	if errors.Is(err, service.ErrPolicyNonceUsed) {
+		s.logger.Error(err)
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		})
+	}
 	if err != nil {
 		err = fmt.Errorf("failed to create plugin policy: %w", err)
 		message := map[string]interface{}{
@@ -318,16 +493,44 @@ func (s *Server) UpdatePluginPolicyById(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, message)
 	}
 
-	if !s.verifyPolicySignature(policy, true) {
-		s.logger.Error("invalid policy signature")
+	if err := s.checkCallingPluginOwnsPolicy(c, policy); err != nil {
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
+	if err := s.verifyPolicySignature(policy, sigutil.PolicyActionUpdate); err != nil {
+		s.logger.Error(err)
 		message := map[string]interface{}{
 			"message": "Authorization failed",
-			"error":   "Invalid policy signature",
+			"error":   err.Error(),
 		}
 		return c.JSON(http.StatusForbidden, message)
 	}
 
 	updatedPolicy, err := s.policyService.UpdatePolicyWithSync(c.Request().Context(), policy)
+	if errors.Is(err, service.ErrPolicyDeleted) {
+		return c.JSON(http.StatusGone, map[string]interface{}{
+			"message": fmt.Sprintf("failed to update policy: %s", policy.ID),
+			"error":   err.Error(),
+		})
+	}
+	if errors.Is(err, service.ErrPolicyNonceUsed) || errors.Is(err, service.ErrPolicyOwnerChanged) {
+		s.logger.Error(err)
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		})
+	}
+	if errors.Is(err, service.ErrStalePolicy) {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"message": fmt.Sprintf("failed to update policy: %s", policy.ID),
+			"error":   err.Error(),
+		})
+	}
 	if err != nil {
 		err = fmt.Errorf("failed to update plugin policy: %w", err)
 		message := map[string]interface{}{
@@ -341,10 +544,7 @@ func (s *Server) UpdatePluginPolicyById(c echo.Context) error {
 }
 
 func (s *Server) DeletePluginPolicyById(c echo.Context) error {
-	var reqBody struct {
-		Signature string `json:"signature"`
-	}
-
+	var reqBody types.PolicyDeleteRequest
 	if err := c.Bind(&reqBody); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
@@ -372,19 +572,51 @@ func (s *Server) DeletePluginPolicyById(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, message)
 	}
 
+	if err := s.checkCallingPlugin(c, policy.PluginID); err != nil {
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
+	if policy.IsDeleted() {
+		return c.JSON(http.StatusGone, map[string]interface{}{
+			"message": fmt.Sprintf("failed to delete policy: %s", policyID),
+			"error":   service.ErrPolicyDeleted.Error(),
+		})
+	}
+
 	// This is because we have different signature stored in the database.
 	policy.Signature = reqBody.Signature
+	policy.Nonce = reqBody.Nonce
+	policy.Expiry = reqBody.Expiry
 
-	if !s.verifyPolicySignature(policy, true) {
-		s.logger.Error("invalid policy signature")
+	if err := s.verifyPolicySignature(policy, sigutil.PolicyActionDelete); err != nil {
+		s.logger.Error(err)
 		message := map[string]interface{}{
 			"message": "Authorization failed",
-			"error":   "Invalid policy signature",
+			"error":   err.Error(),
 		}
 		return c.JSON(http.StatusForbidden, message)
 	}
 
-	if err := s.policyService.DeletePolicyWithSync(c.Request().Context(), policyID, reqBody.Signature); err != nil {
+	err = s.policyService.DeletePolicyWithSync(c.Request().Context(), policyID, reqBody)
+	if errors.Is(err, service.ErrPolicyDeleted) {
+		return c.JSON(http.StatusGone, map[string]interface{}{
+			"message": fmt.Sprintf("failed to delete policy: %s", policyID),
+			"error":   err.Error(),
+		})
+	}
+	if errors.Is(err, service.ErrPolicyNonceUsed) {
+		s.logger.Error(err)
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		})
+	}
+	if err != nil {
 		err = fmt.Errorf("failed to delete policy: %w", err)
 		message := map[string]interface{}{
 			"message": fmt.Sprintf("failed to delete policy: %s", policyID),
@@ -434,6 +666,31 @@ func (s *Server) GetPolicySchema(c echo.Context) error {
 	return c.JSON(http.StatusOK, data)
 }
 
+func (s *Server) PreviewPolicySchedule(c echo.Context) error {
+	var schedule types.Schedule
+	if err := c.Bind(&schedule); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+
+	count, err := strconv.Atoi(c.QueryParam("count"))
+	if err != nil {
+		count = 10
+	}
+
+	times, err := scheduler.PreviewSchedule(schedule, time.Now(), count)
+	if err != nil {
+		message := map[string]interface{}{
+			"message": "failed to preview schedule",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"next_executions": times,
+	})
+}
+
 func (s *Server) GetPluginPolicyTransactionHistory(c echo.Context) error {
 	policyID := c.Param("policyId")
 
@@ -446,6 +703,11 @@ func (s *Server) GetPluginPolicyTransactionHistory(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, message)
 	}
 
+	if status, err := s.checkPolicyOfTokenVault(c, policyID); err != nil {
+		s.logger.Warnf("fail to authorize policy request, err: %v", err)
+		return c.JSON(status, map[string]interface{}{"message": http.StatusText(status)})
+	}
+
 	policyHistory, err := s.policyService.GetPluginPolicyTransactionHistory(c.Request().Context(), policyID)
 	if err != nil {
 		err = fmt.Errorf("failed to get policy history: %w", err)
@@ -459,143 +721,634 @@ func (s *Server) GetPluginPolicyTransactionHistory(c echo.Context) error {
 	return c.JSON(http.StatusOK, policyHistory)
 }
 
-func (s *Server) initializePlugin(pluginType string) (plugin.Plugin, error) {
-	switch pluginType {
-	case "payroll":
-		return payroll.NewPayrollPlugin(s.db, s.logger, s.pluginConfigs["payroll"])
-	case "dca":
-		return dca.NewDCAPlugin(s.db, s.logger, s.pluginConfigs["dca"])
-	default:
-		return nil, fmt.Errorf("unknown plugin type: %s", pluginType)
-	}
-}
-
-func (s *Server) UserLogin(c echo.Context) error {
-	var auth types.UserAuthDto
-	if err := c.Bind(&auth); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request"})
-	}
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
 
-	if err := c.Validate(&auth); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"message": err.Error(),
-		})
+// QueryTransactionHistory returns the transactions of the vault's policies
+// matching the query parameters, newest first unless sort=created_at, with the
+// totals per token. Pages continue with the returned next_cursor.
+func (s *Server) QueryTransactionHistory(c echo.Context) error {
+	owner, err := tokenVault(c, c.Request().Header.Get("public_key"))
+	if err != nil {
+		s.logger.Warnf("fail to authorize transaction history request, err: %v", err)
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": "Forbidden"})
 	}
 
-	user, err := s.db.FindUserByName(c.Request().Context(), auth.Username)
+	query, err := parseTransactionHistoryQuery(c, owner)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid credentials"})
-	}
-	if passwordValid := password.CheckPassword(auth.Password, user.Password); !passwordValid {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid credentials"})
+		message := map[string]interface{}{
+			"message": "failed to query transaction history",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
 	}
 
-	token, err := jwt.GenerateJWT(user.ID, s.cfg.Server.UserAuth.JwtSecret)
+	page, err := s.policyService.QueryTransactionHistory(c.Request().Context(), query)
 	if err != nil {
-		s.logger.Error("Failed to generate jwt", err)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to generate token"})
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to query transaction history for public_key: %s", query.PublicKey),
+		}
+		return c.JSON(http.StatusInternalServerError, message)
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"token": token})
-}
-
-func (s *Server) GetLoggedUser(c echo.Context) error {
-	user := c.Get("user")
-
-	return c.JSON(http.StatusOK, user)
+	return c.JSON(http.StatusOK, page)
 }
 
-func (s *Server) GetPricing(c echo.Context) error {
-	pricingID := c.Param("pricingId")
-	if pricingID == "" {
-		err := fmt.Errorf("pricing id is required")
-		message := echo.Map{
-			"message": "failed to get pricing",
-			"error":   err.Error(),
+// parseTransactionHistoryQuery parses the query over the transactions of the
+// vault with the given public key.
+func parseTransactionHistoryQuery(c echo.Context, publicKey string) (types.TransactionHistoryQuery, error) {
+	query := types.TransactionHistoryQuery{
+		PublicKey: publicKey,
+		PolicyID:  c.QueryParam("policy_id"),
+		Token:     strings.ToLower(c.QueryParam("token")),
+		ChainID:   c.QueryParam("chain_id"),
+		TxHash:    c.QueryParam("tx_hash"),
+		Limit:     defaultHistoryLimit,
+	}
+	if query.PolicyID != "" {
+		if _, err := uuid.Parse(query.PolicyID); err != nil {
+			return query, fmt.Errorf("invalid policy_id: %s", query.PolicyID)
 		}
-		s.logger.Error(err)
+	}
 
-		return c.JSON(http.StatusBadRequest, message)
+	for _, status := range splitQueryList(c.QueryParam("status")) {
+		query.Statuses = append(query.Statuses, types.TransactionStatus(strings.ToUpper(status)))
 	}
+	query.TransactionTypes = splitQueryList(c.QueryParam("type"))
 
-	pricing, err := s.db.FindPricingById(c.Request().Context(), pricingID)
-	if err != nil {
-		message := echo.Map{
-			"message": "failed to get pricing",
+	for name, bound := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
 		}
-		s.logger.Error(err)
-		return c.JSON(http.StatusInternalServerError, message)
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("invalid %s, expected RFC3339: %w", name, err)
+		}
+		*bound = &t
 	}
 
-	return c.JSON(http.StatusOK, pricing)
-}
-
-func (s *Server) CreatePricing(c echo.Context) error {
-	var pricing types.PricingCreateDto
-	if err := c.Bind(&pricing); err != nil {
-		return fmt.Errorf("fail to parse request, err: %w", err)
+	switch c.QueryParam("sort") {
+	case "", "-created_at":
+	case "created_at":
+		query.Ascending = true
+	default:
+		return query, fmt.Errorf("invalid sort %q, expected created_at or -created_at", c.QueryParam("sort"))
 	}
 
-	if err := c.Validate(&pricing); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"message": err.Error(),
-		})
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		parsed, err := types.ParseTransactionCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = parsed
 	}
 
-	created, err := s.db.CreatePricing(c.Request().Context(), pricing)
-	if err != nil {
-		message := echo.Map{
-			"message": "failed to create pricing",
+	if limit := c.QueryParam("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxHistoryLimit {
+			return query, fmt.Errorf("invalid limit, expected 1 to %d", maxHistoryLimit)
 		}
-		s.logger.Error(err)
-		return c.JSON(http.StatusInternalServerError, message)
+		query.Limit = parsed
 	}
 
-	return c.JSON(http.StatusOK, created)
+	return query, nil
 }
 
-func (s *Server) DeletePricing(c echo.Context) error {
-	pricingID := c.Param("pricingId")
-	if pricingID == "" {
-		message := echo.Map{
-			"message": "failed to delete pricing",
-			"error":   "pricing id is required",
+// splitQueryList splits a comma separated query parameter.
+func splitQueryList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
 		}
-		return c.JSON(http.StatusBadRequest, message)
 	}
+	return values
+}
 
-	err := s.db.DeletePricingById(c.Request().Context(), pricingID)
-	if err != nil {
-		message := echo.Map{
-			"message": "failed to delete pricing",
+func (s *Server) GetPluginPolicyRuns(c echo.Context) error {
+	policyID := c.Param("policyId")
+
+	if policyID == "" {
+		err := fmt.Errorf("policy ID is required")
+		message := map[string]interface{}{
+			"message": "failed to get policy runs",
 			"error":   err.Error(),
 		}
-		s.logger.Error(err)
-		return c.JSON(http.StatusInternalServerError, message)
+		return c.JSON(http.StatusBadRequest, message)
 	}
 
-	return c.NoContent(http.StatusNoContent)
-}
+	if status, err := s.checkPolicyOfTokenVault(c, policyID); err != nil {
+		s.logger.Warnf("fail to authorize policy request, err: %v", err)
+		return c.JSON(status, map[string]interface{}{"message": http.StatusText(status)})
+	}
 
-func (s *Server) GetPlugins(c echo.Context) error {
 	skip, err := strconv.Atoi(c.QueryParam("skip"))
-
-	if err != nil {
+	if err != nil || skip < 0 {
 		skip = 0
 	}
 
+	// runs are paged like the transaction history
 	take, err := strconv.Atoi(c.QueryParam("take"))
-
-	if err != nil {
-		take = 999
+	if err != nil || take < 1 {
+		take = 30
 	}
+	take = min(take, maxHistoryLimit)
 
-	sort := c.QueryParam("sort")
-
-	plugins, err := s.db.FindPlugins(c.Request().Context(), skip, take, sort)
+	runs, err := s.policyService.GetPluginPolicyRuns(c.Request().Context(), policyID, take, skip)
 	if err != nil {
-		message := echo.Map{
-			"message": "failed to get plugins",
+		err = fmt.Errorf("failed to get policy runs: %w", err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to get policy runs: %s", policyID),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, runs)
+}
+
+// checkPolicyOfTokenVault checks that the policy belongs to the vault the
+// request's token was issued to. It returns the status to answer with
+// otherwise.
+func (s *Server) checkPolicyOfTokenVault(c echo.Context, policyID string) (int, error) {
+	owner, err := tokenVault(c, "")
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	policy, err := s.db.GetPluginPolicy(c.Request().Context(), policyID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return http.StatusNotFound, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to get policy: %w", err)
+	}
+	if policy.PublicKey != owner {
+		// policies of other vaults are not disclosed
+		return http.StatusNotFound, fmt.Errorf("policy %s doesn't belong to the token's vault", policyID)
+	}
+	return http.StatusOK, nil
+}
+
+// GetPluginPolicyTransactionAggregates returns the daily sums of the policy's
+// archived transactions.
+func (s *Server) GetPluginPolicyTransactionAggregates(c echo.Context) error {
+	policyID := c.Param("policyId")
+
+	if policyID == "" {
+		err := fmt.Errorf("policy ID is required")
+		message := map[string]interface{}{
+			"message": "failed to get transaction aggregates",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	if status, err := s.checkPolicyOfTokenVault(c, policyID); err != nil {
+		s.logger.Warnf("fail to authorize policy request, err: %v", err)
+		return c.JSON(status, map[string]interface{}{"message": http.StatusText(status)})
+	}
+
+	aggregates, err := s.policyService.GetPluginPolicyTransactionAggregates(c.Request().Context(), policyID)
+	if err != nil {
+		err = fmt.Errorf("failed to get transaction aggregates: %w", err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to get transaction aggregates: %s", policyID),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, aggregates)
+}
+
+// GetPolicyDigests returns the verifier's policy digests for a plugin type, to
+// be compared by the plugin's reconciliation job.
+func (s *Server) GetPolicyDigests(c echo.Context) error {
+	pluginType := c.QueryParam("plugin_type")
+	if pluginType == "" {
+		err := fmt.Errorf("plugin_type is required")
+		message := map[string]interface{}{
+			"message": "failed to get policy digests",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	if plugin, ok := c.Get("plugin").(*types.Plugin); ok && plugin.Type != pluginType {
+		err := fmt.Errorf("plugin %s is not of type %s", plugin.ID, pluginType)
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
+	ctx := c.Request().Context()
+	policies, err := s.db.GetPluginPoliciesByType(ctx, pluginType)
+	if err != nil {
+		err = fmt.Errorf("failed to get policies: %w", err)
+		message := map[string]interface{}{
+			"message": "failed to get policy digests",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	entries, err := s.db.GetTransactionStatusesByPluginType(ctx, pluginType)
+	if err != nil {
+		err = fmt.Errorf("failed to get transaction statuses: %w", err)
+		message := map[string]interface{}{
+			"message": "failed to get policy digests",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	// only the calling plugin's own policies
+	owned := make([]types.PluginPolicy, 0, len(policies))
+	for _, policy := range policies {
+		if s.checkCallingPlugin(c, policy.PluginID) == nil {
+			owned = append(owned, policy)
+		}
+	}
+
+	return c.JSON(http.StatusOK, reconcile.BuildDigests(owned, entries))
+}
+
+func (s *Server) ReconcilePolicies(c echo.Context) error {
+	repair, _ := strconv.ParseBool(c.QueryParam("repair"))
+
+	report, err := s.reconciler.Reconcile(c.Request().Context(), repair)
+	if err != nil {
+		err = fmt.Errorf("failed to reconcile policies: %w", err)
+		message := map[string]interface{}{
+			"message": "failed to reconcile policies",
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusBadGateway, message)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// checkCallingPluginOwnsPolicy checks both the submitted policy and the stored
+// one, since an update must not move a policy between plugins.
+func (s *Server) checkCallingPluginOwnsPolicy(c echo.Context, policy types.PluginPolicy) error {
+	if c.Get("plugin") == nil {
+		return nil
+	}
+	if err := s.checkCallingPlugin(c, policy.PluginID); err != nil {
+		return err
+	}
+	existing, err := s.db.GetPluginPolicy(c.Request().Context(), policy.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get policy: %w", err)
+	}
+	return s.checkCallingPlugin(c, existing.PluginID)
+}
+
+func (s *Server) initializePlugin(pluginType string) (plugin.Plugin, error) {
+	switch pluginType {
+	case "payroll":
+		return payroll.NewPayrollPlugin(s.db, s.logger, s.pluginConfigs["payroll"])
+	case "dca":
+		return dca.NewDCAPlugin(s.db, s.logger, s.pluginConfigs["dca"])
+	default:
+		return nil, fmt.Errorf("unknown plugin type: %s", pluginType)
+	}
+}
+
+// minExecutionWindow bounds the execution window from below, so that a zero
+// cooldown doesn't lift the limit.
+const minExecutionWindow = 30 * time.Second
+
+// authorizeSigning evaluates the spending rules of the vault and reserves the
+// signing under the vault's lock, so concurrent requests of its plugins are
+// counted one after the other. The signing is only reserved when the rules
+// allow it, and it is allowed when the policy is within its rate limit. Every
+// decision is recorded.
+func (s *Server) authorizeSigning(ctx context.Context, plg plugin.Plugin, policy types.PluginPolicy, tx *types.TransactionHistory, txHex string, txHash string, txType string) (*rules.Decision, bool, error) {
+	window, limit, err := signingLimit(plg, policy, txType)
+	if err != nil {
+		return nil, false, fmt.Errorf("fail to get policy rate limit: %w", err)
+	}
+
+	dbTx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := s.db.LockVaultTx(ctx, dbTx, policy.PublicKey); err != nil {
+		return nil, false, err
+	}
+
+	decision, err := s.evaluateSpendingRules(ctx, dbTx, policy, txHex, txHash)
+	if err != nil {
+		return nil, false, fmt.Errorf("fail to evaluate spending rules: %w", err)
+	}
+
+	allowed := false
+	if decision.Allowed {
+		allowed, err = s.db.ReserveTransactionSigningTx(ctx, dbTx, tx.ID, tx.PolicyID, txType, time.Now().Add(-window), limit)
+		if err != nil {
+			return nil, false, fmt.Errorf("fail to reserve signing: %w", err)
+		}
+		if err := dbTx.Commit(ctx); err != nil {
+			return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+
+	if err := s.recordRuleDecision(ctx, policy, txHash, decision); err != nil {
+		return nil, false, fmt.Errorf("fail to record rule decision: %w", err)
+	}
+	return decision, allowed, nil
+}
+
+// signingLimit allows at most MaxTransactionsPerRun transactions of a type per
+// execution window of the policy. The window is derived from the signed
+// schedule, or from the cooldown of event triggered policies, and is at least
+// minExecutionWindow.
+func signingLimit(plg plugin.Plugin, policy types.PluginPolicy, txType string) (time.Duration, int64, error) {
+	window, err := policyExecutionWindow(policy)
+	if err != nil {
+		return 0, 0, err
+	}
+	window = max(window, minExecutionWindow)
+
+	limit, err := plg.MaxTransactionsPerRun(policy, txType)
+	if err != nil {
+		return 0, 0, err
+	}
+	return window, limit, nil
+}
+
+func policyExecutionWindow(policy types.PluginPolicy) (time.Duration, error) {
+	policyTrigger, err := watcher.GetPolicyTrigger(policy)
+	if err != nil {
+		return 0, err
+	}
+	if policyTrigger != nil {
+		cooldown, err := policyTrigger.CooldownSeconds()
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(cooldown) * time.Second, nil
+	}
+
+	var policySchedule struct {
+		Schedule types.Schedule `json:"schedule"`
+	}
+	if err := json.Unmarshal(policy.Policy, &policySchedule); err != nil {
+		return 0, fmt.Errorf("failed to parse policy schedule: %w", err)
+	}
+
+	return scheduler.ExecutionWindow(policySchedule.Schedule)
+}
+
+func (s *Server) verifyTriggerCondition(ctx context.Context, policy types.PluginPolicy) error {
+	policyTrigger, err := watcher.GetPolicyTrigger(policy)
+	if err != nil {
+		return err
+	}
+	if policyTrigger == nil {
+		return nil
+	}
+
+	evaluator, ok := s.evaluators[policy.PluginType]
+	if !ok {
+		return fmt.Errorf("no trigger evaluator for plugin type: %s", policy.PluginType)
+	}
+
+	return evaluator.Recheck(ctx, policy)
+}
+
+// verifyReconstruction compares the proposed transaction with the one the
+// plugin rebuilds from the signed policy using the verifier's own RPC, config
+// and signing records.
+func (s *Server) verifyReconstruction(ctx context.Context, plg plugin.Plugin, policy types.PluginPolicy, req types.PluginKeysignRequest) error {
+	cfg := s.cfg.Reconstruction
+	if !cfg.Enabled {
+		return nil
+	}
+
+	reconstructor, ok := plg.(plugin.TransactionReconstructor)
+	if !ok {
+		if cfg.Strict {
+			return fmt.Errorf("plugin type %s doesn't support transaction reconstruction", policy.PluginType)
+		}
+		s.logger.Warnf("plugin type %s doesn't support transaction reconstruction, skipping", policy.PluginType)
+		return nil
+	}
+
+	rawTx, err := hex.DecodeString(req.Transaction)
+	if err != nil {
+		return fmt.Errorf("invalid transaction hex: %w", err)
+	}
+	proposed := &gtypes.Transaction{}
+	if err := proposed.UnmarshalBinary(rawTx); err != nil {
+		return fmt.Errorf("failed to unmarshal transaction: %w", err)
+	}
+
+	tol := reconstruct.Tolerances{
+		GasPricePercent:  cfg.GasPriceTolerance,
+		GasLimitPercent:  cfg.GasLimitTolerance,
+		Nonce:            cfg.NonceTolerance,
+		AmountOutPercent: cfg.AmountOutTolerance,
+		Deadline:         cfg.DeadlineTolerance,
+	}
+	expected, err := reconstructor.ReconstructTransaction(ctx, policy, req.TransactionType, proposed, tol)
+	if err != nil {
+		return err
+	}
+
+	return reconstruct.Compare(expected, proposed, tol)
+}
+
+// evaluateSpendingRules checks the transaction against the spending rules of
+// the vault and the transactions the verifier signed for it within the rule
+// window.
+func (s *Server) evaluateSpendingRules(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy, txHex string, txHash string) (*rules.Decision, error) {
+	tx, err := txdecoder.ParseTransaction(txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	spendingRules, err := s.db.GetSpendingRules(ctx, policy.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := s.db.GetVaultSignedTransactionsTx(ctx, dbTx, policy.PublicKey, time.Now().Add(-rules.Window), txHash)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]rules.HistoryEntry, 0, len(signed))
+	for _, entry := range signed {
+		signedTx, err := txdecoder.ParseTransaction(entry.TxBody)
+		if err != nil {
+			s.logger.Warnf("failed to parse signed transaction %s: %v", entry.TxHash, err)
+			continue
+		}
+		history = append(history, rules.HistoryEntry{PluginType: entry.PluginType, Spend: rules.ExtractSpend(signedTx)})
+	}
+
+	decision := rules.Evaluate(spendingRules, rules.Request{
+		PublicKey:  policy.PublicKey,
+		PluginType: policy.PluginType,
+		Spend:      rules.ExtractSpend(tx),
+	}, history)
+	return &decision, nil
+}
+
+func (s *Server) recordRuleDecision(ctx context.Context, policy types.PluginPolicy, txHash string, decision *rules.Decision) error {
+	policyID, err := uuid.Parse(policy.ID)
+	if err != nil {
+		return fmt.Errorf("invalid policy_id: %s", policy.ID)
+	}
+	return s.db.CreateRuleDecision(ctx, types.RuleDecision{
+		PolicyID:   policyID,
+		TxHash:     txHash,
+		PublicKey:  policy.PublicKey,
+		PluginType: policy.PluginType,
+		Allowed:    decision.Allowed,
+		Reasons:    decision.Reasons,
+		RuleIDs:    decision.RuleIDs,
+	})
+}
+
+func (s *Server) UserLogin(c echo.Context) error {
+	var auth types.UserAuthDto
+	if err := c.Bind(&auth); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request"})
+	}
+
+	if err := c.Validate(&auth); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	user, err := s.db.FindUserByName(c.Request().Context(), auth.Username)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid credentials"})
+	}
+	if passwordValid := password.CheckPassword(auth.Password, user.Password); !passwordValid {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid credentials"})
+	}
+
+	token, err := jwt.GenerateJWT(user.ID, s.cfg.Server.UserAuth.JwtSecret)
+	if err != nil {
+		s.logger.Error("Failed to generate jwt", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to generate token"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"token": token})
+}
+
+func (s *Server) GetLoggedUser(c echo.Context) error {
+	user := c.Get("user")
+
+	return c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) GetPricing(c echo.Context) error {
+	pricingID := c.Param("pricingId")
+	if pricingID == "" {
+		err := fmt.Errorf("pricing id is required")
+		message := echo.Map{
+			"message": "failed to get pricing",
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	pricing, err := s.db.FindPricingById(c.Request().Context(), pricingID)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get pricing",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, pricing)
+}
+
+func (s *Server) CreatePricing(c echo.Context) error {
+	var pricing types.PricingCreateDto
+	if err := c.Bind(&pricing); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+
+	if err := c.Validate(&pricing); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	created, err := s.db.CreatePricing(c.Request().Context(), pricing)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to create pricing",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, created)
+}
+
+func (s *Server) DeletePricing(c echo.Context) error {
+	pricingID := c.Param("pricingId")
+	if pricingID == "" {
+		message := echo.Map{
+			"message": "failed to delete pricing",
+			"error":   "pricing id is required",
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	err := s.db.DeletePricingById(c.Request().Context(), pricingID)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to delete pricing",
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetPlugins(c echo.Context) error {
+	skip, err := strconv.Atoi(c.QueryParam("skip"))
+
+	if err != nil {
+		skip = 0
+	}
+
+	take, err := strconv.Atoi(c.QueryParam("take"))
+
+	if err != nil {
+		take = 999
+	}
+
+	sort := c.QueryParam("sort")
+
+	plugins, err := s.db.FindPlugins(c.Request().Context(), skip, take, sort)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get plugins",
 		}
 		s.logger.Error(err)
 		return c.JSON(http.StatusInternalServerError, message)
@@ -709,45 +1462,177 @@ func (s *Server) DeletePlugin(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
-func (s *Server) verifyPolicySignature(policy types.PluginPolicy, update bool) bool {
-	msgHex, err := policyToMessageHex(policy, update)
+func (s *Server) GetPluginTokens(c echo.Context) error {
+	pluginID := c.Param("pluginId")
+
+	tokens, err := s.db.GetPluginTokens(c.Request().Context(), pluginID)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get plugin tokens",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RevokePluginTokens revokes one token of a plugin, or all of them when no
+// token id is given.
+func (s *Server) RevokePluginTokens(c echo.Context) error {
+	pluginID := c.Param("pluginId")
+	tokenID := c.Param("tokenId")
+
+	revoked, err := s.db.RevokePluginTokens(c.Request().Context(), pluginID, tokenID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to revoke plugin token",
+				"error":   "token not found or already revoked",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to revoke plugin tokens",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"revoked": revoked})
+}
+
+func (s *Server) GetSpendingRules(c echo.Context) error {
+	spendingRules, err := s.db.GetSpendingRules(c.Request().Context(), c.QueryParam("public_key"))
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get spending rules",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, spendingRules)
+}
+
+func (s *Server) CreateSpendingRule(c echo.Context) error {
+	var rule types.SpendingRuleDto
+	if err := c.Bind(&rule); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+
+	if err := c.Validate(&rule); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	created, err := s.db.CreateSpendingRule(c.Request().Context(), rule)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to create spending rule",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, created)
+}
+
+func (s *Server) UpdateSpendingRule(c echo.Context) error {
+	ruleID := c.Param("ruleId")
+
+	var rule types.SpendingRuleDto
+	if err := c.Bind(&rule); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+
+	if err := c.Validate(&rule); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	updated, err := s.db.UpdateSpendingRule(c.Request().Context(), ruleID, rule)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to update spending rule",
+				"error":   "rule not found",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to update spending rule",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+func (s *Server) DeleteSpendingRule(c echo.Context) error {
+	ruleID := c.Param("ruleId")
+
+	err := s.db.DeleteSpendingRule(c.Request().Context(), ruleID)
 	if err != nil {
-		s.logger.Error(fmt.Errorf("failed to convert policy to message hex: %w", err))
-		return false
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to delete spending rule",
+				"error":   "rule not found",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to delete spending rule",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
 	}
 
-	msgBytes, err := hex.DecodeString(strings.TrimPrefix(msgHex, "0x"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetRuleDecisions(c echo.Context) error {
+	skip, err := strconv.Atoi(c.QueryParam("skip"))
 	if err != nil {
-		s.logger.Error(fmt.Errorf("failed to decode message bytes: %w", err))
-		return false
+		skip = 0
 	}
 
-	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(policy.Signature, "0x"))
+	take, err := strconv.Atoi(c.QueryParam("take"))
 	if err != nil {
-		s.logger.Error(fmt.Errorf("failed to decode signature bytes: %w", err))
-		return false
+		take = 30
 	}
 
-	isVerified, err := sigutil.VerifySignature(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath, msgBytes, signatureBytes)
+	decisions, err := s.db.GetRuleDecisions(c.Request().Context(), c.QueryParam("public_key"), take, skip)
 	if err != nil {
-		s.logger.Error(fmt.Errorf("failed to verify signature: %w", err))
-		return false
+		message := echo.Map{
+			"message": "failed to get rule decisions",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
 	}
-	return isVerified
+
+	return c.JSON(http.StatusOK, decisions)
 }
 
-func policyToMessageHex(policy types.PluginPolicy, isUpdate bool) (string, error) {
-	if !isUpdate {
-		policy.ID = ""
+// verifyPolicySignature checks the EIP-712 signature of the action on the
+// policy and its expiry. The verifier doesn't check the expiry of synced
+// policies, which may be retried or repaired long after they were signed, the
+// policy service checks their replay against the stored policy instead.
+func (s *Server) verifyPolicySignature(policy types.PluginPolicy, action string) error {
+	if s.mode != "verifier" {
+		if err := sigutil.CheckPolicySignatureExpiry(policy.Expiry, time.Now(), 0); err != nil {
+			return err
+		}
 	}
-	// signature is not part of the message that is signed
-	policy.Signature = ""
 
-	serializedPolicy, err := json.Marshal(policy)
+	isVerified, err := sigutil.VerifyPolicyAction(policy, action)
 	if err != nil {
-		return "", fmt.Errorf("failed to serialize policy")
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	if !isVerified {
+		return fmt.Errorf("invalid policy signature")
 	}
-	return hex.EncodeToString(serializedPolicy), nil
+	return nil
 }
 
 func calculateTransactionHash(txData string) (string, error) {
@@ -767,3 +1652,313 @@ func calculateTransactionHash(txData string) (string, error) {
 	hash := signer.Hash(tx).String()[2:]
 	return hash, nil
 }
+
+const policyActionMaxAge = 5 * time.Minute
+
+// authorizePolicyAction accepts either an admin JWT or a vault signature over
+// "<action>:<policy_id>:<timestamp>". Signatures are single use.
+func (s *Server) authorizePolicyAction(c echo.Context, policy types.PluginPolicy, action string) error {
+	authHeader := c.Request().Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		userID, err := jwt.ValidateJWT(strings.TrimPrefix(authHeader, "Bearer "), s.cfg.Server.UserAuth.JwtSecret)
+		if err != nil {
+			return fmt.Errorf("invalid token: %w", err)
+		}
+		user, err := s.db.FindUserById(c.Request().Context(), userID)
+		if err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+		if !user.IsAdmin() {
+			return fmt.Errorf("user is not an admin")
+		}
+		return nil
+	}
+
+	var req types.PolicyActionRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+	if req.Signature == "" {
+		return fmt.Errorf("signature is required")
+	}
+
+	signedAt := time.Unix(req.Timestamp, 0)
+	if time.Since(signedAt).Abs() > policyActionMaxAge {
+		return fmt.Errorf("signature timestamp is too old")
+	}
+
+	msg := []byte(fmt.Sprintf("%s:%s:%d", action, policy.ID, req.Timestamp))
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(req.Signature, "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature bytes: %w", err)
+	}
+	// EdDSA keys sign the message itself, ECDSA keys its personal_sign hash
+	var isVerified bool
+	if policy.IsEcdsa {
+		isVerified, err = sigutil.VerifySignature(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath, msg, signatureBytes)
+	} else {
+		isVerified, err = sigutil.VerifyEdDSASignature(policy.PublicKey, msg, signatureBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %w", err)
+	}
+	if !isVerified {
+		return fmt.Errorf("invalid signature")
+	}
+
+	if ok, err := s.policyActions.SetNX(c.Request().Context(), req.Signature, action, 2*policyActionMaxAge); err != nil {
+		s.logger.Errorf("fail to store policy action signature, err: %v", err)
+	} else if !ok {
+		return fmt.Errorf("signature already used")
+	}
+
+	return nil
+}
+
+func (s *Server) RunPluginPolicy(c echo.Context) error {
+	policyID := c.Param("policyId")
+	if policyID == "" {
+		err := fmt.Errorf("policy ID is required")
+		message := map[string]interface{}{
+			"message": "failed to run policy",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	ctx := c.Request().Context()
+	policy, err := s.policyService.GetPluginPolicy(ctx, policyID)
+	if err != nil {
+		err = fmt.Errorf("failed to get policy: %w", err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to get policy: %s", policyID),
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	if err := s.authorizePolicyAction(c, policy, "run"); err != nil {
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
+	if policy.IsDeleted() {
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("policy is deleted: %s", policyID),
+		}
+		return c.JSON(http.StatusGone, message)
+	}
+	if !policy.Runnable() {
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("policy is not active: %s", policyID),
+		}
+		return c.JSON(http.StatusConflict, message)
+	}
+
+	// Don't overlap with a run started by the scheduler, the watcher or
+	// another manual run
+	claimed, err := s.claimPolicyTrigger(ctx, policyID)
+	if err != nil {
+		err = fmt.Errorf("failed to claim policy trigger: %w", err)
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to run policy: %s", policyID),
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+	if !claimed {
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("policy is already running: %s", policyID),
+		}
+		return c.JSON(http.StatusConflict, message)
+	}
+
+	buf, err := json.Marshal(types.PluginTriggerEvent{
+		PolicyID:      policyID,
+		TriggerSource: types.TriggerSourceManual,
+		TriggeredAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("fail to marshal to json, err: %w", err)
+	}
+
+	ti, err := s.client.EnqueueContext(ctx,
+		asynq.NewTask(tasks.TypePluginTransaction, buf),
+		asynq.MaxRetry(0),
+		asynq.Timeout(5*time.Minute),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.QUEUE_NAME),
+	)
+	if err != nil {
+		if err := s.db.UpdateTriggerStatus(ctx, policyID, types.StatusTimeTriggerPending); err != nil {
+			s.logger.Errorf("Failed to reset trigger status: %v", err)
+		}
+		if err := s.db.UpdateEventTriggerStatus(ctx, policyID, types.StatusTimeTriggerPending); err != nil {
+			s.logger.Errorf("Failed to reset event trigger status: %v", err)
+		}
+		return fmt.Errorf("fail to enqueue task, err: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"task_id": ti.ID,
+	})
+}
+
+// claimPolicyTrigger moves the pending trigger of the policy to running. It
+// reports false when the trigger is already running. Policies without a
+// trigger are not run by anything else.
+func (s *Server) claimPolicyTrigger(ctx context.Context, policyID string) (bool, error) {
+	claimed, err := s.db.ClaimTimeTrigger(ctx, policyID)
+	if err != nil || claimed {
+		return claimed, err
+	}
+	claimed, err = s.db.ClaimEventTrigger(ctx, policyID)
+	if err != nil || claimed {
+		return claimed, err
+	}
+
+	// only a missing trigger lets the run through, a failed lookup doesn't
+	if _, err := s.db.GetTriggerStatus(ctx, policyID); !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+	if _, err := s.db.GetEventTriggerStatus(ctx, policyID); !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Server) DryRunPluginPolicy(c echo.Context) error {
+	policyID := c.Param("policyId")
+	if policyID == "" {
+		err := fmt.Errorf("policy ID is required")
+		message := map[string]interface{}{
+			"message": "failed to dry-run policy",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusBadRequest, message)
+	}
+
+	ctx := c.Request().Context()
+	policy, err := s.policyService.GetPluginPolicy(ctx, policyID)
+	if err != nil {
+		err = fmt.Errorf("failed to get policy: %w", err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to get policy: %s", policyID),
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	if err := s.authorizePolicyAction(c, policy, "dry_run"); err != nil {
+		s.logger.Error(err)
+		message := map[string]interface{}{
+			"message": "Authorization failed",
+			"error":   err.Error(),
+		}
+		return c.JSON(http.StatusForbidden, message)
+	}
+
+	if policy.IsDeleted() {
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("policy is deleted: %s", policyID),
+		}
+		return c.JSON(http.StatusGone, message)
+	}
+
+	signRequests, err := s.plugin.ProposeTransactions(policy)
+	if err != nil {
+		err = fmt.Errorf("failed to propose transactions: %w", err)
+		message := map[string]interface{}{
+			"message": fmt.Sprintf("failed to propose transactions: %s", policyID),
+			"error":   err.Error(),
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusUnprocessableEntity, message)
+	}
+
+	result := types.DryRunResult{
+		PolicyID:     policyID,
+		Valid:        true,
+		Transactions: []types.DryRunTransaction{},
+	}
+	if err := s.plugin.ValidateProposedTransactions(policy, signRequests); err != nil {
+		result.Valid = false
+		result.ValidationError = err.Error()
+	}
+
+	rpcClient, err := ethclient.DialContext(ctx, fmt.Sprint(s.pluginConfigs[policy.PluginType]["rpc_url"]))
+	if err != nil {
+		return fmt.Errorf("fail to connect to RPC client: %w", err)
+	}
+	defer rpcClient.Close()
+
+	vaultAddress, err := common.DeriveAddress(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath)
+	if err != nil {
+		return fmt.Errorf("fail to derive vault address: %w", err)
+	}
+
+	for _, signRequest := range signRequests {
+		dryRunTx, err := simulateTransaction(ctx, rpcClient, *vaultAddress, signRequest)
+		if err != nil {
+			return fmt.Errorf("fail to simulate transaction: %w", err)
+		}
+		result.Transactions = append(result.Transactions, *dryRunTx)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// simulateTransaction decodes the proposed transaction, estimates its gas and
+// executes it with eth_call against the latest block. Transactions are
+// simulated independently, so a swap depending on a proposed approval may fail.
+func simulateTransaction(ctx context.Context, rpcClient *ethclient.Client, from gcommon.Address, signRequest types.PluginKeysignRequest) (*types.DryRunTransaction, error) {
+	decoded, err := txdecoder.Decode(signRequest.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := txdecoder.ParseTransaction(signRequest.Transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRunTx := &types.DryRunTransaction{
+		TransactionType: signRequest.TransactionType,
+		Decoded:         decoded,
+	}
+	if len(signRequest.Messages) > 0 {
+		dryRunTx.TxHash = signRequest.Messages[0]
+	}
+
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+
+	estimatedGas, err := rpcClient.EstimateGas(ctx, msg)
+	if err != nil {
+		dryRunTx.Simulation.Error = err.Error()
+		return dryRunTx, nil
+	}
+	dryRunTx.EstimatedGas = estimatedGas
+
+	msg.Gas = tx.Gas()
+	returnData, err := rpcClient.CallContract(ctx, msg, nil)
+	if err != nil {
+		dryRunTx.Simulation.Error = err.Error()
+		return dryRunTx, nil
+	}
+	dryRunTx.Simulation.Success = true
+	dryRunTx.Simulation.ReturnData = hex.EncodeToString(returnData)
+
+	return dryRunTx, nil
+}
@@ -5,22 +5,29 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/vultisig/vultiserver-plugin/common"
 	"github.com/vultisig/vultiserver-plugin/config"
+	"github.com/vultisig/vultiserver-plugin/internal/attestation"
+	"github.com/vultisig/vultiserver-plugin/internal/idempotency"
 	"github.com/vultisig/vultiserver-plugin/internal/scheduler"
 	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
 	"github.com/vultisig/vultiserver-plugin/internal/syncer"
 	"github.com/vultisig/vultiserver-plugin/internal/tasks"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/vaultbackup"
 	vv "github.com/vultisig/vultiserver-plugin/internal/vultisig_validator"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
 	"github.com/vultisig/vultiserver-plugin/plugin"
 	"github.com/vultisig/vultiserver-plugin/plugin/dca"
 	"github.com/vultisig/vultiserver-plugin/plugin/payroll"
@@ -30,6 +37,7 @@ import (
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -42,18 +50,29 @@ import (
 type Server struct {
 	cfg           *config.Config
 	db            storage.DatabaseStorage
-	redis         *storage.RedisStorage
-	blockStorage  *storage.BlockStorage
+	sessions      storage.Keyspace
+	codes         storage.Keyspace
+	resends       storage.Keyspace
+	policyActions storage.Keyspace
+	pluginReplays storage.Keyspace
+	idempotency   *idempotency.Store
+	blockStorage  storage.BlockStorage
 	client        *asynq.Client
 	inspector     *asynq.Inspector
 	sdClient      *statsd.Client
 	scheduler     *scheduler.SchedulerService
+	watcher       *watcher.WatcherService
 	policyService service.Policy
 	authService   *service.AuthService
 	syncer        syncer.PolicySyncer
+	reconciler    *service.ReconcileService
+	attestor      *attestation.Signer
+	webhooks      *webhook.Dispatcher
+	vaultBackups  *vaultbackup.Manager
 	plugin        plugin.Plugin
 	logger        *logrus.Logger
 	pluginConfigs map[string]map[string]interface{}
+	evaluators    map[string]*watcher.Evaluator
 	vaultFilePath string
 	mode          string
 }
@@ -62,8 +81,8 @@ type Server struct {
 func NewServer(
 	cfg *config.Config,
 	db *postgres.PostgresBackend,
-	redis *storage.RedisStorage,
-	blockStorage *storage.BlockStorage,
+	redis storage.KeyValueStore,
+	blockStorage storage.BlockStorage,
 	redisOpts asynq.RedisClientOpt,
 	client *asynq.Client,
 	inspector *asynq.Inspector,
@@ -77,11 +96,35 @@ func NewServer(
 	logger *logrus.Logger,
 ) *Server {
 	logger.Infof("Server mode: %s, plugin type: %s", mode, pluginType)
+	if cfg.Server.ReadOnly {
+		logger.Warn("Server is in read-only mode, writes are refused and background services are not started")
+	}
 
 	var plugin plugin.Plugin
 	var schedulerService *scheduler.SchedulerService
+	var watcherService *watcher.WatcherService
 	var syncerService syncer.PolicySyncer
+	var requestSigner *sigutil.RequestSigner
+	var tokenSource *syncer.TokenSource
 	var err error
+	authService := service.NewAuthService(jwtSecret)
+	if mode == "verifier" {
+		if cfg.Server.PluginTokenKey == "" {
+			logger.Warn("No plugin token key configured, plugin service tokens are not issued")
+		} else if err := authService.SetPluginKey(cfg.Server.PluginTokenKey); err != nil {
+			logger.Fatal("fail to initialize plugin token key: ", err)
+		}
+	}
+	// trigger evaluators hold an RPC client, they are built once per plugin type
+	evaluators := make(map[string]*watcher.Evaluator, len(pluginConfigs))
+	for configType, pluginConfig := range pluginConfigs {
+		evaluator, err := watcher.NewEvaluator(pluginConfig)
+		if err != nil {
+			logger.Fatalf("fail to initialize %s trigger evaluator: %v", configType, err)
+		}
+		evaluators[configType] = evaluator
+	}
+
 	if mode == "plugin" {
 		switch pluginType {
 		case "payroll":
@@ -103,24 +146,96 @@ func NewServer(
 			client,
 			redisOpts,
 		)
-		schedulerService.Start()
-		logger.Info("Scheduler service started")
+		if !cfg.Server.ReadOnly {
+			schedulerService.Start()
+			logger.Info("Scheduler service started")
+		}
+
+		evaluator, ok := evaluators[pluginType]
+		if !ok {
+			logger.Fatalf("no plugin config for plugin type: %s", pluginType)
+		}
+		watcherService = watcher.NewWatcherService(
+			db,
+			logger.WithField("service", "watcher").Logger,
+			client,
+			evaluator,
+		)
+		if !cfg.Server.ReadOnly {
+			watcherService.Start()
+			logger.Info("Watcher service started")
+		}
 
 		logger.Info("Creating Syncer")
 
-		syncerService = syncer.NewPolicySyncer(logger.WithField("service", "syncer").Logger, cfg.Server.Host, cfg.Server.Port)
+		requestSigner, err = syncer.RequestSignerFromConfig(*cfg)
+		if err != nil {
+			logger.Fatal("fail to initialize request signer: ", err)
+		}
+		tokenSource = syncer.NewTokenSource(cfg.Server.Host, cfg.Server.Port, requestSigner)
+
+		syncerService = syncer.NewPolicySyncer(logger.WithField("service", "syncer").Logger, db, cfg.Server.Host, cfg.Server.Port, tokenSource.Token, requestSigner)
+		if !cfg.Server.ReadOnly {
+			syncerService.Start()
+			logger.Info("Syncer relay started")
+		}
 	}
 
-	policyService, err := service.NewPolicyService(db, syncerService, schedulerService, logger.WithField("service", "policy").Logger)
+	policyService, err := service.NewPolicyService(db, syncerService, schedulerService, watcherService, logger.WithField("service", "policy").Logger)
 	if err != nil {
 		logger.Fatalf("Failed to initialize policy service: %v", err)
 	}
+	if mode == "verifier" {
+		policyService.ReceiveSyncs()
+	}
 
-	authService := service.NewAuthService(jwtSecret)
+	var attestor *attestation.Signer
+	if mode == "verifier" {
+		if cfg.Server.AttestationKey == "" {
+			logger.Warn("No attestation key configured, signed transactions get no approval receipts")
+		} else {
+			attestor, err = attestation.NewSigner(cfg.Server.AttestationKey)
+			if err != nil {
+				logger.Fatal("fail to initialize attestation signer: ", err)
+			}
+		}
+	}
+
+	webhookDispatcher := webhook.NewDispatcher(db, logger.WithField("service", "webhook").Logger)
+	if !cfg.Server.ReadOnly {
+		webhookDispatcher.Start()
+		logger.Info("Webhook dispatcher started")
+	}
+
+	var reconcileService *service.ReconcileService
+	if mode == "plugin" {
+		reconcileService = service.NewReconcileService(
+			db,
+			syncerService,
+			policyService,
+			logger.WithField("service", "reconcile").Logger,
+			cfg.Server.Host,
+			cfg.Server.Port,
+			tokenSource.Token,
+			requestSigner,
+			pluginType,
+			cfg.Reconcile.Interval,
+			cfg.Reconcile.Repair,
+		)
+		if cfg.Reconcile.Enabled && !cfg.Server.ReadOnly {
+			reconcileService.Start()
+			logger.Info("Reconcile service started")
+		}
+	}
 
 	return &Server{
 		cfg:           cfg,
-		redis:         redis,
+		sessions:      storage.NewKeyspace(redis, storage.KeyspaceSession),
+		codes:         storage.NewKeyspace(redis, storage.KeyspaceVerificationCode),
+		resends:       storage.NewKeyspace(redis, storage.KeyspaceResend),
+		policyActions: storage.NewKeyspace(redis, storage.KeyspacePolicyAction),
+		pluginReplays: storage.NewKeyspace(redis, storage.KeyspacePluginRequest),
+		idempotency:   idempotency.NewStore(redis, idempotency.DefaultTTL),
 		client:        client,
 		inspector:     inspector,
 		vaultFilePath: vaultFilePath,
@@ -130,11 +245,17 @@ func NewServer(
 		plugin:        plugin,
 		db:            db,
 		scheduler:     schedulerService,
+		watcher:       watcherService,
 		logger:        logger,
 		syncer:        syncerService,
+		reconciler:    reconcileService,
+		attestor:      attestor,
+		webhooks:      webhookDispatcher,
+		vaultBackups:  vaultbackup.NewManager(db, blockStorage, cfg.BlockStorage.BackupVersions, logger),
 		policyService: policyService,
 		authService:   authService,
 		pluginConfigs: pluginConfigs,
+		evaluators:    evaluators,
 	}
 }
 
@@ -146,6 +267,9 @@ func (s *Server) StartServer() error {
 	e.Use(middleware.BodyLimit("2M")) // set maximum allowed size for a request body to 2M
 	e.Use(s.statsdMiddleware)
 	e.Use(middleware.CORS())
+	if s.cfg.Server.ReadOnly {
+		e.Use(s.readOnlyMiddleware)
+	}
 	limiterStore := middleware.NewRateLimiterMemoryStoreWithConfig(
 		middleware.RateLimiterMemoryStoreConfig{Rate: 5, Burst: 30, ExpiresIn: 5 * time.Minute},
 	)
@@ -155,7 +279,14 @@ func (s *Server) StartServer() error {
 
 	e.GET("/ping", s.Ping)
 	e.GET("/getDerivedPublicKey", s.GetDerivedPublicKey)
-	e.POST("/signFromPlugin", s.SignPluginMessages)
+
+	// on the verifier, requests of plugin servers are authenticated with the
+	// plugin's request signature
+	pluginRequestMiddlewares := []echo.MiddlewareFunc{s.idempotencyMiddleware}
+	if s.mode == "verifier" {
+		pluginRequestMiddlewares = append([]echo.MiddlewareFunc{s.pluginAuthMiddleware}, pluginRequestMiddlewares...)
+	}
+	e.POST("/signFromPlugin", s.SignPluginMessages, pluginRequestMiddlewares...)
 
 	// Auth token
 	e.POST("/auth", s.Auth)
@@ -170,8 +301,8 @@ func (s *Server) StartServer() error {
 	grp.GET("/get/:publicKeyECDSA", s.GetVault)     // Get Vault Data
 	grp.GET("/exist/:publicKeyECDSA", s.ExistVault) // Check if Vault exists
 	//	grp.DELETE("/delete/:publicKeyECDSA", s.DeleteVault) // Delete Vault Data
-	grp.POST("/sign", s.SignMessages)       // Sign messages
-	grp.POST("/resend", s.ResendVaultEmail) // request server to send vault share , code through email again
+	grp.POST("/sign", s.SignMessages, s.idempotencyMiddleware) // Sign messages
+	grp.POST("/resend", s.ResendVaultEmail)                    // request server to send vault share , code through email again
 	grp.GET("/verify/:publicKeyECDSA/:code", s.VerifyCode)
 	grp.GET("/sign/response/:taskId", s.GetKeysignResult) // Get keysign result
 
@@ -188,16 +319,26 @@ func (s *Server) StartServer() error {
 			HTML5:      true,
 			Filesystem: http.FS(s.plugin.FrontendSchema()),
 		}))
+
+		pluginGroup.POST("/policy/:policyId/run", s.RunPluginPolicy)
+		pluginGroup.POST("/policy/:policyId/dry-run", s.DryRunPluginPolicy)
+		pluginGroup.POST("/policy/reconcile", s.ReconcilePolicies, s.userAuthMiddleware, s.adminMiddleware)
 	}
 
 	// policy mode is always available since it is used by both verifier server and plugin server
-	pluginGroup.POST("/policy", s.CreatePluginPolicy)
-	pluginGroup.PUT("/policy", s.UpdatePluginPolicyById)
+	// on the verifier, policy writes only come from authenticated plugin servers
+	pluginGroup.POST("/policy", s.CreatePluginPolicy, pluginRequestMiddlewares...)
+	pluginGroup.PUT("/policy", s.UpdatePluginPolicyById, pluginRequestMiddlewares...)
 	pluginGroup.GET("/policy", s.GetAllPluginPolicies, s.AuthMiddleware)
+	pluginGroup.GET("/policy/history", s.QueryTransactionHistory, s.AuthMiddleware)
 	pluginGroup.GET("/policy/history/:policyId", s.GetPluginPolicyTransactionHistory, s.AuthMiddleware)
 	pluginGroup.GET("/policy/schema", s.GetPolicySchema)
-	pluginGroup.GET("/policy/:policyId", s.GetPluginPolicyById, s.AuthMiddleware)
-	pluginGroup.DELETE("/policy/:policyId", s.DeletePluginPolicyById)
+	pluginGroup.POST("/policy/schedule/preview", s.PreviewPolicySchedule)
+	// the plugin's reconciliation job reads the verifier's copy of its policies
+	pluginGroup.GET("/policy/:policyId", s.GetPluginPolicyById, s.tokenAuthMiddleware(types.ScopePolicyRead))
+	pluginGroup.GET("/policy/:policyId/runs", s.GetPluginPolicyRuns, s.AuthMiddleware)
+	pluginGroup.GET("/policy/:policyId/aggregates", s.GetPluginPolicyTransactionAggregates, s.AuthMiddleware)
+	pluginGroup.DELETE("/policy/:policyId", s.DeletePluginPolicyById, pluginRequestMiddlewares...)
 
 	if s.mode == "verifier" {
 		e.POST("/login", s.UserLogin)
@@ -209,17 +350,52 @@ func (s *Server) StartServer() error {
 		pluginsGroup.POST("", s.CreatePlugin, s.userAuthMiddleware)
 		pluginsGroup.PATCH("/:pluginId", s.UpdatePlugin, s.userAuthMiddleware)
 		pluginsGroup.DELETE("/:pluginId", s.DeletePlugin, s.userAuthMiddleware)
+		pluginsGroup.GET("/:pluginId/tokens", s.GetPluginTokens, s.userAuthMiddleware)
+		pluginsGroup.DELETE("/:pluginId/tokens", s.RevokePluginTokens, s.userAuthMiddleware)
+		pluginsGroup.DELETE("/:pluginId/tokens/:tokenId", s.RevokePluginTokens, s.userAuthMiddleware)
+
+		e.POST("/auth/plugin/token", s.IssuePluginToken, s.pluginAuthMiddleware)
+
+		rulesGroup := e.Group("/rules", s.userAuthMiddleware)
+		rulesGroup.GET("", s.GetSpendingRules)
+		rulesGroup.POST("", s.CreateSpendingRule)
+		rulesGroup.PUT("/:ruleId", s.UpdateSpendingRule)
+		rulesGroup.DELETE("/:ruleId", s.DeleteSpendingRule)
+		rulesGroup.GET("/decisions", s.GetRuleDecisions)
+
+		attestationsGroup := e.Group("/attestations")
+		attestationsGroup.GET("/key", s.GetAttestationKey)
+		attestationsGroup.GET("/:txHash", s.GetTransactionAttestation, s.AuthMiddleware)
 
 		pricingsGroup := e.Group("/pricings")
 		pricingsGroup.GET("/:pricingId", s.GetPricing)
 		pricingsGroup.POST("", s.CreatePricing, s.userAuthMiddleware)
 		pricingsGroup.DELETE("/:pricingId", s.DeletePricing, s.userAuthMiddleware)
+
+		vaultBackupsGroup := e.Group("/admin/vaults/:publicKeyECDSA/backups", s.userAuthMiddleware)
+		vaultBackupsGroup.GET("", s.GetVaultBackupVersions)
+		vaultBackupsGroup.POST("/:version/restore", s.RestoreVaultBackupVersion)
 	}
 
+	webhooksGroup := e.Group("/webhooks", s.AuthMiddleware)
+	webhooksGroup.GET("", s.GetWebhookSubscriptions)
+	webhooksGroup.POST("", s.CreateWebhookSubscription)
+	webhooksGroup.DELETE("/:subscriptionId", s.DeleteWebhookSubscription)
+	webhooksGroup.GET("/dead-letters", s.GetWebhookDeadLetters)
+	webhooksGroup.POST("/dead-letters/:deadLetterId/replay", s.ReplayWebhookDeadLetter)
+
 	syncGroup := e.Group("/sync")
-	syncGroup.Use(s.AuthMiddleware)
-	syncGroup.POST("/transaction", s.CreateTransaction)
-	syncGroup.PUT("/transaction", s.UpdateTransaction)
+	if s.mode == "verifier" {
+		// syncs must come from a plugin service account with the matching scope
+		syncGroup.Use(s.pluginAuthMiddleware)
+		syncGroup.POST("/transaction", s.CreateTransaction, s.pluginTokenMiddleware(types.ScopeTransactionSync), s.idempotencyMiddleware)
+		syncGroup.PUT("/transaction", s.UpdateTransaction, s.pluginTokenMiddleware(types.ScopeTransactionSync), s.idempotencyMiddleware)
+		syncGroup.GET("/policy/digests", s.GetPolicyDigests, s.pluginTokenMiddleware(types.ScopePolicyRead))
+	} else {
+		syncGroup.POST("/transaction", s.CreateTransaction, s.tokenAuthMiddleware(types.ScopeTransactionSync), s.idempotencyMiddleware)
+		syncGroup.PUT("/transaction", s.UpdateTransaction, s.tokenAuthMiddleware(types.ScopeTransactionSync), s.idempotencyMiddleware)
+		syncGroup.GET("/policy/digests", s.GetPolicyDigests, s.tokenAuthMiddleware(types.ScopePolicyRead), s.idempotencyMiddleware)
+	}
 
 	return e.Start(fmt.Sprintf(":%d", s.cfg.Server.Port))
 }
@@ -272,13 +448,11 @@ func (s *Server) CreateVault(c echo.Context) error {
 		s.logger.Errorf("fail to count metric, err: %v", err)
 	}
 
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
+	// a session is only started once, retries of the request are acknowledged
+	if ok, err := s.sessions.SetNX(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
 		s.logger.Errorf("fail to set session, err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusOK)
 	}
 	var typeName = ""
 	if req.LibType == types.GG20 {
@@ -310,13 +484,11 @@ func (s *Server) ReshareVault(c echo.Context) error {
 	if err != nil {
 		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
+	// a session is only started once, retries of the request are acknowledged
+	if ok, err := s.sessions.SetNX(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
 		s.logger.Errorf("fail to set session, err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusOK)
 	}
 	var typeName = ""
 	if req.LibType == types.GG20 {
@@ -348,13 +520,11 @@ func (s *Server) MigrateVault(c echo.Context) error {
 	if err != nil {
 		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
+	// a session is only started once, retries of the request are acknowledged
+	if ok, err := s.sessions.SetNX(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
 		s.logger.Errorf("fail to set session, err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusOK)
 	}
 	_, err = s.client.Enqueue(asynq.NewTask(tasks.TypeMigrate, buf),
 		asynq.MaxRetry(-1),
@@ -524,13 +694,11 @@ func (s *Server) SignMessages(c echo.Context) error {
 	if !s.isValidHash(req.PublicKey) {
 		return c.NoContent(http.StatusBadRequest)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 30*time.Minute); err != nil {
+	// a session is only started once, retries of the request are acknowledged
+	if ok, err := s.sessions.SetNX(c.Request().Context(), req.SessionID, req.SessionID, 30*time.Minute); err != nil {
 		s.logger.Errorf("fail to set session, err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusOK)
 	}
 
 	filePathName := common.GetVaultBackupFilename(req.PublicKey)
@@ -627,14 +795,11 @@ func (s *Server) ResendVaultEmail(c echo.Context) error {
 	if !s.isValidHash(publicKeyECDSA) {
 		return c.NoContent(http.StatusBadRequest)
 	}
-	key := fmt.Sprintf("resend_%s", publicKeyECDSA)
-	result, err := s.redis.Get(c.Request().Context(), key)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusTooManyRequests)
-	}
 	// user will allow to request once per minute
-	if err := s.redis.Set(c.Request().Context(), key, key, 3*time.Minute); err != nil {
+	if ok, err := s.resends.SetNX(c.Request().Context(), publicKeyECDSA, publicKeyECDSA, 3*time.Minute); err != nil {
 		s.logger.Errorf("fail to set , err: %v", err)
+	} else if !ok {
+		return c.NoContent(http.StatusTooManyRequests)
 	}
 	if err := s.sdClient.Count("vault.resend", 1, nil, 1); err != nil {
 		s.logger.Errorf("fail to count metric, err: %v", err)
@@ -686,9 +851,8 @@ func (s *Server) createVerificationCode(ctx context.Context, publicKeyECDSA stri
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	code := rnd.Intn(9000) + 1000
 	verificationCode := strconv.Itoa(code)
-	key := fmt.Sprintf("verification_code_%s", publicKeyECDSA)
 	// verification code will be valid for 1 hour
-	if err := s.redis.Set(context.Background(), key, verificationCode, time.Hour); err != nil {
+	if err := s.codes.Set(context.Background(), publicKeyECDSA, verificationCode, time.Hour); err != nil {
 		return "", fmt.Errorf("failed to set cache: %w", err)
 	}
 	return verificationCode, nil
@@ -711,8 +875,7 @@ func (s *Server) VerifyCode(c echo.Context) error {
 	if err := s.sdClient.Count("vault.verify", 1, nil, 1); err != nil {
 		s.logger.Errorf("fail to count metric, err: %v", err)
 	}
-	key := fmt.Sprintf("verification_code_%s", publicKeyECDSA)
-	result, err := s.redis.Get(c.Request().Context(), key)
+	result, err := s.codes.Get(c.Request().Context(), publicKeyECDSA)
 	if err != nil {
 		s.logger.Errorf("fail to get code, err: %v", err)
 		return c.NoContent(http.StatusBadRequest)
@@ -721,13 +884,68 @@ func (s *Server) VerifyCode(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 	// set the code to be expired in 5 minutes
-	if err := s.redis.Expire(c.Request().Context(), key, time.Minute*5); err != nil {
+	if err := s.codes.Expire(c.Request().Context(), publicKeyECDSA, time.Minute*5); err != nil {
 		s.logger.Errorf("fail to expire code, err: %v", err)
 	}
 
 	return c.NoContent(http.StatusOK)
 }
 
+func (s *Server) GetVaultBackupVersions(c echo.Context) error {
+	publicKeyECDSA := c.Param("publicKeyECDSA")
+	if !s.isValidHash(publicKeyECDSA) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	versions, err := s.vaultBackups.List(c.Request().Context(), publicKeyECDSA)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get vault backup versions",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, versions)
+}
+
+// RestoreVaultBackupVersion makes a version the vault's backup again. The
+// backup it replaces is kept as a new version.
+func (s *Server) RestoreVaultBackupVersion(c echo.Context) error {
+	publicKeyECDSA := c.Param("publicKeyECDSA")
+	if !s.isValidHash(publicKeyECDSA) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "failed to restore vault backup",
+			"error":   "invalid version",
+		})
+	}
+
+	restored, err := s.vaultBackups.Restore(c.Request().Context(), publicKeyECDSA, version)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to restore vault backup",
+				"error":   "version not found",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to restore vault backup",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"public_key": publicKeyECDSA,
+		"version":    version,
+	}).Info("Vault backup restored")
+
+	return c.JSON(http.StatusOK, restored)
+}
+
 // TODO: Make those handlers require jwt auth
 func (s *Server) CreateTransaction(c echo.Context) error {
 	var reqTx types.TransactionHistory
@@ -735,18 +953,17 @@ func (s *Server) CreateTransaction(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
-	existingTx, _ := s.db.GetTransactionByHash(c.Request().Context(), reqTx.TxHash)
-	if existingTx != nil {
-		if existingTx.Status != types.StatusSigningFailed &&
-			existingTx.Status != types.StatusRejected {
-			return c.NoContent(http.StatusConflict)
-		}
+	if err := s.checkTransactionPlugin(c, reqTx.PolicyID); err != nil {
+		s.logger.Warnf("fail to authorize transaction sync, err: %v", err)
+		return c.NoContent(http.StatusForbidden)
+	}
 
-		if err := s.db.UpdateTransactionStatus(c.Request().Context(), existingTx.ID, types.StatusPending, reqTx.Metadata); err != nil {
-			s.logger.Errorf("fail to update transaction status: %v", err)
-			return c.NoContent(http.StatusInternalServerError)
-		}
-		return c.NoContent(http.StatusOK)
+	// failed attempts stay in the history, the signing hash can be proposed again
+	existingTx, _ := s.db.GetTransactionByHash(c.Request().Context(), reqTx.TxHash)
+	if existingTx != nil &&
+		existingTx.Status != types.StatusSigningFailed &&
+		existingTx.Status != types.StatusRejected {
+		return c.NoContent(http.StatusConflict)
 	}
 
 	if _, err := s.db.CreateTransactionHistory(c.Request().Context(), reqTx); err != nil {
@@ -767,13 +984,29 @@ func (s *Server) UpdateTransaction(c echo.Context) error {
 		return c.NoContent(http.StatusNotFound)
 	}
 
-	if err := s.db.UpdateTransactionStatus(c.Request().Context(), existingTx.ID, reqTx.Status, reqTx.Metadata); err != nil {
+	if err := s.checkTransactionPlugin(c, existingTx.PolicyID); err != nil {
+		s.logger.Warnf("fail to authorize transaction sync, err: %v", err)
+		return c.NoContent(http.StatusForbidden)
+	}
+
+	if err := s.db.UpdateTransactionStatus(c.Request().Context(), existingTx.ID, reqTx.Update()); err != nil {
 		s.logger.Errorf("fail to update transaction status, err: %v", err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 	return c.NoContent(http.StatusOK)
 }
 
+func (s *Server) checkTransactionPlugin(c echo.Context, policyID uuid.UUID) error {
+	if c.Get("plugin") == nil {
+		return nil
+	}
+	policy, err := s.db.GetPluginPolicy(c.Request().Context(), policyID.String())
+	if err != nil {
+		return fmt.Errorf("failed to get policy: %w", err)
+	}
+	return s.checkCallingPlugin(c, policy.PluginID)
+}
+
 func (s *Server) Auth(c echo.Context) error {
 	var req struct {
 		Message      string `json:"message"`
@@ -808,7 +1041,7 @@ func (s *Server) Auth(c echo.Context) error {
 		return c.NoContent(http.StatusUnauthorized)
 	}
 
-	token, err := s.authService.GenerateToken()
+	token, err := s.authService.GenerateToken(req.PublicKey)
 	if err != nil {
 		s.logger.Error("failed to generate token:", err)
 		return c.NoContent(http.StatusInternalServerError)
@@ -817,6 +1050,53 @@ func (s *Server) Auth(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"token": token})
 }
 
+// IssuePluginToken issues a service token to the plugin authenticated by its
+// request signature.
+func (s *Server) IssuePluginToken(c echo.Context) error {
+	plugin, ok := c.Get("plugin").(*types.Plugin)
+	if !ok {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	var req types.PluginTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = types.PluginScopes
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(types.PluginScopes, scope) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Unknown scope %s", scope)})
+		}
+	}
+
+	token, claims, err := s.authService.GeneratePluginToken(plugin.ID, req.Scopes)
+	if err != nil {
+		s.logger.Error("failed to generate plugin token:", err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	pluginToken := types.PluginToken{
+		ID:        claims.Id,
+		PluginID:  plugin.ID,
+		Scopes:    req.Scopes,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}
+	if err := s.db.CreatePluginToken(c.Request().Context(), pluginToken); err != nil {
+		s.logger.Errorf("fail to store plugin token, err: %v", err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":      token,
+		"token_id":   pluginToken.ID,
+		"scopes":     pluginToken.Scopes,
+		"expires_at": pluginToken.ExpiresAt,
+	})
+}
+
 func (s *Server) RefreshToken(c echo.Context) error {
 	var req struct {
 		Token string `json:"token"`
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const webhookSecretBytes = 32
+
+func (s *Server) GetWebhookSubscriptions(c echo.Context) error {
+	owner, err := tokenVault(c, c.QueryParam("public_key"))
+	if err != nil {
+		s.logger.Warnf("fail to authorize webhook request, err: %v", err)
+		return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+	}
+
+	subscriptions, err := s.db.GetWebhookSubscriptions(c.Request().Context(), owner)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get webhook subscriptions",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, subscriptions)
+}
+
+// CreateWebhookSubscription returns the signing secret of the subscription,
+// which isn't shown again. The URL must point to a public address.
+func (s *Server) CreateWebhookSubscription(c echo.Context) error {
+	var dto types.WebhookSubscriptionDto
+	if err := c.Bind(&dto); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+
+	if err := c.Validate(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	owner, err := tokenVault(c, dto.PublicKey)
+	if err != nil {
+		s.logger.Warnf("fail to authorize webhook request, err: %v", err)
+		return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+	}
+	if dto.PolicyID != "" {
+		policy, err := s.db.GetPluginPolicy(c.Request().Context(), dto.PolicyID)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, echo.Map{
+					"message": "failed to create webhook subscription",
+					"error":   "policy not found",
+				})
+			}
+			s.logger.Error(err)
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": "failed to create webhook subscription",
+			})
+		}
+		if policy.PublicKey != owner {
+			s.logger.Warnf("fail to authorize webhook request, policy %s belongs to another vault", policy.ID)
+			return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+		}
+	}
+	if err := webhook.ValidateURL(c.Request().Context(), dto.URL); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "failed to create webhook subscription",
+			"error":   err.Error(),
+		})
+	}
+
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("fail to generate webhook secret, err: %w", err)
+	}
+
+	created, err := s.db.CreateWebhookSubscription(c.Request().Context(), types.WebhookSubscription{
+		PublicKey:  owner,
+		PolicyID:   dto.PolicyID,
+		URL:        dto.URL,
+		Secret:     hex.EncodeToString(secret),
+		EventTypes: dto.EventTypes,
+		Active:     true,
+	})
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to create webhook subscription",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, created)
+}
+
+func (s *Server) DeleteWebhookSubscription(c echo.Context) error {
+	owner, err := tokenVault(c, "")
+	if err != nil {
+		s.logger.Warnf("fail to authorize webhook request, err: %v", err)
+		return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+	}
+
+	subscriptionID := c.Param("subscriptionId")
+
+	err = s.db.DeleteWebhookSubscription(c.Request().Context(), owner, subscriptionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to delete webhook subscription",
+				"error":   "subscription not found",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to delete webhook subscription",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetWebhookDeadLetters(c echo.Context) error {
+	owner, err := tokenVault(c, c.QueryParam("public_key"))
+	if err != nil {
+		s.logger.Warnf("fail to authorize webhook request, err: %v", err)
+		return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+	}
+
+	skip, err := strconv.Atoi(c.QueryParam("skip"))
+	if err != nil {
+		skip = 0
+	}
+
+	take, err := strconv.Atoi(c.QueryParam("take"))
+	if err != nil {
+		take = 30
+	}
+
+	deadLetters, err := s.db.GetWebhookDeadLetters(c.Request().Context(), owner, take, skip)
+	if err != nil {
+		message := echo.Map{
+			"message": "failed to get webhook dead letters",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.JSON(http.StatusOK, deadLetters)
+}
+
+// ReplayWebhookDeadLetter queues the dead letter for delivery again.
+func (s *Server) ReplayWebhookDeadLetter(c echo.Context) error {
+	owner, err := tokenVault(c, "")
+	if err != nil {
+		s.logger.Warnf("fail to authorize webhook request, err: %v", err)
+		return c.JSON(http.StatusForbidden, echo.Map{"message": "Forbidden"})
+	}
+
+	deadLetterID, err := strconv.ParseInt(c.Param("deadLetterId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid dead letter id",
+		})
+	}
+
+	err = s.db.ReplayWebhookDeadLetter(c.Request().Context(), owner, deadLetterID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, echo.Map{
+				"message": "failed to replay webhook dead letter",
+				"error":   "dead letter not found",
+			})
+		}
+		message := echo.Map{
+			"message": "failed to replay webhook dead letter",
+		}
+		s.logger.Error(err)
+		return c.JSON(http.StatusInternalServerError, message)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
@@ -55,7 +55,11 @@ func main() {
 		panic(err)
 	}
 
-	db, err := postgres.NewPostgresBackend(false, cfg.Server.Database.DSN)
+	db, err := postgres.NewPostgresBackend(cfg.Server.ReadOnly, cfg.Server.Database.DSN, postgres.ReplicaOptions{
+		DSNs:          cfg.Server.Database.ReplicaDSNs,
+		MaxLag:        cfg.Server.Database.MaxReplicaLag,
+		CheckInterval: cfg.Server.Database.ReplicaCheckInterval,
+	})
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
 	}
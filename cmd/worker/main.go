@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/hibiken/asynq"
@@ -12,6 +13,7 @@ import (
 	"github.com/vultisig/vultiserver-plugin/internal/tasks"
 	"github.com/vultisig/vultiserver-plugin/service"
 	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/postgres"
 )
 
 func main() {
@@ -39,13 +41,25 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	syncerService := syncer.NewPolicySyncer(logger.WithField("service", "syncer").Logger, verifierConfig.Server.Host, verifierConfig.Server.Port)
-	authService := service.NewAuthService(cfg.Server.JWTSecret)
+	if cfg.Server.ReadOnly {
+		panic("the worker can't run in read-only mode")
+	}
+	db, err := postgres.NewPostgresBackend(false, cfg.Server.Database.DSN, postgres.ReplicaOptions{})
+	if err != nil {
+		panic(err)
+	}
+	requestSigner, err := syncer.RequestSignerFromConfig(*cfg)
+	if err != nil {
+		panic(err)
+	}
+	tokenSource := syncer.NewTokenSource(verifierConfig.Server.Host, verifierConfig.Server.Port, requestSigner)
+	syncerService := syncer.NewPolicySyncer(logger.WithField("service", "syncer").Logger, db, verifierConfig.Server.Host, verifierConfig.Server.Port, tokenSource.Token, requestSigner)
+	syncerService.Start()
 
 	client := asynq.NewClient(redisOptions)
 	inspector := asynq.NewInspector(redisOptions)
 
-	workerService, err := service.NewWorker(*cfg, verifierConfig.Server.Port, client, sdClient, syncerService, authService, blockStorage, inspector)
+	workerService, err := service.NewWorker(*cfg, verifierConfig.Server.Port, requestSigner, client, sdClient, syncerService, blockStorage, inspector)
 	if err != nil {
 		panic(err)
 	}
@@ -73,6 +87,24 @@ func main() {
 	mux.HandleFunc(tasks.TypeKeySignDKLS, workerService.HandleKeySignDKLS)
 	mux.HandleFunc(tasks.TypeReshareDKLS, workerService.HandleReshareDKLS)
 	mux.HandleFunc(tasks.TypeMigrate, workerService.HandleMigrateDKLS)
+	mux.HandleFunc(tasks.TypeTransactionRetention, workerService.HandleTransactionRetention)
+
+	if cfg.Retention.Enabled {
+		// every worker registers the task, the unique option enqueues it once
+		scheduler := asynq.NewScheduler(redisOptions, &asynq.SchedulerOpts{Logger: logger})
+		_, err := scheduler.Register(cfg.Retention.Schedule,
+			asynq.NewTask(tasks.TypeTransactionRetention, nil),
+			asynq.Queue(tasks.QUEUE_NAME),
+			asynq.Unique(time.Hour))
+		if err != nil {
+			panic(fmt.Errorf("could not schedule transaction retention: %w", err))
+		}
+		if err := scheduler.Start(); err != nil {
+			panic(fmt.Errorf("could not run scheduler: %w", err))
+		}
+		defer scheduler.Shutdown()
+	}
+
 	if err := srv.Run(mux); err != nil {
 		panic(fmt.Errorf("could not run server: %w", err))
 	}
@@ -283,14 +283,34 @@ func DecryptGCM(rawData []byte, hexEncryptKey string) ([]byte, error) {
 	return plaintext, nil
 }
 
-// TODO: pass if the key is ecdsa or eddsa
-func DeriveAddress(compressedPubKeyHex, hexChainCode, derivePath string) (*common.Address, error) {
-	derivedPubKeyHex, err := tss.GetDerivedPubKey(compressedPubKeyHex, hexChainCode, derivePath, false)
+// DerivePublicKey returns the key the vault signs with for the path. ECDSA
+// keys are derived with the chain code, while vaults sign with their root
+// EdDSA key on every EdDSA chain.
+func DerivePublicKey(publicKeyHex, hexChainCode, derivePath string, isEcdsa bool) ([]byte, error) {
+	publicKeyHex = strings.TrimPrefix(publicKeyHex, "0x")
+	if !isEcdsa {
+		publicKey, err := hex.DecodeString(publicKeyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex encoding: %w", err)
+		}
+		if !CheckIfPublicKeyIsValid(publicKey, false) {
+			return nil, fmt.Errorf("invalid EdDSA public key")
+		}
+		return publicKey, nil
+	}
+
+	derivedPubKeyHex, err := tss.GetDerivedPubKey(publicKeyHex, hexChainCode, derivePath, false)
 	if err != nil {
 		return nil, err
 	}
 
-	derivedPubKeyBytes, err := hex.DecodeString(derivedPubKeyHex)
+	return hex.DecodeString(derivedPubKeyHex)
+}
+
+// DeriveAddress returns the EVM address of the vault's ECDSA key derived for
+// the path. EdDSA keys have no EVM address.
+func DeriveAddress(compressedPubKeyHex, hexChainCode, derivePath string) (*common.Address, error) {
+	derivedPubKeyBytes, err := DerivePublicKey(compressedPubKeyHex, hexChainCode, derivePath, true)
 	if err != nil {
 		return nil, err
 	}
@@ -312,6 +332,12 @@ func GetVaultBackupFilename(publicKey string) string {
 	return fmt.Sprintf("%s%s", publicKey, vaultBackupSuffix)
 }
 
+// GetVaultBackupVersionFilename names a copy of a vault backup after the hash
+// of its content.
+func GetVaultBackupVersionFilename(publicKey string, contentHash string) string {
+	return fmt.Sprintf("%s.%s%s", publicKey, contentHash, vaultBackupSuffix)
+}
+
 func CheckIfPublicKeyIsValid(pubKeyBytes []byte, isEcdsa bool) bool {
 	if isEcdsa {
 
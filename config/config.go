@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,13 +14,26 @@ type Config struct {
 		Port     int64  `mapstructure:"port" json:"port,omitempty"`
 		Database struct {
 			DSN string `mapstructure:"dsn" json:"dsn,omitempty"`
+			// ReplicaDSNs are read replicas serving transaction history, policy
+			// listings and the plugin catalog
+			ReplicaDSNs []string `mapstructure:"replica_dsns" json:"replica_dsns,omitempty"`
+			// MaxReplicaLag is the replication lag past which a replica stops
+			// serving reads
+			MaxReplicaLag        time.Duration `mapstructure:"max_replica_lag" json:"max_replica_lag,omitempty"`
+			ReplicaCheckInterval time.Duration `mapstructure:"replica_check_interval" json:"replica_check_interval,omitempty"`
 		} `mapstructure:"database" json:"database,omitempty"`
+		// ReadOnly serves the API without writing to the database, e.g. from a
+		// promoted standby during disaster recovery
+		ReadOnly       bool   `mapstructure:"read_only" json:"read_only,omitempty"`
 		VaultsFilePath string `mapstructure:"vaults_file_path" json:"vaults_file_path,omitempty"`
 		Mode           string `mapstructure:"mode" json:"mode,omitempty"`
 		JWTSecret      string `mapstructure:"jwt_secret" json:"jwt_secret,omitempty"`
 		Plugin         struct {
 			Type string `mapstructure:"type" json:"type,omitempty"`
-			Eth  struct {
+			// ID and SigningKey identify the plugin server to the verifier
+			ID         string `mapstructure:"id" json:"id,omitempty"`
+			SigningKey string `mapstructure:"signing_key" json:"signing_key,omitempty"`
+			Eth        struct {
 				Rpc     string `mapstructure:"rpc" json:"rpc,omitempty"`
 				Uniswap struct {
 					V2Router string `mapstructure:"v2_router" json:"v2_router,omitempty"`
@@ -27,7 +41,11 @@ type Config struct {
 				} `mapstructure:"uniswap" json:"uniswap,omitempty"`
 			} `mapstructure:"eth" json:"eth,omitempty"`
 		} `mapstructure:"plugin" json:"plugin,omitempty"`
-		UserAuth struct {
+		// PluginTokenKey is the hex Ed25519 seed the verifier signs plugin service tokens with
+		PluginTokenKey string `mapstructure:"plugin_token_key" json:"plugin_token_key,omitempty"`
+		// AttestationKey is the hex Ed25519 seed the verifier signs approval receipts with
+		AttestationKey string `mapstructure:"attestation_key" json:"attestation_key,omitempty"`
+		UserAuth       struct {
 			JwtSecret string `mapstructure:"jwt_secret" json:"jwt_secret,omitempty"`
 		} `mapstructure:"user_auth" json:"auth,omitempty"`
 	} `mapstructure:"server" json:"server"`
@@ -53,13 +71,62 @@ type Config struct {
 	} `mapstructure:"email_server" json:"email_server"`
 
 	BlockStorage struct {
+		// Type is s3, the default, or local
+		Type      string `mapstructure:"type" json:"type"`
 		Host      string `mapstructure:"host" json:"host"`
 		Region    string `mapstructure:"region" json:"region"`
 		AccessKey string `mapstructure:"access_key" json:"access_key"`
 		SecretKey string `mapstructure:"secret" json:"secret"`
 		Bucket    string `mapstructure:"bucket" json:"bucket"`
+		// Path is the directory of the local block storage
+		Path       string `mapstructure:"path" json:"path"`
+		Encryption struct {
+			Enabled bool `mapstructure:"enabled" json:"enabled"`
+			// KMS is local, wrapping data keys with Key, or aws, with the KMS key KeyID
+			KMS   string `mapstructure:"kms" json:"kms"`
+			Key   string `mapstructure:"key" json:"key"`
+			KeyID string `mapstructure:"key_id" json:"key_id"`
+		} `mapstructure:"encryption" json:"encryption"`
+		// BackupVersions is the number of versions kept of each vault backup
+		BackupVersions int `mapstructure:"backup_versions" json:"backup_versions"`
 	} `mapstructure:"block_storage" json:"block_storage"`
 
+	Reconcile struct {
+		Enabled  bool          `mapstructure:"enabled" json:"enabled,omitempty"`
+		Interval time.Duration `mapstructure:"interval" json:"interval,omitempty"`
+		Repair   bool          `mapstructure:"repair" json:"repair,omitempty"`
+	} `mapstructure:"reconcile" json:"reconcile,omitempty"`
+
+	// Retention archives and purges finished transactions on a schedule.
+	Retention struct {
+		Enabled bool `mapstructure:"enabled" json:"enabled,omitempty"`
+		// Schedule is the cron spec the retention task is enqueued on
+		Schedule string `mapstructure:"schedule" json:"schedule,omitempty"`
+		// ArchiveAfter moves transactions finished for longer to archives in
+		// block storage, zero keeps them
+		ArchiveAfter time.Duration `mapstructure:"archive_after" json:"archive_after,omitempty"`
+		// PurgeAfter removes PurgeMetadataKeys from the metadata of transactions
+		// finished for longer, zero keeps them
+		PurgeAfter        time.Duration `mapstructure:"purge_after" json:"purge_after,omitempty"`
+		PurgeMetadataKeys []string      `mapstructure:"purge_metadata_keys" json:"purge_metadata_keys,omitempty"`
+		BatchSize         int           `mapstructure:"batch_size" json:"batch_size,omitempty"`
+		// DryRun reports what would be archived and purged without changing anything
+		DryRun bool `mapstructure:"dry_run" json:"dry_run,omitempty"`
+	} `mapstructure:"retention" json:"retention,omitempty"`
+
+	// Reconstruction makes the verifier rebuild every proposed transaction from
+	// the signed policy and its own chain view before signing it.
+	Reconstruction struct {
+		Enabled bool `mapstructure:"enabled" json:"enabled,omitempty"`
+		// Strict rejects transactions of plugins that don't support reconstruction
+		Strict             bool          `mapstructure:"strict" json:"strict,omitempty"`
+		GasPriceTolerance  float64       `mapstructure:"gas_price_tolerance" json:"gas_price_tolerance,omitempty"`
+		GasLimitTolerance  float64       `mapstructure:"gas_limit_tolerance" json:"gas_limit_tolerance,omitempty"`
+		NonceTolerance     uint64        `mapstructure:"nonce_tolerance" json:"nonce_tolerance,omitempty"`
+		AmountOutTolerance float64       `mapstructure:"amount_out_tolerance" json:"amount_out_tolerance,omitempty"`
+		DeadlineTolerance  time.Duration `mapstructure:"deadline_tolerance" json:"deadline_tolerance,omitempty"`
+	} `mapstructure:"reconstruction" json:"reconstruction,omitempty"`
+
 	Datadog struct {
 		Host string `mapstructure:"host" json:"host,omitempty"`
 		Port string `mapstructure:"port" json:"port,omitempty"`
@@ -81,6 +148,16 @@ func ReadConfig(configName string) (*Config, error) {
 	viper.AutomaticEnv()
 
 	viper.SetDefault("Server.VaultsFilePath", "vaults")
+	viper.SetDefault("Server.Database.MaxReplicaLag", 5*time.Second)
+	viper.SetDefault("Server.Database.ReplicaCheckInterval", 5*time.Second)
+	viper.SetDefault("Reconcile.Interval", time.Hour)
+	viper.SetDefault("Retention.Schedule", "0 3 * * *")
+	viper.SetDefault("Retention.BatchSize", 500)
+	viper.SetDefault("Reconstruction.GasPriceTolerance", 20.0)
+	viper.SetDefault("Reconstruction.GasLimitTolerance", 20.0)
+	viper.SetDefault("Reconstruction.NonceTolerance", 1)
+	viper.SetDefault("Reconstruction.AmountOutTolerance", 1.0)
+	viper.SetDefault("Reconstruction.DeadlineTolerance", time.Minute)
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("fail to reading config file, %w", err)
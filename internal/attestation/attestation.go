@@ -0,0 +1,112 @@
+package attestation
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
+)
+
+// ReceiptVersion is bumped whenever the receipt fields change meaning.
+const ReceiptVersion = 1
+
+// domain separates receipt signatures from anything else signed with the key.
+const domain = "vultisig-approval-receipt:"
+
+// Receipt states that the verifier checked the transaction against the policy
+// before co-signing it.
+type Receipt struct {
+	Version       int                           `json:"version"`
+	PolicyID      string                        `json:"policy_id"`
+	PolicyVersion string                        `json:"policy_version"`
+	PolicyHash    string                        `json:"policy_hash"`
+	PluginID      string                        `json:"plugin_id"`
+	PublicKey     string                        `json:"public_key"`
+	TxHash        string                        `json:"tx_hash"`
+	Intent        *txdecoder.DecodedTransaction `json:"intent"`
+	IssuedAt      int64                         `json:"issued_at"`
+}
+
+// Attestation is a receipt signed by the verifier. Receipt is the signed JSON
+// text, kept as a string so the signed bytes survive re-encoding by clients
+// and storage.
+type Attestation struct {
+	Receipt   string `json:"receipt"`
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// Signer signs approval receipts with the verifier's Ed25519 key.
+type Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewSigner takes the hex encoded 32 byte Ed25519 seed of the verifier.
+func NewSigner(seedHex string) (*Signer, error) {
+	seed, err := hex.DecodeString(strings.TrimPrefix(seedHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attestation key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("attestation key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+
+	return &Signer{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+func (s *Signer) PublicKeyHex() string {
+	return hex.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+// Sign stamps the receipt with the current version and time and signs it.
+func (s *Signer) Sign(receipt Receipt) (*Attestation, error) {
+	receipt.Version = ReceiptVersion
+	if receipt.IssuedAt == 0 {
+		receipt.IssuedAt = time.Now().Unix()
+	}
+
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	return &Attestation{
+		Receipt:   string(payload),
+		Signer:    s.PublicKeyHex(),
+		Signature: hex.EncodeToString(ed25519.Sign(s.privateKey, message(payload))),
+	}, nil
+}
+
+// Verify checks that the attestation was signed by the trusted verifier key
+// and returns its receipt.
+func Verify(attestation Attestation, trustedKeyHex string) (*Receipt, error) {
+	publicKey, err := hex.DecodeString(strings.TrimPrefix(trustedKeyHex, "0x"))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid verifier public key")
+	}
+	if !strings.EqualFold(strings.TrimPrefix(attestation.Signer, "0x"), hex.EncodeToString(publicKey)) {
+		return nil, fmt.Errorf("attestation signed by untrusted key %s", attestation.Signer)
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(attestation.Signature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if !ed25519.Verify(publicKey, message([]byte(attestation.Receipt)), signature) {
+		return nil, fmt.Errorf("invalid attestation signature")
+	}
+
+	var receipt Receipt
+	if err := json.Unmarshal([]byte(attestation.Receipt), &receipt); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+func message(payload []byte) []byte {
+	return append([]byte(domain), payload...)
+}
@@ -0,0 +1,67 @@
+package attestation_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/attestation"
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
+)
+
+const (
+	testSeed  = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	otherSeed = "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer, err := attestation.NewSigner(testSeed)
+	require.NoError(t, err)
+
+	receipt := attestation.Receipt{
+		PolicyID:      "policy-1",
+		PolicyVersion: "0.0.1",
+		PolicyHash:    "0xabc",
+		PluginID:      "dca",
+		PublicKey:     "vault-key",
+		TxHash:        "deadbeef",
+		Intent:        &txdecoder.DecodedTransaction{ChainID: "1", To: "0x01", Value: "0", Method: "approve"},
+	}
+	att, err := signer.Sign(receipt)
+	require.NoError(t, err)
+	assert.Equal(t, signer.PublicKeyHex(), att.Signer)
+
+	verified, err := attestation.Verify(*att, signer.PublicKeyHex())
+	require.NoError(t, err)
+	assert.Equal(t, attestation.ReceiptVersion, verified.Version)
+	assert.NotZero(t, verified.IssuedAt)
+	assert.Equal(t, "deadbeef", verified.TxHash)
+	assert.Equal(t, "approve", verified.Intent.Method)
+
+	// the attestation survives a JSON round trip, as stored with the transaction
+	stored, err := json.Marshal(att)
+	require.NoError(t, err)
+	var loaded attestation.Attestation
+	require.NoError(t, json.Unmarshal(stored, &loaded))
+	_, err = attestation.Verify(loaded, signer.PublicKeyHex())
+	require.NoError(t, err)
+
+	tampered := *att
+	tampered.Receipt = `{"version":1,"policy_id":"policy-2"}`
+	_, err = attestation.Verify(tampered, signer.PublicKeyHex())
+	assert.Error(t, err)
+
+	other, err := attestation.NewSigner(otherSeed)
+	require.NoError(t, err)
+	_, err = attestation.Verify(*att, other.PublicKeyHex())
+	assert.Error(t, err)
+}
+
+func TestNewSignerInvalidKey(t *testing.T) {
+	_, err := attestation.NewSigner("abcd")
+	assert.Error(t, err)
+	_, err = attestation.NewSigner("not hex")
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+// Package ethtest provides a fake Ethereum JSON-RPC endpoint for tests of code
+// that talks to a chain through an ethclient.
+package ethtest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Handler answers a JSON-RPC call with its result.
+type Handler func(params []json.RawMessage) (any, error)
+
+// RPC serves registered methods, calls of other methods fail.
+type RPC struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    map[string]int
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// NewRPC starts the endpoint, it is closed when the test finishes.
+func NewRPC(t testing.TB) *RPC {
+	r := &RPC{
+		handlers: make(map[string]Handler),
+		calls:    make(map[string]int),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// URL is the address to dial.
+func (r *RPC) URL() string {
+	return r.server.URL
+}
+
+// Handle registers the handler of a method.
+func (r *RPC) Handle(method string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[method] = handler
+}
+
+// Result answers every call of the method with the same result.
+func (r *RPC) Result(method string, result any) {
+	r.Handle(method, func([]json.RawMessage) (any, error) {
+		return result, nil
+	})
+}
+
+// Calls counts the calls of a method.
+func (r *RPC) Calls(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[method]
+}
+
+func (r *RPC) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responses := make([]response, len(batch))
+		for i, call := range batch {
+			responses[i] = r.call(call)
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+		return
+	}
+
+	var call request
+	if err := json.Unmarshal(body, &call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(r.call(call))
+}
+
+func (r *RPC) call(call request) response {
+	r.mu.Lock()
+	handler, ok := r.handlers[call.Method]
+	r.calls[call.Method]++
+	r.mu.Unlock()
+
+	resp := response{JSONRPC: "2.0", ID: call.ID}
+	if !ok {
+		resp.Error = &rpcError{Code: -32601, Message: fmt.Sprintf("method %s not found", call.Method)}
+		return resp
+	}
+	result, err := handler(call.Params)
+	if err != nil {
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// CallData returns the call data of an eth_call or eth_estimateGas call.
+func CallData(params []json.RawMessage) ([]byte, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("missing call object")
+	}
+	var msg struct {
+		Data  string `json:"data"`
+		Input string `json:"input"`
+	}
+	if err := json.Unmarshal(params[0], &msg); err != nil {
+		return nil, err
+	}
+	data := msg.Input
+	if data == "" {
+		data = msg.Data
+	}
+	return hex.DecodeString(strings.TrimPrefix(data, "0x"))
+}
@@ -0,0 +1,149 @@
+// Package idempotency records the responses of requests sent with an
+// Idempotency-Key header, so that a retried request is answered with the
+// recorded response instead of being applied again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// Header is the request header carrying the idempotency key.
+const Header = "Idempotency-Key"
+
+const (
+	// DefaultTTL is how long responses are kept for replay.
+	DefaultTTL = 24 * time.Hour
+	// claimTTL bounds how long a request holds its key, so a crashed request
+	// doesn't block retries forever.
+	claimTTL = time.Minute
+)
+
+var (
+	// ErrInProgress is returned while another request holds the key.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrKeyReused is returned when the key was used for a different request.
+	ErrKeyReused = errors.New("idempotency key was used for a different request")
+)
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// record is stored under the key. Response is nil while the request claiming
+// the key is in progress.
+type record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+type Store struct {
+	keys storage.Keyspace
+	ttl  time.Duration
+}
+
+func NewStore(kv storage.KeyValueStore, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{
+		keys: storage.NewKeyspace(kv, storage.KeyspaceIdempotency),
+		ttl:  ttl,
+	}
+}
+
+// Begin claims the key for the request with the given fingerprint. It returns
+// the recorded response if the request was already completed, and nil if the
+// caller now holds the key and must Complete or Release it.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	claim, err := json.Marshal(record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	// the claim may expire between SetNX and Get, so try again once
+	for attempt := 0; attempt < 2; attempt++ {
+		ok, err := s.keys.SetNX(ctx, key, string(claim), claimTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+		}
+		if ok {
+			return nil, nil
+		}
+
+		value, err := s.keys.Get(ctx, key)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+		}
+		var stored record
+		if err := json.Unmarshal([]byte(value), &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+		}
+		if stored.Fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		if stored.Response == nil {
+			return nil, ErrInProgress
+		}
+		return stored.Response, nil
+	}
+	return nil, ErrInProgress
+}
+
+// Complete records the response of the request holding the key.
+func (s *Store) Complete(ctx context.Context, key, fingerprint string, response Response) error {
+	value, err := json.Marshal(record{Fingerprint: fingerprint, Response: &response})
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	if err := s.keys.Set(ctx, key, string(value), s.ttl); err != nil {
+		return fmt.Errorf("failed to record idempotency response: %w", err)
+	}
+	return nil
+}
+
+// Release gives up the key without recording a response, so the request can
+// be retried.
+func (s *Store) Release(ctx context.Context, key string) error {
+	if err := s.keys.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Replayable reports whether a response with the status code is recorded.
+// Server errors, timeouts and rate limits are left for the retry to redo.
+func Replayable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return statusCode >= http.StatusOK && statusCode < http.StatusInternalServerError
+}
+
+// Fingerprint hashes the parts identifying a request, so a key reused for a
+// different request is detected.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// length-prefix the parts so their boundaries are part of the hash
+		_ = binary.Write(h, binary.BigEndian, uint64(len(part)))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/idempotency"
+	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	kv := memory.NewKeyValueStore()
+	now := time.Unix(1700000000, 0)
+	kv.SetClock(func() time.Time { return now })
+	store := idempotency.NewStore(kv, time.Hour)
+
+	fingerprint := idempotency.Fingerprint([]byte("POST"), []byte("/plugin/policy"), []byte(`{"id":"1"}`))
+	response := idempotency.Response{StatusCode: http.StatusOK, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+
+	// the first request claims the key
+	recorded, err := store.Begin(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Nil(t, recorded)
+
+	// retries wait for it to finish
+	_, err = store.Begin(ctx, "key", fingerprint)
+	assert.ErrorIs(t, err, idempotency.ErrInProgress)
+
+	require.NoError(t, store.Complete(ctx, "key", fingerprint, response))
+
+	// retries get the recorded response
+	recorded, err = store.Begin(ctx, "key", fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, &response, recorded)
+
+	// the key can't be reused for another request
+	other := idempotency.Fingerprint([]byte("POST"), []byte("/plugin/policy"), []byte(`{"id":"2"}`))
+	_, err = store.Begin(ctx, "key", other)
+	assert.ErrorIs(t, err, idempotency.ErrKeyReused)
+
+	// records are kept in their own keyspace
+	_, err = kv.Get(ctx, "key")
+	assert.ErrorIs(t, err, storage.ErrKeyNotFound)
+
+	// expired records are forgotten
+	now = now.Add(2 * time.Hour)
+	recorded, err = store.Begin(ctx, "key", other)
+	require.NoError(t, err)
+	assert.Nil(t, recorded)
+}
+
+func TestStoreRelease(t *testing.T) {
+	ctx := context.Background()
+	kv := memory.NewKeyValueStore()
+	now := time.Unix(1700000000, 0)
+	kv.SetClock(func() time.Time { return now })
+	store := idempotency.NewStore(kv, time.Hour)
+
+	_, err := store.Begin(ctx, "released", "fingerprint")
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, "released"))
+	recorded, err := store.Begin(ctx, "released", "fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, recorded)
+
+	// an abandoned claim expires so the request can be retried
+	_, err = store.Begin(ctx, "abandoned", "fingerprint")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	recorded, err = store.Begin(ctx, "abandoned", "fingerprint")
+	require.NoError(t, err)
+	assert.Nil(t, recorded)
+}
+
+func TestReplayable(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       bool
+	}{
+		{statusCode: http.StatusOK, want: true},
+		{statusCode: http.StatusNoContent, want: true},
+		{statusCode: http.StatusForbidden, want: true},
+		{statusCode: http.StatusRequestTimeout, want: false},
+		{statusCode: http.StatusTooManyRequests, want: false},
+		{statusCode: http.StatusInternalServerError, want: false},
+		{statusCode: http.StatusBadGateway, want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, idempotency.Replayable(tt.statusCode), tt.statusCode)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// part boundaries matter
+	assert.NotEqual(t, idempotency.Fingerprint([]byte("ab"), []byte("c")), idempotency.Fingerprint([]byte("a"), []byte("bc")))
+	assert.Equal(t, idempotency.Fingerprint([]byte("a")), idempotency.Fingerprint([]byte("a")))
+}
@@ -0,0 +1,242 @@
+package reconcile
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// BuildDigests computes the digest of every policy together with the
+// transactions recorded for it.
+func BuildDigests(policies []types.PluginPolicy, entries []types.TransactionStatusEntry) []types.PolicyDigest {
+	txByPolicy := make(map[string]map[string]types.TransactionStatus)
+	for _, entry := range entries {
+		if txByPolicy[entry.PolicyID] == nil {
+			txByPolicy[entry.PolicyID] = make(map[string]types.TransactionStatus)
+		}
+		txByPolicy[entry.PolicyID][entry.TxHash] = entry.Status
+	}
+
+	digests := make([]types.PolicyDigest, 0, len(policies))
+	for _, policy := range policies {
+		digests = append(digests, NewPolicyDigest(policy, txByPolicy[policy.ID]))
+	}
+	return digests
+}
+
+func NewPolicyDigest(policy types.PluginPolicy, transactions map[string]types.TransactionStatus) types.PolicyDigest {
+	if transactions == nil {
+		transactions = map[string]types.TransactionStatus{}
+	}
+
+	digest := types.PolicyDigest{
+		PolicyID:      policy.ID,
+		PolicyVersion: policy.PolicyVersion,
+		Active:        policy.Active,
+		Signature:     policy.Signature,
+		PolicyHash:    hashPolicy(policy.Policy),
+		Transactions:  transactions,
+	}
+
+	txHashes := make([]string, 0, len(transactions))
+	for txHash := range transactions {
+		txHashes = append(txHashes, txHash)
+	}
+	sort.Strings(txHashes)
+
+	var b strings.Builder
+	b.WriteString(digest.PolicyID + "|" + digest.PolicyVersion + "|" + strconv.FormatBool(digest.Active) + "|" + digest.Signature + "|" + digest.PolicyHash)
+	for _, txHash := range txHashes {
+		b.WriteString("|" + txHash + "=" + string(transactions[txHash]))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	digest.Digest = hex.EncodeToString(sum[:])
+
+	return digest
+}
+
+// hashPolicy hashes the policy document in a canonical form, since JSONB does
+// not preserve the formatting the policy was submitted with.
+func hashPolicy(raw json.RawMessage) string {
+	canonical := []byte(raw)
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err == nil {
+		if b, err := json.Marshal(doc); err == nil {
+			canonical = b
+		}
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare reports every difference between the plugin's and the verifier's
+// digests. Policies with equal digests are skipped.
+func Compare(pluginDigests, verifierDigests []types.PolicyDigest) []types.ReconcileMismatch {
+	verifierByID := make(map[string]types.PolicyDigest, len(verifierDigests))
+	for _, digest := range verifierDigests {
+		verifierByID[digest.PolicyID] = digest
+	}
+
+	var mismatches []types.ReconcileMismatch
+	seen := make(map[string]bool, len(pluginDigests))
+	for _, pluginDigest := range pluginDigests {
+		seen[pluginDigest.PolicyID] = true
+		verifierDigest, ok := verifierByID[pluginDigest.PolicyID]
+		if !ok {
+			mismatches = append(mismatches, types.ReconcileMismatch{
+				Kind:     types.MismatchMissingOnVerifier,
+				PolicyID: pluginDigest.PolicyID,
+			})
+			continue
+		}
+		if pluginDigest.Digest == verifierDigest.Digest {
+			continue
+		}
+		mismatches = append(mismatches, comparePolicy(pluginDigest, verifierDigest)...)
+	}
+
+	for _, verifierDigest := range verifierDigests {
+		if !seen[verifierDigest.PolicyID] {
+			mismatches = append(mismatches, types.ReconcileMismatch{
+				Kind:     types.MismatchMissingOnPlugin,
+				PolicyID: verifierDigest.PolicyID,
+			})
+		}
+	}
+
+	return mismatches
+}
+
+func comparePolicy(pluginDigest, verifierDigest types.PolicyDigest) []types.ReconcileMismatch {
+	var mismatches []types.ReconcileMismatch
+
+	var fields []string
+	if pluginDigest.PolicyVersion != verifierDigest.PolicyVersion {
+		fields = append(fields, "policy_version")
+	}
+	if pluginDigest.Active != verifierDigest.Active {
+		fields = append(fields, "active")
+	}
+	if pluginDigest.Signature != verifierDigest.Signature {
+		fields = append(fields, "signature")
+	}
+	if pluginDigest.PolicyHash != verifierDigest.PolicyHash {
+		fields = append(fields, "policy")
+	}
+	if len(fields) > 0 {
+		mismatches = append(mismatches, types.ReconcileMismatch{
+			Kind:     types.MismatchPolicy,
+			PolicyID: pluginDigest.PolicyID,
+			Fields:   fields,
+		})
+	}
+
+	txHashes := make(map[string]bool)
+	for txHash := range pluginDigest.Transactions {
+		txHashes[txHash] = true
+	}
+	for txHash := range verifierDigest.Transactions {
+		txHashes[txHash] = true
+	}
+	sortedHashes := make([]string, 0, len(txHashes))
+	for txHash := range txHashes {
+		sortedHashes = append(sortedHashes, txHash)
+	}
+	sort.Strings(sortedHashes)
+
+	for _, txHash := range sortedHashes {
+		pluginStatus, onPlugin := pluginDigest.Transactions[txHash]
+		verifierStatus, onVerifier := verifierDigest.Transactions[txHash]
+		if onPlugin && onVerifier && pluginStatus == verifierStatus {
+			continue
+		}
+		mismatches = append(mismatches, types.ReconcileMismatch{
+			Kind:          types.MismatchTransaction,
+			PolicyID:      pluginDigest.PolicyID,
+			TxHash:        txHash,
+			PluginValue:   statusOrMissing(pluginStatus, onPlugin),
+			VerifierValue: statusOrMissing(verifierStatus, onVerifier),
+		})
+	}
+
+	return mismatches
+}
+
+func statusOrMissing(status types.TransactionStatus, ok bool) string {
+	if !ok {
+		return "missing"
+	}
+	return fmt.Sprint(status)
+}
+
+// ComparePolicyRevisions orders two copies of a policy by their policy
+// version, then by the expiry of their vault signature, which a later
+// signature never precedes. It returns a negative number when a is older
+// than b and a positive one when it is newer.
+func ComparePolicyRevisions(a, b types.PluginPolicy) int {
+	if c := compareVersions(a.PolicyVersion, b.PolicyVersion); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Expiry, b.Expiry)
+}
+
+// compareVersions compares dotted versions part by part, numerically where
+// both parts are numbers.
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		aNum, aErr := strconv.ParseUint(aPart, 10, 64)
+		bNum, bErr := strconv.ParseUint(bPart, 10, 64)
+		if aErr == nil && bErr == nil {
+			if c := cmp.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+			continue
+		}
+		if c := strings.Compare(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// statusStages orders the transaction statuses along the life of a
+// transaction: the worker records it PENDING before signing, then SIGNED or
+// SIGNING_FAILED, and the broadcast ends MINED or REJECTED. Statuses sharing a
+// stage don't replace each other.
+var statusStages = map[types.TransactionStatus]int{
+	types.StatusPending:           0,
+	types.StatusSigningInProgress: 1,
+	types.StatusSigningFailed:     2,
+	types.StatusSigned:            3,
+	types.StatusBroadcast:         4,
+	types.StatusMined:             5,
+	types.StatusRejected:          5,
+}
+
+// StatusAdvances reports whether a transaction record in status from may be
+// moved to status to. A transaction never goes back, so e.g. a signed or
+// mined record is not replaced by one still signing.
+func StatusAdvances(from, to types.TransactionStatus) bool {
+	fromStage, ok := statusStages[from]
+	if !ok {
+		return false
+	}
+	toStage, ok := statusStages[to]
+	return ok && toStage > fromStage
+}
@@ -0,0 +1,113 @@
+package reconcile_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/reconcile"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func testPolicy(id string, policy string) types.PluginPolicy {
+	return types.PluginPolicy{
+		ID:            id,
+		PolicyVersion: "0.0.1",
+		Signature:     "0xsig",
+		Active:        true,
+		Policy:        json.RawMessage(policy),
+	}
+}
+
+func TestPolicyDigestIgnoresFormatting(t *testing.T) {
+	a := reconcile.NewPolicyDigest(testPolicy("p1", `{"a":1,"b":"x"}`), nil)
+	b := reconcile.NewPolicyDigest(testPolicy("p1", `{ "b": "x", "a": 1 }`), nil)
+	assert.Equal(t, a.Digest, b.Digest)
+
+	c := reconcile.NewPolicyDigest(testPolicy("p1", `{"a":2,"b":"x"}`), nil)
+	assert.NotEqual(t, a.Digest, c.Digest)
+}
+
+func TestCompare(t *testing.T) {
+	inactive := testPolicy("p2", `{}`)
+	inactive.Active = false
+
+	pluginDigests := reconcile.BuildDigests(
+		[]types.PluginPolicy{testPolicy("p1", `{}`), testPolicy("p2", `{}`), testPolicy("p3", `{}`)},
+		[]types.TransactionStatusEntry{
+			{PolicyID: "p1", TxHash: "h1", Status: types.StatusMined},
+			{PolicyID: "p1", TxHash: "h2", Status: types.StatusSigned},
+		},
+	)
+	verifierDigests := reconcile.BuildDigests(
+		[]types.PluginPolicy{testPolicy("p1", `{}`), inactive, testPolicy("p4", `{}`)},
+		[]types.TransactionStatusEntry{
+			{PolicyID: "p1", TxHash: "h1", Status: types.StatusPending},
+		},
+	)
+
+	mismatches := reconcile.Compare(pluginDigests, verifierDigests)
+	require.Len(t, mismatches, 5)
+
+	assert.Equal(t, types.ReconcileMismatch{
+		Kind: types.MismatchTransaction, PolicyID: "p1", TxHash: "h1",
+		PluginValue: string(types.StatusMined), VerifierValue: string(types.StatusPending),
+	}, mismatches[0])
+	assert.Equal(t, types.ReconcileMismatch{
+		Kind: types.MismatchTransaction, PolicyID: "p1", TxHash: "h2",
+		PluginValue: string(types.StatusSigned), VerifierValue: "missing",
+	}, mismatches[1])
+	assert.Equal(t, types.ReconcileMismatch{
+		Kind: types.MismatchPolicy, PolicyID: "p2", Fields: []string{"active"},
+	}, mismatches[2])
+	assert.Equal(t, types.ReconcileMismatch{Kind: types.MismatchMissingOnVerifier, PolicyID: "p3"}, mismatches[3])
+	assert.Equal(t, types.ReconcileMismatch{Kind: types.MismatchMissingOnPlugin, PolicyID: "p4"}, mismatches[4])
+}
+
+func TestCompareInSync(t *testing.T) {
+	entries := []types.TransactionStatusEntry{{PolicyID: "p1", TxHash: "h1", Status: types.StatusMined}}
+	digests := reconcile.BuildDigests([]types.PluginPolicy{testPolicy("p1", `{}`)}, entries)
+
+	assert.Empty(t, reconcile.Compare(digests, digests))
+}
+
+func TestComparePolicyRevisions(t *testing.T) {
+	policy := func(version string, expiry int64) types.PluginPolicy {
+		return types.PluginPolicy{PolicyVersion: version, Expiry: expiry}
+	}
+	tests := []struct {
+		name string
+		a, b types.PluginPolicy
+		want int
+	}{
+		{name: "same revision", a: policy("0.0.1", 10), b: policy("0.0.1", 10), want: 0},
+		{name: "later signature", a: policy("0.0.1", 20), b: policy("0.0.1", 10), want: 1},
+		{name: "earlier signature", a: policy("0.0.1", 10), b: policy("0.0.1", 20), want: -1},
+		{name: "newer version", a: policy("0.0.10", 10), b: policy("0.0.9", 20), want: 1},
+		{name: "older version", a: policy("0.9", 20), b: policy("1.0.0", 10), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, reconcile.ComparePolicyRevisions(tt.a, tt.b))
+		})
+	}
+}
+
+func TestStatusAdvances(t *testing.T) {
+	assert.True(t, reconcile.StatusAdvances(types.StatusPending, types.StatusSigned))
+	assert.True(t, reconcile.StatusAdvances(types.StatusPending, types.StatusSigningFailed))
+	assert.True(t, reconcile.StatusAdvances(types.StatusSigningInProgress, types.StatusSigned))
+	assert.True(t, reconcile.StatusAdvances(types.StatusSigned, types.StatusMined))
+	assert.True(t, reconcile.StatusAdvances(types.StatusBroadcast, types.StatusRejected))
+
+	// signed and mined records are not pushed back
+	assert.False(t, reconcile.StatusAdvances(types.StatusSigned, types.StatusPending))
+	assert.False(t, reconcile.StatusAdvances(types.StatusBroadcast, types.StatusPending))
+	assert.False(t, reconcile.StatusAdvances(types.StatusSigned, types.StatusSigningInProgress))
+	assert.False(t, reconcile.StatusAdvances(types.StatusSigned, types.StatusSigningFailed))
+	assert.False(t, reconcile.StatusAdvances(types.StatusMined, types.StatusBroadcast))
+	assert.False(t, reconcile.StatusAdvances(types.StatusMined, types.StatusRejected))
+	assert.False(t, reconcile.StatusAdvances(types.StatusSigned, "UNKNOWN"))
+}
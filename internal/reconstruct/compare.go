@@ -0,0 +1,129 @@
+package reconstruct
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tolerances bound how far a proposed transaction may drift from the one the
+// verifier rebuilt on its own. Fields without a tolerance must match exactly.
+type Tolerances struct {
+	// GasPricePercent and GasLimitPercent are allowed deviations in percent.
+	GasPricePercent float64
+	GasLimitPercent float64
+	// Nonce is how many nonces above the pending nonce a proposal may use, so
+	// that transactions of the same run can be proposed before broadcasting.
+	Nonce uint64
+	// AmountOutPercent is how much lower than the verifier's own minimum the
+	// minimum output amount of a swap may be.
+	AmountOutPercent float64
+	// Deadline is the allowed clock skew on swap deadlines.
+	Deadline time.Duration
+}
+
+type FieldMismatch struct {
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// MismatchError lists every field of a proposed transaction that does not
+// match its reconstruction.
+type MismatchError struct {
+	Mismatches []FieldMismatch
+}
+
+func (e *MismatchError) Error() string {
+	parts := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		parts = append(parts, fmt.Sprintf("%s: expected %s, got %s", m.Field, m.Expected, m.Actual))
+	}
+	return "transaction does not match reconstruction: " + strings.Join(parts, "; ")
+}
+
+// Compare checks the proposed transaction against the expected one field by
+// field. Chain ID, recipient, value and call data must be identical, nonce and
+// gas are accepted within the given tolerances.
+func Compare(expected, actual *gtypes.Transaction, tol Tolerances) error {
+	var mismatches []FieldMismatch
+	add := func(field, expected, actual string) {
+		mismatches = append(mismatches, FieldMismatch{Field: field, Expected: expected, Actual: actual})
+	}
+
+	if expected.ChainId().Cmp(actual.ChainId()) != 0 {
+		add("chain_id", expected.ChainId().String(), actual.ChainId().String())
+	}
+	if addressString(expected) != addressString(actual) {
+		add("to", addressString(expected), addressString(actual))
+	}
+	if expected.Value().Cmp(actual.Value()) != 0 {
+		add("value", expected.Value().String(), actual.Value().String())
+	}
+	if !bytes.Equal(expected.Data(), actual.Data()) {
+		add("data", fmt.Sprintf("0x%x", expected.Data()), fmt.Sprintf("0x%x", actual.Data()))
+	}
+	if actual.Nonce() < expected.Nonce() || actual.Nonce()-expected.Nonce() > tol.Nonce {
+		add("nonce", fmt.Sprintf("%d (+%d)", expected.Nonce(), tol.Nonce), fmt.Sprintf("%d", actual.Nonce()))
+	}
+	if !WithinPercent(expected.GasPrice(), actual.GasPrice(), tol.GasPricePercent) {
+		add("gas_price", fmt.Sprintf("%s (±%g%%)", expected.GasPrice(), tol.GasPricePercent), actual.GasPrice().String())
+	}
+	expectedGas := new(big.Int).SetUint64(expected.Gas())
+	actualGas := new(big.Int).SetUint64(actual.Gas())
+	if !WithinPercent(expectedGas, actualGas, tol.GasLimitPercent) {
+		add("gas_limit", fmt.Sprintf("%d (±%g%%)", expected.Gas(), tol.GasLimitPercent), fmt.Sprintf("%d", actual.Gas()))
+	}
+
+	if len(mismatches) > 0 {
+		return &MismatchError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// WithinPercent reports whether actual deviates from expected by at most the
+// given percentage of expected, in either direction.
+func WithinPercent(expected, actual *big.Int, percent float64) bool {
+	diff := new(big.Int).Sub(actual, expected)
+	diff.Abs(diff)
+
+	// percentages are applied in basis points to stay in integer arithmetic
+	allowed := new(big.Int).Mul(expected, big.NewInt(int64(percent*100)))
+	allowed.Div(allowed, big.NewInt(10000))
+	return diff.Cmp(allowed) <= 0
+}
+
+// ReducedByPercent returns amount lowered by the given percentage.
+func ReducedByPercent(amount *big.Int, percent float64) *big.Int {
+	reduced := new(big.Int).Mul(amount, big.NewInt(10000-int64(percent*100)))
+	return reduced.Div(reduced, big.NewInt(10000))
+}
+
+// WithChainID returns the unsigned legacy transaction with the EIP-155 chain ID
+// encoded in V, the way proposals carry it.
+func WithChainID(tx *gtypes.Transaction, chainID *big.Int) *gtypes.Transaction {
+	v := new(big.Int).Mul(chainID, big.NewInt(2))
+	v.Add(v, big.NewInt(35))
+	return gtypes.NewTx(&gtypes.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		Gas:      tx.Gas(),
+		To:       tx.To(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+		V:        v,
+		R:        big.NewInt(0),
+		S:        big.NewInt(0),
+	})
+}
+
+func addressString(tx *gtypes.Transaction) string {
+	if tx.To() == nil {
+		return "<contract creation>"
+	}
+	return tx.To().Hex()
+}
@@ -0,0 +1,104 @@
+package reconstruct_test
+
+import (
+	"math/big"
+	"testing"
+
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+)
+
+var (
+	router   = gcommon.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	attacker = gcommon.HexToAddress("0x000000000000000000000000000000000000dEaD")
+)
+
+func legacyTx(chainID int64, nonce uint64, to gcommon.Address, gas uint64, gasPrice int64, data []byte) *gtypes.Transaction {
+	tx := gtypes.NewTransaction(nonce, to, big.NewInt(0), gas, big.NewInt(gasPrice), data)
+	return reconstruct.WithChainID(tx, big.NewInt(chainID))
+}
+
+func TestCompare(t *testing.T) {
+	tol := reconstruct.Tolerances{
+		GasPricePercent: 10,
+		GasLimitPercent: 20,
+		Nonce:           1,
+	}
+	expected := legacyTx(1, 5, router, 100000, 1000, []byte{0x01, 0x02})
+
+	tests := []struct {
+		name   string
+		actual *gtypes.Transaction
+		fields []string
+	}{
+		{
+			name:   "identical",
+			actual: legacyTx(1, 5, router, 100000, 1000, []byte{0x01, 0x02}),
+		},
+		{
+			name:   "within tolerances",
+			actual: legacyTx(1, 6, router, 120000, 900, []byte{0x01, 0x02}),
+		},
+		{
+			name:   "other chain",
+			actual: legacyTx(137, 5, router, 100000, 1000, []byte{0x01, 0x02}),
+			fields: []string{"chain_id"},
+		},
+		{
+			name:   "other recipient and data",
+			actual: legacyTx(1, 5, attacker, 100000, 1000, []byte{0x01, 0x03}),
+			fields: []string{"to", "data"},
+		},
+		{
+			name:   "replaces pending nonce",
+			actual: legacyTx(1, 4, router, 100000, 1000, []byte{0x01, 0x02}),
+			fields: []string{"nonce"},
+		},
+		{
+			name:   "nonce too far ahead",
+			actual: legacyTx(1, 7, router, 100000, 1000, []byte{0x01, 0x02}),
+			fields: []string{"nonce"},
+		},
+		{
+			name:   "gas out of tolerance",
+			actual: legacyTx(1, 5, router, 130000, 1200, []byte{0x01, 0x02}),
+			fields: []string{"gas_price", "gas_limit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reconstruct.Compare(expected, tt.actual, tol)
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var mismatchErr *reconstruct.MismatchError
+			require.ErrorAs(t, err, &mismatchErr)
+			var fields []string
+			for _, m := range mismatchErr.Mismatches {
+				fields = append(fields, m.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestCompareValue(t *testing.T) {
+	expected := legacyTx(1, 5, router, 100000, 1000, nil)
+	actual := reconstruct.WithChainID(gtypes.NewTransaction(5, router, big.NewInt(1), 100000, big.NewInt(1000), nil), big.NewInt(1))
+
+	var mismatchErr *reconstruct.MismatchError
+	require.ErrorAs(t, reconstruct.Compare(expected, actual, reconstruct.Tolerances{}), &mismatchErr)
+	assert.Equal(t, "value", mismatchErr.Mismatches[0].Field)
+}
+
+func TestReducedByPercent(t *testing.T) {
+	assert.Equal(t, big.NewInt(990), reconstruct.ReducedByPercent(big.NewInt(1000), 1))
+	assert.Equal(t, big.NewInt(1000), reconstruct.ReducedByPercent(big.NewInt(1000), 0))
+}
@@ -0,0 +1,291 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const defaultBatchSize = 500
+
+// Policy selects what a retention run changes. Only finished transactions are
+// touched, by how long ago they were last updated.
+type Policy struct {
+	// ArchiveAfter moves transactions of inactive or deleted policies to
+	// archives in block storage, zero keeps them
+	ArchiveAfter time.Duration
+	// PurgeAfter removes PurgeMetadataKeys from the metadata, zero keeps them
+	PurgeAfter        time.Duration
+	PurgeMetadataKeys []string
+	BatchSize         int
+	// DryRun runs everything in a transaction that is rolled back, without
+	// uploading archives
+	DryRun bool
+}
+
+// Report counts what a run changed, or would have in a dry run.
+type Report struct {
+	DryRun   bool  `json:"dry_run"`
+	Purged   int64 `json:"purged"`
+	Archived int64 `json:"archived"`
+	Archives int   `json:"archives"`
+}
+
+// Job applies the retention policy to transaction_history.
+type Job struct {
+	db     storage.DatabaseStorage
+	blocks storage.BlockStorage
+	policy Policy
+	logger *logrus.Logger
+}
+
+func NewJob(db storage.DatabaseStorage, blocks storage.BlockStorage, policy Policy, logger *logrus.Logger) *Job {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = defaultBatchSize
+	}
+	return &Job{
+		db:     db,
+		blocks: blocks,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// Run purges and archives in batches, committing each batch. The report counts
+// the committed batches when a later one fails.
+func (j *Job) Run(ctx context.Context, now time.Time) (Report, error) {
+	report := Report{DryRun: j.policy.DryRun}
+
+	// a dry run keeps a single transaction, so that each batch sees the
+	// changes of the previous ones
+	var dryRunTx storage.Tx
+	if j.policy.DryRun {
+		dbTx, err := j.db.BeginTx(ctx)
+		if err != nil {
+			return report, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer dbTx.Rollback(ctx)
+		dryRunTx = dbTx
+	}
+
+	if j.policy.PurgeAfter > 0 && len(j.policy.PurgeMetadataKeys) > 0 {
+		before := now.Add(-j.policy.PurgeAfter)
+		for {
+			var purged int64
+			err := j.inTx(ctx, dryRunTx, func(dbTx storage.Tx) error {
+				var err error
+				purged, err = j.db.PurgeTransactionMetadataTx(ctx, dbTx, before, j.policy.PurgeMetadataKeys, j.policy.BatchSize)
+				return err
+			})
+			if err != nil {
+				return report, err
+			}
+			report.Purged += purged
+			if purged < int64(j.policy.BatchSize) {
+				break
+			}
+		}
+	}
+
+	if j.policy.ArchiveAfter > 0 {
+		before := now.Add(-j.policy.ArchiveAfter)
+		for {
+			var archived int
+			err := j.inTx(ctx, dryRunTx, func(dbTx storage.Tx) error {
+				var err error
+				archived, err = j.archiveBatch(ctx, dbTx, before, now)
+				return err
+			})
+			if err != nil {
+				return report, err
+			}
+			if archived == 0 {
+				break
+			}
+			report.Archived += int64(archived)
+			report.Archives++
+			if archived < j.policy.BatchSize {
+				break
+			}
+		}
+	}
+
+	j.logger.WithFields(logrus.Fields{
+		"dry_run":  report.DryRun,
+		"purged":   report.Purged,
+		"archived": report.Archived,
+		"archives": report.Archives,
+	}).Info("Transaction retention finished")
+
+	return report, nil
+}
+
+// inTx runs fn in the dry run's transaction, or in a new one it commits.
+func (j *Job) inTx(ctx context.Context, dryRunTx storage.Tx, fn func(dbTx storage.Tx) error) error {
+	if dryRunTx != nil {
+		return fn(dryRunTx)
+	}
+
+	dbTx, err := j.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := fn(dbTx); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// archiveBatch uploads a batch of transactions to an archive before deleting
+// them. If the deletion isn't committed the archive is left behind and the
+// transactions are archived again by the next run.
+func (j *Job) archiveBatch(ctx context.Context, dbTx storage.Tx, before, now time.Time) (int, error) {
+	transactions, err := j.db.GetArchivableTransactionsTx(ctx, dbTx, before, j.policy.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(transactions) == 0 {
+		return 0, nil
+	}
+
+	archive := types.TransactionArchive{
+		ID:             uuid.New(),
+		Count:          len(transactions),
+		FirstCreatedAt: transactions[0].CreatedAt,
+		LastCreatedAt:  transactions[len(transactions)-1].CreatedAt,
+	}
+	archive.ObjectName = fmt.Sprintf("transaction-archives/%s/%s.jsonl.gz", now.UTC().Format("2006/01/02"), archive.ID)
+
+	if !j.policy.DryRun {
+		content, err := encodeArchive(transactions)
+		if err != nil {
+			return 0, err
+		}
+		if err := storage.UploadFileWithRetry(j.blocks, content, archive.ObjectName, 3); err != nil {
+			return 0, fmt.Errorf("failed to upload transaction archive: %w", err)
+		}
+	}
+
+	txIDs := make([]uuid.UUID, len(transactions))
+	for i, tx := range transactions {
+		txIDs[i] = tx.ID
+	}
+	if err := j.db.ArchiveTransactionsTx(ctx, dbTx, archive, txIDs, Aggregate(transactions)); err != nil {
+		return 0, err
+	}
+
+	return len(transactions), nil
+}
+
+// encodeArchive writes the transactions as gzip compressed JSON lines.
+func encodeArchive(transactions []types.ArchivedTransaction) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	for _, tx := range transactions {
+		if err := encoder.Encode(tx); err != nil {
+			return nil, fmt.Errorf("failed to encode archived transaction: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress transaction archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeArchive reads the transactions of an archive.
+func DecodeArchive(content []byte) ([]types.ArchivedTransaction, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress transaction archive: %w", err)
+	}
+	defer zr.Close()
+
+	var transactions []types.ArchivedTransaction
+	decoder := json.NewDecoder(zr)
+	for decoder.More() {
+		var tx types.ArchivedTransaction
+		if err := decoder.Decode(&tx); err != nil {
+			return nil, fmt.Errorf("failed to decode archived transaction: %w", err)
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// Aggregate sums the transactions by policy, day of creation in UTC, token,
+// type and status. Unknown amounts count as zero.
+func Aggregate(transactions []types.ArchivedTransaction) []types.TransactionAggregate {
+	type key struct {
+		policyID uuid.UUID
+		day      time.Time
+		chainID  string
+		token    string
+		txType   string
+		status   types.TransactionStatus
+	}
+	type sums struct {
+		count  int64
+		amount *big.Int
+		gasFee *big.Int
+	}
+
+	var keys []key
+	totals := make(map[key]*sums)
+	for _, tx := range transactions {
+		created := tx.CreatedAt.UTC()
+		k := key{
+			policyID: tx.PolicyID,
+			day:      time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC),
+			chainID:  tx.ChainID,
+			token:    tx.Token,
+			txType:   tx.TxType,
+			status:   tx.Status,
+		}
+		if totals[k] == nil {
+			totals[k] = &sums{amount: new(big.Int), gasFee: new(big.Int)}
+			keys = append(keys, k)
+		}
+		totals[k].count++
+		addDecimal(totals[k].amount, tx.Amount)
+		addDecimal(totals[k].gasFee, tx.GasFee)
+	}
+
+	aggregates := make([]types.TransactionAggregate, 0, len(keys))
+	for _, k := range keys {
+		aggregates = append(aggregates, types.TransactionAggregate{
+			PolicyID: k.policyID,
+			Day:      k.day,
+			ChainID:  k.chainID,
+			Token:    k.token,
+			TxType:   k.txType,
+			Status:   k.status,
+			Count:    totals[k].count,
+			Amount:   totals[k].amount.String(),
+			GasFee:   totals[k].gasFee.String(),
+		})
+	}
+	return aggregates
+}
+
+func addDecimal(sum *big.Int, value string) {
+	if amount, ok := new(big.Int).SetString(value, 10); ok && amount.Sign() >= 0 {
+		sum.Add(sum, amount)
+	}
+}
@@ -0,0 +1,221 @@
+package retention_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/retention"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+type fixture struct {
+	db       *memory.MemoryBackend
+	root     string
+	blocks   *storage.LocalBlockStorage
+	policyID uuid.UUID
+}
+
+func newFixture(t *testing.T, active bool) fixture {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	root := t.TempDir()
+	blocks, err := storage.NewLocalBlockStorage(root)
+	require.NoError(t, err)
+
+	policyID := uuid.New()
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, types.PluginPolicy{ID: policyID.String(), PublicKey: "vault", PluginType: "dca", Active: active})
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+
+	return fixture{db: db, root: root, blocks: blocks, policyID: policyID}
+}
+
+func (f fixture) createTransaction(t *testing.T, txHash string, status types.TransactionStatus, amount string) uuid.UUID {
+	ctx := context.Background()
+	txID, err := f.db.CreateTransactionHistory(ctx, types.TransactionHistory{
+		PolicyID: f.policyID,
+		TxHash:   txHash,
+		TxBody:   "0x" + txHash,
+		Status:   status,
+		ChainID:  "1",
+		Token:    "0xtoken",
+		TxType:   "SWAP",
+		Amount:   amount,
+		GasFee:   "10",
+		Metadata: map[string]interface{}{"recipient": "0xrecipient", "note": "kept"},
+	})
+	require.NoError(t, err)
+	return txID
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, false)
+	for i, hash := range []string{"a1", "a2", "a3"} {
+		f.createTransaction(t, hash, types.StatusMined, []string{"100", "200", "300"}[i])
+	}
+	failed := f.createTransaction(t, "f1", types.StatusSigningFailed, "")
+	pending := f.createTransaction(t, "p1", types.StatusPending, "50")
+
+	job := retention.NewJob(f.db, f.blocks, retention.Policy{
+		ArchiveAfter:      48 * time.Hour,
+		PurgeAfter:        24 * time.Hour,
+		PurgeMetadataKeys: []string{"recipient"},
+		BatchSize:         2,
+	}, logrus.New())
+
+	// a day later only the metadata is old enough to purge
+	report, err := job.Run(ctx, time.Now().Add(36*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, retention.Report{Purged: 4}, report)
+
+	tx, err := f.db.GetTransactionByHash(ctx, "f1")
+	require.NoError(t, err)
+	assert.Equal(t, failed, tx.ID)
+	assert.Equal(t, map[string]interface{}{"note": "kept"}, tx.Metadata)
+	tx, err = f.db.GetTransactionByHash(ctx, "p1")
+	require.NoError(t, err)
+	assert.Contains(t, tx.Metadata, "recipient", "unfinished transactions are left alone")
+
+	report, err = job.Run(ctx, time.Now().Add(72*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, retention.Report{Archived: 4, Archives: 2}, report)
+
+	for _, hash := range []string{"a1", "a2", "a3", "f1"} {
+		_, err := f.db.GetTransactionByHash(ctx, hash)
+		assert.Error(t, err, hash)
+	}
+	tx, err = f.db.GetTransactionByHash(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, pending, tx.ID)
+
+	aggregates, err := f.db.GetTransactionAggregates(ctx, f.policyID)
+	require.NoError(t, err)
+	require.Len(t, aggregates, 2)
+	assert.Equal(t, types.StatusMined, aggregates[0].Status)
+	assert.Equal(t, int64(3), aggregates[0].Count)
+	assert.Equal(t, "600", aggregates[0].Amount)
+	assert.Equal(t, "30", aggregates[0].GasFee)
+	assert.Equal(t, types.StatusSigningFailed, aggregates[1].Status)
+	assert.Equal(t, int64(1), aggregates[1].Count)
+	assert.Equal(t, "0", aggregates[1].Amount)
+}
+
+func TestRunArchives(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, false)
+	f.createTransaction(t, "a1", types.StatusMined, "100")
+	f.createTransaction(t, "a2", types.StatusRejected, "")
+
+	job := retention.NewJob(f.db, f.blocks, retention.Policy{ArchiveAfter: time.Hour}, logrus.New())
+	now := time.Now().Add(2 * time.Hour)
+	report, err := job.Run(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Archives)
+
+	// the object is named after the archive id, under the day of the run
+	dir := path.Join("transaction-archives", now.UTC().Format("2006/01/02"))
+	entries, err := os.ReadDir(filepath.Join(f.root, dir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasSuffix(entries[0].Name(), ".jsonl.gz"))
+	content, err := f.blocks.GetFile(path.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+
+	archived, err := retention.DecodeArchive(content)
+	require.NoError(t, err)
+	require.Len(t, archived, 2)
+	assert.Equal(t, "a1", archived[0].TxHash)
+	assert.Equal(t, "100", archived[0].Amount)
+	assert.Equal(t, "0xrecipient", archived[0].Metadata["recipient"])
+	assert.Equal(t, "a2", archived[1].TxHash)
+}
+
+func TestRunDryRun(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, false)
+	for _, hash := range []string{"a1", "a2", "a3"} {
+		f.createTransaction(t, hash, types.StatusMined, "100")
+	}
+
+	job := retention.NewJob(f.db, f.blocks, retention.Policy{
+		ArchiveAfter:      time.Hour,
+		PurgeAfter:        time.Hour,
+		PurgeMetadataKeys: []string{"recipient"},
+		BatchSize:         2,
+		DryRun:            true,
+	}, logrus.New())
+	report, err := job.Run(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, retention.Report{DryRun: true, Purged: 3, Archived: 3, Archives: 2}, report)
+
+	for _, hash := range []string{"a1", "a2", "a3"} {
+		tx, err := f.db.GetTransactionByHash(ctx, hash)
+		require.NoError(t, err)
+		assert.Contains(t, tx.Metadata, "recipient")
+	}
+	aggregates, err := f.db.GetTransactionAggregates(ctx, f.policyID)
+	require.NoError(t, err)
+	assert.Empty(t, aggregates)
+	exist, err := f.blocks.FileExist("transaction-archives")
+	require.NoError(t, err)
+	assert.False(t, exist, "nothing is uploaded")
+}
+
+func TestRunKeepsRunningPolicies(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, true)
+	for _, hash := range []string{"a1", "a2"} {
+		txID := f.createTransaction(t, hash, types.StatusMined, "100")
+		require.NoError(t, f.db.MarkTransactionSigned(ctx, txID, "SWAP"))
+		require.NoError(t, f.db.SetTransactionAttestation(ctx, txID, map[string]string{"tx_hash": hash}))
+	}
+
+	counts := func() (int64, int64) {
+		mined, err := f.db.CountTransactions(ctx, f.policyID, types.StatusMined, "SWAP")
+		require.NoError(t, err)
+		signed, err := f.db.CountSignedTransactions(ctx, f.policyID, "SWAP", "")
+		require.NoError(t, err)
+		return mined, signed
+	}
+
+	job := retention.NewJob(f.db, f.blocks, retention.Policy{ArchiveAfter: time.Hour}, logrus.New())
+	report, err := job.Run(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, retention.Report{}, report)
+
+	// the orders of a running policy are still counted
+	mined, signed := counts()
+	assert.Equal(t, int64(2), mined)
+	assert.Equal(t, int64(2), signed)
+
+	// once the policy stops its transactions are archived
+	dbTx, err := f.db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = f.db.UpdatePluginPolicyTx(ctx, dbTx, types.PluginPolicy{ID: f.policyID.String(), PublicKey: "vault", PluginType: "dca", Active: false})
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+
+	report, err = job.Run(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, retention.Report{Archived: 2, Archives: 1}, report)
+
+	// their attestations are still served
+	attestation, err := f.db.GetTransactionAttestation(ctx, "a1")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx_hash":"a1"}`, string(attestation))
+}
@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// Window is the period the daily limits are counted over.
+const Window = 24 * time.Hour
+
+// Request is a transaction the verifier was asked to sign.
+type Request struct {
+	PublicKey  string
+	PluginType string
+	Spend      Spend
+}
+
+// HistoryEntry is a transaction the verifier signed for the vault within the
+// window.
+type HistoryEntry struct {
+	PluginType string
+	Spend      Spend
+}
+
+type Decision struct {
+	Allowed bool
+	Reasons []string
+	RuleIDs []string
+}
+
+// Evaluate checks the request against every active rule matching the vault,
+// plugin and chain. Daily limits add the request to the vault's history, only
+// counting the rule's plugin type when it has one. Amounts are compared in
+// base units of the rule's token, there is no conversion between tokens.
+func Evaluate(rules []types.SpendingRule, req Request, history []HistoryEntry) Decision {
+	decision := Decision{Allowed: true, Reasons: []string{}, RuleIDs: []string{}}
+
+	for _, rule := range rules {
+		if !applies(rule, req) {
+			continue
+		}
+		decision.RuleIDs = append(decision.RuleIDs, rule.ID.String())
+
+		violations := check(rule, req, history)
+		if len(violations) == 0 {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("rule %s: within limits", rule.ID))
+			continue
+		}
+		decision.Allowed = false
+		for _, violation := range violations {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("rule %s: %s", rule.ID, violation))
+		}
+	}
+
+	if len(decision.RuleIDs) == 0 {
+		decision.Reasons = append(decision.Reasons, "no matching rules")
+	}
+
+	return decision
+}
+
+func applies(rule types.SpendingRule, req Request) bool {
+	return rule.Active &&
+		(rule.PublicKey == "" || rule.PublicKey == req.PublicKey) &&
+		(rule.PluginType == "" || rule.PluginType == req.PluginType) &&
+		(rule.ChainID == "" || rule.ChainID == req.Spend.ChainID)
+}
+
+func check(rule types.SpendingRule, req Request, history []HistoryEntry) []string {
+	var violations []string
+	spend := req.Spend
+
+	if len(rule.AllowedContracts) > 0 && !containsFold(rule.AllowedContracts, spend.Contract) {
+		violations = append(violations, fmt.Sprintf("contract %s is not allowed", spend.Contract))
+	}
+	if len(rule.AllowedTokens) > 0 && !containsFold(rule.AllowedTokens, spend.Token) {
+		violations = append(violations, fmt.Sprintf("token %s is not allowed", spend.Token))
+	}
+
+	// history of the vault the rule aggregates over
+	var scoped []Spend
+	for _, entry := range history {
+		if rule.PluginType != "" && entry.PluginType != rule.PluginType {
+			continue
+		}
+		if rule.ChainID != "" && entry.Spend.ChainID != rule.ChainID {
+			continue
+		}
+		scoped = append(scoped, entry.Spend)
+	}
+
+	if rule.MaxTransactionsPerDay > 0 && int64(len(scoped))+1 > rule.MaxTransactionsPerDay {
+		violations = append(violations, fmt.Sprintf("more than %d transactions per day", rule.MaxTransactionsPerDay))
+	}
+
+	if !strings.EqualFold(rule.Token, spend.Token) {
+		return violations
+	}
+
+	// an approval moves nothing until the swap or transfer that spends it,
+	// which is counted then, so it is only held to the allowance limit
+	if spend.Approval {
+		if limit, ok := parseLimit(rule.MaxAllowance); ok && spend.Amount.Cmp(limit) > 0 {
+			violations = append(violations, fmt.Sprintf("allowance %s exceeds %s base units of %s", spend.Amount, limit, rule.Token))
+		}
+		return violations
+	}
+
+	if limit, ok := parseLimit(rule.MaxAmountPerTx); ok && spend.Amount.Cmp(limit) > 0 {
+		violations = append(violations, fmt.Sprintf("amount %s exceeds %s base units of %s per transaction", spend.Amount, limit, rule.Token))
+	}
+
+	spentToday := new(big.Int).Set(spend.Amount)
+	spentToRecipient := new(big.Int).Set(spend.Amount)
+	for _, previous := range scoped {
+		if previous.Approval || !strings.EqualFold(previous.Token, rule.Token) {
+			continue
+		}
+		spentToday.Add(spentToday, previous.Amount)
+		if strings.EqualFold(previous.Recipient, spend.Recipient) {
+			spentToRecipient.Add(spentToRecipient, previous.Amount)
+		}
+	}
+	if limit, ok := parseLimit(rule.MaxAmountPerDay); ok && spentToday.Cmp(limit) > 0 {
+		violations = append(violations, fmt.Sprintf("%s spent per day exceeds %s base units of %s", spentToday, limit, rule.Token))
+	}
+	if limit, ok := parseLimit(rule.MaxAmountPerRecipientPerDay); ok && spentToRecipient.Cmp(limit) > 0 {
+		violations = append(violations, fmt.Sprintf("%s sent to %s per day exceeds %s base units of %s", spentToRecipient, spend.Recipient, limit, rule.Token))
+	}
+
+	return violations
+}
+
+func parseLimit(value string) (*big.Int, bool) {
+	if value == "" {
+		return nil, false
+	}
+	return new(big.Int).SetString(value, 10)
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
@@ -0,0 +1,218 @@
+package rules_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/rules"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+const transferABI = `[{"name":"transfer","type":"function","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},{"name":"approve","type":"function","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+var (
+	usdc  = "0x00000000000000000000000000000000000000bb"
+	alice = "0x00000000000000000000000000000000000000aa"
+	bob   = "0x00000000000000000000000000000000000000cc"
+)
+
+func transferTx(t *testing.T, token, recipient string, amount int64) *gtypes.Transaction {
+	return tokenTx(t, "transfer", token, recipient, amount)
+}
+
+func tokenTx(t *testing.T, method, token, recipient string, amount int64) *gtypes.Transaction {
+	parsedABI, err := abi.JSON(strings.NewReader(transferABI))
+	require.NoError(t, err)
+	data, err := parsedABI.Pack(method, gcommon.HexToAddress(recipient), big.NewInt(amount))
+	require.NoError(t, err)
+
+	return gtypes.NewTx(&gtypes.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(1),
+		Gas:      60000,
+		To:       ptr(gcommon.HexToAddress(token)),
+		Value:    big.NewInt(0),
+		Data:     data,
+		V:        big.NewInt(1*2 + 35),
+		R:        big.NewInt(0),
+		S:        big.NewInt(0),
+	})
+}
+
+func ptr(address gcommon.Address) *gcommon.Address {
+	return &address
+}
+
+func spend(token, recipient string, amount int64) rules.Spend {
+	return rules.Spend{ChainID: "1", Contract: token, Token: token, Recipient: recipient, Amount: big.NewInt(amount)}
+}
+
+func approval(token, spender string, amount int64) rules.Spend {
+	s := spend(token, spender, amount)
+	s.Approval = true
+	return s
+}
+
+func TestExtractSpend(t *testing.T) {
+	s := rules.ExtractSpend(transferTx(t, usdc, alice, 500))
+	assert.Equal(t, spend(usdc, alice, 500), s)
+
+	s = rules.ExtractSpend(tokenTx(t, "approve", usdc, bob, 900))
+	assert.Equal(t, approval(usdc, bob, 900), s)
+
+	native := gtypes.NewTx(&gtypes.LegacyTx{
+		To:    ptr(gcommon.HexToAddress(bob)),
+		Value: big.NewInt(7),
+		V:     big.NewInt(1*2 + 35),
+		R:     big.NewInt(0),
+		S:     big.NewInt(0),
+	})
+	s = rules.ExtractSpend(native)
+	assert.Equal(t, types.NativeToken, s.Token)
+	assert.Equal(t, bob, s.Recipient)
+	assert.Equal(t, big.NewInt(7), s.Amount)
+}
+
+func TestEvaluate(t *testing.T) {
+	vault := "vault-key"
+	rule := types.SpendingRule{
+		ID:                          uuid.New(),
+		PublicKey:                   vault,
+		Token:                       usdc,
+		MaxAmountPerTx:              "1000",
+		MaxAmountPerDay:             "1500",
+		MaxAmountPerRecipientPerDay: "1200",
+		MaxAllowance:                "2000",
+		Active:                      true,
+	}
+
+	tests := []struct {
+		name    string
+		rules   []types.SpendingRule
+		spend   rules.Spend
+		history []rules.HistoryEntry
+		allowed bool
+		reasons int
+	}{
+		{
+			name:    "no rules",
+			spend:   spend(usdc, alice, 5000),
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "within limits",
+			rules:   []types.SpendingRule{rule},
+			spend:   spend(usdc, alice, 1000),
+			history: []rules.HistoryEntry{{PluginType: "dca", Spend: spend(usdc, bob, 400)}},
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "amount per transaction",
+			rules:   []types.SpendingRule{rule},
+			spend:   spend(usdc, alice, 1001),
+			allowed: false,
+			reasons: 1,
+		},
+		{
+			name:    "amount per day across plugins",
+			rules:   []types.SpendingRule{rule},
+			spend:   spend(usdc, alice, 600),
+			history: []rules.HistoryEntry{{PluginType: "payroll", Spend: spend(usdc, bob, 1000)}},
+			allowed: false,
+			reasons: 1,
+		},
+		{
+			name:    "amount per recipient",
+			rules:   []types.SpendingRule{rule},
+			spend:   spend(usdc, alice, 600),
+			history: []rules.HistoryEntry{{PluginType: "dca", Spend: spend(usdc, alice, 700)}},
+			allowed: false,
+			reasons: 1,
+		},
+		{
+			name:    "approval within allowance",
+			rules:   []types.SpendingRule{rule},
+			spend:   approval(usdc, bob, 2000),
+			history: []rules.HistoryEntry{{PluginType: "dca", Spend: spend(usdc, alice, 1000)}},
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "approval above allowance",
+			rules:   []types.SpendingRule{rule},
+			spend:   approval(usdc, bob, 2001),
+			allowed: false,
+			reasons: 1,
+		},
+		{
+			name:    "approvals are not spent",
+			rules:   []types.SpendingRule{rule},
+			spend:   spend(usdc, bob, 1000),
+			history: []rules.HistoryEntry{{PluginType: "dca", Spend: approval(usdc, bob, 1000)}},
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "other vault",
+			rules:   []types.SpendingRule{{ID: uuid.New(), PublicKey: "other", Token: usdc, MaxAmountPerTx: "1", Active: true}},
+			spend:   spend(usdc, alice, 600),
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "inactive rule",
+			rules:   []types.SpendingRule{{ID: uuid.New(), Token: usdc, MaxAmountPerTx: "1"}},
+			spend:   spend(usdc, alice, 600),
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name: "plugin scoped history",
+			rules: []types.SpendingRule{{
+				ID: uuid.New(), PluginType: "dca", Token: usdc, MaxAmountPerDay: "1000", MaxTransactionsPerDay: 2, Active: true,
+			}},
+			spend: spend(usdc, alice, 600),
+			history: []rules.HistoryEntry{
+				{PluginType: "payroll", Spend: spend(usdc, alice, 900)},
+				{PluginType: "dca", Spend: spend(usdc, alice, 300)},
+			},
+			allowed: true,
+			reasons: 1,
+		},
+		{
+			name:    "velocity",
+			rules:   []types.SpendingRule{{ID: uuid.New(), MaxTransactionsPerDay: 1, Active: true}},
+			spend:   spend(usdc, alice, 1),
+			history: []rules.HistoryEntry{{PluginType: "dca", Spend: spend(usdc, alice, 1)}},
+			allowed: false,
+			reasons: 1,
+		},
+		{
+			name: "allowed tokens and contracts",
+			rules: []types.SpendingRule{{
+				ID: uuid.New(), AllowedTokens: []string{types.NativeToken}, AllowedContracts: []string{bob}, Active: true,
+			}},
+			spend:   spend(usdc, alice, 1),
+			allowed: false,
+			reasons: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := rules.Evaluate(tt.rules, rules.Request{PublicKey: vault, PluginType: "dca", Spend: tt.spend}, tt.history)
+			assert.Equal(t, tt.allowed, decision.Allowed, decision.Reasons)
+			assert.Len(t, decision.Reasons, tt.reasons)
+		})
+	}
+}
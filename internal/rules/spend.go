@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"math/big"
+	"strings"
+
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// Spend is what a transaction takes out of the vault. Addresses are lower
+// case hex, the native coin is types.NativeToken. Amounts are in base units of
+// Token. Approval marks an allowance granted to Recipient, which moves nothing
+// by itself.
+type Spend struct {
+	ChainID   string
+	Contract  string
+	Token     string
+	Recipient string
+	Amount    *big.Int
+	Approval  bool
+}
+
+// ExtractSpend decodes the transaction into the token, amount and recipient
+// it moves. Swaps are spends of the input amount and unknown calls only spend
+// their value. Approvals are extracted with the approved amount and spender,
+// they are spent by the transfer or swap that follows.
+func ExtractSpend(tx *gtypes.Transaction) Spend {
+	spend := Spend{
+		ChainID: tx.ChainId().String(),
+		Token:   types.NativeToken,
+		Amount:  new(big.Int).Set(tx.Value()),
+	}
+	if tx.To() != nil {
+		spend.Contract = strings.ToLower(tx.To().Hex())
+		spend.Recipient = spend.Contract
+	}
+
+	method, args, err := txdecoder.DecodeCallData(tx.Data())
+	if err != nil {
+		return spend
+	}
+
+	switch method {
+	case "transfer", "transferFrom":
+		spend.Token = spend.Contract
+		spend.Recipient = stringArg(args, "recipient")
+		spend.Amount = bigArg(args, "amount")
+	case "approve":
+		spend.Token = spend.Contract
+		spend.Recipient = stringArg(args, "spender")
+		spend.Amount = bigArg(args, "amount")
+		spend.Approval = true
+	case "swapExactTokensForTokens", "swapExactTokensForETH":
+		if path, ok := args["path"].([]string); ok && len(path) > 0 {
+			spend.Token = strings.ToLower(path[0])
+		}
+		spend.Recipient = stringArg(args, "to")
+		spend.Amount = bigArg(args, "amountIn")
+	case "swapExactETHForTokens":
+		spend.Recipient = stringArg(args, "to")
+	}
+
+	return spend
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return strings.ToLower(value)
+}
+
+func bigArg(args map[string]interface{}, name string) *big.Int {
+	value, _ := args[name].(string)
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return amount
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+const MaxPreviewCount = 50
+
+// PreviewSchedule returns the next count execution times of a policy with the
+// given schedule created at createdAt. It follows the scheduler: the first run
+// happens when the policy is created and every following run is computed from
+// the previous one with createSchedule.
+func PreviewSchedule(schedule types.Schedule, createdAt time.Time, count int) ([]time.Time, error) {
+	if count < 1 || count > MaxPreviewCount {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxPreviewCount)
+	}
+
+	startTime, err := time.Parse(time.RFC3339, schedule.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start time: %w", err)
+	}
+
+	interval, err := strconv.Atoi(schedule.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %w", err)
+	}
+	if interval < 1 {
+		return nil, fmt.Errorf("interval must be at least 1")
+	}
+
+	var endTime *time.Time
+	if schedule.EndTime != "" {
+		t, err := time.Parse(time.RFC3339, schedule.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse end time: %w", err)
+		}
+		endTime = &t
+	}
+
+	createdAt = createdAt.UTC()
+	cronExpr := frequencyToCron(schedule.Frequency, startTime, interval)
+	cronSchedule, err := createSchedule(cronExpr, schedule.Frequency, createdAt, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	times := make([]time.Time, 0, count)
+	next := createdAt
+	for len(times) < count {
+		if endTime != nil && next.After(*endTime) {
+			break
+		}
+		times = append(times, next)
+
+		following := cronSchedule.Next(next).UTC()
+		if !following.After(next) {
+			return nil, fmt.Errorf("schedule does not advance after %s", next)
+		}
+		next = following
+	}
+
+	return times, nil
+}
@@ -7,8 +7,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-
 	"github.com/hibiken/asynq"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
@@ -120,27 +118,30 @@ func (s *SchedulerService) checkAndEnqueueTasks() error {
 			continue
 		}
 
-		triggerStatus, err := s.db.GetTriggerStatus(ctx, trigger.PolicyID)
-		if err != nil {
-			s.logger.Errorf("Failed to get trigger status: %v", err)
-			continue
-		}
-
-		if time.Now().UTC().Before(nextTime) || triggerStatus == types.StatusTimeTriggerRunning {
+		if time.Now().UTC().Before(nextTime) {
 			s.logger.WithFields(logrus.Fields{
 				"policy_id": trigger.PolicyID,
 				"next_time": nextTime,
-				"state":     triggerStatus,
-			}).Info("Trigger have not reached next time or it's in running status")
+			}).Info("Trigger have not reached next time")
 			continue
 		}
 
-		if err := s.db.UpdateTriggerStatus(ctx, trigger.PolicyID, types.StatusTimeTriggerRunning); err != nil {
+		// the trigger may have been claimed by a manual run since it was listed
+		claimed, err := s.db.ClaimTimeTrigger(ctx, trigger.PolicyID)
+		if err != nil {
 			s.logger.Errorf("Failed to update trigger status: %v", err)
 			continue
 		}
+		if !claimed {
+			s.logger.WithField("policy_id", trigger.PolicyID).Info("Trigger is in running status")
+			continue
+		}
 
-		buf, err := json.Marshal(trigger)
+		buf, err := json.Marshal(types.PluginTriggerEvent{
+			PolicyID:      trigger.PolicyID,
+			TriggerSource: types.TriggerSourceTime,
+			TriggeredAt:   time.Now().UTC(),
+		})
 		if err != nil {
 			s.logger.Errorf("Failed to marshal trigger event: %v", err)
 			continue
@@ -167,7 +168,7 @@ func (s *SchedulerService) checkAndEnqueueTasks() error {
 	return nil
 }
 
-func (s *SchedulerService) CreateTimeTrigger(ctx context.Context, policy types.PluginPolicy, dbTx pgx.Tx) error {
+func (s *SchedulerService) CreateTimeTrigger(ctx context.Context, policy types.PluginPolicy, dbTx storage.Tx) error {
 	if s.db == nil {
 		return fmt.Errorf("database backend is nil")
 	}
@@ -393,17 +394,29 @@ func (s *IntervalSchedule) nextMonthly(t time.Time) time.Time {
 		nextIntervalMonth = lastIntervalMonth + s.Interval
 	}
 
+	candidate := s.monthlyCandidate(nextIntervalMonth)
+
+	// A candidate clamped to the end of a shorter month can be at or before t,
+	// in which case the next interval month is the next execution
+	if !candidate.After(t) {
+		candidate = s.monthlyCandidate(nextIntervalMonth + s.Interval)
+	}
+
+	return candidate
+}
+
+func (s *IntervalSchedule) monthlyCandidate(months int) time.Time {
 	// Convert back to year and month
-	nextYear := nextIntervalMonth / 12
-	nextMonth := time.Month(nextIntervalMonth%12 + 1)
+	year := months / 12
+	month := time.Month(months%12 + 1)
 
 	// Create the candidate time
-	candidate := time.Date(nextYear, nextMonth, s.Day, s.Hour, s.Minute, 0, 0, s.Location)
+	candidate := time.Date(year, month, s.Day, s.Hour, s.Minute, 0, 0, s.Location)
 
 	// Handle months with fewer days than our target day
 	if candidate.Day() != s.Day {
 		// We got bumped to the next month due to day overflow, go back to last day of previous month
-		candidate = time.Date(nextYear, nextMonth, 0, s.Hour, s.Minute, 0, 0, s.Location)
+		candidate = time.Date(year, month+1, 0, s.Hour, s.Minute, 0, 0, s.Location)
 	}
 
 	return candidate
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+func TestPreviewSchedule(t *testing.T) {
+	// Friday
+	createdAt := time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC)
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name     string
+		schedule types.Schedule
+		count    int
+		expected []time.Time
+	}{
+		{
+			name:     "Daily",
+			schedule: types.Schedule{Frequency: "daily", Interval: "1", StartTime: "2025-01-31T09:30:00Z"},
+			count:    3,
+			expected: []time.Time{createdAt, date(time.February, 1, 9, 30), date(time.February, 2, 9, 30)},
+		},
+		{
+			name:     "Every two hours",
+			schedule: types.Schedule{Frequency: "hourly", Interval: "2", StartTime: "2025-01-31T09:15:00Z"},
+			count:    3,
+			expected: []time.Time{createdAt, date(time.January, 31, 10, 15), date(time.January, 31, 12, 15)},
+		},
+		{
+			name:     "Every three weeks",
+			schedule: types.Schedule{Frequency: "weekly", Interval: "3", StartTime: "2025-01-31T10:00:00Z"},
+			count:    3,
+			expected: []time.Time{createdAt, date(time.February, 21, 10, 0), date(time.March, 14, 10, 0)},
+		},
+		{
+			name:     "Every three months from the end of a month",
+			schedule: types.Schedule{Frequency: "monthly", Interval: "3", StartTime: "2025-01-31T10:00:00Z"},
+			count:    4,
+			expected: []time.Time{createdAt, date(time.April, 30, 10, 0), date(time.July, 31, 10, 0), date(time.October, 31, 10, 0)},
+		},
+		{
+			name:     "Stops at end time",
+			schedule: types.Schedule{Frequency: "daily", Interval: "1", StartTime: "2025-01-31T09:30:00Z", EndTime: "2025-02-01T12:00:00Z"},
+			count:    5,
+			expected: []time.Time{createdAt, date(time.February, 1, 9, 30)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			times, err := PreviewSchedule(tc.schedule, createdAt, tc.count)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, times)
+		})
+	}
+}
+
+func TestPreviewScheduleInvalid(t *testing.T) {
+	createdAt := time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		schedule types.Schedule
+		count    int
+	}{
+		{
+			name:     "Unknown frequency",
+			schedule: types.Schedule{Frequency: "yearly", Interval: "1", StartTime: "2025-01-31T10:00:00Z"},
+			count:    1,
+		},
+		{
+			name:     "Invalid interval",
+			schedule: types.Schedule{Frequency: "daily", Interval: "0", StartTime: "2025-01-31T10:00:00Z"},
+			count:    1,
+		},
+		{
+			name:     "Missing start time",
+			schedule: types.Schedule{Frequency: "daily", Interval: "1"},
+			count:    1,
+		},
+		{
+			name:     "Count out of range",
+			schedule: types.Schedule{Frequency: "daily", Interval: "1", StartTime: "2025-01-31T10:00:00Z"},
+			count:    MaxPreviewCount + 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := PreviewSchedule(tc.schedule, createdAt, tc.count)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExecutionWindow(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schedule types.Schedule
+		expected time.Duration
+	}{
+		{
+			name:     "Every minute",
+			schedule: types.Schedule{Frequency: "minutely", Interval: "1"},
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "Every two days",
+			schedule: types.Schedule{Frequency: "daily", Interval: "2"},
+			expected: 48*time.Hour - 5*time.Minute,
+		},
+		{
+			name:     "Monthly",
+			schedule: types.Schedule{Frequency: "monthly", Interval: "1"},
+			expected: 28*24*time.Hour - 5*time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := ExecutionWindow(tc.schedule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, window)
+		})
+	}
+
+	_, err := ExecutionWindow(types.Schedule{Frequency: "yearly", Interval: "1"})
+	assert.Error(t, err)
+}
+
+func TestCreateTimeTrigger(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	s := &SchedulerService{db: db}
+
+	policy := types.PluginPolicy{
+		ID:         uuid.NewString(),
+		PublicKey:  "vault",
+		PluginType: "dca",
+		Active:     true,
+		Policy:     json.RawMessage(`{"schedule":{"frequency":"daily","interval":"1","start_time":"2025-01-31T09:30:00Z"}}`),
+	}
+
+	// the trigger is created in the policy's transaction
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateTimeTrigger(ctx, policy, dbTx))
+	require.NoError(t, dbTx.Rollback(ctx))
+
+	triggers, err := db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, triggers)
+
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateTimeTrigger(ctx, policy, dbTx))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	triggers, err = db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	require.Len(t, triggers, 1)
+	assert.Equal(t, policy.ID, triggers[0].PolicyID)
+	assert.Equal(t, "daily", triggers[0].Frequency)
+
+	// running triggers aren't pending
+	require.NoError(t, db.UpdateTriggerStatus(ctx, policy.ID, types.StatusTimeTriggerRunning))
+	triggers, err = db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, triggers)
+}
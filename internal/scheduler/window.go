@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// ExecutionWindow returns the shortest time expected between two runs of a
+// policy with the given schedule, minus a tolerance for scheduler jitter.
+// Months are counted as 28 days so that the window never exceeds a real month.
+func ExecutionWindow(schedule types.Schedule) (time.Duration, error) {
+	interval, err := strconv.Atoi(schedule.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse interval: %w", err)
+	}
+	if interval < 1 {
+		return 0, fmt.Errorf("interval must be at least 1")
+	}
+
+	var period time.Duration
+	switch schedule.Frequency {
+	case "minutely":
+		period = time.Minute
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 28 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("unsupported frequency: %s", schedule.Frequency)
+	}
+	period *= time.Duration(interval)
+
+	return period - min(period/2, 5*time.Minute), nil
+}
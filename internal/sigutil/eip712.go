@@ -0,0 +1,151 @@
+package sigutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// Actions a vault signs a policy for.
+const (
+	PolicyActionCreate = "create"
+	PolicyActionUpdate = "update"
+	PolicyActionDelete = "delete"
+)
+
+const (
+	policyDomainName    = "Vultisig Plugin Policy"
+	policyDomainVersion = "1"
+	policyPrimaryType   = "PolicyAction"
+)
+
+var ErrPolicySignatureExpired = errors.New("policy signature expired")
+
+var policyTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+	},
+	policyPrimaryType: {
+		{Name: "action", Type: "string"},
+		{Name: "policyId", Type: "string"},
+		{Name: "publicKey", Type: "string"},
+		{Name: "chainCodeHex", Type: "string"},
+		{Name: "derivePath", Type: "string"},
+		{Name: "pluginId", Type: "string"},
+		{Name: "pluginType", Type: "string"},
+		{Name: "pluginVersion", Type: "string"},
+		{Name: "policyVersion", Type: "string"},
+		{Name: "policyHash", Type: "bytes32"},
+		{Name: "active", Type: "bool"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "expiry", Type: "uint256"},
+	},
+}
+
+// PolicyTypedData returns the EIP-712 typed data the vault signs for an action
+// on the policy. Policies are created without an ID, the server assigns it.
+func PolicyTypedData(policy types.PluginPolicy, action string) (apitypes.TypedData, error) {
+	policyID := policy.ID
+	if action == PolicyActionCreate {
+		policyID = ""
+	}
+
+	policyHash, err := PolicyDocumentHash(policy.Policy)
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+
+	return apitypes.TypedData{
+		Types:       policyTypes,
+		PrimaryType: policyPrimaryType,
+		Domain: apitypes.TypedDataDomain{
+			Name:    policyDomainName,
+			Version: policyDomainVersion,
+		},
+		Message: apitypes.TypedDataMessage{
+			"action":        action,
+			"policyId":      policyID,
+			"publicKey":     policy.PublicKey,
+			"chainCodeHex":  policy.ChainCodeHex,
+			"derivePath":    policy.DerivePath,
+			"pluginId":      policy.PluginID,
+			"pluginType":    policy.PluginType,
+			"pluginVersion": policy.PluginVersion,
+			"policyVersion": policy.PolicyVersion,
+			"policyHash":    hexutil.Encode(policyHash),
+			"active":        policy.Active,
+			"nonce":         policy.Nonce,
+			"expiry":        fmt.Sprintf("%d", policy.Expiry),
+		},
+	}, nil
+}
+
+// PolicyDocumentHash is the keccak256 of the policy document in canonical JSON:
+// object keys sorted, no insignificant whitespace, numbers and strings as
+// submitted and without HTML escaping.
+func PolicyDocumentHash(raw json.RawMessage) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode policy document: %w", err)
+	}
+
+	var canonical bytes.Buffer
+	encoder := json.NewEncoder(&canonical)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode policy document: %w", err)
+	}
+
+	return crypto.Keccak256(bytes.TrimSuffix(canonical.Bytes(), []byte("\n"))), nil
+}
+
+// VerifyPolicyAction checks the EIP-712 signature of an action on the policy.
+// ECDSA vault keys sign the digest as eth_signTypedData_v4 does, EdDSA keys
+// sign the same 32 byte digest with Ed25519. Expiry and nonce reuse are left to the caller, as they depend on its clock
+// and records.
+func VerifyPolicyAction(policy types.PluginPolicy, action string) (bool, error) {
+	if policy.Nonce == "" {
+		return false, fmt.Errorf("policy signature nonce is required")
+	}
+
+	typedData, err := PolicyTypedData(policy, action)
+	if err != nil {
+		return false, err
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(policy.Signature, "0x"))
+	if err != nil {
+		return false, fmt.Errorf("failed to decode signature bytes: %w", err)
+	}
+
+	return VerifyVaultSignature(policy.IsEcdsa, policy.PublicKey, policy.ChainCodeHex, policy.DerivePath, hash, signatureBytes)
+}
+
+// CheckPolicySignatureExpiry rejects signatures whose expiry passed more than
+// grace ago.
+func CheckPolicySignatureExpiry(expiry int64, now time.Time, grace time.Duration) error {
+	if expiry <= 0 {
+		return fmt.Errorf("policy signature expiry is required")
+	}
+	if now.After(time.Unix(expiry, 0).Add(grace)) {
+		return ErrPolicySignatureExpired
+	}
+	return nil
+}
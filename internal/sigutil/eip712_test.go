@@ -0,0 +1,116 @@
+package sigutil_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func TestPolicyDocumentHash(t *testing.T) {
+	hash, err := sigutil.PolicyDocumentHash(json.RawMessage(`{"b": 1.50, "a": {"d": "<x>", "c": [2, 1]}}`))
+	require.NoError(t, err)
+
+	canonical, err := sigutil.PolicyDocumentHash(json.RawMessage(`{"a":{"c":[2,1],"d":"<x>"},"b":1.50}`))
+	require.NoError(t, err)
+	assert.Equal(t, canonical, hash)
+
+	reordered, err := sigutil.PolicyDocumentHash(json.RawMessage(`{"a":{"c":[1,2],"d":"<x>"},"b":1.50}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, canonical, reordered)
+
+	_, err = sigutil.PolicyDocumentHash(json.RawMessage(`{`))
+	assert.Error(t, err)
+}
+
+func TestPolicyTypedDataHash(t *testing.T) {
+	policy := types.PluginPolicy{
+		ID:            "policy-1",
+		PublicKey:     "vault-key",
+		ChainCodeHex:  "chain-code",
+		DerivePath:    "m/44'/60'/0'/0/0",
+		PluginID:      "dca",
+		PluginType:    "dca",
+		PluginVersion: "1",
+		PolicyVersion: "1",
+		Nonce:         "12345",
+		Expiry:        1700000000,
+		Policy:        json.RawMessage(`{"chain_id":"1"}`),
+		Active:        true,
+	}
+
+	hash := func(policy types.PluginPolicy, action string) []byte {
+		typedData, err := sigutil.PolicyTypedData(policy, action)
+		require.NoError(t, err)
+		digest, _, err := apitypes.TypedDataAndHash(typedData)
+		require.NoError(t, err)
+		return digest
+	}
+
+	update := hash(policy, sigutil.PolicyActionUpdate)
+	assert.Equal(t, update, hash(policy, sigutil.PolicyActionUpdate))
+	assert.NotEqual(t, update, hash(policy, sigutil.PolicyActionDelete))
+
+	otherNonce := policy
+	otherNonce.Nonce = "12346"
+	assert.NotEqual(t, update, hash(otherNonce, sigutil.PolicyActionUpdate))
+
+	// the ID is assigned after creation, so it isn't signed
+	otherID := policy
+	otherID.ID = "policy-2"
+	assert.Equal(t, hash(policy, sigutil.PolicyActionCreate), hash(otherID, sigutil.PolicyActionCreate))
+	assert.NotEqual(t, update, hash(otherID, sigutil.PolicyActionUpdate))
+}
+
+func TestCheckPolicySignatureExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	assert.NoError(t, sigutil.CheckPolicySignatureExpiry(now.Add(time.Minute).Unix(), now, 0))
+	assert.ErrorIs(t, sigutil.CheckPolicySignatureExpiry(now.Add(-time.Minute).Unix(), now, 0), sigutil.ErrPolicySignatureExpired)
+	assert.NoError(t, sigutil.CheckPolicySignatureExpiry(now.Add(-time.Minute).Unix(), now, time.Hour))
+	assert.Error(t, sigutil.CheckPolicySignatureExpiry(0, now, time.Hour))
+}
+
+func TestVerifyPolicyActionEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	policy := types.PluginPolicy{
+		ID:        "policy-1",
+		PublicKey: hex.EncodeToString(publicKey),
+		IsEcdsa:   false,
+		PluginID:  "solana-dca",
+		Nonce:     "1",
+		Expiry:    1700000000,
+		Policy:    json.RawMessage(`{}`),
+	}
+
+	typedData, err := sigutil.PolicyTypedData(policy, sigutil.PolicyActionDelete)
+	require.NoError(t, err)
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	policy.Signature = hex.EncodeToString(ed25519.Sign(privateKey, digest))
+
+	ok, err := sigutil.VerifyPolicyAction(policy, sigutil.PolicyActionDelete)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = sigutil.VerifyPolicyAction(policy, sigutil.PolicyActionUpdate)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// an ECDSA key can't verify the Ed25519 signature
+	policy.IsEcdsa = true
+	ok, err = sigutil.VerifyPolicyAction(policy, sigutil.PolicyActionDelete)
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package sigutil
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// PolicyToMessageHex returns the hex of the legacy personal_sign message of a
+// policy, which is still accepted for policies stored before EIP-712 signing.
+// The ID is only part of the message for updates and deletes.
+func PolicyToMessageHex(policy types.PluginPolicy, isUpdate bool) (string, error) {
+	if !isUpdate {
+		policy.ID = ""
+	}
+	// signature is not part of the message that is signed
+	policy.Signature = ""
+	policy.Nonce = ""
+	policy.Expiry = 0
+
+	serializedPolicy, err := json.Marshal(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize policy")
+	}
+	return hex.EncodeToString(serializedPolicy), nil
+}
+
+// VerifyPolicySignature checks a legacy personal_sign policy signature, which
+// only ECDSA vault keys produce.
+func VerifyPolicySignature(policy types.PluginPolicy, isUpdate bool) (bool, error) {
+	if !policy.IsEcdsa {
+		return false, fmt.Errorf("legacy policy signatures require an ECDSA key")
+	}
+
+	msgHex, err := PolicyToMessageHex(policy, isUpdate)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert policy to message hex: %w", err)
+	}
+
+	msgBytes, err := hex.DecodeString(strings.TrimPrefix(msgHex, "0x"))
+	if err != nil {
+		return false, fmt.Errorf("failed to decode message bytes: %w", err)
+	}
+
+	signatureBytes, err := hex.DecodeString(strings.TrimPrefix(policy.Signature, "0x"))
+	if err != nil {
+		return false, fmt.Errorf("failed to decode signature bytes: %w", err)
+	}
+	if len(signatureBytes) < 64 {
+		return false, fmt.Errorf("signature is too short")
+	}
+
+	return VerifySignature(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath, msgBytes, signatureBytes)
+}
+
+// VerifyStoredPolicySignature checks a persisted policy, which carries either
+// its creation signature or the signature of its latest update. Expiry and
+// nonce were checked when the policy was accepted.
+func VerifyStoredPolicySignature(policy types.PluginPolicy) bool {
+	verify := VerifyPolicySignature
+	if policy.Nonce != "" {
+		verify = func(policy types.PluginPolicy, isUpdate bool) (bool, error) {
+			if isUpdate {
+				return VerifyPolicyAction(policy, PolicyActionUpdate)
+			}
+			return VerifyPolicyAction(policy, PolicyActionCreate)
+		}
+	}
+
+	if ok, err := verify(policy, true); err == nil && ok {
+		return true
+	}
+	ok, err := verify(policy, false)
+	return err == nil && ok
+}
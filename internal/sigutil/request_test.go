@@ -0,0 +1,72 @@
+package sigutil_test
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
+)
+
+const testSeed = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+
+func TestRequestSignature(t *testing.T) {
+	signer, err := sigutil.NewRequestSigner("plugin-1", testSeed)
+	require.NoError(t, err)
+
+	body := []byte(`{"id":"policy"}`)
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/plugin/policy?repair=true", bytes.NewReader(body))
+	require.NoError(t, err)
+	signer.Sign(req, body)
+
+	assert.Equal(t, "plugin-1", req.Header.Get(sigutil.HeaderPluginID))
+	timestamp := req.Header.Get(sigutil.HeaderTimestamp)
+	signature := req.Header.Get(sigutil.HeaderSignature)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		timestamp string
+		body      []byte
+		wantErr   bool
+	}{
+		{name: "valid", method: http.MethodPost, path: "/plugin/policy?repair=true", timestamp: timestamp, body: body},
+		{name: "other method", method: http.MethodPut, path: "/plugin/policy?repair=true", timestamp: timestamp, body: body, wantErr: true},
+		{name: "other path", method: http.MethodPost, path: "/sync/transaction", timestamp: timestamp, body: body, wantErr: true},
+		{name: "other query", method: http.MethodPost, path: "/plugin/policy?repair=false", timestamp: timestamp, body: body, wantErr: true},
+		{name: "without query", method: http.MethodPost, path: "/plugin/policy", timestamp: timestamp, body: body, wantErr: true},
+		{name: "tampered body", method: http.MethodPost, path: "/plugin/policy?repair=true", timestamp: timestamp, body: []byte(`{"id":"other"}`), wantErr: true},
+		{
+			name:      "expired",
+			method:    http.MethodPost,
+			path:      "/plugin/policy?repair=true",
+			timestamp: strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10),
+			body:      body,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sigutil.VerifyRequestSignature(signer.PublicKeyHex(), tt.method, tt.path, tt.timestamp, tt.body, signature)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewRequestSignerInvalidKey(t *testing.T) {
+	_, err := sigutil.NewRequestSigner("plugin-1", "abcd")
+	assert.Error(t, err)
+
+	_, err = sigutil.NewRequestSigner("", testSeed)
+	assert.Error(t, err)
+}
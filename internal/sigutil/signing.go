@@ -2,11 +2,11 @@ package sigutil
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 	"strconv"
-	"strings"
 
 	"github.com/eager7/dogd/btcec"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -14,6 +14,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/vultisig/mobile-tss-lib/tss"
+
+	vcommon "github.com/vultisig/vultiserver-plugin/common"
 )
 
 func SignLegacyTx(keysignResponse tss.KeysignResponse, txHash string, rawTx string, chainID *big.Int) (*types.Transaction, *common.Address, error) {
@@ -71,13 +73,36 @@ func SignLegacyTx(keysignResponse tss.KeysignResponse, txHash string, rawTx stri
 
 func VerifySignature(vaultPublicKey string, chainCodeHex string, derivePath string, messageHex []byte, signature []byte) (bool, error) {
 	msgHash := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(messageHex), messageHex)))
+	return VerifyHashSignature(vaultPublicKey, chainCodeHex, derivePath, msgHash, signature)
+}
+
+// VerifyVaultSignature verifies a signature of the message hash by the vault
+// key of the given type.
+func VerifyVaultSignature(isEcdsa bool, vaultPublicKey string, chainCodeHex string, derivePath string, msgHash []byte, signature []byte) (bool, error) {
+	if isEcdsa {
+		return VerifyHashSignature(vaultPublicKey, chainCodeHex, derivePath, msgHash, signature)
+	}
+	return VerifyEdDSASignature(vaultPublicKey, msgHash, signature)
+}
 
-	derivedPubKeyHex, err := tss.GetDerivedPubKey(strings.TrimPrefix(vaultPublicKey, "0x"), chainCodeHex, derivePath, false)
+// VerifyEdDSASignature verifies a 64 byte Ed25519 signature of the message by
+// the vault's EdDSA key.
+func VerifyEdDSASignature(vaultPublicKey string, message []byte, signature []byte) (bool, error) {
+	publicKeyBytes, err := vcommon.DerivePublicKey(vaultPublicKey, "", "", false)
 	if err != nil {
 		return false, err
 	}
+	if len(signature) != ed25519.SignatureSize {
+		return false, fmt.Errorf("invalid EdDSA signature length: %d", len(signature))
+	}
+
+	return ed25519.Verify(publicKeyBytes, message, signature), nil
+}
 
-	publicKeyBytes, err := hex.DecodeString(derivedPubKeyHex)
+// VerifyHashSignature verifies an ECDSA signature of the hash by the key the
+// vault derives for the path.
+func VerifyHashSignature(vaultPublicKey string, chainCodeHex string, derivePath string, msgHash []byte, signature []byte) (bool, error) {
+	publicKeyBytes, err := vcommon.DerivePublicKey(vaultPublicKey, chainCodeHex, derivePath, true)
 	if err != nil {
 		return false, err
 	}
@@ -92,6 +117,9 @@ func VerifySignature(vaultPublicKey string, chainCodeHex string, derivePath stri
 		X:     pk.X,
 		Y:     pk.Y,
 	}
+	if len(signature) < 64 {
+		return false, fmt.Errorf("signature is too short")
+	}
 	R := new(big.Int).SetBytes(signature[:32])
 	S := new(big.Int).SetBytes(signature[32:64])
 
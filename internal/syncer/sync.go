@@ -2,14 +2,21 @@ package syncer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/config"
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
 )
 
 const (
@@ -17,265 +24,400 @@ const (
 	policyEndpoint      = "/plugin/policy"
 	transactionEndpoint = "/sync/transaction"
 
-	// Retry configuration
-	maxRetries     = 3
-	initialBackoff = 100 * time.Millisecond
+	// Relay configuration
+	relayInterval  = 5 * time.Second
+	relayBatchSize = 50
+	initialBackoff = time.Second
+	maxBackoff     = 10 * time.Minute
+	// claimLease holds claimed events back from other relays while they are
+	// delivered, a relay that dies mid-batch releases them once it expires
+	claimLease = 3 * defaultTimeout
 )
 
 type Action int
+
 const (
 	CreateAction Action = iota
 	UpdateAction
 )
 
+// PolicySyncer records sync events in the outbox within the caller's
+// transaction and relays them to the verifier once committed.
 type PolicySyncer interface {
-	CreatePolicySync(policy types.PluginPolicy) error
-	UpdatePolicySync(policy types.PluginPolicy) error
-	DeletePolicySync(policyID, signature string) error
-	SyncTransaction(action Action, jwtToken string, tx types.TransactionHistory) error
+	CreatePolicySyncTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) error
+	UpdatePolicySyncTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) error
+	DeletePolicySyncTx(ctx context.Context, dbTx storage.Tx, policyID string, req types.PolicyDeleteRequest) error
+	SyncTransactionTx(ctx context.Context, dbTx storage.Tx, action Action, tx types.TransactionHistory) error
+	// Flush delivers the pending events of a policy and fails if any remain undelivered.
+	Flush(ctx context.Context, policyID string) error
+	Start()
+	Stop()
 }
 
 type Syncer struct {
+	db         storage.DatabaseStorage
 	logger     *logrus.Logger
 	client     *http.Client
 	serverAddr string
+	tokenFunc  func() (string, error)
+	signer     *sigutil.RequestSigner
+	done       chan struct{}
+}
+
+// deliveryError is returned for verifier responses that will not succeed on retry.
+type deliveryError struct {
+	statusCode int
+	body       string
 }
 
-func NewPolicySyncer(logger *logrus.Logger, serverHost string, serverPort int64) PolicySyncer {
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("verifier rejected sync event, status: %d, body: %s", e.statusCode, e.body)
+}
+
+func NewPolicySyncer(logger *logrus.Logger, db storage.DatabaseStorage, serverHost string, serverPort int64, tokenFunc func() (string, error), signer *sigutil.RequestSigner) PolicySyncer {
 	return &Syncer{
+		db:     db,
 		logger: logger,
 		client: &http.Client{
 			Timeout: defaultTimeout,
 		},
 		serverAddr: fmt.Sprintf("http://%s:%d", serverHost, serverPort),
+		tokenFunc:  tokenFunc,
+		signer:     signer,
+		done:       make(chan struct{}),
 	}
 }
 
-func (s *Syncer) CreatePolicySync(policy types.PluginPolicy) error {
-	s.logger.WithFields(logrus.Fields{
-		"policy_id":   policy.ID,
-		"plugin_type": policy.PluginType,
-	}).Info("Starting policy creation sync")
-
-	return s.retryWithBackoff("CreatePolicySync", func() error {
-		policyBytes, err := json.Marshal(policy)
-		if err != nil {
-			return fmt.Errorf("fail to marshal policy: %w", err)
-		}
+// RequestSignerFromConfig returns the signer for verifier requests. The
+// verifier refuses unsigned requests, so a plugin server without its ID and
+// signing key is an error. It returns nil in the other modes.
+func RequestSignerFromConfig(cfg config.Config) (*sigutil.RequestSigner, error) {
+	if cfg.Server.Mode != "plugin" {
+		return nil, nil
+	}
+	if cfg.Server.Plugin.ID == "" || cfg.Server.Plugin.SigningKey == "" {
+		return nil, fmt.Errorf("server.plugin.id and server.plugin.signing_key are required in plugin mode")
+	}
+	return sigutil.NewRequestSigner(cfg.Server.Plugin.ID, cfg.Server.Plugin.SigningKey)
+}
 
-		url := s.serverAddr + policyEndpoint
+func (s *Syncer) CreatePolicySyncTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) error {
+	return s.insertEvent(ctx, dbTx, policy.ID, types.SyncEventPolicyCreate, policy)
+}
 
-		resp, err := s.client.Post(url, "application/json", bytes.NewBuffer(policyBytes))
-		if err != nil {
-			return fmt.Errorf("fail to sync policy with verifier server: %w", err)
-		}
-		defer resp.Body.Close()
+func (s *Syncer) UpdatePolicySyncTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) error {
+	return s.insertEvent(ctx, dbTx, policy.ID, types.SyncEventPolicyUpdate, policy)
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
-		}
+func (s *Syncer) DeletePolicySyncTx(ctx context.Context, dbTx storage.Tx, policyID string, req types.PolicyDeleteRequest) error {
+	return s.insertEvent(ctx, dbTx, policyID, types.SyncEventPolicyDelete, types.PolicyDeleteSyncPayload{
+		PolicyID:  policyID,
+		Signature: req.Signature,
+		Nonce:     req.Nonce,
+		Expiry:    req.Expiry,
+	})
+}
 
-		if resp.StatusCode != http.StatusOK {
-			s.logger.WithFields(logrus.Fields{
-				"status_code": resp.StatusCode,
-				"body":        string(body),
-				"policy_id":   policy.ID,
-			}).Error("Failed to sync create policy")
-			return fmt.Errorf("fail to sync policy with verifier server: status: %d", resp.StatusCode)
-		}
+func (s *Syncer) SyncTransactionTx(ctx context.Context, dbTx storage.Tx, action Action, tx types.TransactionHistory) error {
+	eventType := types.SyncEventTransactionCreate
+	if action == UpdateAction {
+		eventType = types.SyncEventTransactionUpdate
+	}
+	return s.insertEvent(ctx, dbTx, tx.PolicyID.String(), eventType, tx)
+}
 
-		s.logger.WithFields(logrus.Fields{
-			"policy_id": policy.ID,
-		}).Info("Successfully sync created policy")
+func (s *Syncer) insertEvent(ctx context.Context, dbTx storage.Tx, aggregateID string, eventType types.SyncEventType, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("fail to marshal sync payload: %w", err)
+	}
 
-		return nil
+	return s.db.InsertSyncEventTx(ctx, dbTx, types.SyncEvent{
+		AggregateID: aggregateID,
+		EventType:   eventType,
+		Payload:     payloadBytes,
 	})
 }
 
-func (s *Syncer) UpdatePolicySync(policy types.PluginPolicy) error {
-	s.logger.WithFields(logrus.Fields{
-		"policy_id":   policy.ID,
-		"plugin_type": policy.PluginType,
-	}).Info("Starting policy update sync")
+func (s *Syncer) Start() {
+	go s.run()
+}
 
-	return s.retryWithBackoff("UpdatePolicySync", func() error {
-		policyBytes, err := json.Marshal(policy)
-		if err != nil {
-			return fmt.Errorf("fail to marshal policy: %w", err)
+func (s *Syncer) Stop() {
+	close(s.done)
+}
+
+func (s *Syncer) run() {
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.relay(context.Background(), ""); err != nil {
+				s.logger.Errorf("Failed to relay sync events: %v", err)
+			}
+		case <-s.done:
+			return
 		}
+	}
+}
 
-		url := s.serverAddr + policyEndpoint
+func (s *Syncer) Flush(ctx context.Context, policyID string) error {
+	if _, err := s.relay(ctx, policyID); err != nil {
+		return err
+	}
 
-		req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(policyBytes))
-		if err != nil {
-			return fmt.Errorf("fail to create request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
+	pending, err := s.db.CountPendingSyncEvents(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d sync events pending for policy %s", pending, policyID)
+	}
 
-		resp, err := s.client.Do(req)
-		if err != nil {
-			return fmt.Errorf("fail to sync policy with verifier server: %w", err)
-		}
-		defer resp.Body.Close()
+	return nil
+}
 
-		body, err := io.ReadAll(resp.Body)
+// relay delivers deliverable events one at a time until none are left, so that
+// events of the same policy keep their order. It returns the number delivered.
+func (s *Syncer) relay(ctx context.Context, aggregateID string) (int, error) {
+	delivered := 0
+	for {
+		processed, err := s.relayBatch(ctx, aggregateID)
 		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
+			return delivered, err
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			s.logger.WithFields(logrus.Fields{
-				"status_code": resp.StatusCode,
-				"body":        string(body),
-				"policy_id":   policy.ID,
-			}).Error("Failed to sync update policy")
-			return fmt.Errorf("fail to sync policy with verifier server, status: %d", resp.StatusCode)
+		if processed == 0 {
+			return delivered, nil
 		}
+		delivered += processed
+	}
+}
 
-		s.logger.WithFields(logrus.Fields{
-			"policy_id": policy.ID,
-		}).Info("Successfully sync updated policy")
+// relayBatch claims a batch of events, delivers them and records their
+// outcome. No transaction is held while delivering: the claim is committed
+// with a lease that keeps other relays off the events. A batch has at most one
+// event per aggregate, so its events are delivered concurrently.
+func (s *Syncer) relayBatch(ctx context.Context, aggregateID string) (int, error) {
+	events, err := s.claim(ctx, aggregateID)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
 
-		return nil
-	})
-}
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		delivered  int
+		deliverErr error
+	)
+	for _, event := range events {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.relayEvent(ctx, event)
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				delivered++
+			}
+			if err != nil && deliverErr == nil {
+				deliverErr = err
+			}
+		}()
+	}
+	wg.Wait()
 
-type DeleteRequestBody struct {
-	Signature string `json:"signature"`
+	return delivered, deliverErr
 }
 
-func (s *Syncer) DeletePolicySync(policyID, signature string) error {
-	s.logger.WithFields(logrus.Fields{
-		"policy_id": policyID,
-	}).Info("Starting policy delete sync")
+// claim leases the deliverable events of the aggregate, or of all aggregates.
+func (s *Syncer) claim(ctx context.Context, aggregateID string) ([]types.SyncEvent, error) {
+	dbTx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
 
-	return s.retryWithBackoff("DeletePolicySync", func() error {
-		reqBody := DeleteRequestBody{
-			Signature: signature,
-		}
-		reqBodyBytes, err := json.Marshal(reqBody)
-		if err != nil {
-			return fmt.Errorf("fail to marshal request body: %w", err)
-		}
+	events, err := s.db.GetDeliverableSyncEventsTx(ctx, dbTx, aggregateID, relayBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
 
-		url := s.serverAddr + policyEndpoint + "/" + policyID
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if err := s.db.LeaseSyncEventsTx(ctx, dbTx, ids, time.Now().Add(claimLease)); err != nil {
+		return nil, err
+	}
 
-		req, err := http.NewRequest(http.MethodDelete, url, bytes.NewBuffer(reqBodyBytes))
-		if err != nil {
-			return fmt.Errorf("fail to create request, err: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
+	if err := dbTx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return events, nil
+}
 
-		resp, err := s.client.Do(req)
-		if err != nil {
-			return fmt.Errorf("fail to delete policy on verifier server, err: %w", err)
-		}
-		defer resp.Body.Close()
+// relayEvent delivers a claimed event and records the outcome. It reports
+// whether the event is done with, delivered or permanently failed.
+func (s *Syncer) relayEvent(ctx context.Context, event types.SyncEvent) (bool, error) {
+	err := s.deliver(ctx, event)
+	if err == nil {
+		return true, s.record(ctx, func(dbTx storage.Tx) error {
+			return s.db.MarkSyncEventDeliveredTx(ctx, dbTx, event.ID)
+		})
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
-		}
+	logger := s.logger.WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"policy_id":  event.AggregateID,
+		"attempts":   event.Attempts + 1,
+	})
 
-		if resp.StatusCode != http.StatusNoContent {
-			s.logger.WithFields(logrus.Fields{
-				"status_code": resp.StatusCode,
-				"body":        string(body),
-				"policy_id":   policyID,
-			}).Error("Failed to sync delete policy")
-			return fmt.Errorf("fail to delete policy on verifier server, status: %d", resp.StatusCode)
-		}
+	var rejected *deliveryError
+	if errors.As(err, &rejected) {
+		logger.Errorf("Sync event permanently failed: %v", err)
+		return true, s.record(ctx, func(dbTx storage.Tx) error {
+			return s.db.FailSyncEventTx(ctx, dbTx, event.ID, rejected.Error())
+		})
+	}
 
-		s.logger.WithFields(logrus.Fields{
-			"policy_id": policyID,
-		}).Info("Successfully sync deleted policy")
+	nextAttemptAt := time.Now().Add(backoff(event.Attempts))
+	logger.Warnf("Sync event delivery failed, retrying at %s: %v", nextAttemptAt.Format(time.RFC3339), err)
+	if recordErr := s.record(ctx, func(dbTx storage.Tx) error {
+		return s.db.RescheduleSyncEventTx(ctx, dbTx, event.ID, err.Error(), nextAttemptAt)
+	}); recordErr != nil {
+		return false, recordErr
+	}
+	return false, fmt.Errorf("failed to deliver sync event %d: %w", event.ID, err)
+}
 
-		return nil
-	})
+func (s *Syncer) record(ctx context.Context, fn func(dbTx storage.Tx) error) error {
+	dbTx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := fn(dbTx); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
-func (s *Syncer) SyncTransaction(action Action, jwtToken string, tx types.TransactionHistory) error {
-	s.logger.WithFields(logrus.Fields{
-		"tx_id":   tx.ID,
-		"tx_hash": tx.TxHash,
-	}).Info("Starting tx sync")
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
 
-	return s.retryWithBackoff("SyncTransaction", func() error {
-		txBytes, err := json.Marshal(tx)
-		if err != nil {
-			return fmt.Errorf("fail to marshal transaction: %w", err)
-		}
-		url := s.serverAddr + transactionEndpoint
-		var method string
-		switch action {
-		case CreateAction:
-			method = http.MethodPost
-		case UpdateAction:
-			method = http.MethodPut
+func (s *Syncer) deliver(ctx context.Context, event types.SyncEvent) error {
+	var method, url string
+	body := []byte(event.Payload)
+	authorize := false
+
+	switch event.EventType {
+	case types.SyncEventPolicyCreate:
+		method, url = http.MethodPost, s.serverAddr+policyEndpoint
+	case types.SyncEventPolicyUpdate:
+		method, url = http.MethodPut, s.serverAddr+policyEndpoint
+	case types.SyncEventPolicyDelete:
+		var payload types.PolicyDeleteSyncPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return &deliveryError{body: fmt.Sprintf("fail to unmarshal delete payload: %v", err)}
 		}
-
-		req, err := http.NewRequest(method, url, bytes.NewBuffer(txBytes))
+		reqBody, err := json.Marshal(DeleteRequestBody{
+			Signature: payload.Signature,
+			Nonce:     payload.Nonce,
+			Expiry:    payload.Expiry,
+		})
 		if err != nil {
-			return fmt.Errorf("fail to create request: %w", err)
+			return fmt.Errorf("fail to marshal request body: %w", err)
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", jwtToken))
+		method, url, body = http.MethodDelete, s.serverAddr+policyEndpoint+"/"+payload.PolicyID, reqBody
+	case types.SyncEventTransactionCreate:
+		method, url, authorize = http.MethodPost, s.serverAddr+transactionEndpoint, true
+	case types.SyncEventTransactionUpdate:
+		method, url, authorize = http.MethodPut, s.serverAddr+transactionEndpoint, true
+	default:
+		return &deliveryError{body: fmt.Sprintf("unknown sync event type: %s", event.EventType)}
+	}
 
-		resp, err := s.client.Do(req)
-		if err != nil {
-			return fmt.Errorf("fail to sync transaction on verifier server: %w", err)
-		}
-		body, err := io.ReadAll(resp.Body)
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fail to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.IdempotencyKey.String())
+	if s.signer != nil {
+		s.signer.Sign(req, body)
+	}
+	if authorize {
+		token, err := s.tokenFunc()
 		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
+			return fmt.Errorf("fail to generate token: %w", err)
 		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", token))
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			s.logger.WithFields(logrus.Fields{
-				"status_code": resp.StatusCode,
-				"body":        string(body),
-				"tx_id":       tx.ID,
-			}).Error("Failed to sync update policy")
-			return fmt.Errorf("fail to sync transaction with verifier server, status: %d", resp.StatusCode)
-		}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to sync with verifier server: %w", err)
+	}
+	defer resp.Body.Close()
 
-		s.logger.WithFields(logrus.Fields{
-			"tx_id": tx.ID,
-		}).Info("Successfully sync transaction")
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
 
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		s.logger.WithFields(logrus.Fields{
+			"event_id":   event.ID,
+			"event_type": event.EventType,
+			"policy_id":  event.AggregateID,
+		}).Info("Successfully delivered sync event")
 		return nil
-	})
-}
+	}
 
-// retryWithBackoff attempts to execute the given operation with exponential backoff
-func (s *Syncer) retryWithBackoff(operation string, fn func() error) error {
-	var lastErr error
-	backoff := initialBackoff
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			s.logger.WithFields(logrus.Fields{
-				"attempt":   attempt,
-				"backoff":   backoff.String(),
-				"operation": operation,
-			}).Debug("Retrying sync")
-
-			time.Sleep(backoff)
-			backoff *= 2
-		}
+	if isPermanentFailure(resp.StatusCode) {
+		return &deliveryError{statusCode: resp.StatusCode, body: string(respBody)}
+	}
 
-		err := fn()
-		if err == nil {
-			return nil
-		}
-		lastErr = err
-		s.logger.WithFields(logrus.Fields{
-			"attempt":   attempt,
-			"error":     err.Error(),
-			"operation": operation,
-		}).Warn("Sync failed, will retry")
+	return fmt.Errorf("fail to sync with verifier server, status: %d, body: %s", resp.StatusCode, string(respBody))
+}
+
+// isPermanentFailure reports whether the verifier rejected the request itself,
+// in which case retrying the same payload is pointless. Auth errors, missing
+// policies and conflicts can clear up, e.g. once a rotated key is registered or
+// an earlier event arrived, so they are retried and hold back the later
+// events of the policy.
+func isPermanentFailure(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusNotFound,
+		http.StatusRequestTimeout,
+		http.StatusConflict,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests:
+		return false
 	}
+	return statusCode >= http.StatusBadRequest && statusCode < http.StatusInternalServerError
+}
 
-	return fmt.Errorf("sync failed: %w", lastErr)
+type DeleteRequestBody struct {
+	Signature string `json:"signature"`
+	Nonce     string `json:"nonce"`
+	Expiry    int64  `json:"expiry"`
 }
@@ -0,0 +1,199 @@
+package syncer_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/syncer"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+type request struct {
+	method         string
+	path           string
+	idempotencyKey string
+	body           string
+}
+
+// verifier records the sync requests it receives and answers them with the
+// status returned by respond.
+type verifier struct {
+	mu       sync.Mutex
+	requests []request
+	respond  func(r *http.Request) int
+}
+
+func (v *verifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	v.mu.Lock()
+	v.requests = append(v.requests, request{
+		method:         r.Method,
+		path:           r.URL.Path,
+		idempotencyKey: r.Header.Get("Idempotency-Key"),
+		body:           string(body),
+	})
+	respond := v.respond
+	v.mu.Unlock()
+
+	status := http.StatusOK
+	if respond != nil {
+		status = respond(r)
+	}
+	w.WriteHeader(status)
+}
+
+func (v *verifier) received() []request {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]request(nil), v.requests...)
+}
+
+func newSyncer(t *testing.T, v *verifier) (syncer.PolicySyncer, *memory.MemoryBackend) {
+	server := httptest.NewServer(v)
+	t.Cleanup(server.Close)
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.ParseInt(portStr, 10, 64)
+	require.NoError(t, err)
+
+	db := memory.NewMemoryBackend()
+	tokenFunc := func() (string, error) { return "token", nil }
+	return syncer.NewPolicySyncer(logrus.New(), db, host, port, tokenFunc, nil), db
+}
+
+// record stores the policy change and the transaction sync events of the
+// policy in one transaction, like the services do.
+func record(t *testing.T, s syncer.PolicySyncer, db *memory.MemoryBackend, policy types.PluginPolicy, txs ...types.TransactionHistory) {
+	ctx := context.Background()
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.UpdatePolicySyncTx(ctx, dbTx, policy))
+	for _, tx := range txs {
+		require.NoError(t, s.SyncTransactionTx(ctx, dbTx, syncer.CreateAction, tx))
+	}
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	v := &verifier{}
+	s, db := newSyncer(t, v)
+
+	policyID := uuid.New()
+	policy := types.PluginPolicy{ID: policyID.String(), PluginType: "dca"}
+	record(t, s, db, policy,
+		types.TransactionHistory{PolicyID: policyID, TxHash: "a1"},
+		types.TransactionHistory{PolicyID: policyID, TxHash: "a2"},
+	)
+
+	require.NoError(t, s.Flush(ctx, policy.ID))
+
+	// events of a policy are delivered in order, each with its own key
+	requests := v.received()
+	require.Len(t, requests, 3)
+	assert.Equal(t, http.MethodPut, requests[0].method)
+	assert.Equal(t, "/plugin/policy", requests[0].path)
+	for i, hash := range []string{"a1", "a2"} {
+		assert.Equal(t, http.MethodPost, requests[i+1].method)
+		assert.Equal(t, "/sync/transaction", requests[i+1].path)
+		var tx types.TransactionHistory
+		require.NoError(t, json.Unmarshal([]byte(requests[i+1].body), &tx))
+		assert.Equal(t, hash, tx.TxHash)
+	}
+	assert.NotEqual(t, requests[1].idempotencyKey, requests[2].idempotencyKey)
+
+	pending, err := db.CountPendingSyncEvents(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	// delivered events are not sent again
+	require.NoError(t, s.Flush(ctx, policy.ID))
+	assert.Len(t, v.received(), 3)
+}
+
+func TestFlushRetries(t *testing.T) {
+	for _, status := range []int{
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusNotFound,
+		http.StatusConflict,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+	} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			ctx := context.Background()
+			v := &verifier{respond: func(*http.Request) int { return status }}
+			s, db := newSyncer(t, v)
+
+			policyID := uuid.New()
+			policy := types.PluginPolicy{ID: policyID.String(), PluginType: "dca"}
+			record(t, s, db, policy, types.TransactionHistory{PolicyID: policyID, TxHash: "a1"})
+
+			// the event is retried later, and holds back the next one
+			assert.Error(t, s.Flush(ctx, policy.ID))
+			assert.Len(t, v.received(), 1)
+			pending, err := db.CountPendingSyncEvents(ctx, policy.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), pending)
+		})
+	}
+}
+
+func TestFlushPermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	v := &verifier{respond: func(r *http.Request) int {
+		if r.Method == http.MethodPut {
+			return http.StatusBadRequest
+		}
+		return http.StatusOK
+	}}
+	s, db := newSyncer(t, v)
+
+	policyID := uuid.New()
+	policy := types.PluginPolicy{ID: policyID.String(), PluginType: "dca"}
+	record(t, s, db, policy, types.TransactionHistory{PolicyID: policyID, TxHash: "a1"})
+
+	// a payload the verifier refuses is given up, it won't be accepted later
+	require.NoError(t, s.Flush(ctx, policy.ID))
+	assert.Len(t, v.received(), 2)
+	pending, err := db.CountPendingSyncEvents(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+func TestFlushLeasesClaimedEvents(t *testing.T) {
+	ctx := context.Background()
+	v := &verifier{}
+	s, db := newSyncer(t, v)
+
+	policyID := uuid.New()
+	policy := types.PluginPolicy{ID: policyID.String(), PluginType: "dca"}
+	record(t, s, db, policy)
+
+	// while the event is being delivered the claim is committed, so a
+	// concurrent relay neither waits on it nor delivers it again
+	var concurrentErr error
+	var once sync.Once
+	v.respond = func(*http.Request) int {
+		once.Do(func() {
+			concurrentErr = s.Flush(ctx, policy.ID)
+		})
+		return http.StatusOK
+	}
+	require.NoError(t, s.Flush(ctx, policy.ID))
+	assert.ErrorContains(t, concurrentErr, "1 sync events pending")
+	assert.Len(t, v.received(), 1)
+}
@@ -0,0 +1,93 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+const (
+	pluginTokenEndpoint = "/auth/plugin/token"
+	// tokens are renewed this long before they expire
+	tokenRenewMargin = 5 * time.Minute
+)
+
+// TokenSource obtains the plugin's service token from the verifier, using the
+// plugin's signing key as credentials, and caches it until close to expiry.
+type TokenSource struct {
+	client     *http.Client
+	serverAddr string
+	signer     *sigutil.RequestSigner
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewTokenSource(serverHost string, serverPort int64, signer *sigutil.RequestSigner) *TokenSource {
+	return &TokenSource{
+		client: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		serverAddr: fmt.Sprintf("http://%s:%d", serverHost, serverPort),
+		signer:     signer,
+	}
+}
+
+func (t *TokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Until(t.expiresAt) > tokenRenewMargin {
+		return t.token, nil
+	}
+	if t.signer == nil {
+		return "", fmt.Errorf("plugin signing key is not configured")
+	}
+
+	body, err := json.Marshal(types.PluginTokenRequest{Scopes: types.PluginScopes})
+	if err != nil {
+		return "", fmt.Errorf("fail to marshal token request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, t.serverAddr+pluginTokenEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("fail to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	t.signer.Sign(req, body)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fail to request plugin token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fail to request plugin token, status: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var tokenResp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return "", fmt.Errorf("fail to unmarshal token response: %w", err)
+	}
+
+	t.token = tokenResp.Token
+	t.expiresAt = tokenResp.ExpiresAt
+	return t.token, nil
+}
@@ -18,6 +18,8 @@ const (
 	TypeKeySignDKLS       = "key:signDKLS"
 	TypeReshareDKLS       = "key:reshareDKLS"
 	TypeMigrate           = "key:migrate"
+
+	TypeTransactionRetention = "transaction:retention"
 )
 
 func GetTaskResult(inspector *asynq.Inspector, taskID string) ([]byte, error) {
@@ -0,0 +1,159 @@
+package txdecoder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// knownABI lists the contract methods the plugins build transactions for:
+// ERC20 approvals and transfers and Uniswap V2 swaps.
+const knownABI = `[
+	{"name":"approve","type":"function","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"name":"transfer","type":"function","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"name":"transferFrom","type":"function","inputs":[{"name":"sender","type":"address"},{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"name":"swapExactTokensForTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"outputs":[{"name":"amounts","type":"uint256[]"}]},
+	{"name":"swapExactETHForTokens","type":"function","inputs":[{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"outputs":[{"name":"amounts","type":"uint256[]"}]},
+	{"name":"swapExactTokensForETH","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"outputs":[{"name":"amounts","type":"uint256[]"}]}
+]`
+
+var parsedABI abi.ABI
+
+func init() {
+	var err error
+	parsedABI, err = abi.JSON(strings.NewReader(knownABI))
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse known ABI: %v", err))
+	}
+}
+
+// DecodedTransaction is a human readable view of an unsigned EIP-155 legacy
+// transaction as proposed by the plugins.
+type DecodedTransaction struct {
+	ChainID  string                 `json:"chain_id"`
+	Nonce    uint64                 `json:"nonce"`
+	To       string                 `json:"to"`
+	Value    string                 `json:"value"`
+	GasLimit uint64                 `json:"gas_limit"`
+	GasPrice string                 `json:"gas_price"`
+	Data     string                 `json:"data"`
+	Method   string                 `json:"method,omitempty"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// ParseTransaction parses a hex encoded unsigned transaction.
+func ParseTransaction(txHex string) (*gtypes.Transaction, error) {
+	rawTx, err := hex.DecodeString(strings.TrimPrefix(txHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hex: %w", err)
+	}
+
+	tx := &gtypes.Transaction{}
+	if err := tx.UnmarshalBinary(rawTx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// Decode parses the transaction and decodes its call data when the method is
+// known. Unknown call data is returned as is without an error.
+func Decode(txHex string) (*DecodedTransaction, error) {
+	tx, err := ParseTransaction(txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &DecodedTransaction{
+		ChainID:  tx.ChainId().String(),
+		Nonce:    tx.Nonce(),
+		Value:    tx.Value().String(),
+		GasLimit: tx.Gas(),
+		GasPrice: tx.GasPrice().String(),
+		Data:     hex.EncodeToString(tx.Data()),
+	}
+	if tx.To() != nil {
+		decoded.To = tx.To().Hex()
+	}
+
+	method, args, err := DecodeCallData(tx.Data())
+	if err == nil {
+		decoded.Method = method
+		decoded.Args = args
+	}
+
+	return decoded, nil
+}
+
+// TransactionType classifies the transaction the way the plugins label their
+// proposals, so that limits per type don't depend on the label a plugin sends.
+// Plain value transfers and ERC20 transfers are TRANSFER, approvals APPROVE
+// and Uniswap swaps SWAP. Other calls are refused.
+func TransactionType(tx *gtypes.Transaction) (string, error) {
+	if len(tx.Data()) == 0 {
+		return "TRANSFER", nil
+	}
+
+	method, _, err := DecodeCallData(tx.Data())
+	if err != nil {
+		return "", err
+	}
+	switch method {
+	case "approve":
+		return "APPROVE", nil
+	case "transfer", "transferFrom":
+		return "TRANSFER", nil
+	case "swapExactTokensForTokens", "swapExactETHForTokens", "swapExactTokensForETH":
+		return "SWAP", nil
+	}
+	return "", fmt.Errorf("unsupported method: %s", method)
+}
+
+// DecodeCallData returns the method name and the named arguments of call data
+// matching one of the known methods.
+func DecodeCallData(data []byte) (string, map[string]interface{}, error) {
+	if len(data) < 4 {
+		return "", nil, fmt.Errorf("call data too short")
+	}
+
+	method, err := parsedABI.MethodById(data[:4])
+	if err != nil {
+		return "", nil, fmt.Errorf("unknown method: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(values, data[4:]); err != nil {
+		return "", nil, fmt.Errorf("failed to unpack arguments: %w", err)
+	}
+
+	args := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		args[name] = normalize(value)
+	}
+
+	return method.Name, args, nil
+}
+
+// normalize turns ABI values into JSON friendly values, big integers are
+// rendered as decimal strings to avoid precision loss in clients.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case gcommon.Address:
+		return v.Hex()
+	case []gcommon.Address:
+		addresses := make([]string, len(v))
+		for i, address := range v {
+			addresses[i] = address.Hex()
+		}
+		return addresses
+	default:
+		return v
+	}
+}
@@ -0,0 +1,134 @@
+package txdecoder_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
+)
+
+const transferABI = `[{"name":"transfer","type":"function","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+func unsignedTxHex(t *testing.T, chainID int64, to gcommon.Address, data []byte) string {
+	V := big.NewInt(chainID*2 + 35)
+	rawTx, err := rlp.EncodeToBytes([]interface{}{
+		uint64(7),
+		big.NewInt(1000000000),
+		uint64(60000),
+		to,
+		big.NewInt(0),
+		data,
+		V,
+		uint(0),
+		uint(0),
+	})
+	require.NoError(t, err)
+	return hex.EncodeToString(rawTx)
+}
+
+func TestDecode(t *testing.T) {
+	parsedABI, err := abi.JSON(strings.NewReader(transferABI))
+	require.NoError(t, err)
+
+	recipient := gcommon.HexToAddress("0x00000000000000000000000000000000000000aa")
+	token := gcommon.HexToAddress("0x00000000000000000000000000000000000000bb")
+	data, err := parsedABI.Pack("transfer", recipient, big.NewInt(12345))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		data       []byte
+		wantMethod string
+		wantArgs   map[string]interface{}
+	}{
+		{
+			name:       "Known method",
+			data:       data,
+			wantMethod: "transfer",
+			wantArgs: map[string]interface{}{
+				"recipient": recipient.Hex(),
+				"amount":    "12345",
+			},
+		},
+		{
+			name: "Unknown method",
+			data: []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := txdecoder.Decode(unsignedTxHex(t, 1, token, tc.data))
+			require.NoError(t, err)
+
+			assert.Equal(t, "1", decoded.ChainID)
+			assert.Equal(t, uint64(7), decoded.Nonce)
+			assert.Equal(t, token.Hex(), decoded.To)
+			assert.Equal(t, uint64(60000), decoded.GasLimit)
+			assert.Equal(t, "1000000000", decoded.GasPrice)
+			assert.Equal(t, tc.wantMethod, decoded.Method)
+			assert.Equal(t, tc.wantArgs, decoded.Args)
+		})
+	}
+}
+
+func TestDecodeInvalidTransaction(t *testing.T) {
+	_, err := txdecoder.Decode("zz")
+	assert.Error(t, err)
+}
+
+func TestTransactionType(t *testing.T) {
+	parsedABI, err := abi.JSON(strings.NewReader(`[
+		{"name":"approve","type":"function","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+		{"name":"transfer","type":"function","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+		{"name":"swapExactTokensForTokens","type":"function","inputs":[{"name":"amountIn","type":"uint256"},{"name":"amountOutMin","type":"uint256"},{"name":"path","type":"address[]"},{"name":"to","type":"address"},{"name":"deadline","type":"uint256"}],"outputs":[{"name":"amounts","type":"uint256[]"}]}
+	]`))
+	require.NoError(t, err)
+
+	address := gcommon.HexToAddress("0x00000000000000000000000000000000000000aa")
+	pack := func(method string, args ...interface{}) []byte {
+		data, err := parsedABI.Pack(method, args...)
+		require.NoError(t, err)
+		return data
+	}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		wantType string
+		wantErr  bool
+	}{
+		{name: "Value transfer", wantType: "TRANSFER"},
+		{name: "Token transfer", data: pack("transfer", address, big.NewInt(1)), wantType: "TRANSFER"},
+		{name: "Approval", data: pack("approve", address, big.NewInt(1)), wantType: "APPROVE"},
+		{
+			name:     "Swap",
+			data:     pack("swapExactTokensForTokens", big.NewInt(1), big.NewInt(1), []gcommon.Address{address, address}, address, big.NewInt(1)),
+			wantType: "SWAP",
+		},
+		{name: "Unknown method", data: []byte{0xde, 0xad, 0xbe, 0xef}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := txdecoder.ParseTransaction(unsignedTxHex(t, 1, address, tc.data))
+			require.NoError(t, err)
+
+			txType, err := txdecoder.TransactionType(tx)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantType, txType)
+		})
+	}
+}
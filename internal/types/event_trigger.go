@@ -0,0 +1,197 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	gcommon "github.com/ethereum/go-ethereum/common"
+)
+
+type EventTriggerType string
+
+const (
+	EventTriggerTypePrice         EventTriggerType = "PRICE"
+	EventTriggerTypeBalance       EventTriggerType = "BALANCE"
+	EventTriggerTypeContractEvent EventTriggerType = "CONTRACT_EVENT"
+)
+
+type ComparisonOperator string
+
+const (
+	OperatorLessThan           ComparisonOperator = "lt"
+	OperatorLessThanOrEqual    ComparisonOperator = "lte"
+	OperatorGreaterThan        ComparisonOperator = "gt"
+	OperatorGreaterThanOrEqual ComparisonOperator = "gte"
+)
+
+// Compare reports whether value satisfies the operator against threshold.
+func (o ComparisonOperator) Compare(value, threshold *big.Int) bool {
+	cmp := value.Cmp(threshold)
+	switch o {
+	case OperatorLessThan:
+		return cmp < 0
+	case OperatorLessThanOrEqual:
+		return cmp <= 0
+	case OperatorGreaterThan:
+		return cmp > 0
+	case OperatorGreaterThanOrEqual:
+		return cmp >= 0
+	}
+	return false
+}
+
+func (o ComparisonOperator) IsValid() bool {
+	switch o {
+	case OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual:
+		return true
+	}
+	return false
+}
+
+// PolicyTrigger is the optional "trigger" section of a policy document.
+// When present the policy is fired by the watcher instead of the scheduler.
+type PolicyTrigger struct {
+	Type     EventTriggerType        `json:"type"`
+	Cooldown string                  `json:"cooldown,omitempty"` // seconds between two firings
+	Price    *PriceCondition         `json:"price,omitempty"`
+	Balance  *BalanceCondition       `json:"balance,omitempty"`
+	Event    *ContractEventCondition `json:"event,omitempty"`
+}
+
+// PriceCondition fires when swapping Amount of BaseTokenID yields an amount of
+// QuoteTokenID that satisfies Operator against Threshold.
+type PriceCondition struct {
+	ChainID      string             `json:"chain_id"`
+	BaseTokenID  string             `json:"base_token_id"`
+	QuoteTokenID string             `json:"quote_token_id"`
+	Amount       string             `json:"amount"`
+	Operator     ComparisonOperator `json:"operator"`
+	Threshold    string             `json:"threshold"`
+}
+
+// BalanceCondition fires when the balance of TokenID held by Address satisfies
+// Operator against Threshold. An empty TokenID means the native coin and an
+// empty Address means the vault address of the policy.
+type BalanceCondition struct {
+	ChainID   string             `json:"chain_id"`
+	TokenID   string             `json:"token_id,omitempty"`
+	Address   string             `json:"address,omitempty"`
+	Operator  ComparisonOperator `json:"operator"`
+	Threshold string             `json:"threshold"`
+}
+
+// ContractEventCondition fires when ContractAddress emits a log matching
+// EventSignature, e.g. "Transfer(address,address,uint256)", and the optional
+// indexed topic filters.
+type ContractEventCondition struct {
+	ChainID         string   `json:"chain_id"`
+	ContractAddress string   `json:"contract_address"`
+	EventSignature  string   `json:"event_signature"`
+	Topics          []string `json:"topics,omitempty"`
+	LookbackBlocks  uint64   `json:"lookback_blocks,omitempty"`
+}
+
+func (t *PolicyTrigger) IsValid() error {
+	if t.Cooldown != "" {
+		if _, err := t.CooldownSeconds(); err != nil {
+			return err
+		}
+	}
+
+	switch t.Type {
+	case EventTriggerTypePrice:
+		if t.Price == nil {
+			return errors.New("price condition is required")
+		}
+		if !gcommon.IsHexAddress(t.Price.BaseTokenID) || !gcommon.IsHexAddress(t.Price.QuoteTokenID) {
+			return errors.New("invalid price condition token address")
+		}
+		if err := validatePositiveAmount("price amount", t.Price.Amount); err != nil {
+			return err
+		}
+		if err := validatePositiveAmount("price threshold", t.Price.Threshold); err != nil {
+			return err
+		}
+		if !t.Price.Operator.IsValid() {
+			return fmt.Errorf("invalid operator: %s", t.Price.Operator)
+		}
+	case EventTriggerTypeBalance:
+		if t.Balance == nil {
+			return errors.New("balance condition is required")
+		}
+		if t.Balance.TokenID != "" && !gcommon.IsHexAddress(t.Balance.TokenID) {
+			return errors.New("invalid balance condition token address")
+		}
+		if t.Balance.Address != "" && !gcommon.IsHexAddress(t.Balance.Address) {
+			return errors.New("invalid balance condition address")
+		}
+		if err := validatePositiveAmount("balance threshold", t.Balance.Threshold); err != nil {
+			return err
+		}
+		if !t.Balance.Operator.IsValid() {
+			return fmt.Errorf("invalid operator: %s", t.Balance.Operator)
+		}
+	case EventTriggerTypeContractEvent:
+		if t.Event == nil {
+			return errors.New("event condition is required")
+		}
+		if !gcommon.IsHexAddress(t.Event.ContractAddress) {
+			return errors.New("invalid event contract address")
+		}
+		if t.Event.EventSignature == "" {
+			return errors.New("event signature is required")
+		}
+		if len(t.Event.Topics) > 3 {
+			return errors.New("at most 3 indexed topics can be filtered")
+		}
+	default:
+		return fmt.Errorf("unsupported trigger type: %s", t.Type)
+	}
+
+	return nil
+}
+
+func (t *PolicyTrigger) CooldownSeconds() (int, error) {
+	if t.Cooldown == "" {
+		return 0, nil
+	}
+	var cooldown int
+	if _, err := fmt.Sscan(t.Cooldown, &cooldown); err != nil || cooldown < 0 {
+		return 0, fmt.Errorf("invalid cooldown: %s", t.Cooldown)
+	}
+	return cooldown, nil
+}
+
+// Condition returns the condition matching the trigger type.
+func (t *PolicyTrigger) Condition() (json.RawMessage, error) {
+	switch t.Type {
+	case EventTriggerTypePrice:
+		return json.Marshal(t.Price)
+	case EventTriggerTypeBalance:
+		return json.Marshal(t.Balance)
+	case EventTriggerTypeContractEvent:
+		return json.Marshal(t.Event)
+	}
+	return nil, fmt.Errorf("unsupported trigger type: %s", t.Type)
+}
+
+func validatePositiveAmount(name, value string) error {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || amount.Sign() <= 0 {
+		return fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return nil
+}
+
+type EventTrigger struct {
+	PolicyID         string            `json:"policy_id"`
+	TriggerType      EventTriggerType  `json:"trigger_type"`
+	Condition        json.RawMessage   `json:"condition"`
+	Cooldown         int               `json:"cooldown"`
+	LastCheckedBlock *uint64           `json:"last_checked_block"`
+	LastTriggered    *time.Time        `json:"last_triggered"`
+	Status           TimeTriggerStatus `json:"status"`
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+const (
+	ScopeTransactionSync = "transaction:sync"
+	ScopePolicyRead      = "policy:read"
+)
+
+// PluginScopes are the scopes a plugin service account may request.
+var PluginScopes = []string{ScopeTransactionSync, ScopePolicyRead}
+
+// PluginToken records a service token issued to a plugin, keyed by its JWT ID.
+type PluginToken struct {
+	ID        string     `json:"id"`
+	PluginID  string     `json:"plugin_id"`
+	Scopes    []string   `json:"scopes"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type PluginTokenRequest struct {
+	Scopes []string `json:"scopes"`
+}
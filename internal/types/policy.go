@@ -1,26 +1,68 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type PluginTriggerEvent struct {
-	PolicyID string `json:"policy_id"`
+	PolicyID      string    `json:"policy_id"`
+	TriggerSource string    `json:"trigger_source,omitempty"`
+	TriggeredAt   time.Time `json:"triggered_at,omitempty"`
 }
 
 // TODO: add validation of the public key, type, chain code, derive path, etc.
 
 type PluginPolicy struct {
-	ID            string          `json:"id" validate:"required"`
-	PublicKey     string          `json:"public_key" validate:"required"`
-	IsEcdsa       bool            `json:"is_ecdsa" validate:"required"`
-	ChainCodeHex  string          `json:"chain_code_hex" validate:"required"`
-	DerivePath    string          `json:"derive_path" validate:"required"`
-	PluginID      string          `json:"plugin_id" validate:"required"`
-	PluginVersion string          `json:"plugin_version" validate:"required"`
-	PolicyVersion string          `json:"policy_version" validate:"required"`
-	PluginType    string          `json:"plugin_type" validate:"required"`
-	Signature     string          `json:"signature" validate:"required"`
-	Policy        json.RawMessage `json:"policy" validate:"required"`
-	Active        bool            `json:"active" validate:"required"`
+	ID            string `json:"id" validate:"required"`
+	PublicKey     string `json:"public_key" validate:"required"`
+	IsEcdsa       bool   `json:"is_ecdsa" validate:"required"`
+	ChainCodeHex  string `json:"chain_code_hex" validate:"required"`
+	DerivePath    string `json:"derive_path" validate:"required"`
+	PluginID      string `json:"plugin_id" validate:"required"`
+	PluginVersion string `json:"plugin_version" validate:"required"`
+	PolicyVersion string `json:"policy_version" validate:"required"`
+	PluginType    string `json:"plugin_type" validate:"required"`
+	Signature     string `json:"signature" validate:"required"`
+	// Nonce and Expiry are covered by the EIP-712 signature of the policy
+	Nonce  string          `json:"nonce,omitempty"`
+	Expiry int64           `json:"expiry,omitempty"`
+	Policy json.RawMessage `json:"policy" validate:"required"`
+	Active bool            `json:"active" validate:"required"`
+	// Status, DeletedAt and Deletion are kept by the server and not covered by
+	// the policy signature
+	Status    PolicyStatus         `json:"status,omitempty"`
+	DeletedAt *time.Time           `json:"deleted_at,omitempty"`
+	Deletion  *PolicyDeleteRequest `json:"deletion,omitempty"`
+}
+
+type PolicyStatus string
+
+const (
+	PolicyStatusActive  PolicyStatus = "ACTIVE"
+	PolicyStatusDeleted PolicyStatus = "DELETED"
+	// PolicyStatusCompleted marks a policy the plugin has nothing left to do
+	// for, e.g. a DCA policy that placed all of its orders
+	PolicyStatusCompleted PolicyStatus = "COMPLETED"
+)
+
+// IsDeleted reports whether the policy was soft-deleted. Deleted policies are
+// kept for their transaction history but are never signed for again.
+func (p PluginPolicy) IsDeleted() bool {
+	return p.Status == PolicyStatusDeleted
+}
+
+// Runnable reports whether the policy is signed active and neither completed
+// nor deleted, so that its triggers may run.
+func (p PluginPolicy) Runnable() bool {
+	return p.Active && p.Status == PolicyStatusActive
+}
+
+// PolicyDeleteRequest carries the vault's EIP-712 signature of a policy deletion.
+type PolicyDeleteRequest struct {
+	Signature string `json:"signature"`
+	Nonce     string `json:"nonce"`
+	Expiry    int64  `json:"expiry"`
 }
 
 type PublicKey struct {
@@ -32,16 +74,18 @@ type PayrollPolicy struct {
 	TokenID    []string           `json:"token_id"`
 	Recipients []PayrollRecipient `json:"recipients"`
 	Schedule   Schedule           `json:"schedule"`
+	Trigger    *PolicyTrigger     `json:"trigger,omitempty"`
 }
 
 type DCAPolicy struct {
-	ChainID            string     `json:"chain_id"`
-	SourceTokenID      string     `json:"source_token_id"`
-	DestinationTokenID string     `json:"destination_token_id"`
-	TotalAmount        string     `json:"total_amount"`
-	TotalOrders        string     `json:"total_orders"`
-	Schedule           Schedule   `json:"schedule"`
-	PriceRange         PriceRange `json:"price_range"`
+	ChainID            string         `json:"chain_id"`
+	SourceTokenID      string         `json:"source_token_id"`
+	DestinationTokenID string         `json:"destination_token_id"`
+	TotalAmount        string         `json:"total_amount"`
+	TotalOrders        string         `json:"total_orders"`
+	Schedule           Schedule       `json:"schedule"`
+	PriceRange         PriceRange     `json:"price_range"`
+	Trigger            *PolicyTrigger `json:"trigger,omitempty"`
 }
 
 type PayrollRecipient struct {
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
+)
+
+type PolicyRunOutcome string
+
+const (
+	PolicyRunRunning   PolicyRunOutcome = "RUNNING"
+	PolicyRunSucceeded PolicyRunOutcome = "SUCCEEDED"
+	PolicyRunFailed    PolicyRunOutcome = "FAILED"
+	PolicyRunSkipped   PolicyRunOutcome = "SKIPPED"
+)
+
+const (
+	TriggerSourceTime   = "time"
+	TriggerSourceEvent  = "event"
+	TriggerSourceManual = "manual"
+)
+
+type PolicyRun struct {
+	ID             uuid.UUID        `json:"id"`
+	PolicyID       uuid.UUID        `json:"policy_id"`
+	TriggerSource  string           `json:"trigger_source"`
+	TriggeredAt    time.Time        `json:"triggered_at"`
+	StartedAt      time.Time        `json:"started_at"`
+	FinishedAt     *time.Time       `json:"finished_at"`
+	DurationMs     *int64           `json:"duration_ms"`
+	Outcome        PolicyRunOutcome `json:"outcome"`
+	ErrorMessage   *string          `json:"error_message"`
+	TransactionIDs []uuid.UUID      `json:"transaction_ids"`
+}
+
+// PolicyActionRequest authorises a one-off action on a policy with a vault
+// signature over "<action>:<policy_id>:<timestamp>".
+type PolicyActionRequest struct {
+	Signature string `json:"signature"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type DryRunResult struct {
+	PolicyID        string              `json:"policy_id"`
+	Valid           bool                `json:"valid"`
+	ValidationError string              `json:"validation_error,omitempty"`
+	Transactions    []DryRunTransaction `json:"transactions"`
+}
+
+type DryRunTransaction struct {
+	TransactionType string                        `json:"transaction_type"`
+	TxHash          string                        `json:"tx_hash"`
+	Decoded         *txdecoder.DecodedTransaction `json:"decoded"`
+	EstimatedGas    uint64                        `json:"estimated_gas,omitempty"`
+	Simulation      Simulation                    `json:"simulation"`
+}
+
+type Simulation struct {
+	Success    bool   `json:"success"`
+	ReturnData string `json:"return_data,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,47 @@
+package types
+
+type TransactionStatusEntry struct {
+	PolicyID string            `json:"policy_id"`
+	TxHash   string            `json:"tx_hash"`
+	Status   TransactionStatus `json:"status"`
+}
+
+// PolicyDigest summarises a policy and the statuses of its transactions so
+// that the plugin and verifier databases can be compared cheaply.
+type PolicyDigest struct {
+	PolicyID      string                       `json:"policy_id"`
+	PolicyVersion string                       `json:"policy_version"`
+	Active        bool                         `json:"active"`
+	Signature     string                       `json:"signature"`
+	PolicyHash    string                       `json:"policy_hash"`
+	Transactions  map[string]TransactionStatus `json:"transactions"`
+	Digest        string                       `json:"digest"`
+}
+
+type MismatchKind string
+
+const (
+	MismatchMissingOnVerifier MismatchKind = "MISSING_ON_VERIFIER"
+	MismatchMissingOnPlugin   MismatchKind = "MISSING_ON_PLUGIN"
+	MismatchPolicy            MismatchKind = "POLICY_MISMATCH"
+	MismatchTransaction       MismatchKind = "TRANSACTION_MISMATCH"
+)
+
+type ReconcileMismatch struct {
+	Kind          MismatchKind `json:"kind"`
+	PolicyID      string       `json:"policy_id"`
+	TxHash        string       `json:"tx_hash,omitempty"`
+	Fields        []string     `json:"fields,omitempty"`
+	PluginValue   string       `json:"plugin_value,omitempty"`
+	VerifierValue string       `json:"verifier_value,omitempty"`
+	Repaired      bool         `json:"repaired"`
+	RepairDetail  string       `json:"repair_detail,omitempty"`
+	RepairError   string       `json:"repair_error,omitempty"`
+}
+
+type ReconcileReport struct {
+	PluginType      string              `json:"plugin_type"`
+	PoliciesChecked int                 `json:"policies_checked"`
+	Mismatches      []ReconcileMismatch `json:"mismatches"`
+	RepairRequested bool                `json:"repair_requested"`
+}
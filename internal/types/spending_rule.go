@@ -0,0 +1,70 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NativeToken names the native coin of a chain in spending rules.
+const NativeToken = "native"
+
+// SpendingRule limits what the verifier co-signs for a vault. Empty PublicKey
+// and PluginType apply the rule to every vault and aggregate across plugins.
+// Amount limits are token denominated, in base units of Token, and are not
+// converted to any currency: a rule only limits amounts of its own Token.
+// Approvals only count against MaxAllowance, not as spends.
+type SpendingRule struct {
+	ID                          uuid.UUID `json:"id"`
+	PublicKey                   string    `json:"public_key"`
+	PluginType                  string    `json:"plugin_type"`
+	ChainID                     string    `json:"chain_id"`
+	Token                       string    `json:"token"`
+	MaxAmountPerTx              string    `json:"max_amount_per_tx"`
+	MaxAmountPerDay             string    `json:"max_amount_per_day"`
+	MaxAmountPerRecipientPerDay string    `json:"max_amount_per_recipient_per_day"`
+	MaxTransactionsPerDay       int64     `json:"max_transactions_per_day"`
+	MaxAllowance                string    `json:"max_allowance"`
+	AllowedTokens               []string  `json:"allowed_tokens"`
+	AllowedContracts            []string  `json:"allowed_contracts"`
+	Active                      bool      `json:"active"`
+	CreatedAt                   time.Time `json:"created_at"`
+	UpdatedAt                   time.Time `json:"updated_at"`
+}
+
+type SpendingRuleDto struct {
+	PublicKey                   string   `json:"public_key"`
+	PluginType                  string   `json:"plugin_type"`
+	ChainID                     string   `json:"chain_id" validate:"omitempty,numeric"`
+	Token                       string   `json:"token" validate:"required_with=MaxAmountPerTx MaxAmountPerDay MaxAmountPerRecipientPerDay MaxAllowance"`
+	MaxAmountPerTx              string   `json:"max_amount_per_tx" validate:"omitempty,numeric"`
+	MaxAmountPerDay             string   `json:"max_amount_per_day" validate:"omitempty,numeric"`
+	MaxAmountPerRecipientPerDay string   `json:"max_amount_per_recipient_per_day" validate:"omitempty,numeric"`
+	MaxTransactionsPerDay       int64    `json:"max_transactions_per_day" validate:"gte=0"`
+	MaxAllowance                string   `json:"max_allowance" validate:"omitempty,numeric"`
+	AllowedTokens               []string `json:"allowed_tokens"`
+	AllowedContracts            []string `json:"allowed_contracts"`
+	Active                      bool     `json:"active"`
+}
+
+// RuleDecision records the outcome of evaluating the spending rules for a
+// transaction the verifier was asked to sign.
+type RuleDecision struct {
+	ID         uuid.UUID `json:"id"`
+	PolicyID   uuid.UUID `json:"policy_id"`
+	TxHash     string    `json:"tx_hash"`
+	PublicKey  string    `json:"public_key"`
+	PluginType string    `json:"plugin_type"`
+	Allowed    bool      `json:"allowed"`
+	Reasons    []string  `json:"reasons"`
+	RuleIDs    []string  `json:"rule_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// VaultTransaction is a transaction the verifier signed for a vault.
+type VaultTransaction struct {
+	TxHash     string    `json:"tx_hash"`
+	TxBody     string    `json:"tx_body"`
+	PluginType string    `json:"plugin_type"`
+	SignedAt   time.Time `json:"signed_at"`
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SyncEventType string
+
+const (
+	SyncEventPolicyCreate      SyncEventType = "policy.create"
+	SyncEventPolicyUpdate      SyncEventType = "policy.update"
+	SyncEventPolicyDelete      SyncEventType = "policy.delete"
+	SyncEventTransactionCreate SyncEventType = "transaction.create"
+	SyncEventTransactionUpdate SyncEventType = "transaction.update"
+)
+
+type SyncEventStatus string
+
+const (
+	SyncEventStatusPending   SyncEventStatus = "PENDING"
+	SyncEventStatusDelivered SyncEventStatus = "DELIVERED"
+	SyncEventStatusFailed    SyncEventStatus = "FAILED"
+)
+
+// SyncEvent is an outbox entry delivered from the plugin to the verifier.
+// Events sharing an AggregateID (the policy ID) are delivered in order.
+type SyncEvent struct {
+	ID             int64           `json:"id"`
+	IdempotencyKey uuid.UUID       `json:"idempotency_key"`
+	AggregateID    string          `json:"aggregate_id"`
+	EventType      SyncEventType   `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         SyncEventStatus `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type PolicyDeleteSyncPayload struct {
+	PolicyID  string `json:"policy_id"`
+	Signature string `json:"signature"`
+	Nonce     string `json:"nonce"`
+	Expiry    int64  `json:"expiry"`
+}
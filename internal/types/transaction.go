@@ -1,6 +1,10 @@
 package types
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,16 +22,60 @@ const (
 	StatusRejected          TransactionStatus = "REJECTED"
 )
 
+// TransactionHistory is a transaction proposed for a policy. TxHash is the
+// signing hash the vault signs, BroadcastHash the hash of the signed
+// transaction on chain, known once signed. The typed fields describe the
+// transaction, Metadata holds what the plugin wants to keep besides. Amounts
+// and gas prices are decimal strings in base units, empty when unknown.
 type TransactionHistory struct {
-	ID           uuid.UUID              `json:"id"`
-	PolicyID     uuid.UUID              `json:"policy_id"`
-	TxBody       string                 `json:"tx_body"`
-	TxHash       string                 `json:"tx_hash"`
-	Status       TransactionStatus      `json:"status"`
-	CreatedAt    time.Time              `json:"created_at"`
-	UpdatedAt    time.Time              `json:"updated_at"`
-	Metadata     map[string]interface{} `json:"metadata"`
-	ErrorMessage *string                `json:"error_message,omitempty"`
+	ID            uuid.UUID              `json:"id"`
+	PolicyID      uuid.UUID              `json:"policy_id"`
+	TxBody        string                 `json:"tx_body"`
+	TxHash        string                 `json:"tx_hash"`
+	BroadcastHash string                 `json:"broadcast_hash,omitempty"`
+	Status        TransactionStatus      `json:"status"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	ErrorMessage  *string                `json:"error_message,omitempty"`
+	TxType        string                 `json:"tx_type,omitempty"`
+	ChainID       string                 `json:"chain_id,omitempty"`
+	FromAddress   string                 `json:"from_address,omitempty"`
+	ToAddress     string                 `json:"to_address,omitempty"`
+	Token         string                 `json:"token,omitempty"`
+	Amount        string                 `json:"amount,omitempty"`
+	Nonce         *uint64                `json:"nonce,omitempty"`
+	GasLimit      *uint64                `json:"gas_limit,omitempty"`
+	GasPrice      string                 `json:"gas_price,omitempty"`
+	GasFee        string                 `json:"gas_fee,omitempty"`
+	SigningTaskID string                 `json:"signing_task_id,omitempty"`
+	BlockNumber   *uint64                `json:"block_number,omitempty"`
+	ErrorCode     string                 `json:"error_code,omitempty"`
+}
+
+// TransactionUpdate moves a transaction to a new status. Metadata is merged
+// into the stored metadata, other empty fields keep their stored values.
+type TransactionUpdate struct {
+	Status        TransactionStatus
+	Metadata      map[string]interface{}
+	BroadcastHash string
+	SigningTaskID string
+	BlockNumber   *uint64
+	ErrorCode     string
+	ErrorMessage  *string
+}
+
+// Update returns the update setting the status and details of tx.
+func (tx TransactionHistory) Update() TransactionUpdate {
+	return TransactionUpdate{
+		Status:        tx.Status,
+		Metadata:      tx.Metadata,
+		BroadcastHash: tx.BroadcastHash,
+		SigningTaskID: tx.SigningTaskID,
+		BlockNumber:   tx.BlockNumber,
+		ErrorCode:     tx.ErrorCode,
+		ErrorMessage:  tx.ErrorMessage,
+	}
 }
 
 type SignedTransaction struct {
@@ -38,3 +86,73 @@ type SignedTransaction struct {
 	Metadata    map[string]any
 	BroadcastAt *time.Time
 }
+
+// SpentTransactionStatuses are the statuses of transactions that may have been
+// executed on chain, counted in the history totals.
+var SpentTransactionStatuses = []TransactionStatus{StatusSigned, StatusBroadcast, StatusMined}
+
+// TransactionHistoryQuery selects transactions of a vault's policies. Empty
+// fields don't filter. From is inclusive and To exclusive.
+type TransactionHistoryQuery struct {
+	PublicKey        string
+	PolicyID         string
+	Statuses         []TransactionStatus
+	TransactionTypes []string
+	From             *time.Time
+	To               *time.Time
+	Token            string
+	ChainID          string
+	TxHash           string
+	Ascending        bool
+	Cursor           *TransactionCursor
+	Limit            int
+}
+
+// TransactionCursor points at the last transaction of a page, pages continue
+// after it in the query's order.
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (c TransactionCursor) String() string {
+	raw := fmt.Sprintf("%d:%s", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseTransactionCursor(cursor string) (*TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	txID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	return &TransactionCursor{CreatedAt: time.Unix(0, unixNano).UTC(), ID: txID}, nil
+}
+
+// TransactionTotal sums the spent transactions of a token. Amounts are in
+// base units of the token, gas in base units of the chain's native coin at the
+// transactions' gas limits.
+type TransactionTotal struct {
+	ChainID     string `json:"chain_id"`
+	Token       string `json:"token"`
+	Count       int64  `json:"count"`
+	AmountSpent string `json:"amount_spent"`
+	GasPaid     string `json:"gas_paid"`
+}
+
+type TransactionHistoryPage struct {
+	Transactions []TransactionHistory `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+	Totals       []TransactionTotal   `json:"totals"`
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FinishedTransactionStatuses are the statuses a transaction doesn't leave,
+// the only ones retention archives or purges.
+var FinishedTransactionStatuses = []TransactionStatus{StatusMined, StatusSigningFailed, StatusRejected}
+
+// ArchivedTransaction is a transaction as written to an archive, with the
+// signing details kept beside it.
+type ArchivedTransaction struct {
+	TransactionHistory
+	SignedAt    *time.Time      `json:"signed_at,omitempty"`
+	SignedType  string          `json:"signed_type,omitempty"`
+	Attestation json.RawMessage `json:"attestation,omitempty"`
+}
+
+// TransactionArchive is an object in block storage holding archived
+// transactions, one JSON document per line, gzip compressed.
+type TransactionArchive struct {
+	ID             uuid.UUID `json:"id"`
+	ObjectName     string    `json:"object_name"`
+	Count          int       `json:"count"`
+	FirstCreatedAt time.Time `json:"first_created_at"`
+	LastCreatedAt  time.Time `json:"last_created_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TransactionAggregate sums the archived transactions of a policy created on
+// a day. Amount and GasFee are decimal strings in base units.
+type TransactionAggregate struct {
+	PolicyID uuid.UUID         `json:"policy_id"`
+	Day      time.Time         `json:"day"`
+	ChainID  string            `json:"chain_id"`
+	Token    string            `json:"token"`
+	TxType   string            `json:"tx_type"`
+	Status   TransactionStatus `json:"status"`
+	Count    int64             `json:"count"`
+	Amount   string            `json:"amount"`
+	GasFee   string            `json:"gas_fee"`
+}
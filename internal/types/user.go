@@ -7,15 +7,28 @@ type UserAuthDto struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
 type User struct {
 	ID        string    `json:"id" validate:"required"`
 	Username  string    `json:"username" validate:"required"`
+	Role      UserRole  `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+func (u User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
 type UserWithPassword struct {
 	ID        string    `json:"id" validate:"required"`
 	Username  string    `json:"username" validate:"required"`
 	Password  string    `json:"password" validate:"required"`
+	Role      UserRole  `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 }
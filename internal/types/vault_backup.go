@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type VaultBackupReason string
+
+const (
+	// VaultBackupSaved is a backup written by keygen, reshare or migration. The
+	// PRE_ reasons snapshot the backup before the operation replaces it.
+	VaultBackupSaved      VaultBackupReason = "SAVED"
+	VaultBackupPreReshare VaultBackupReason = "PRE_RESHARE"
+	VaultBackupPreMigrate VaultBackupReason = "PRE_MIGRATE"
+	VaultBackupPreRestore VaultBackupReason = "PRE_RESTORE"
+	VaultBackupRestored   VaultBackupReason = "RESTORED"
+)
+
+// VaultBackupVersion is a copy of a vault backup as it was stored at some
+// point, kept in block storage under FileName.
+type VaultBackupVersion struct {
+	ID            uuid.UUID         `json:"id"`
+	PublicKey     string            `json:"public_key"`
+	Version       int               `json:"version"`
+	FileName      string            `json:"file_name"`
+	ContentHash   string            `json:"content_hash"`
+	LibType       string            `json:"lib_type"`
+	Signers       []string          `json:"signers"`
+	ResharePrefix string            `json:"reshare_prefix"`
+	SessionID     string            `json:"session_id"`
+	Reason        VaultBackupReason `json:"reason"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventTransactionStatus WebhookEventType = "transaction.status_changed"
+	WebhookEventPolicyCreated     WebhookEventType = "policy.created"
+	WebhookEventPolicyUpdated     WebhookEventType = "policy.updated"
+	WebhookEventPolicyDeleted     WebhookEventType = "policy.deleted"
+	WebhookEventPolicyRunFailed   WebhookEventType = "policy_run.failed"
+)
+
+// WebhookSubscription delivers the events of a vault, or of one of its
+// policies when PolicyID is set. Empty EventTypes subscribes to all events.
+type WebhookSubscription struct {
+	ID         uuid.UUID `json:"id"`
+	PublicKey  string    `json:"public_key"`
+	PolicyID   string    `json:"policy_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookSubscriptionDto struct {
+	PublicKey  string   `json:"public_key" validate:"required"`
+	PolicyID   string   `json:"policy_id" validate:"omitempty,uuid"`
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=transaction.status_changed policy.created policy.updated policy.deleted policy_run.failed"`
+}
+
+// WebhookEvent is the JSON body posted to subscribers.
+type WebhookEvent struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	PublicKey string           `json:"public_key"`
+	PolicyID  string           `json:"policy_id,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      any              `json:"data"`
+}
+
+// WebhookDelivery is a pending delivery of an event to a subscription.
+type WebhookDelivery struct {
+	ID             int64            `json:"id"`
+	SubscriptionID uuid.UUID        `json:"subscription_id"`
+	EventID        uuid.UUID        `json:"event_id"`
+	EventType      WebhookEventType `json:"event_type"`
+	Payload        json.RawMessage  `json:"payload"`
+	Attempts       int              `json:"attempts"`
+	URL            string           `json:"-"`
+	Secret         string           `json:"-"`
+}
+
+// WebhookDeadLetter is a delivery that failed every attempt. Replaying it
+// queues it for delivery again.
+type WebhookDeadLetter struct {
+	ID             int64            `json:"id"`
+	SubscriptionID uuid.UUID        `json:"subscription_id"`
+	PublicKey      string           `json:"public_key"`
+	EventID        uuid.UUID        `json:"event_id"`
+	EventType      WebhookEventType `json:"event_type"`
+	Payload        json.RawMessage  `json:"payload"`
+	Attempts       int              `json:"attempts"`
+	LastError      string           `json:"last_error"`
+	FailedAt       time.Time        `json:"failed_at"`
+}
@@ -0,0 +1,222 @@
+package vaultbackup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// DefaultKeep is the number of versions kept per vault when none is configured.
+const DefaultKeep = 5
+
+// Info describes the vault a backup holds.
+type Info struct {
+	LibType       string
+	Signers       []string
+	ResharePrefix string
+	// SessionID is the session that wrote the backup, or for snapshots the
+	// session about to replace it.
+	SessionID string
+}
+
+func InfoFromVault(vault *vaultType.Vault, sessionID string) Info {
+	return Info{
+		LibType:       vault.LibType.String(),
+		Signers:       vault.Signers,
+		ResharePrefix: vault.ResharePrefix,
+		SessionID:     sessionID,
+	}
+}
+
+// Manager keeps copies of the vault backups in block storage. The backup the
+// vault is loaded from stays at common.GetVaultBackupFilename, every version
+// is a separate file recorded in the database. Only the latest versions of a
+// vault are kept.
+type Manager struct {
+	db     storage.DatabaseStorage
+	blocks storage.BlockStorage
+	keep   int
+	logger *logrus.Logger
+}
+
+func NewManager(db storage.DatabaseStorage, blocks storage.BlockStorage, keep int, logger *logrus.Logger) *Manager {
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	return &Manager{
+		db:     db,
+		blocks: blocks,
+		keep:   keep,
+		logger: logger,
+	}
+}
+
+// Save replaces the backup of the vault with content and records it as a new
+// version.
+func (m *Manager) Save(ctx context.Context, publicKey string, content []byte, info Info) (*types.VaultBackupVersion, error) {
+	if err := storage.UploadFileWithRetry(m.blocks, content, common.GetVaultBackupFilename(publicKey), 5); err != nil {
+		return nil, fmt.Errorf("failed to upload vault backup: %w", err)
+	}
+
+	version, err := m.record(ctx, publicKey, content, info, types.VaultBackupSaved)
+	if err != nil {
+		return nil, err
+	}
+	m.prune(ctx, publicKey)
+
+	return version, nil
+}
+
+// Snapshot records the current backup of the vault as a version before an
+// operation replaces it. It does nothing if there is no backup, and returns
+// the latest version if that already holds the backup.
+func (m *Manager) Snapshot(ctx context.Context, publicKey string, info Info, reason types.VaultBackupReason) (*types.VaultBackupVersion, error) {
+	fileName := common.GetVaultBackupFilename(publicKey)
+	exist, err := m.blocks.FileExist(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check vault backup: %w", err)
+	}
+	if !exist {
+		return nil, nil
+	}
+	content, err := m.blocks.GetFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault backup: %w", err)
+	}
+
+	versions, err := m.db.GetVaultBackupVersions(ctx, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 && versions[0].ContentHash == contentHash(content) {
+		return &versions[0], nil
+	}
+
+	version, err := m.record(ctx, publicKey, content, info, reason)
+	if err != nil {
+		return nil, err
+	}
+	m.prune(ctx, publicKey)
+
+	return version, nil
+}
+
+// List returns the versions of the vault, latest first.
+func (m *Manager) List(ctx context.Context, publicKey string) ([]types.VaultBackupVersion, error) {
+	return m.db.GetVaultBackupVersions(ctx, publicKey)
+}
+
+// Restore makes the version the backup of the vault again. The backup it
+// replaces is snapshotted first, so a restore can be undone.
+func (m *Manager) Restore(ctx context.Context, publicKey string, number int) (*types.VaultBackupVersion, error) {
+	version, err := m.db.GetVaultBackupVersion(ctx, publicKey, number)
+	if err != nil {
+		return nil, err
+	}
+	content, err := m.blocks.GetFile(version.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault backup version %d: %w", number, err)
+	}
+	if contentHash(content) != version.ContentHash {
+		return nil, fmt.Errorf("vault backup version %d doesn't match its hash", number)
+	}
+
+	// the metadata of the current backup is the latest recorded
+	var current Info
+	versions, err := m.db.GetVaultBackupVersions(ctx, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		current = infoFromVersion(versions[0])
+	}
+	if _, err := m.Snapshot(ctx, publicKey, current, types.VaultBackupPreRestore); err != nil {
+		return nil, fmt.Errorf("failed to snapshot vault backup: %w", err)
+	}
+
+	if err := storage.UploadFileWithRetry(m.blocks, content, common.GetVaultBackupFilename(publicKey), 5); err != nil {
+		return nil, fmt.Errorf("failed to upload vault backup: %w", err)
+	}
+	restored, err := m.record(ctx, publicKey, content, infoFromVersion(*version), types.VaultBackupRestored)
+	if err != nil {
+		return nil, err
+	}
+	m.prune(ctx, publicKey)
+
+	return restored, nil
+}
+
+// record copies content to the file of its hash and records it as a version.
+func (m *Manager) record(ctx context.Context, publicKey string, content []byte, info Info, reason types.VaultBackupReason) (*types.VaultBackupVersion, error) {
+	hash := contentHash(content)
+	fileName := common.GetVaultBackupVersionFilename(publicKey, hash)
+	if err := storage.UploadFileWithRetry(m.blocks, content, fileName, 5); err != nil {
+		return nil, fmt.Errorf("failed to upload vault backup version: %w", err)
+	}
+
+	return m.db.CreateVaultBackupVersion(ctx, types.VaultBackupVersion{
+		PublicKey:     publicKey,
+		FileName:      fileName,
+		ContentHash:   hash,
+		LibType:       info.LibType,
+		Signers:       info.Signers,
+		ResharePrefix: info.ResharePrefix,
+		SessionID:     info.SessionID,
+		Reason:        reason,
+	})
+}
+
+// prune deletes the versions of the vault beyond the ones kept, and their files
+// unless a kept version has the same content. Failures are logged, the
+// versions are pruned again on the next save.
+func (m *Manager) prune(ctx context.Context, publicKey string) {
+	versions, err := m.db.GetVaultBackupVersions(ctx, publicKey)
+	if err != nil {
+		m.logger.Errorf("failed to get vault backup versions: %v", err)
+		return
+	}
+	if len(versions) <= m.keep {
+		return
+	}
+
+	kept := make(map[string]bool)
+	for _, version := range versions[:m.keep] {
+		kept[version.FileName] = true
+	}
+	for _, version := range versions[m.keep:] {
+		if err := m.db.DeleteVaultBackupVersion(ctx, version.ID); err != nil {
+			m.logger.Errorf("failed to delete vault backup version %d: %v", version.Version, err)
+			continue
+		}
+		if kept[version.FileName] {
+			continue
+		}
+		// older versions sharing the file don't delete it again
+		kept[version.FileName] = true
+		if err := m.blocks.DeleteFile(version.FileName); err != nil {
+			m.logger.Errorf("failed to delete vault backup version %d: %v", version.Version, err)
+		}
+	}
+}
+
+func infoFromVersion(version types.VaultBackupVersion) Info {
+	return Info{
+		LibType:       version.LibType,
+		Signers:       version.Signers,
+		ResharePrefix: version.ResharePrefix,
+		SessionID:     version.SessionID,
+	}
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
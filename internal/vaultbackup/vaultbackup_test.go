@@ -0,0 +1,127 @@
+package vaultbackup_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/vaultbackup"
+	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+const publicKey = "02a1b2c3"
+
+func newManager(t *testing.T, keep int) (*vaultbackup.Manager, storage.BlockStorage) {
+	blocks, err := storage.NewLocalBlockStorage(t.TempDir())
+	require.NoError(t, err)
+	return vaultbackup.NewManager(memory.NewMemoryBackend(), blocks, keep, logrus.New()), blocks
+}
+
+func TestSaveKeepsVersions(t *testing.T) {
+	ctx := context.Background()
+	manager, blocks := newManager(t, 2)
+
+	var saved []*types.VaultBackupVersion
+	for i := 1; i <= 3; i++ {
+		version, err := manager.Save(ctx, publicKey, []byte(fmt.Sprintf("backup %d", i)), vaultbackup.Info{
+			LibType:   "LIB_TYPE_DKLS",
+			Signers:   []string{"server", "device"},
+			SessionID: fmt.Sprintf("session-%d", i),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, i, version.Version)
+		assert.Equal(t, types.VaultBackupSaved, version.Reason)
+		saved = append(saved, version)
+	}
+
+	content, err := blocks.GetFile(common.GetVaultBackupFilename(publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("backup 3"), content)
+
+	versions, err := manager.List(ctx, publicKey)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 3, versions[0].Version)
+	assert.Equal(t, 2, versions[1].Version)
+	assert.Equal(t, "session-3", versions[0].SessionID)
+	assert.Equal(t, []string{"server", "device"}, versions[0].Signers)
+
+	exist, err := blocks.FileExist(saved[0].FileName)
+	require.NoError(t, err)
+	assert.False(t, exist, "pruned version file is deleted")
+	content, err = blocks.GetFile(versions[1].FileName)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("backup 2"), content)
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	manager, blocks := newManager(t, 5)
+	info := vaultbackup.Info{LibType: "LIB_TYPE_GG20", SessionID: "reshare"}
+
+	version, err := manager.Snapshot(ctx, publicKey, info, types.VaultBackupPreReshare)
+	require.NoError(t, err)
+	assert.Nil(t, version, "nothing to snapshot without a backup")
+
+	// a backup written before versioning
+	require.NoError(t, blocks.UploadFile([]byte("legacy"), common.GetVaultBackupFilename(publicKey)))
+	version, err = manager.Snapshot(ctx, publicKey, info, types.VaultBackupPreReshare)
+	require.NoError(t, err)
+	require.NotNil(t, version)
+	assert.Equal(t, 1, version.Version)
+	assert.Equal(t, types.VaultBackupPreReshare, version.Reason)
+
+	again, err := manager.Snapshot(ctx, publicKey, info, types.VaultBackupPreReshare)
+	require.NoError(t, err)
+	assert.Equal(t, version.ID, again.ID, "unchanged backup is not copied again")
+
+	versions, err := manager.List(ctx, publicKey)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	manager, blocks := newManager(t, 5)
+
+	_, err := manager.Save(ctx, publicKey, []byte("before reshare"), vaultbackup.Info{SessionID: "keygen"})
+	require.NoError(t, err)
+	_, err = manager.Save(ctx, publicKey, []byte("broken reshare"), vaultbackup.Info{SessionID: "reshare"})
+	require.NoError(t, err)
+
+	restored, err := manager.Restore(ctx, publicKey, 1)
+	require.NoError(t, err)
+	assert.Equal(t, types.VaultBackupRestored, restored.Reason)
+	assert.Equal(t, "keygen", restored.SessionID)
+
+	content, err := blocks.GetFile(common.GetVaultBackupFilename(publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("before reshare"), content)
+
+	versions, err := manager.List(ctx, publicKey)
+	require.NoError(t, err)
+	require.Len(t, versions, 3, "the replaced backup was saved already and isn't snapshotted again")
+	assert.Equal(t, restored.ID, versions[0].ID)
+
+	_, err = manager.Restore(ctx, publicKey, 10)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestRestoreRejectsModifiedVersion(t *testing.T) {
+	ctx := context.Background()
+	manager, blocks := newManager(t, 5)
+
+	version, err := manager.Save(ctx, publicKey, []byte("backup"), vaultbackup.Info{})
+	require.NoError(t, err)
+	require.NoError(t, blocks.UploadFile([]byte("tampered"), version.FileName))
+
+	_, err = manager.Restore(ctx, publicKey, version.Version)
+	assert.Error(t, err)
+}
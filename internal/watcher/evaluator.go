@@ -0,0 +1,315 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/pkg/uniswap"
+)
+
+const (
+	// maxLogRange bounds a single eth_getLogs call made by the watcher.
+	maxLogRange = 2000
+	// defaultLookbackBlocks is how far back from the head the watcher and the
+	// verifier look for a matching log, by default a whole eth_getLogs range.
+	defaultLookbackBlocks = maxLogRange - 1
+)
+
+var ErrConditionNotMet = errors.New("trigger condition is not met")
+
+type EvaluatorConfig struct {
+	RpcURL  string `mapstructure:"rpc_url" json:"rpc_url"`
+	Uniswap struct {
+		V2Router string `mapstructure:"v2_router" json:"v2_router"`
+	} `mapstructure:"uniswap" json:"uniswap"`
+}
+
+// Evaluator checks event trigger conditions against the chain. It is used by
+// the watcher to fire policies and by the verifier to re-check a condition
+// before co-signing.
+type Evaluator struct {
+	rpcClient     *ethclient.Client
+	uniswapClient *uniswap.Client
+
+	// chainID is the chain of the RPC endpoint, cached once it was fetched
+	chainIDMu sync.Mutex
+	chainID   *big.Int
+}
+
+type Evaluation struct {
+	Fired bool
+	// Checkpoint is the last block inspected for contract events, if any.
+	Checkpoint *uint64
+}
+
+func NewEvaluator(rawConfig map[string]interface{}) (*Evaluator, error) {
+	var cfg EvaluatorConfig
+	if err := mapstructure.Decode(rawConfig, &cfg); err != nil {
+		return nil, err
+	}
+
+	rpcClient, err := ethclient.Dial(cfg.RpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("fail to connect to RPC client: %w", err)
+	}
+
+	evaluator := &Evaluator{
+		rpcClient: rpcClient,
+	}
+
+	if cfg.Uniswap.V2Router != "" {
+		routerAddress := gcommon.HexToAddress(cfg.Uniswap.V2Router)
+		uniswapClient, err := uniswap.NewClient(uniswap.NewConfig(rpcClient, &routerAddress, 0, 0, 0))
+		if err != nil {
+			return nil, fmt.Errorf("fail to initialize Uniswap client: %w", err)
+		}
+		evaluator.uniswapClient = uniswapClient
+	}
+
+	return evaluator, nil
+}
+
+// Evaluate checks the trigger condition. Contract events are searched from the
+// block after the trigger checkpoint; a trigger without a checkpoint only
+// records the current head so that historical logs do not fire it.
+func (e *Evaluator) Evaluate(ctx context.Context, policy types.PluginPolicy, trigger types.EventTrigger) (*Evaluation, error) {
+	switch trigger.TriggerType {
+	case types.EventTriggerTypePrice:
+		var cond types.PriceCondition
+		if err := json.Unmarshal(trigger.Condition, &cond); err != nil {
+			return nil, fmt.Errorf("failed to parse price condition: %w", err)
+		}
+		fired, err := e.evaluatePrice(ctx, cond)
+		if err != nil {
+			return nil, err
+		}
+		return &Evaluation{Fired: fired}, nil
+	case types.EventTriggerTypeBalance:
+		var cond types.BalanceCondition
+		if err := json.Unmarshal(trigger.Condition, &cond); err != nil {
+			return nil, fmt.Errorf("failed to parse balance condition: %w", err)
+		}
+		fired, err := e.evaluateBalance(ctx, policy, cond)
+		if err != nil {
+			return nil, err
+		}
+		return &Evaluation{Fired: fired}, nil
+	case types.EventTriggerTypeContractEvent:
+		var cond types.ContractEventCondition
+		if err := json.Unmarshal(trigger.Condition, &cond); err != nil {
+			return nil, fmt.Errorf("failed to parse event condition: %w", err)
+		}
+		latest, err := e.rpcClient.BlockNumber(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest block: %w", err)
+		}
+		if trigger.LastCheckedBlock == nil || *trigger.LastCheckedBlock >= latest {
+			return &Evaluation{Checkpoint: &latest}, nil
+		}
+
+		// blocks older than the lookback are skipped, the verifier wouldn't
+		// find their logs when it re-checks the condition
+		fromBlock := max(*trigger.LastCheckedBlock+1, lookbackFrom(cond, latest))
+		fired, err := e.evaluateContractEvent(ctx, cond, fromBlock, latest)
+		if err != nil {
+			return nil, err
+		}
+		return &Evaluation{Fired: fired, Checkpoint: &latest}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported trigger type: %s", trigger.TriggerType)
+}
+
+// Recheck verifies that the trigger declared by the policy holds right now.
+// Policies without a trigger section are time based and always pass.
+func (e *Evaluator) Recheck(ctx context.Context, policy types.PluginPolicy) error {
+	policyTrigger, err := GetPolicyTrigger(policy)
+	if err != nil {
+		return err
+	}
+	if policyTrigger == nil {
+		return nil
+	}
+
+	var fired bool
+	switch policyTrigger.Type {
+	case types.EventTriggerTypePrice:
+		fired, err = e.evaluatePrice(ctx, *policyTrigger.Price)
+	case types.EventTriggerTypeBalance:
+		fired, err = e.evaluateBalance(ctx, policy, *policyTrigger.Balance)
+	case types.EventTriggerTypeContractEvent:
+		var latest uint64
+		latest, err = e.rpcClient.BlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get latest block: %w", err)
+		}
+		fromBlock := lookbackFrom(*policyTrigger.Event, latest)
+		fired, err = e.evaluateContractEvent(ctx, *policyTrigger.Event, fromBlock, latest)
+	default:
+		return fmt.Errorf("unsupported trigger type: %s", policyTrigger.Type)
+	}
+	if err != nil {
+		return err
+	}
+	if !fired {
+		return ErrConditionNotMet
+	}
+
+	return nil
+}
+
+// lookbackFrom returns the first block searched for a log of the condition,
+// the lookback is capped to a single eth_getLogs range.
+func lookbackFrom(cond types.ContractEventCondition, latest uint64) uint64 {
+	lookback := cond.LookbackBlocks
+	if lookback == 0 || lookback > defaultLookbackBlocks {
+		lookback = defaultLookbackBlocks
+	}
+	if latest <= lookback {
+		return 0
+	}
+	return latest - lookback
+}
+
+func (e *Evaluator) evaluatePrice(ctx context.Context, cond types.PriceCondition) (bool, error) {
+	if e.uniswapClient == nil {
+		return false, errors.New("price conditions require a uniswap router")
+	}
+	if err := e.checkChainID(ctx, cond.ChainID); err != nil {
+		return false, err
+	}
+
+	amountIn, ok := new(big.Int).SetString(cond.Amount, 10)
+	if !ok {
+		return false, fmt.Errorf("invalid price amount: %s", cond.Amount)
+	}
+	threshold, ok := new(big.Int).SetString(cond.Threshold, 10)
+	if !ok {
+		return false, fmt.Errorf("invalid price threshold: %s", cond.Threshold)
+	}
+
+	path := []gcommon.Address{gcommon.HexToAddress(cond.BaseTokenID), gcommon.HexToAddress(cond.QuoteTokenID)}
+	amountOut, err := e.uniswapClient.GetExpectedAmountOut(amountIn, path)
+	if err != nil {
+		return false, fmt.Errorf("failed to get price quote: %w", err)
+	}
+
+	return cond.Operator.Compare(amountOut, threshold), nil
+}
+
+func (e *Evaluator) evaluateBalance(ctx context.Context, policy types.PluginPolicy, cond types.BalanceCondition) (bool, error) {
+	if err := e.checkChainID(ctx, cond.ChainID); err != nil {
+		return false, err
+	}
+
+	threshold, ok := new(big.Int).SetString(cond.Threshold, 10)
+	if !ok {
+		return false, fmt.Errorf("invalid balance threshold: %s", cond.Threshold)
+	}
+
+	var address gcommon.Address
+	if cond.Address != "" {
+		address = gcommon.HexToAddress(cond.Address)
+	} else {
+		vaultAddress, err := common.DeriveAddress(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath)
+		if err != nil {
+			return false, fmt.Errorf("failed to derive vault address: %w", err)
+		}
+		address = *vaultAddress
+	}
+
+	var balance *big.Int
+	var err error
+	if cond.TokenID == "" {
+		balance, err = e.rpcClient.BalanceAt(ctx, address, nil)
+	} else {
+		balance, err = e.tokenBalance(ctx, address, gcommon.HexToAddress(cond.TokenID))
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return cond.Operator.Compare(balance, threshold), nil
+}
+
+func (e *Evaluator) evaluateContractEvent(ctx context.Context, cond types.ContractEventCondition, fromBlock, toBlock uint64) (bool, error) {
+	if err := e.checkChainID(ctx, cond.ChainID); err != nil {
+		return false, err
+	}
+
+	topics := [][]gcommon.Hash{{crypto.Keccak256Hash([]byte(cond.EventSignature))}}
+	for _, topic := range cond.Topics {
+		if topic == "" {
+			topics = append(topics, nil)
+			continue
+		}
+		topics = append(topics, []gcommon.Hash{gcommon.HexToHash(topic)})
+	}
+
+	logs, err := e.rpcClient.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: []gcommon.Address{gcommon.HexToAddress(cond.ContractAddress)},
+		Topics:    topics,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to filter logs: %w", err)
+	}
+
+	return len(logs) > 0, nil
+}
+
+// tokenBalance calls balanceOf(address) on an ERC20 token.
+func (e *Evaluator) tokenBalance(ctx context.Context, owner, token gcommon.Address) (*big.Int, error) {
+	data := append(crypto.Keccak256([]byte("balanceOf(address)"))[:4], gcommon.LeftPadBytes(owner.Bytes(), 32)...)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := e.rpcClient.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(result), nil
+}
+
+// checkChainID checks the condition's chain against the RPC endpoint's. A
+// failed lookup is not cached, the next check asks the endpoint again.
+func (e *Evaluator) checkChainID(ctx context.Context, chainID string) error {
+	rpcChainID, err := e.rpcChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id: %w", err)
+	}
+	if chainID != "" && chainID != rpcChainID.String() {
+		return fmt.Errorf("condition chain id %s does not match rpc chain id %s", chainID, rpcChainID.String())
+	}
+
+	return nil
+}
+
+func (e *Evaluator) rpcChainID(ctx context.Context) (*big.Int, error) {
+	e.chainIDMu.Lock()
+	defer e.chainIDMu.Unlock()
+
+	if e.chainID == nil {
+		chainID, err := e.rpcClient.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.chainID = chainID
+	}
+	return e.chainID, nil
+}
@@ -0,0 +1,211 @@
+package watcher_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/ethtest"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
+)
+
+const (
+	vaultAddress    = "0x1111111111111111111111111111111111111111"
+	tokenAddress    = "0x2222222222222222222222222222222222222222"
+	contractAddress = "0x3333333333333333333333333333333333333333"
+)
+
+// newChain fakes chain 1 at block 100, on which the vault holds 100 wei and
+// 200 base units of the token.
+func newChain(t *testing.T) *ethtest.RPC {
+	rpc := ethtest.NewRPC(t)
+	rpc.Result("eth_chainId", "0x1")
+	rpc.Result("eth_blockNumber", "0x64")
+	rpc.Result("eth_getBalance", "0x64")
+	rpc.Result("eth_call", fmt.Sprintf("0x%064x", 200))
+	rpc.Result("eth_getLogs", []gtypes.Log{})
+	return rpc
+}
+
+func newEvaluator(t *testing.T, rpc *ethtest.RPC) *watcher.Evaluator {
+	evaluator, err := watcher.NewEvaluator(map[string]interface{}{"rpc_url": rpc.URL()})
+	require.NoError(t, err)
+	return evaluator
+}
+
+func eventTrigger(t *testing.T, triggerType types.EventTriggerType, condition any, checkpoint *uint64) types.EventTrigger {
+	raw, err := json.Marshal(condition)
+	require.NoError(t, err)
+	return types.EventTrigger{
+		TriggerType:      triggerType,
+		Condition:        raw,
+		LastCheckedBlock: checkpoint,
+		Status:           types.StatusTimeTriggerPending,
+	}
+}
+
+func TestEvaluateBalance(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition types.BalanceCondition
+		fired     bool
+		wantErr   bool
+	}{
+		{
+			name:      "native balance below threshold",
+			condition: types.BalanceCondition{ChainID: "1", Address: vaultAddress, Operator: types.OperatorLessThan, Threshold: "101"},
+			fired:     true,
+		},
+		{
+			name:      "native balance not below threshold",
+			condition: types.BalanceCondition{ChainID: "1", Address: vaultAddress, Operator: types.OperatorLessThan, Threshold: "100"},
+		},
+		{
+			name:      "token balance above threshold",
+			condition: types.BalanceCondition{ChainID: "1", TokenID: tokenAddress, Address: vaultAddress, Operator: types.OperatorGreaterThanOrEqual, Threshold: "200"},
+			fired:     true,
+		},
+		{
+			name:      "other chain",
+			condition: types.BalanceCondition{ChainID: "137", Address: vaultAddress, Operator: types.OperatorLessThan, Threshold: "101"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := newEvaluator(t, newChain(t))
+			evaluation, err := evaluator.Evaluate(context.Background(), types.PluginPolicy{},
+				eventTrigger(t, types.EventTriggerTypeBalance, tt.condition, nil))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.fired, evaluation.Fired)
+			assert.Nil(t, evaluation.Checkpoint)
+		})
+	}
+}
+
+func TestChainIDLookupRetried(t *testing.T) {
+	ctx := context.Background()
+	rpc := newChain(t)
+	rpc.Handle("eth_chainId", func([]json.RawMessage) (any, error) {
+		return nil, errors.New("rpc unavailable")
+	})
+	evaluator := newEvaluator(t, rpc)
+	trigger := eventTrigger(t, types.EventTriggerTypeBalance,
+		types.BalanceCondition{ChainID: "1", Address: vaultAddress, Operator: types.OperatorLessThan, Threshold: "101"}, nil)
+
+	// a failed lookup isn't cached, the endpoint is asked again
+	_, err := evaluator.Evaluate(ctx, types.PluginPolicy{}, trigger)
+	require.Error(t, err)
+
+	rpc.Result("eth_chainId", "0x1")
+	evaluation, err := evaluator.Evaluate(ctx, types.PluginPolicy{}, trigger)
+	require.NoError(t, err)
+	assert.True(t, evaluation.Fired)
+
+	// a successful one is
+	_, err = evaluator.Evaluate(ctx, types.PluginPolicy{}, trigger)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rpc.Calls("eth_chainId"))
+}
+
+func TestEvaluateContractEvent(t *testing.T) {
+	ctx := context.Background()
+	rpc := newChain(t)
+	evaluator := newEvaluator(t, rpc)
+	condition := types.ContractEventCondition{
+		ChainID:         "1",
+		ContractAddress: contractAddress,
+		EventSignature:  "Transfer(address,address,uint256)",
+	}
+
+	// without a checkpoint only the head is recorded, past logs don't fire
+	evaluation, err := evaluator.Evaluate(ctx, types.PluginPolicy{},
+		eventTrigger(t, types.EventTriggerTypeContractEvent, condition, nil))
+	require.NoError(t, err)
+	assert.False(t, evaluation.Fired)
+	require.NotNil(t, evaluation.Checkpoint)
+	assert.Equal(t, uint64(100), *evaluation.Checkpoint)
+	assert.Zero(t, rpc.Calls("eth_getLogs"))
+
+	checkpoint := uint64(90)
+	evaluation, err = evaluator.Evaluate(ctx, types.PluginPolicy{},
+		eventTrigger(t, types.EventTriggerTypeContractEvent, condition, &checkpoint))
+	require.NoError(t, err)
+	assert.False(t, evaluation.Fired)
+	assert.Equal(t, uint64(100), *evaluation.Checkpoint)
+
+	rpc.Result("eth_getLogs", []gtypes.Log{{
+		Address:     gcommon.HexToAddress(contractAddress),
+		Topics:      []gcommon.Hash{{}},
+		Data:        []byte{},
+		BlockNumber: 95,
+	}})
+	evaluation, err = evaluator.Evaluate(ctx, types.PluginPolicy{},
+		eventTrigger(t, types.EventTriggerTypeContractEvent, condition, &checkpoint))
+	require.NoError(t, err)
+	assert.True(t, evaluation.Fired)
+	assert.Equal(t, uint64(100), *evaluation.Checkpoint)
+}
+
+func TestEvaluateContractEventLookback(t *testing.T) {
+	ctx := context.Background()
+	rpc := newChain(t)
+	var fromBlocks []string
+	rpc.Handle("eth_getLogs", func(params []json.RawMessage) (any, error) {
+		var filter struct {
+			FromBlock string `json:"fromBlock"`
+		}
+		if err := json.Unmarshal(params[0], &filter); err != nil {
+			return nil, err
+		}
+		fromBlocks = append(fromBlocks, filter.FromBlock)
+		return []gtypes.Log{}, nil
+	})
+	evaluator := newEvaluator(t, rpc)
+	condition := types.ContractEventCondition{
+		ChainID:         "1",
+		ContractAddress: contractAddress,
+		EventSignature:  "Transfer(address,address,uint256)",
+		LookbackBlocks:  20,
+	}
+	policy := types.PluginPolicy{Policy: json.RawMessage(fmt.Sprintf(
+		`{"trigger":{"type":"CONTRACT_EVENT","event":{"chain_id":"1","contract_address":%q,"event_signature":"Transfer(address,address,uint256)","lookback_blocks":20}}}`, contractAddress))}
+
+	// the watcher skips the blocks the verifier wouldn't search when it re-checks
+	checkpoint := uint64(10)
+	evaluation, err := evaluator.Evaluate(ctx, policy,
+		eventTrigger(t, types.EventTriggerTypeContractEvent, condition, &checkpoint))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), *evaluation.Checkpoint)
+	assert.ErrorIs(t, evaluator.Recheck(ctx, policy), watcher.ErrConditionNotMet)
+	assert.Equal(t, []string{"0x50", "0x50"}, fromBlocks)
+}
+
+func TestRecheck(t *testing.T) {
+	ctx := context.Background()
+	evaluator := newEvaluator(t, newChain(t))
+
+	// scheduled policies have no condition to re-check
+	scheduled := types.PluginPolicy{Policy: json.RawMessage(`{"schedule":{"frequency":"daily","interval":"1"}}`)}
+	assert.NoError(t, evaluator.Recheck(ctx, scheduled))
+
+	met := types.PluginPolicy{Policy: json.RawMessage(fmt.Sprintf(
+		`{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","address":%q,"operator":"lt","threshold":"101"}}}`, vaultAddress))}
+	assert.NoError(t, evaluator.Recheck(ctx, met))
+
+	notMet := types.PluginPolicy{Policy: json.RawMessage(fmt.Sprintf(
+		`{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","address":%q,"operator":"gt","threshold":"100"}}}`, vaultAddress))}
+	assert.ErrorIs(t, evaluator.Recheck(ctx, notMet), watcher.ErrConditionNotMet)
+}
@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/internal/tasks"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// WatcherService evaluates event triggers (price, balance and contract
+// events) and enqueues a plugin transaction task when a condition is met.
+type WatcherService struct {
+	db        storage.DatabaseStorage
+	logger    *logrus.Logger
+	client    *asynq.Client
+	evaluator *Evaluator
+	done      chan struct{}
+}
+
+func NewWatcherService(db storage.DatabaseStorage, logger *logrus.Logger, client *asynq.Client, evaluator *Evaluator) *WatcherService {
+	if db == nil {
+		logger.Fatal("database connection is nil")
+	}
+
+	return &WatcherService{
+		db:        db,
+		logger:    logger,
+		client:    client,
+		evaluator: evaluator,
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *WatcherService) Start() {
+	go s.run()
+}
+
+func (s *WatcherService) Stop() {
+	close(s.done)
+}
+
+func (s *WatcherService) run() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.checkAndEnqueueTriggers(); err != nil {
+				s.logger.Errorf("Failed to check event triggers: %v", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *WatcherService) checkAndEnqueueTriggers() error {
+	ctx := context.Background()
+	triggers, err := s.db.GetPendingEventTriggers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get pending event triggers: %w", err)
+	}
+
+	for _, trigger := range triggers {
+		logger := s.logger.WithFields(logrus.Fields{
+			"policy_id":    trigger.PolicyID,
+			"trigger_type": trigger.TriggerType,
+		})
+
+		if trigger.LastTriggered != nil && time.Now().UTC().Before(trigger.LastTriggered.Add(time.Duration(trigger.Cooldown)*time.Second)) {
+			continue
+		}
+
+		policy, err := s.db.GetPluginPolicy(ctx, trigger.PolicyID)
+		if err != nil {
+			logger.Errorf("Failed to get policy: %v", err)
+			continue
+		}
+
+		evaluation, err := s.evaluator.Evaluate(ctx, policy, trigger)
+		if err != nil {
+			logger.Errorf("Failed to evaluate trigger: %v", err)
+			continue
+		}
+
+		// the checkpoint only moves past a match once its run is enqueued,
+		// otherwise the same blocks are searched again in the next round
+		if !evaluation.Fired {
+			s.updateCheckpoint(ctx, logger, trigger.PolicyID, evaluation)
+			continue
+		}
+
+		claimed, err := s.db.ClaimEventTrigger(ctx, trigger.PolicyID)
+		if err != nil {
+			logger.Errorf("Failed to update trigger status: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.db.UpdateEventTriggerLastTriggered(ctx, trigger.PolicyID); err != nil {
+			logger.Errorf("Failed to update trigger last triggered: %v", err)
+		}
+
+		buf, err := json.Marshal(types.PluginTriggerEvent{
+			PolicyID:      trigger.PolicyID,
+			TriggerSource: types.TriggerSourceEvent,
+			TriggeredAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			logger.Errorf("Failed to marshal trigger event: %v", err)
+			continue
+		}
+		ti, err := s.client.Enqueue(
+			asynq.NewTask(tasks.TypePluginTransaction, buf),
+			asynq.MaxRetry(0),
+			asynq.Timeout(5*time.Minute),
+			asynq.Retention(10*time.Minute),
+			asynq.Queue(tasks.QUEUE_NAME),
+		)
+		if err != nil {
+			logger.Errorf("Failed to enqueue trigger task: %v", err)
+			if err := s.db.UpdateEventTriggerStatus(ctx, trigger.PolicyID, types.StatusTimeTriggerPending); err != nil {
+				logger.Errorf("Failed to reset trigger status: %v", err)
+			}
+			continue
+		}
+
+		logger.WithField("task_id", ti.ID).Info("Enqueued event trigger task")
+		s.updateCheckpoint(ctx, logger, trigger.PolicyID, evaluation)
+	}
+
+	return nil
+}
+
+func (s *WatcherService) updateCheckpoint(ctx context.Context, logger *logrus.Entry, policyID string, evaluation *Evaluation) {
+	if evaluation.Checkpoint == nil {
+		return
+	}
+	if err := s.db.UpdateEventTriggerCheckpoint(ctx, policyID, *evaluation.Checkpoint); err != nil {
+		logger.Errorf("Failed to update trigger checkpoint: %v", err)
+	}
+}
+
+func (s *WatcherService) CreateEventTrigger(ctx context.Context, policy types.PluginPolicy, dbTx storage.Tx) error {
+	trigger, err := GetEventTriggerFromPolicy(policy)
+	if err != nil {
+		return fmt.Errorf("failed to get event trigger from policy: %w", err)
+	}
+
+	return s.db.CreateEventTriggerTx(ctx, dbTx, *trigger)
+}
+
+// GetPolicyTrigger returns the trigger section of the policy document, or nil
+// when the policy is driven by its schedule.
+func GetPolicyTrigger(policy types.PluginPolicy) (*types.PolicyTrigger, error) {
+	var policyTrigger struct {
+		Trigger *types.PolicyTrigger `json:"trigger"`
+	}
+	if err := json.Unmarshal(policy.Policy, &policyTrigger); err != nil {
+		return nil, fmt.Errorf("failed to parse policy trigger: %w", err)
+	}
+	if policyTrigger.Trigger == nil {
+		return nil, nil
+	}
+	if err := policyTrigger.Trigger.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid policy trigger: %w", err)
+	}
+
+	return policyTrigger.Trigger, nil
+}
+
+func GetEventTriggerFromPolicy(policy types.PluginPolicy) (*types.EventTrigger, error) {
+	policyTrigger, err := GetPolicyTrigger(policy)
+	if err != nil {
+		return nil, err
+	}
+	if policyTrigger == nil {
+		return nil, fmt.Errorf("policy does not declare an event trigger")
+	}
+
+	condition, err := policyTrigger.Condition()
+	if err != nil {
+		return nil, err
+	}
+	cooldown, err := policyTrigger.CooldownSeconds()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.EventTrigger{
+		PolicyID:    policy.ID,
+		TriggerType: policyTrigger.Type,
+		Condition:   condition,
+		Cooldown:    cooldown,
+		Status:      types.StatusTimeTriggerPending,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/ethtest"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+func TestGetPolicyTrigger(t *testing.T) {
+	address := "0x1111111111111111111111111111111111111111"
+	tests := []struct {
+		name    string
+		policy  string
+		trigger bool
+		wantErr bool
+	}{
+		{name: "schedule", policy: `{"schedule":{"frequency":"daily","interval":"1"}}`},
+		{
+			name:    "price",
+			policy:  fmt.Sprintf(`{"trigger":{"type":"PRICE","cooldown":"60","price":{"chain_id":"1","base_token_id":%q,"quote_token_id":%q,"amount":"1000","operator":"gte","threshold":"2000"}}}`, address, address),
+			trigger: true,
+		},
+		{
+			name:    "balance of the vault",
+			policy:  `{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","operator":"lt","threshold":"100"}}}`,
+			trigger: true,
+		},
+		{
+			name:    "contract event",
+			policy:  fmt.Sprintf(`{"trigger":{"type":"CONTRACT_EVENT","event":{"chain_id":"1","contract_address":%q,"event_signature":"Transfer(address,address,uint256)","topics":["",""]}}}`, address),
+			trigger: true,
+		},
+		{name: "unsupported type", policy: `{"trigger":{"type":"TIME"}}`, wantErr: true},
+		{name: "missing condition", policy: `{"trigger":{"type":"BALANCE"}}`, wantErr: true},
+		{name: "invalid cooldown", policy: `{"trigger":{"type":"BALANCE","cooldown":"-1","balance":{"chain_id":"1","operator":"lt","threshold":"100"}}}`, wantErr: true},
+		{name: "invalid operator", policy: `{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","operator":"eq","threshold":"100"}}}`, wantErr: true},
+		{name: "zero threshold", policy: `{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","operator":"lt","threshold":"0"}}}`, wantErr: true},
+		{name: "invalid token", policy: `{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","token_id":"usdc","operator":"lt","threshold":"100"}}}`, wantErr: true},
+		{
+			name:    "missing event signature",
+			policy:  fmt.Sprintf(`{"trigger":{"type":"CONTRACT_EVENT","event":{"chain_id":"1","contract_address":%q}}}`, address),
+			wantErr: true,
+		},
+		{
+			name:    "too many topics",
+			policy:  fmt.Sprintf(`{"trigger":{"type":"CONTRACT_EVENT","event":{"chain_id":"1","contract_address":%q,"event_signature":"Transfer(address,address,uint256)","topics":["","","",""]}}}`, address),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger, err := GetPolicyTrigger(types.PluginPolicy{Policy: json.RawMessage(tt.policy)})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.trigger, trigger != nil)
+		})
+	}
+}
+
+func TestCheckAndEnqueueTriggersCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+
+	rpc := ethtest.NewRPC(t)
+	rpc.Result("eth_chainId", "0x1")
+	rpc.Result("eth_blockNumber", "0x64")
+	rpc.Result("eth_getLogs", []gtypes.Log{})
+	evaluator, err := NewEvaluator(map[string]interface{}{"rpc_url": rpc.URL()})
+	require.NoError(t, err)
+
+	policy := types.PluginPolicy{
+		ID:         uuid.NewString(),
+		PublicKey:  "vault",
+		PluginType: "dca",
+		Active:     true,
+		Policy:     json.RawMessage(`{"trigger":{"type":"CONTRACT_EVENT","event":{"chain_id":"1","contract_address":"0x3333333333333333333333333333333333333333","event_signature":"Transfer(address,address,uint256)"}}}`),
+	}
+	// nothing listens on the redis address, every enqueue fails
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: "127.0.0.1:1"})
+	t.Cleanup(func() { _ = client.Close() })
+	s := NewWatcherService(db, logrus.New(), client, evaluator)
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateEventTrigger(ctx, policy, dbTx))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	checkpoint := func() *uint64 {
+		t.Helper()
+		triggers, err := db.GetPendingEventTriggers(ctx)
+		require.NoError(t, err)
+		require.Len(t, triggers, 1)
+		return triggers[0].LastCheckedBlock
+	}
+
+	// the first round records the head, the next ones move past the blocks
+	// they searched without firing the policy
+	require.NoError(t, s.checkAndEnqueueTriggers())
+	require.NotNil(t, checkpoint())
+	assert.Equal(t, uint64(100), *checkpoint())
+	assert.Zero(t, rpc.Calls("eth_getLogs"))
+
+	rpc.Result("eth_blockNumber", "0x6e")
+	require.NoError(t, s.checkAndEnqueueTriggers())
+	assert.Equal(t, uint64(110), *checkpoint())
+	assert.Equal(t, 1, rpc.Calls("eth_getLogs"))
+
+	// a match whose run can't be enqueued is searched again in the next round
+	rpc.Result("eth_blockNumber", "0x78")
+	rpc.Result("eth_getLogs", []gtypes.Log{{
+		Address:     gcommon.HexToAddress("0x3333333333333333333333333333333333333333"),
+		Topics:      []gcommon.Hash{{}},
+		Data:        []byte{},
+		BlockNumber: 115,
+	}})
+	require.NoError(t, s.checkAndEnqueueTriggers())
+	assert.Equal(t, uint64(110), *checkpoint())
+}
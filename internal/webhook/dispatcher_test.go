@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+const publicKey = "vault-public-key"
+
+// subscriber records the signed deliveries it receives and answers them with
+// the status returned by respond.
+type subscriber struct {
+	mu       sync.Mutex
+	received []http.Header
+	respond  func() int
+}
+
+func (s *subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.received = append(s.received, r.Header.Clone())
+	s.mu.Unlock()
+
+	status := http.StatusOK
+	if s.respond != nil {
+		status = s.respond()
+	}
+	w.WriteHeader(status)
+}
+
+func (s *subscriber) deliveries() []http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]http.Header(nil), s.received...)
+}
+
+// newFixture subscribes a test server to the events of the vault. The
+// dispatcher's client is swapped for one that may dial the loopback server.
+func newFixture(t *testing.T, sub *subscriber) (*Dispatcher, *memory.MemoryBackend) {
+	server := httptest.NewServer(sub)
+	t.Cleanup(server.Close)
+
+	db := memory.NewMemoryBackend()
+	_, err := db.CreateWebhookSubscription(context.Background(), types.WebhookSubscription{
+		PublicKey: publicKey,
+		URL:       server.URL,
+		Secret:    "secret",
+		Active:    true,
+	})
+	require.NoError(t, err)
+
+	d := NewDispatcher(db, logrus.New())
+	d.client = server.Client()
+	return d, db
+}
+
+func publish(t *testing.T, db *memory.MemoryBackend) {
+	require.NoError(t, Publish(context.Background(), db, types.WebhookEvent{
+		Type:      types.WebhookEventPolicyCreated,
+		PublicKey: publicKey,
+		PolicyID:  uuid.NewString(),
+	}))
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	sub := &subscriber{}
+	d, db := newFixture(t, sub)
+	publish(t, db)
+
+	require.NoError(t, d.relay(ctx))
+	deliveries := sub.deliveries()
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, string(types.WebhookEventPolicyCreated), deliveries[0].Get(HeaderEvent))
+	assert.NotEmpty(t, deliveries[0].Get(HeaderSignature))
+
+	// delivered events are not posted again
+	require.NoError(t, d.relay(ctx))
+	assert.Len(t, sub.deliveries(), 1)
+}
+
+func TestRelayReschedulesFailures(t *testing.T) {
+	ctx := context.Background()
+	sub := &subscriber{respond: func() int { return http.StatusBadGateway }}
+	d, db := newFixture(t, sub)
+	publish(t, db)
+
+	// the failed delivery waits for its backoff
+	require.NoError(t, d.relay(ctx))
+	require.NoError(t, d.relay(ctx))
+	assert.Len(t, sub.deliveries(), 1)
+}
+
+func TestRelayLeasesClaimedDeliveries(t *testing.T) {
+	ctx := context.Background()
+	sub := &subscriber{}
+	d, db := newFixture(t, sub)
+	publish(t, db)
+
+	// while the delivery is posted the claim is committed, so a concurrent
+	// relay neither waits on it nor posts it again
+	var concurrentErr error
+	var once sync.Once
+	sub.respond = func() int {
+		once.Do(func() {
+			concurrentErr = d.relay(ctx)
+		})
+		return http.StatusOK
+	}
+	require.NoError(t, d.relay(ctx))
+	assert.NoError(t, concurrentErr)
+	assert.Len(t, sub.deliveries(), 1)
+}
+
+func TestDeliverRefusesInternalTargets(t *testing.T) {
+	sub := &subscriber{}
+	server := httptest.NewServer(sub)
+	t.Cleanup(server.Close)
+
+	d := NewDispatcher(memory.NewMemoryBackend(), logrus.New())
+	err := d.deliver(context.Background(), types.WebhookDelivery{
+		EventID: uuid.New(),
+		URL:     server.URL,
+		Payload: []byte(`{}`),
+	})
+	assert.ErrorIs(t, err, ErrForbiddenTarget)
+	assert.Empty(t, sub.deliveries())
+}
@@ -0,0 +1,48 @@
+package webhook_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"type":"policy.created"}`)
+	signature := webhook.Sign("secret", now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		wantErr   bool
+	}{
+		{name: "valid", secret: "secret", timestamp: timestamp, signature: signature, body: body, now: now},
+		{name: "within tolerance", secret: "secret", timestamp: timestamp, signature: signature, body: body, now: now.Add(4 * time.Minute)},
+		{name: "wrong secret", secret: "other", timestamp: timestamp, signature: signature, body: body, now: now, wantErr: true},
+		{name: "tampered body", secret: "secret", timestamp: timestamp, signature: signature, body: []byte(`{"type":"policy.deleted"}`), now: now, wantErr: true},
+		{name: "replayed timestamp", secret: "secret", timestamp: "1699999999", signature: signature, body: body, now: now, wantErr: true},
+		{name: "stale", secret: "secret", timestamp: timestamp, signature: signature, body: body, now: now.Add(time.Hour), wantErr: true},
+		{name: "bad timestamp", secret: "secret", timestamp: "now", signature: signature, body: body, now: now, wantErr: true},
+		{name: "missing scheme", secret: "secret", timestamp: timestamp, signature: signature[len("sha256="):], body: body, now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute, tt.now)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package webhook_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		forbidden bool
+		wantErr   bool
+	}{
+		{name: "public address", url: "https://8.8.8.8/hooks"},
+		{name: "public address with port", url: "http://1.1.1.1:8080/hooks"},
+		{name: "loopback", url: "http://127.0.0.1/hooks", forbidden: true},
+		{name: "ipv6 loopback", url: "http://[::1]/hooks", forbidden: true},
+		{name: "ipv4 mapped loopback", url: "http://[::ffff:127.0.0.1]/hooks", forbidden: true},
+		{name: "private", url: "http://10.1.2.3/hooks", forbidden: true},
+		{name: "private ipv6", url: "http://[fd00::1]/hooks", forbidden: true},
+		{name: "link local metadata", url: "http://169.254.169.254/latest/meta-data", forbidden: true},
+		{name: "unspecified", url: "http://0.0.0.0/hooks", forbidden: true},
+		{name: "shared address space", url: "http://100.64.0.1/hooks", forbidden: true},
+		{name: "unsupported scheme", url: "ftp://8.8.8.8/hooks", wantErr: true},
+		{name: "missing host", url: "https:///hooks", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.ValidateURL(context.Background(), tt.url)
+			switch {
+			case tt.forbidden:
+				assert.ErrorIs(t, err, webhook.ErrForbiddenTarget)
+			case tt.wantErr:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, webhook.ErrForbiddenTarget)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const (
+	deliveryTimeout = 10 * time.Second
+
+	// Relay configuration
+	relayInterval  = 5 * time.Second
+	relayBatchSize = 50
+	initialBackoff = 5 * time.Second
+	maxBackoff     = time.Hour
+	maxAttempts    = 10
+	// claimLease holds claimed deliveries back from other dispatchers while
+	// they are posted, a dispatcher that dies mid-batch releases them once it
+	// expires
+	claimLease = 3 * deliveryTimeout
+)
+
+// PublishTx queues the event for the matching subscriptions within the
+// caller's transaction, so it is only delivered if the change commits.
+func PublishTx(ctx context.Context, db storage.DatabaseStorage, dbTx storage.Tx, event types.WebhookEvent) error {
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("fail to marshal webhook event: %w", err)
+	}
+
+	return db.InsertWebhookDeliveriesTx(ctx, dbTx, event, payload)
+}
+
+// Publish queues the event in its own transaction.
+func Publish(ctx context.Context, db storage.DatabaseStorage, event types.WebhookEvent) error {
+	dbTx, err := db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := PublishTx(ctx, db, dbTx, event); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Dispatcher posts queued deliveries to the subscribers, retrying failures with
+// backoff and moving them to the dead letter table after maxAttempts.
+type Dispatcher struct {
+	db     storage.DatabaseStorage
+	logger *logrus.Logger
+	client *http.Client
+	done   chan struct{}
+}
+
+func NewDispatcher(db storage.DatabaseStorage, logger *logrus.Logger) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		logger: logger,
+		client: newClient(),
+		done:   make(chan struct{}),
+	}
+}
+
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+func (d *Dispatcher) Stop() {
+	close(d.done)
+}
+
+func (d *Dispatcher) run() {
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.relay(context.Background()); err != nil {
+				d.logger.Errorf("Failed to relay webhooks: %v", err)
+			}
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) relay(ctx context.Context) error {
+	for {
+		processed, err := d.relayBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if processed < relayBatchSize {
+			return nil
+		}
+	}
+}
+
+// relayBatch claims a batch of due deliveries, posts them and records their
+// outcome. No transaction is held while posting: the claim is committed with a
+// lease that keeps other dispatchers off the deliveries.
+func (d *Dispatcher) relayBatch(ctx context.Context) (int, error) {
+	deliveries, err := d.claim(ctx)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		recordErr error
+	)
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.relayDelivery(ctx, delivery); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if recordErr == nil {
+					recordErr = err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), recordErr
+}
+
+// claim leases the due deliveries.
+func (d *Dispatcher) claim(ctx context.Context) ([]types.WebhookDelivery, error) {
+	dbTx, err := d.db.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	deliveries, err := d.db.GetDueWebhookDeliveriesTx(ctx, dbTx, relayBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+	if err := d.db.LeaseWebhookDeliveriesTx(ctx, dbTx, ids, time.Now().Add(claimLease)); err != nil {
+		return nil, err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deliveries, nil
+}
+
+// relayDelivery posts a claimed delivery and records the outcome. Only failing
+// to record it is returned, a failed post is retried later.
+func (d *Dispatcher) relayDelivery(ctx context.Context, delivery types.WebhookDelivery) error {
+	err := d.deliver(ctx, delivery)
+	if err == nil {
+		return d.record(ctx, func(dbTx storage.Tx) error {
+			return d.db.MarkWebhookDeliveredTx(ctx, dbTx, delivery.ID)
+		})
+	}
+
+	logger := d.logger.WithFields(logrus.Fields{
+		"delivery_id":     delivery.ID,
+		"subscription_id": delivery.SubscriptionID,
+		"event_type":      delivery.EventType,
+		"attempts":        delivery.Attempts + 1,
+	})
+
+	if delivery.Attempts+1 >= maxAttempts {
+		logger.Errorf("Webhook delivery failed, moving to dead letters: %v", err)
+		return d.record(ctx, func(dbTx storage.Tx) error {
+			return d.db.DeadLetterWebhookDeliveryTx(ctx, dbTx, delivery.ID, err.Error())
+		})
+	}
+
+	nextAttemptAt := time.Now().Add(backoff(delivery.Attempts))
+	logger.Warnf("Webhook delivery failed, retrying at %s: %v", nextAttemptAt.Format(time.RFC3339), err)
+	return d.record(ctx, func(dbTx storage.Tx) error {
+		return d.db.RescheduleWebhookDeliveryTx(ctx, dbTx, delivery.ID, err.Error(), nextAttemptAt)
+	})
+}
+
+func (d *Dispatcher) record(ctx context.Context, fn func(dbTx storage.Tx) error) error {
+	dbTx, err := d.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := fn(dbTx); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery types.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fail to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.EventID.String())
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("webhook endpoint responded with status: %d, body: %s", resp.StatusCode, string(respBody))
+}
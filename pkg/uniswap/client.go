@@ -27,7 +27,26 @@ func (uc *Client) GetRouterAddress() *common.Address {
 	return uc.cfg.routerAddress
 }
 
+func (uc *Client) GetDeadlineDuration() time.Duration {
+	return uc.cfg.deadlineDuration
+}
+
 func (uc *Client) ApproveERC20Token(chainID *big.Int, signerAddress *common.Address, tokenAddress, spenderAddress common.Address, amount *big.Int, nonceOffset uint64) ([]byte, []byte, error) {
+	tx, err := uc.BuildApproveTx(signerAddress, tokenAddress, spenderAddress, amount, nonceOffset)
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, rawTx, err := uc.rlpUnsignedTxAndHash(tx, chainID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed rlp hash tx data: %w", err)
+	}
+
+	return hash, rawTx, err
+}
+
+// BuildApproveTx builds the unsigned approve transaction with the nonce and gas
+// parameters of the current chain state.
+func (uc *Client) BuildApproveTx(signerAddress *common.Address, tokenAddress, spenderAddress common.Address, amount *big.Int, nonceOffset uint64) (*types.Transaction, error) {
 	tokenABI := `[
 		{
 			"name": "approve",
@@ -52,21 +71,21 @@ func (uc *Client) ApproveERC20Token(chainID *big.Int, signerAddress *common.Addr
 	]`
 	parsedABI, err := abi.JSON(strings.NewReader(tokenABI))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	approveData, err := parsedABI.Pack("approve", spenderAddress, amount)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to pack approve data: %w", err)
+		return nil, fmt.Errorf("failed to pack approve data: %w", err)
 	}
 	nonce, err := uc.cfg.rpcClient.PendingNonceAt(context.Background(), *signerAddress)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get nonce: %w", err)
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
 	}
 	nonce += nonceOffset
 
 	gasPrice, err := uc.cfg.rpcClient.SuggestGasPrice(context.Background())
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get gas price: %w", err)
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
 	}
 	gasLimit, err := uc.cfg.rpcClient.EstimateGas(context.Background(), ethereum.CallMsg{
 		From: *signerAddress, //This field is needed when there is approve on USDC token.
@@ -74,16 +93,10 @@ func (uc *Client) ApproveERC20Token(chainID *big.Int, signerAddress *common.Addr
 		Data: approveData,
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to estimate gas limit: %w", err)
+		return nil, fmt.Errorf("failed to estimate gas limit: %w", err)
 	}
 	gasLimit += uc.cfg.gasLimitBuffer
-	tx := types.NewTransaction(nonce, tokenAddress, big.NewInt(0), gasLimit, gasPrice, approveData)
-	hash, rawTx, err := uc.rlpUnsignedTxAndHash(tx, chainID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed rlp hash tx data: %w", err)
-	}
-
-	return hash, rawTx, err
+	return types.NewTransaction(nonce, tokenAddress, big.NewInt(0), gasLimit, gasPrice, approveData), nil
 }
 
 func (uc *Client) GetAllowance(signerAddress common.Address, tokenAddress common.Address) (*big.Int, error) {
@@ -139,6 +152,23 @@ func (uc *Client) GetAllowance(signerAddress common.Address, tokenAddress common
 
 func (uc *Client) SwapTokens(chainID *big.Int, signerAddress *common.Address, amountIn, amountOutMin *big.Int, path []common.Address, nonceOffset uint64) ([]byte, []byte, error) {
 	log.Println("Swapping tokens...")
+	deadline := big.NewInt(time.Now().Add(uc.cfg.deadlineDuration).Unix())
+
+	tx, err := uc.BuildSwapTx(signerAddress, amountIn, amountOutMin, path, deadline, nonceOffset)
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, rawTx, err := uc.rlpUnsignedTxAndHash(tx, chainID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hash, rawTx, err
+}
+
+// BuildSwapTx builds the unsigned swapExactTokensForTokens transaction with the
+// nonce and gas price of the current chain state.
+func (uc *Client) BuildSwapTx(signerAddress *common.Address, amountIn, amountOutMin *big.Int, path []common.Address, deadline *big.Int, nonceOffset uint64) (*types.Transaction, error) {
 	routerABI := `[
 		{
 			"name": "swapExactTokensForTokens",
@@ -169,33 +199,25 @@ func (uc *Client) SwapTokens(chainID *big.Int, signerAddress *common.Address, am
 	]`
 	parsedRouterABI, err := abi.JSON(strings.NewReader(routerABI))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	deadline := big.NewInt(time.Now().Add(uc.cfg.deadlineDuration).Unix())
-
 	swapData, err := parsedRouterABI.Pack("swapExactTokensForTokens", amountIn, amountOutMin, path, *signerAddress, deadline)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	nonce, err := uc.cfg.rpcClient.PendingNonceAt(context.Background(), *signerAddress)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	nonce += nonceOffset
 
 	gasPrice, err := uc.cfg.rpcClient.SuggestGasPrice(context.Background())
 	if err != nil {
-		return nil, nil, err
-	}
-
-	tx := types.NewTransaction(nonce, *uc.cfg.routerAddress, big.NewInt(0), uc.cfg.swapGasLimit, gasPrice, swapData)
-	hash, rawTx, err := uc.rlpUnsignedTxAndHash(tx, chainID)
-	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return hash, rawTx, err
+	return types.NewTransaction(nonce, *uc.cfg.routerAddress, big.NewInt(0), uc.cfg.swapGasLimit, gasPrice, swapData), nil
 }
 
 func (uc *Client) GetTokenBalance(signerAddress *common.Address, tokenAddress common.Address) (*big.Int, error) {
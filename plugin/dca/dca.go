@@ -16,7 +16,9 @@ import (
 	"github.com/vultisig/vultiserver-plugin/common"
 	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
 	"github.com/vultisig/vultiserver-plugin/pkg/uniswap"
+	"github.com/vultisig/vultiserver-plugin/plugin"
 	"github.com/vultisig/vultiserver-plugin/storage"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -34,6 +36,9 @@ const (
 	pluginType    = "dca"
 	pluginVersion = "0.0.1"
 	policyVersion = "0.0.1"
+
+	// slippagePercentage bounds the minimum output amount of a swap
+	slippagePercentage = 1.0
 )
 
 // TODO: remove once the plugin installation is implemented (resharding)
@@ -42,10 +47,6 @@ const (
 	hexEncryptionKey = "539440138236b389cb0355aa1e81d11e51e9ad7c94b09bb45704635913604a73"
 )
 
-var (
-	ErrCompletedPolicy = errors.New("policy completed all swaps")
-)
-
 type DCAPlugin struct {
 	uniswapClient *uniswap.Client
 	rpcClient     *ethclient.Client
@@ -99,46 +100,49 @@ func (p *DCAPlugin) SigningComplete(
 	signature tss.KeysignResponse,
 	signRequest types.PluginKeysignRequest,
 	policy types.PluginPolicy,
-) error {
+) (*gtypes.Receipt, error) {
 	var dcaPolicy types.DCAPolicy
 	if err := json.Unmarshal(policy.Policy, &dcaPolicy); err != nil {
-		return fmt.Errorf("fail to unmarshal DCA policy: %w", err)
+		return nil, fmt.Errorf("fail to unmarshal DCA policy: %w", err)
 	}
 
 	chainID, ok := new(big.Int).SetString(dcaPolicy.ChainID, 10)
 	if !ok {
-		return errors.New("fail to parse chain ID")
+		return nil, errors.New("fail to parse chain ID")
 	}
 
 	// currently we are only signing one transaction
 	txHash := signRequest.Messages[0]
 	if len(txHash) == 0 {
-		return errors.New("transaction hash is missing")
+		return nil, errors.New("transaction hash is missing")
 	}
 
 	signedTx, _, err := sigutil.SignLegacyTx(signature, txHash, signRequest.Transaction, chainID)
 	if err != nil {
 		p.logger.Error("fail to sign transaction: ", err)
-		return fmt.Errorf("fail to sign transaction: %w", err)
+		return nil, fmt.Errorf("fail to sign transaction: %w", err)
 	}
 
 	err = p.rpcClient.SendTransaction(context.Background(), signedTx)
 	if err != nil {
 		p.logger.Error("fail to send transaction: ", err)
-		return fmt.Errorf("failed to send transaction: %w", err)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
 	}
 
 	receipt, err := bind.WaitMined(context.Background(), p.rpcClient, signedTx)
 	if err != nil {
 		p.logger.Error("fail to wait for transaction receipt: ", err)
-		return fmt.Errorf("fail to wait for transaction to be mined: %w", err)
+		return nil, fmt.Errorf("fail to wait for transaction to be mined: %w", err)
 	}
 	if receipt.Status != 1 {
-		return fmt.Errorf("transaction reverted: %d", receipt.Status)
+		return receipt, &types.TransactionError{
+			Code:    types.ErrExecutionReverted,
+			Message: fmt.Sprintf("transaction reverted: %d", receipt.Status),
+		}
 	}
 
 	p.logger.Info("transaction receipt: ", "status: ", receipt.Status)
-	return nil
+	return receipt, nil
 }
 
 func (p *DCAPlugin) ValidatePluginPolicy(policyDoc types.PluginPolicy) error {
@@ -173,6 +177,11 @@ func (p *DCAPlugin) ValidatePluginPolicy(policyDoc types.PluginPolicy) error {
 		return fmt.Errorf("invalid public_key")
 	}
 
+	// swaps are EVM transactions, signed with the vault's ECDSA key
+	if !policyDoc.IsEcdsa {
+		return fmt.Errorf("dca policies require an ECDSA public key")
+	}
+
 	var dcaPolicy types.DCAPolicy
 	if err := json.Unmarshal(policyDoc.Policy, &dcaPolicy); err != nil {
 		return fmt.Errorf("fail to unmarshal DCA policy: %w", err)
@@ -252,9 +261,16 @@ func (p *DCAPlugin) ValidatePluginPolicy(policyDoc types.PluginPolicy) error {
 		return fmt.Errorf("policy does not match derive path, expected: %s, got: %s", common.DerivePathMap[dcaPolicy.ChainID], policyDoc.DerivePath)
 	}
 
-	if err := validateInterval(dcaPolicy.Schedule.Interval, dcaPolicy.Schedule.Frequency); err != nil {
+	// event triggered policies don't need a schedule
+	policyTrigger, err := watcher.GetPolicyTrigger(policyDoc)
+	if err != nil {
 		return err
 	}
+	if policyTrigger == nil {
+		if err := validateInterval(dcaPolicy.Schedule.Interval, dcaPolicy.Schedule.Frequency); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -324,17 +340,18 @@ func (p *DCAPlugin) ProposeTransactions(policy types.PluginPolicy) ([]types.Plug
 		return txs, fmt.Errorf("invalid total orders %s", dcaPolicy.TotalOrders)
 	}
 
-	// Check number of completed swaps
-	completedSwaps, err := p.getCompletedSwapTransactionsCount(context.Background(), policy.ID)
+	// Orders are counted from the swaps the verifier signed, like it does, so
+	// a swap that failed on chain still uses up its order
+	completedSwaps, err := p.getSignedSwapCount(context.Background(), policy.ID, "")
 	if err != nil {
-		return txs, fmt.Errorf("fail to get completed swap transactions count: %w", err)
+		return txs, fmt.Errorf("fail to get signed swap transactions count: %w", err)
 	}
 
 	if completedSwaps >= totalOrders.Int64() {
 		if err := p.completePolicy(context.Background(), policy); err != nil {
 			return txs, fmt.Errorf("fail to complete policy: %w", err)
 		}
-		return txs, ErrCompletedPolicy
+		return txs, plugin.ErrCompletedPolicy
 	}
 
 	// Calculate base amount and remainder
@@ -421,9 +438,16 @@ func (p *DCAPlugin) ValidateProposedTransactions(policy types.PluginPolicy, txs
 	}
 	p.logger.Warn("Signer address used for swaps: ", signerAddress.String())
 
-	completedSwaps, err := p.getCompletedSwapTransactionsCount(context.Background(), policy.ID)
+	// the proposed swap may be a retry the verifier signed already
+	var swapHash string
+	for _, tx := range txs {
+		if tx.TransactionType == "SWAP" && len(tx.Messages) > 0 {
+			swapHash = tx.Messages[0]
+		}
+	}
+	completedSwaps, err := p.getSignedSwapCount(context.Background(), policy.ID, swapHash)
 	if err != nil {
-		return fmt.Errorf("fail to get completed swaps: %w", err)
+		return fmt.Errorf("fail to get signed swaps: %w", err)
 	}
 	// TODO: Change this to make the policy to status COMPLETED if: completed swaps == total orders.
 	if completedSwaps >= totalOrders.Int64() {
@@ -431,7 +455,7 @@ func (p *DCAPlugin) ValidateProposedTransactions(policy types.PluginPolicy, txs
 			return fmt.Errorf("fail to complete policy: %w", err)
 		}
 		p.logger.Info("DCA: COMPLETED SWAPS: ", totalOrders.Int64())
-		return ErrCompletedPolicy
+		return plugin.ErrCompletedPolicy
 	}
 
 	// Validate each transaction
@@ -681,6 +705,16 @@ func (p *DCAPlugin) getApproveABI() (abi.ABI, error) {
 	return abi.JSON(strings.NewReader(approveABI))
 }
 
+func (p *DCAPlugin) MaxTransactionsPerRun(policy types.PluginPolicy, txType string) (int64, error) {
+	// a run swaps once and approves the router beforehand when the allowance is too low
+	switch txType {
+	case "APPROVE", "SWAP":
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("unsupported transaction type: %s", txType)
+	}
+}
+
 func (p *DCAPlugin) FrontendSchema() embed.FS {
 	return embed.FS{}
 }
@@ -726,7 +760,6 @@ func (p *DCAPlugin) generateSwapTransactions(chainID *big.Int, signerAddress *gc
 	}
 	p.logger.Info("DCA: EXPECTED AMOUNT OUT: ", expectedAmountOut.String())
 
-	slippagePercentage := 1.0
 	amountOutMin := p.uniswapClient.CalculateAmountOutMin(expectedAmountOut, slippagePercentage)
 
 	txHash, rawTx, err := p.uniswapClient.SwapTokens(chainID, signerAddress, swapAmount, amountOutMin, tokensPair, swapNonce)
@@ -755,12 +788,15 @@ func (p *DCAPlugin) logTokenBalances(client *uniswap.Client, signerAddress *gcom
 	p.logger.Info("Output token balance: ", tokenOutBalance.String())
 }
 
-func (p *DCAPlugin) getCompletedSwapTransactionsCount(ctx context.Context, policyID string) (int64, error) {
+// getSignedSwapCount counts the swaps of the policy the verifier signed,
+// leaving out the one with the given hash. The plugin and the verifier both
+// derive the next order from it.
+func (p *DCAPlugin) getSignedSwapCount(ctx context.Context, policyID string, excludeTxHash string) (int64, error) {
 	policyUUID, err := uuid.Parse(policyID)
 	if err != nil {
 		return 0, fmt.Errorf("invalid policy_id: %s", policyID)
 	}
-	count, err := p.db.CountTransactions(ctx, policyUUID, types.StatusMined, "SWAP")
+	count, err := p.db.CountSignedTransactions(ctx, policyUUID, "SWAP", excludeTxHash)
 	if err != nil {
 		return 0, err
 	}
@@ -787,16 +823,15 @@ func (p *DCAPlugin) completePolicy(ctx context.Context, policy types.PluginPolic
 		"policy_id": policy.ID,
 	}).Info("DCA: All orders completed, no transactions to propose")
 
-	// TODO: Sync a COMPLETED state for the policy with the verifier database.
-	dbTx, err := p.db.Pool().Begin(ctx)
-	defer dbTx.Rollback(ctx)
+	// active is part of the signed policy, so completion is kept in the status,
+	// the verifier refuses further orders from its own signing records
+	dbTx, err := p.db.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("fail to begin transaction: %w", err)
 	}
-	policy.Active = false
-	_, err = p.db.UpdatePluginPolicyTx(ctx, dbTx, policy)
-	if err != nil {
-		return fmt.Errorf("fail to update policy: %w", err)
+	defer dbTx.Rollback(ctx)
+	if err := p.db.CompletePluginPolicyTx(ctx, dbTx, policy.ID); err != nil {
+		return fmt.Errorf("fail to complete policy: %w", err)
 	}
 
 	if err := dbTx.Commit(ctx); err != nil {
@@ -0,0 +1,128 @@
+package dca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/plugin"
+)
+
+// ReconstructTransaction rebuilds the next order of the policy from the
+// verifier's own configuration and chain view. The amount follows from the
+// swaps the verifier itself signed, so a swap that failed on chain still uses
+// up its order.
+func (p *DCAPlugin) ReconstructTransaction(ctx context.Context, policy types.PluginPolicy, txType string, proposed *gtypes.Transaction, tol reconstruct.Tolerances) (*gtypes.Transaction, error) {
+	var dcaPolicy types.DCAPolicy
+	if err := json.Unmarshal(policy.Policy, &dcaPolicy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DCA policy: %w", err)
+	}
+
+	chainID, ok := new(big.Int).SetString(dcaPolicy.ChainID, 10)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse chain ID: %s", dcaPolicy.ChainID)
+	}
+	totalAmount, ok := new(big.Int).SetString(dcaPolicy.TotalAmount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid total amount")
+	}
+	totalOrders, ok := new(big.Int).SetString(dcaPolicy.TotalOrders, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid total orders")
+	}
+
+	signerAddress, err := common.DeriveAddress(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive address: %w", err)
+	}
+
+	proposedHash := gtypes.NewEIP155Signer(proposed.ChainId()).Hash(proposed).Hex()[2:]
+	signedSwaps, err := p.getSignedSwapCount(ctx, policy.ID, proposedHash)
+	if err != nil {
+		return nil, fmt.Errorf("fail to count signed swaps: %w", err)
+	}
+	if signedSwaps >= totalOrders.Int64() {
+		return nil, plugin.ErrCompletedPolicy
+	}
+
+	swapAmount := p.calculateSwapAmountPerOrder(totalAmount, totalOrders, signedSwaps)
+	srcTokenAddress := gcommon.HexToAddress(dcaPolicy.SourceTokenID)
+	destTokenAddress := gcommon.HexToAddress(dcaPolicy.DestinationTokenID)
+
+	var expected *gtypes.Transaction
+	switch txType {
+	case "APPROVE":
+		expected, err = p.uniswapClient.BuildApproveTx(signerAddress, srcTokenAddress, *p.uniswapClient.GetRouterAddress(), swapAmount, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build APPROVE transaction: %w", err)
+		}
+	case "SWAP":
+		path := []gcommon.Address{srcTokenAddress, destTokenAddress}
+		amountOutMin, deadline, err := p.reconstructSwapBounds(proposed, swapAmount, path, tol)
+		if err != nil {
+			return nil, err
+		}
+		expected, err = p.uniswapClient.BuildSwapTx(signerAddress, swapAmount, amountOutMin, path, deadline, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build SWAP transaction: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type: %s", txType)
+	}
+
+	return reconstruct.WithChainID(expected, chainID), nil
+}
+
+// reconstructSwapBounds takes the minimum output amount and the deadline of
+// the proposed swap, as both depend on the moment the plugin built it. The
+// minimum must not undercut the verifier's own quote and the deadline must lie
+// within the configured swap deadline.
+func (p *DCAPlugin) reconstructSwapBounds(proposed *gtypes.Transaction, swapAmount *big.Int, path []gcommon.Address, tol reconstruct.Tolerances) (*big.Int, *big.Int, error) {
+	swapABI, err := p.getSwapABI()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get swap ABI: %w", err)
+	}
+	if len(proposed.Data()) < 4 {
+		return nil, nil, fmt.Errorf("transaction contains empty payload")
+	}
+	method, err := swapABI.MethodById(proposed.Data()[:4])
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown swap method: %w", err)
+	}
+	params, err := method.Inputs.Unpack(proposed.Data()[4:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode transaction swap parameters: %w", err)
+	}
+	amountOutMin, ok := params[1].(*big.Int)
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to parse minimum output amount")
+	}
+	deadline, ok := params[4].(*big.Int)
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to parse swap deadline")
+	}
+
+	expectedAmountOut, err := p.uniswapClient.GetExpectedAmountOut(swapAmount, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get expected amount out: %w", err)
+	}
+	ownAmountOutMin := p.uniswapClient.CalculateAmountOutMin(expectedAmountOut, slippagePercentage)
+	if amountOutMin.Cmp(reconstruct.ReducedByPercent(ownAmountOutMin, tol.AmountOutPercent)) < 0 {
+		return nil, nil, fmt.Errorf("minimum output amount too low: expected at least %s (-%g%%), got %s", ownAmountOutMin, tol.AmountOutPercent, amountOutMin)
+	}
+
+	now := time.Now()
+	latest := now.Add(p.uniswapClient.GetDeadlineDuration() + tol.Deadline).Unix()
+	if deadline.Cmp(big.NewInt(now.Unix())) <= 0 || deadline.Cmp(big.NewInt(latest)) > 0 {
+		return nil, nil, fmt.Errorf("swap deadline out of range: %s", deadline)
+	}
+
+	return amountOutMin, deadline, nil
+}
@@ -0,0 +1,254 @@
+package dca_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/ethtest"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/plugin"
+	"github.com/vultisig/vultiserver-plugin/plugin/dca"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+var (
+	allowanceSelector     = "dd62ed3e"
+	balanceOfSelector     = "70a08231"
+	getAmountsOutSelector = "d06ca61f"
+)
+
+// newChain fakes a chain on which the vault has no allowance and every swap
+// returns twice its input. The vault's nonce is read from nonce.
+func newChain(t *testing.T, nonce *atomic.Uint64) *ethtest.RPC {
+	uint256Array, err := abi.NewType("uint256[]", "", nil)
+	require.NoError(t, err)
+	amounts := abi.Arguments{{Type: uint256Array}}
+
+	rpc := ethtest.NewRPC(t)
+	rpc.Handle("eth_getTransactionCount", func([]json.RawMessage) (any, error) {
+		return fmt.Sprintf("0x%x", nonce.Load()), nil
+	})
+	rpc.Result("eth_gasPrice", "0x3b9aca00")
+	rpc.Result("eth_estimateGas", "0xb411")
+	rpc.Handle("eth_call", func(params []json.RawMessage) (any, error) {
+		data, err := ethtest.CallData(params)
+		if err != nil {
+			return nil, err
+		}
+		switch hex.EncodeToString(data[:4]) {
+		case allowanceSelector, balanceOfSelector:
+			return "0x" + hex.EncodeToString(make([]byte, 32)), nil
+		case getAmountsOutSelector:
+			amountIn := new(big.Int).SetBytes(data[4:36])
+			out, err := amounts.Pack([]*big.Int{amountIn, new(big.Int).Mul(amountIn, big.NewInt(2))})
+			if err != nil {
+				return nil, err
+			}
+			return "0x" + hex.EncodeToString(out), nil
+		}
+		return nil, fmt.Errorf("unexpected call %x", data[:4])
+	})
+	return rpc
+}
+
+type fixture struct {
+	db     *memory.MemoryBackend
+	plugin *dca.DCAPlugin
+	policy types.PluginPolicy
+	nonce  *atomic.Uint64
+}
+
+func newFixture(t *testing.T, totalAmount, totalOrders string) fixture {
+	ctx := context.Background()
+	nonce := new(atomic.Uint64)
+	rpc := newChain(t, nonce)
+	db := memory.NewMemoryBackend()
+	dcaPlugin, err := dca.NewDCAPlugin(db, logrus.New(), map[string]interface{}{
+		"rpc_url": rpc.URL(),
+		"uniswap": map[string]interface{}{
+			"v2_router": "0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D",
+			"deadline":  int64(5),
+		},
+	})
+	require.NoError(t, err)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	chainCode := make([]byte, 32)
+	_, err = rand.Read(chainCode)
+	require.NoError(t, err)
+
+	dcaPolicy, err := json.Marshal(types.DCAPolicy{
+		ChainID:            "1",
+		SourceTokenID:      "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		DestinationTokenID: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",
+		TotalAmount:        totalAmount,
+		TotalOrders:        totalOrders,
+		Schedule:           types.Schedule{Frequency: "hourly", Interval: "1"},
+	})
+	require.NoError(t, err)
+	policy := types.PluginPolicy{
+		ID:            uuid.New().String(),
+		PublicKey:     hex.EncodeToString(crypto.CompressPubkey(&key.PublicKey)),
+		IsEcdsa:       true,
+		ChainCodeHex:  hex.EncodeToString(chainCode),
+		DerivePath:    common.DerivePathMap["1"],
+		PluginID:      "dca",
+		PluginVersion: "0.0.1",
+		PolicyVersion: "0.0.1",
+		PluginType:    "dca",
+		Active:        true,
+		Policy:        dcaPolicy,
+	}
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+
+	return fixture{db: db, plugin: dcaPlugin, policy: policy, nonce: nonce}
+}
+
+// propose has the plugin propose the next order and returns its swap.
+func (f fixture) propose(t *testing.T) types.PluginKeysignRequest {
+	requests, err := f.plugin.ProposeTransactions(f.policy)
+	require.NoError(t, err)
+	for _, request := range requests {
+		if request.TransactionType == "SWAP" {
+			return request
+		}
+	}
+	t.Fatal("no swap proposed")
+	return types.PluginKeysignRequest{}
+}
+
+// sign records the proposal as signed by the verifier, with the status the
+// plugin saw on chain.
+func (f fixture) sign(t *testing.T, request types.PluginKeysignRequest, status types.TransactionStatus) {
+	ctx := context.Background()
+	txID, err := f.db.CreateTransactionHistory(ctx, types.TransactionHistory{
+		PolicyID: uuid.MustParse(f.policy.ID),
+		TxHash:   request.Messages[0],
+		TxBody:   request.Transaction,
+		TxType:   request.TransactionType,
+		Status:   status,
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.db.MarkTransactionSigned(ctx, txID, request.TransactionType))
+}
+
+// broadcast moves the vault's nonce past the approve and swap of a run.
+func (f fixture) broadcast() {
+	f.nonce.Add(2)
+}
+
+func decode(t *testing.T, request types.PluginKeysignRequest) *gtypes.Transaction {
+	raw, err := hex.DecodeString(request.Transaction)
+	require.NoError(t, err)
+	tx := &gtypes.Transaction{}
+	require.NoError(t, tx.UnmarshalBinary(raw))
+	return tx
+}
+
+func amountIn(t *testing.T, tx *gtypes.Transaction) *big.Int {
+	// swapExactTokensForTokens(amountIn, ...)
+	return new(big.Int).SetBytes(tx.Data()[4:36])
+}
+
+// the swap follows the approve of the same run, one nonce later
+var tolerances = reconstruct.Tolerances{Nonce: 1, Deadline: time.Minute}
+
+func TestReconstructTransaction(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "1000", "3")
+	var reconstructor plugin.TransactionReconstructor = f.plugin
+
+	requests, err := f.plugin.ProposeTransactions(f.policy)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	for _, request := range requests {
+		proposed := decode(t, request)
+		expected, err := reconstructor.ReconstructTransaction(ctx, f.policy, request.TransactionType, proposed, tolerances)
+		require.NoError(t, err, request.TransactionType)
+		assert.NoError(t, reconstruct.Compare(expected, proposed, tolerances), request.TransactionType)
+	}
+
+	// the first order takes the remainder
+	swap := f.propose(t)
+	assert.Equal(t, big.NewInt(334), amountIn(t, decode(t, swap)))
+
+	// a retry of a signed swap is rebuilt as the same order
+	f.sign(t, swap, types.StatusSigned)
+	proposed := decode(t, swap)
+	expected, err := reconstructor.ReconstructTransaction(ctx, f.policy, "SWAP", proposed, tolerances)
+	require.NoError(t, err)
+	assert.NoError(t, reconstruct.Compare(expected, proposed, tolerances))
+
+	// an amount the policy doesn't allow is refused
+	data := append([]byte(nil), proposed.Data()...)
+	big.NewInt(999).FillBytes(data[4:36])
+	tampered := reconstruct.WithChainID(gtypes.NewTransaction(proposed.Nonce(), *proposed.To(), proposed.Value(), proposed.Gas(), proposed.GasPrice(), data), big.NewInt(1))
+	expected, err = reconstructor.ReconstructTransaction(ctx, f.policy, "SWAP", tampered, tolerances)
+	require.NoError(t, err)
+	var mismatchErr *reconstruct.MismatchError
+	assert.ErrorAs(t, reconstruct.Compare(expected, tampered, tolerances), &mismatchErr)
+}
+
+func TestReconstructTransactionAfterFailedSwap(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "1000", "3")
+
+	// the first swap is signed but reverts on chain, it still uses up its
+	// order on both sides
+	f.sign(t, f.propose(t), types.StatusRejected)
+	f.broadcast()
+
+	swap := f.propose(t)
+	proposed := decode(t, swap)
+	assert.Equal(t, big.NewInt(333), amountIn(t, proposed))
+	expected, err := f.plugin.ReconstructTransaction(ctx, f.policy, "SWAP", proposed, tolerances)
+	require.NoError(t, err)
+	assert.NoError(t, reconstruct.Compare(expected, proposed, tolerances))
+}
+
+func TestReconstructTransactionCompletedPolicy(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, "1000", "2")
+
+	f.sign(t, f.propose(t), types.StatusMined)
+	f.broadcast()
+	last := f.propose(t)
+	f.sign(t, last, types.StatusRejected)
+	f.broadcast()
+
+	// the verifier refuses further orders
+	next := decode(t, last)
+	next = gtypes.NewTransaction(next.Nonce()+1, *next.To(), next.Value(), next.Gas(), next.GasPrice(), next.Data())
+	_, err := f.plugin.ReconstructTransaction(ctx, f.policy, "SWAP", reconstruct.WithChainID(next, big.NewInt(1)), tolerances)
+	assert.ErrorIs(t, err, plugin.ErrCompletedPolicy)
+
+	// and the plugin stops proposing them
+	_, err = f.plugin.ProposeTransactions(f.policy)
+	assert.ErrorIs(t, err, plugin.ErrCompletedPolicy)
+	policy, err := f.db.GetPluginPolicy(ctx, f.policy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.PolicyStatusCompleted, policy.Status)
+	assert.True(t, policy.Active, "the signed policy is left as it is")
+}
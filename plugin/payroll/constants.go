@@ -1,6 +1,7 @@
 package payroll
 
 const PLUGIN_TYPE = "payroll"
+const TRANSACTION_TYPE_TRANSFER = "TRANSFER"
 const erc20ABI = `[{
     "name": "transfer",
     "type": "function",
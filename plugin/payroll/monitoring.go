@@ -49,7 +49,7 @@ func (p *PayrollPlugin) handleBroadcastError(err error, sender gcommon.Address)
 	return nil
 }
 
-func (p *PayrollPlugin) monitorTransaction(tx *gtypes.Transaction) error {
+func (p *PayrollPlugin) monitorTransaction(tx *gtypes.Transaction) (*gtypes.Receipt, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute) //how much time should we monitor the tx?
 	defer cancel()
 
@@ -60,7 +60,7 @@ func (p *PayrollPlugin) monitorTransaction(tx *gtypes.Transaction) error {
 	for {
 		select {
 		case <-ctx.Done():
-			return &types.TransactionError{
+			return nil, &types.TransactionError{
 				Code:    types.ErrTxTimeout,
 				Message: fmt.Sprintf("Transaction monitoring timed out for tx: %s", txHash.Hex()),
 			}
@@ -70,7 +70,7 @@ func (p *PayrollPlugin) monitorTransaction(tx *gtypes.Transaction) error {
 			_, isPending, err := p.rpcClient.TransactionByHash(ctx, txHash)
 			if err != nil {
 				if err == ethereum.NotFound {
-					return &types.TransactionError{
+					return nil, &types.TransactionError{
 						Code:    types.ErrTxDropped,
 						Message: fmt.Sprintf("Transaction dropped from mempool: %s", txHash.Hex()),
 					}
@@ -89,21 +89,21 @@ func (p *PayrollPlugin) monitorTransaction(tx *gtypes.Transaction) error {
 
 					// Check if it's a permanent failure (like insufficient token balance)
 					if !p.isRetriableError(reason) {
-						return &types.TransactionError{
+						return receipt, &types.TransactionError{
 							Code:    types.ErrPermanentFailure,
 							Message: fmt.Sprintf("Transaction permanently failed: %s", reason),
 						}
 					}
 
 					// It's a retriable error
-					return &types.TransactionError{
+					return receipt, &types.TransactionError{
 						Code:    types.ErrRetriable,
 						Message: fmt.Sprintf("Transaction failed with retriable error: %s", reason),
 					}
 				}
 
 				// Transaction successful
-				return nil
+				return receipt, nil
 			}
 		}
 	}
@@ -12,6 +12,7 @@ import (
 	gtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/sirupsen/logrus"
+	"github.com/vultisig/vultiserver-plugin/common"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
 )
 
@@ -91,6 +92,19 @@ func (p *PayrollPlugin) ValidatePluginPolicy(policyDoc types.PluginPolicy) error
 		return fmt.Errorf("policy does not match plugin type, expected: %s, got: %s", PLUGIN_TYPE, policyDoc.PluginType)
 	}
 
+	pubKeyBytes, err := hex.DecodeString(policyDoc.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid hex encoding: %w", err)
+	}
+	if !common.CheckIfPublicKeyIsValid(pubKeyBytes, policyDoc.IsEcdsa) {
+		return fmt.Errorf("invalid public_key")
+	}
+
+	// transfers are EVM transactions, signed with the vault's ECDSA key
+	if !policyDoc.IsEcdsa {
+		return fmt.Errorf("payroll policies require an ECDSA public key")
+	}
+
 	var payrollPolicy types.PayrollPolicy
 	if err := json.Unmarshal(policyDoc.Policy, &payrollPolicy); err != nil {
 		return fmt.Errorf("fail to unmarshal payroll policy, err: %w", err)
@@ -135,5 +149,25 @@ func (p *PayrollPlugin) ValidatePluginPolicy(policyDoc types.PluginPolicy) error
 		}
 	}
 
+	if payrollPolicy.Trigger != nil {
+		if err := payrollPolicy.Trigger.IsValid(); err != nil {
+			return fmt.Errorf("invalid trigger: %w", err)
+		}
+	}
+
 	return nil
 }
+
+func (p *PayrollPlugin) MaxTransactionsPerRun(policy types.PluginPolicy, txType string) (int64, error) {
+	if txType != TRANSACTION_TYPE_TRANSFER {
+		return 0, fmt.Errorf("unsupported transaction type: %s", txType)
+	}
+
+	var payrollPolicy types.PayrollPolicy
+	if err := json.Unmarshal(policy.Policy, &payrollPolicy); err != nil {
+		return 0, fmt.Errorf("fail to unmarshal payroll policy, err: %w", err)
+	}
+
+	// one transfer per recipient
+	return int64(len(payrollPolicy.Recipients)), nil
+}
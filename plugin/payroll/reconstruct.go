@@ -0,0 +1,98 @@
+package payroll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// ReconstructTransaction rebuilds the payment of the recipient the proposal
+// addresses. Token, chain and amount come from the signed policy, nonce and gas
+// from the verifier's own RPC.
+func (p *PayrollPlugin) ReconstructTransaction(ctx context.Context, policy types.PluginPolicy, txType string, proposed *gtypes.Transaction, tol reconstruct.Tolerances) (*gtypes.Transaction, error) {
+	if txType != TRANSACTION_TYPE_TRANSFER {
+		return nil, fmt.Errorf("unsupported transaction type: %s", txType)
+	}
+
+	var payrollPolicy types.PayrollPolicy
+	if err := json.Unmarshal(policy.Policy, &payrollPolicy); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal payroll policy, err: %w", err)
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %v", err)
+	}
+
+	// the proposal only selects the recipient, everything else is rebuilt
+	if proposed.To() == nil || len(proposed.Data()) < 4 {
+		return nil, fmt.Errorf("transaction is not a token transfer")
+	}
+	method, err := parsedABI.MethodById(proposed.Data()[:4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get method by ID: %v", err)
+	}
+	v := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(v, proposed.Data()[4:]); err != nil {
+		return nil, fmt.Errorf("failed to unpack transaction data: %v", err)
+	}
+	recipientAddress, ok := v["recipient"].(gcommon.Address)
+	if !ok {
+		return nil, fmt.Errorf("failed to get recipient address")
+	}
+
+	index := -1
+	for i, recipient := range payrollPolicy.Recipients {
+		if i >= len(payrollPolicy.TokenID) || i >= len(payrollPolicy.ChainID) {
+			break
+		}
+		if strings.EqualFold(recipient.Address, recipientAddress.Hex()) &&
+			strings.EqualFold(payrollPolicy.TokenID[i], proposed.To().Hex()) &&
+			payrollPolicy.ChainID[i] == proposed.ChainId().String() {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("recipient %s is not part of the policy", recipientAddress.Hex())
+	}
+
+	amount, ok := new(big.Int).SetString(payrollPolicy.Recipients[index].Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %s", payrollPolicy.Recipients[index].Amount)
+	}
+	chainID, ok := new(big.Int).SetString(payrollPolicy.ChainID[index], 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid chain ID: %s", payrollPolicy.ChainID[index])
+	}
+
+	inputData, err := parsedABI.Pack("transfer", recipientAddress, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack transfer data: %v", err)
+	}
+	gasLimit, gasPrice, err := p.estimatePayrollGas(ctx, recipientAddress, inputData)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedAddress, err := common.DeriveAddress(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive address: %v", err)
+	}
+	nonce, err := p.GetNextNonce(derivedAddress.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %v", err)
+	}
+
+	tx := gtypes.NewTransaction(nonce, gcommon.HexToAddress(payrollPolicy.TokenID[index]), big.NewInt(0), gasLimit, gasPrice, inputData)
+	return reconstruct.WithChainID(tx, chainID), nil
+}
@@ -0,0 +1,149 @@
+package payroll_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gcommon "github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/ethtest"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/plugin/payroll"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+const (
+	token     = "0xA0b86991c6218b36c1D19D4a2e9Eb0cE3606eB48"
+	recipient = "0x1111111111111111111111111111111111111111"
+	stranger  = "0x2222222222222222222222222222222222222222"
+)
+
+func newPayrollPlugin(t *testing.T) *payroll.PayrollPlugin {
+	rpc := ethtest.NewRPC(t)
+	rpc.Result("eth_getTransactionCount", "0x3")
+	rpc.Result("eth_gasPrice", "0x3b9aca00")
+	rpc.Result("eth_estimateGas", "0xc350")
+
+	p, err := payroll.NewPayrollPlugin(memory.NewMemoryBackend(), logrus.New(), map[string]interface{}{
+		"rpc_url": rpc.URL(),
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func newPolicy(t *testing.T) types.PluginPolicy {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	chainCode := make([]byte, 32)
+	_, err = rand.Read(chainCode)
+	require.NoError(t, err)
+
+	payrollPolicy, err := json.Marshal(types.PayrollPolicy{
+		ChainID:    []string{"1"},
+		TokenID:    []string{token},
+		Recipients: []types.PayrollRecipient{{Address: recipient, Amount: "1000"}},
+	})
+	require.NoError(t, err)
+	return types.PluginPolicy{
+		ID:           uuid.New().String(),
+		PublicKey:    hex.EncodeToString(crypto.CompressPubkey(&key.PublicKey)),
+		IsEcdsa:      true,
+		ChainCodeHex: hex.EncodeToString(chainCode),
+		DerivePath:   common.DerivePathMap["1"],
+		PluginType:   "payroll",
+		Policy:       payrollPolicy,
+	}
+}
+
+func transfer(t *testing.T, nonce uint64, to, amount string) *gtypes.Transaction {
+	parsedABI, err := abi.JSON(strings.NewReader(`[{"name":"transfer","type":"function","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`))
+	require.NoError(t, err)
+	value, ok := new(big.Int).SetString(amount, 10)
+	require.True(t, ok)
+	data, err := parsedABI.Pack("transfer", gcommon.HexToAddress(to), value)
+	require.NoError(t, err)
+	tx := gtypes.NewTransaction(nonce, gcommon.HexToAddress(token), big.NewInt(0), 150000, big.NewInt(3000000000), data)
+	return reconstruct.WithChainID(tx, big.NewInt(1))
+}
+
+func TestReconstructTransaction(t *testing.T) {
+	ctx := context.Background()
+	p := newPayrollPlugin(t)
+	policy := newPolicy(t)
+	tol := reconstruct.Tolerances{Nonce: 1}
+
+	tests := []struct {
+		name     string
+		txType   string
+		proposed *gtypes.Transaction
+		// wantErr fails the reconstruction, wantMismatch the comparison
+		wantErr      bool
+		wantMismatch bool
+	}{
+		{
+			name:     "payment of the policy",
+			txType:   "TRANSFER",
+			proposed: transfer(t, 3, recipient, "1000"),
+		},
+		{
+			name:     "next nonce of the run",
+			txType:   "TRANSFER",
+			proposed: transfer(t, 4, recipient, "1000"),
+		},
+		{
+			name:         "amount above the policy",
+			txType:       "TRANSFER",
+			proposed:     transfer(t, 3, recipient, "1001"),
+			wantMismatch: true,
+		},
+		{
+			name:         "nonce out of range",
+			txType:       "TRANSFER",
+			proposed:     transfer(t, 9, recipient, "1000"),
+			wantMismatch: true,
+		},
+		{
+			name:     "recipient outside the policy",
+			txType:   "TRANSFER",
+			proposed: transfer(t, 3, stranger, "1000"),
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported type",
+			txType:   "SWAP",
+			proposed: transfer(t, 3, recipient, "1000"),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := p.ReconstructTransaction(ctx, policy, tt.txType, tt.proposed, tol)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			err = reconstruct.Compare(expected, tt.proposed, tol)
+			if tt.wantMismatch {
+				var mismatchErr *reconstruct.MismatchError
+				assert.ErrorAs(t, err, &mismatchErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -69,9 +69,10 @@ func (p *PayrollPlugin) ProposeTransactions(policy types.PluginPolicy) ([]types.
 				IsECDSA:          IsECDSA(chainIDInt),
 				VaultPassword:    vaultPassword,
 			},
-			Transaction: hex.EncodeToString(rawTx),
-			PluginID:    policy.PluginID,
-			PolicyID:    policy.ID,
+			Transaction:     hex.EncodeToString(rawTx),
+			PluginID:        policy.PluginID,
+			PolicyID:        policy.ID,
+			TransactionType: TRANSACTION_TYPE_TRANSFER,
 		}
 		txs = append(txs, signRequest)
 	}
@@ -110,26 +111,10 @@ func (p *PayrollPlugin) generatePayrollTransaction(amountString, recipientString
 		return nil, nil, fmt.Errorf("failed to pack transfer data: %v", err)
 	}
 
-	// create call message to estimate gas
-	callMsg := ethereum.CallMsg{
-		From:  recipient, //todo : this works, but maybe better to put the correct sender address once we have it
-		To:    &recipient,
-		Data:  inputData,
-		Value: big.NewInt(0),
-	}
-	// estimate gas limit
-	gasLimit, err := p.rpcClient.EstimateGas(context.Background(), callMsg)
+	gasLimit, gasPrice, err := p.estimatePayrollGas(context.Background(), recipient, inputData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to estimate gas: %v", err)
+		return nil, nil, err
 	}
-	// add 20% to gas limit for safety
-	gasLimit = gasLimit * 300 / 100
-	// get suggested gas price
-	gasPrice, err := p.rpcClient.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get gas price: %v", err)
-	}
-	gasPrice = new(big.Int).Mul(gasPrice, big.NewInt(3))
 	// Parse chain ID
 	chainIDInt := new(big.Int)
 	chainIDInt.SetString(chainID, 10)
@@ -206,10 +191,34 @@ func (p *PayrollPlugin) generatePayrollTransaction(amountString, recipientString
 	return txHash, rawTx, nil
 }
 
-func (p *PayrollPlugin) SigningComplete(ctx context.Context, signature tss.KeysignResponse, signRequest types.PluginKeysignRequest, policy types.PluginPolicy) error {
+func (p *PayrollPlugin) estimatePayrollGas(ctx context.Context, recipient gcommon.Address, inputData []byte) (uint64, *big.Int, error) {
+	// create call message to estimate gas
+	callMsg := ethereum.CallMsg{
+		From:  recipient, //todo : this works, but maybe better to put the correct sender address once we have it
+		To:    &recipient,
+		Data:  inputData,
+		Value: big.NewInt(0),
+	}
+	// estimate gas limit
+	gasLimit, err := p.rpcClient.EstimateGas(ctx, callMsg)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+	// add 20% to gas limit for safety
+	gasLimit = gasLimit * 300 / 100
+	// get suggested gas price
+	gasPrice, err := p.rpcClient.SuggestGasPrice(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get gas price: %v", err)
+	}
+	gasPrice = new(big.Int).Mul(gasPrice, big.NewInt(3))
+	return gasLimit, gasPrice, nil
+}
+
+func (p *PayrollPlugin) SigningComplete(ctx context.Context, signature tss.KeysignResponse, signRequest types.PluginKeysignRequest, policy types.PluginPolicy) (*gtypes.Receipt, error) {
 	R, S, V, originalTx, chainID, _, err := p.convertData(signature, signRequest, policy)
 	if err != nil {
-		return fmt.Errorf("failed to convert R and S: %v", err)
+		return nil, fmt.Errorf("failed to convert R and S: %v", err)
 	}
 
 	innerTx := &gtypes.LegacyTx{
@@ -235,13 +244,13 @@ func (p *PayrollPlugin) SigningComplete(ctx context.Context, signature tss.Keysi
 
 	// Check if RPC client is initialized
 	if p.rpcClient == nil {
-		return fmt.Errorf("RPC client not initialized")
+		return nil, fmt.Errorf("RPC client not initialized")
 	}
 
 	err = p.rpcClient.SendTransaction(ctx, signedTx)
 	if err != nil {
 		p.logger.WithError(err).Error("Failed to broadcast transaction")
-		return p.handleBroadcastError(err, sender)
+		return nil, p.handleBroadcastError(err, sender)
 	}
 
 	p.logger.WithField("hash", signedTx.Hash().Hex()).Info("Transaction successfully broadcast")
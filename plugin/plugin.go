@@ -3,15 +3,36 @@ package plugin
 import (
 	"context"
 	"embed"
+	"errors"
 
+	gtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/vultisig/mobile-tss-lib/tss"
+	"github.com/vultisig/vultiserver-plugin/internal/reconstruct"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
 )
 
+// ErrCompletedPolicy is returned by plugins asked to propose transactions for a
+// policy that has nothing left to do. The run is skipped rather than failed.
+var ErrCompletedPolicy = errors.New("policy completed all swaps")
+
 type Plugin interface {
 	FrontendSchema() embed.FS
 	ValidatePluginPolicy(policyDoc types.PluginPolicy) error
 	ProposeTransactions(policy types.PluginPolicy) ([]types.PluginKeysignRequest, error)
 	ValidateProposedTransactions(policy types.PluginPolicy, txs []types.PluginKeysignRequest) error
-	SigningComplete(ctx context.Context, signature tss.KeysignResponse, signRequest types.PluginKeysignRequest, policy types.PluginPolicy) error
+	// SigningComplete broadcasts the signed transaction and returns its receipt
+	// once mined. The receipt of a reverted transaction comes with the error.
+	SigningComplete(ctx context.Context, signature tss.KeysignResponse, signRequest types.PluginKeysignRequest, policy types.PluginPolicy) (*gtypes.Receipt, error)
+	// MaxTransactionsPerRun returns how many transactions of the given type a single run of the policy may produce.
+	MaxTransactionsPerRun(policy types.PluginPolicy, txType string) (int64, error)
+}
+
+// TransactionReconstructor is implemented by plugins whose proposals the
+// verifier can rebuild from the signed policy and its own view of the chain.
+type TransactionReconstructor interface {
+	// ReconstructTransaction returns the transaction of the given type the policy
+	// calls for right now. The proposal is only consulted for arguments that
+	// can't be derived deterministically, such as swap deadlines, and only after
+	// checking them against the tolerances.
+	ReconstructTransaction(ctx context.Context, policy types.PluginPolicy, txType string, proposed *gtypes.Transaction, tol reconstruct.Tolerances) (*gtypes.Transaction, error)
 }
@@ -8,8 +8,13 @@ import PolicyService from "../services/policyService";
 import {
   derivePathMap,
   isSupportedChainType,
-  toHex,
 } from "@/modules/shared/wallet/wallet.utils";
+import {
+  newPolicyExpiry,
+  newPolicyNonce,
+  PolicyAction,
+  policyTypedData,
+} from "../utils/policySignature";
 import Toast from "@/modules/core/components/ui/toast/Toast";
 import VulticonnectWalletService from "@/modules/shared/wallet/vulticonnectWalletService";
 import { useParams } from "react-router-dom";
@@ -96,7 +101,7 @@ export const PolicyProvider: React.FC<{ children: React.ReactNode }> = ({
 
   const addPolicy = async (policy: PluginPolicy): Promise<boolean> => {
     try {
-      const signature = await signPolicy(policy);
+      const signature = await signPolicy(policy, "create");
       if (signature && typeof signature === "string") {
         policy.signature = signature;
         const newPolicy = await PolicyService.createPolicy(policy);
@@ -120,7 +125,7 @@ export const PolicyProvider: React.FC<{ children: React.ReactNode }> = ({
 
   const updatePolicy = async (policy: PluginPolicy): Promise<boolean> => {
     try {
-      const signature = await signPolicy(policy);
+      const signature = await signPolicy(policy, "update");
 
       if (signature && typeof signature === "string") {
         policy.signature = signature;
@@ -153,9 +158,14 @@ export const PolicyProvider: React.FC<{ children: React.ReactNode }> = ({
     if (!policy) return;
 
     try {
-      const signature = await signPolicy(policy);
+      const signature = await signPolicy(policy, "delete");
       if (signature && typeof signature === "string") {
-        await PolicyService.deletePolicy(policyId, signature);
+        await PolicyService.deletePolicy(
+          policyId,
+          signature,
+          policy.nonce as string,
+          policy.expiry as number
+        );
 
         setPolicyMap((prev) => {
           const updatedPolicyMap = new Map(prev);
@@ -179,7 +189,10 @@ export const PolicyProvider: React.FC<{ children: React.ReactNode }> = ({
     }
   };
 
-  const signPolicy = async (policy: PluginPolicy): Promise<string> => {
+  const signPolicy = async (
+    policy: PluginPolicy,
+    action: PolicyAction
+  ): Promise<string> => {
     const chain = localStorage.getItem("chain") as string;
 
     if (isSupportedChainType(chain)) {
@@ -199,18 +212,19 @@ export const PolicyProvider: React.FC<{ children: React.ReactNode }> = ({
       policy.is_ecdsa = true;
       policy.chain_code_hex = vaults[0].hexChainCode;
       policy.derive_path = derivePathMap[chain];
-      const serializedPolicy = JSON.stringify(policy);
-      const hexMessage = toHex(serializedPolicy);
+      policy.nonce = newPolicyNonce();
+      policy.expiry = newPolicyExpiry();
+      const typedData = policyTypedData(policy, action);
 
-      const signature = await VulticonnectWalletService.signCustomMessage(
-        hexMessage,
+      const signature = await VulticonnectWalletService.signTypedData(
+        typedData,
         accounts[0]
       );
 
       console.log("Public key ecdsa: ", policy.public_key);
       console.log("Chain code hex: ", policy.chain_code_hex);
       console.log("Derive path: ", policy.derive_path);
-      console.log("Hex message: ", hexMessage);
+      console.log("Typed data: ", typedData);
       console.log("Account[0]: ", accounts[0]);
       console.log("Signature: ", signature);
 
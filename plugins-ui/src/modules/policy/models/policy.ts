@@ -15,6 +15,8 @@ export type PluginPolicy = {
   policy_version: string;
   plugin_type: string;
   signature: string;
+  nonce?: string;
+  expiry?: number;
   policy: Policy;
   active: boolean;
 };
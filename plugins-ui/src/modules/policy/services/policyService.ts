@@ -85,10 +85,15 @@ const PolicyService = {
    * Delete policy from the API.
    * @param {id} string - The policy to be deleted.
    */
-  deletePolicy: async (id: string, signature: string) => {
+  deletePolicy: async (
+    id: string,
+    signature: string,
+    nonce: string,
+    expiry: number
+  ) => {
     try {
       const endpoint = `${getPluginUrl()}/plugin/policy/${id}`;
-      return await remove(endpoint, { signature: signature });
+      return await remove(endpoint, { signature, nonce, expiry });
     } catch (error) {
       console.error("Error deleting policy:", error);
       throw error;
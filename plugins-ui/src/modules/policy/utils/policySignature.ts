@@ -0,0 +1,80 @@
+import { keccak256, toUtf8Bytes } from "ethers";
+import { PluginPolicy } from "../models/policy";
+
+export type PolicyAction = "create" | "update" | "delete";
+
+// how long a policy signature is accepted by the plugin server
+const SIGNATURE_TTL_SECONDS = 15 * 60;
+
+// JSON with sorted object keys, matching the canonical form hashed by the server
+const canonicalJson = (value: unknown): string => {
+  if (Array.isArray(value)) {
+    return `[${value.map(canonicalJson).join(",")}]`;
+  }
+  if (value !== null && typeof value === "object") {
+    const entries = Object.keys(value as Record<string, unknown>)
+      .sort()
+      .filter((key) => (value as Record<string, unknown>)[key] !== undefined)
+      .map(
+        (key) =>
+          `${JSON.stringify(key)}:${canonicalJson((value as Record<string, unknown>)[key])}`
+      );
+    return `{${entries.join(",")}}`;
+  }
+  return JSON.stringify(value);
+};
+
+export const newPolicyNonce = (): string => {
+  const bytes = crypto.getRandomValues(new Uint8Array(16));
+  return BigInt(
+    "0x" + Array.from(bytes, (b) => b.toString(16).padStart(2, "0")).join("")
+  ).toString();
+};
+
+export const newPolicyExpiry = (): number =>
+  Math.floor(Date.now() / 1000) + SIGNATURE_TTL_SECONDS;
+
+/**
+ * EIP-712 typed data of an action on the policy, as verified by the server.
+ * The policy id is assigned by the server, so it isn't signed on creation.
+ */
+export const policyTypedData = (policy: PluginPolicy, action: PolicyAction) => ({
+  types: {
+    EIP712Domain: [
+      { name: "name", type: "string" },
+      { name: "version", type: "string" },
+    ],
+    PolicyAction: [
+      { name: "action", type: "string" },
+      { name: "policyId", type: "string" },
+      { name: "publicKey", type: "string" },
+      { name: "chainCodeHex", type: "string" },
+      { name: "derivePath", type: "string" },
+      { name: "pluginId", type: "string" },
+      { name: "pluginType", type: "string" },
+      { name: "pluginVersion", type: "string" },
+      { name: "policyVersion", type: "string" },
+      { name: "policyHash", type: "bytes32" },
+      { name: "active", type: "bool" },
+      { name: "nonce", type: "uint256" },
+      { name: "expiry", type: "uint256" },
+    ],
+  },
+  primaryType: "PolicyAction",
+  domain: { name: "Vultisig Plugin Policy", version: "1" },
+  message: {
+    action,
+    policyId: action === "create" ? "" : policy.id,
+    publicKey: policy.public_key,
+    chainCodeHex: policy.chain_code_hex,
+    derivePath: policy.derive_path,
+    pluginId: policy.plugin_id,
+    pluginType: policy.plugin_type,
+    pluginVersion: policy.plugin_version,
+    policyVersion: policy.policy_version,
+    policyHash: keccak256(toUtf8Bytes(canonicalJson(policy.policy))),
+    active: policy.active,
+    nonce: policy.nonce ?? "0",
+    expiry: String(policy.expiry ?? 0),
+  },
+});
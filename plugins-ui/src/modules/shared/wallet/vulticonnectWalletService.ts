@@ -68,6 +68,28 @@ const VulticonnectWalletService = {
     }
   },
 
+  signTypedData: async (typedData: object, walletAddress: string) => {
+    if (!window.vultisig?.ethereum) {
+      alert(`No ethereum provider found. Please install VultiConnect.`);
+      return;
+    }
+
+    try {
+      const signature = await window.vultisig.ethereum.request({
+        method: "eth_signTypedData_v4",
+        params: [walletAddress, JSON.stringify(typedData)],
+      });
+
+      if (signature && signature.error) {
+        throw signature.error;
+      }
+      return signature;
+    } catch (error) {
+      console.error("Failed to sign the typed data", error);
+      throw new Error("Failed to sign the typed data");
+    }
+  },
+
   getVaults: async () => {
     if (!window.vultisig) {
       alert(`No wallet found. Please install VultiConnect.`);
@@ -14,11 +14,11 @@ type LocalStateAccessorImp struct {
 	Folder       string
 	Vault        *vaultType.Vault
 	cache        map[string]string
-	blockStorage *storage.BlockStorage
+	blockStorage storage.BlockStorage
 }
 
 func NewLocalStateAccessorImp(folder, vaultFileName, vaultPasswd string,
-	storage *storage.BlockStorage) (*LocalStateAccessorImp, error) {
+	storage storage.BlockStorage) (*LocalStateAccessorImp, error) {
 	localStateAccessor := &LocalStateAccessorImp{
 		Folder:       folder,
 		Vault:        nil,
@@ -77,7 +77,7 @@ func main() {
 	policy := types.PluginPolicy{
 		ID:            policyId,
 		PublicKey:     key,
-		PluginID:      "dca",
+		PluginID:      pluginConfig.Server.Plugin.ID,
 		PluginVersion: "1.0.0",
 		PolicyVersion: "1.0.0",
 		PluginType:    "dca",
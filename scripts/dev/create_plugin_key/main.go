@@ -0,0 +1,22 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Usage:
+// `go run ./scripts/dev/create_plugin_key/main.go`
+// Set the signing key as `server.plugin.signing_key` in the plugin config and
+// register the public key as the plugin's `public_key` on the verifier.
+func main() {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Errorf("failed to generate key: %w", err))
+	}
+
+	fmt.Printf("signing_key: %s\n", hex.EncodeToString(privateKey.Seed()))
+	fmt.Printf("public_key:  %s\n", hex.EncodeToString(publicKey))
+}
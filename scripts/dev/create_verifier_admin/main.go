@@ -10,6 +10,7 @@ import (
 
 	"github.com/vultisig/vultiserver-plugin/config"
 	pass "github.com/vultisig/vultiserver-plugin/internal/password"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
 )
 
 var username string
@@ -40,14 +41,17 @@ func main() {
 
 	query := fmt.Sprintf(`INSERT INTO %s (
 		username,
-		password
+		password,
+		role
 	) VALUES (
 		@Username,
-		@Password
+		@Password,
+		@Role
 	) RETURNING id;`, "users")
 	args := pgx.NamedArgs{
 		"Username": username,
 		"Password": passwordHash,
+		"Role":     types.UserRoleAdmin,
 	}
 
 	var createdId string
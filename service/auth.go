@@ -1,22 +1,43 @@
 package service
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
+// Claims of tokens issued to plugin service accounts also carry the plugin ID,
+// the granted scopes and a token ID (jti) used for revocation. Tokens issued
+// to a vault carry the public key it proved to own.
 type Claims struct {
 	jwt.StandardClaims
+	PluginID  string   `json:"plugin_id,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	PublicKey string   `json:"public_key,omitempty"`
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
 }
 
 const (
-	expireDuration = 7 * 24 * time.Hour
+	expireDuration       = 7 * 24 * time.Hour
+	pluginExpireDuration = time.Hour
 )
 
+// AuthService signs vault tokens with the shared JWT secret and plugin tokens
+// with the verifier's Ed25519 key, so that holding the secret isn't enough to
+// act as a plugin.
 type AuthService struct {
 	JWTSecret []byte
+	pluginKey ed25519.PrivateKey
 }
 
 func NewAuthService(secret string) *AuthService {
@@ -25,21 +46,83 @@ func NewAuthService(secret string) *AuthService {
 	}
 }
 
-func (a *AuthService) GenerateToken() (string, error) {
+// SetPluginKey takes the hex encoded 32 byte Ed25519 seed plugin tokens are
+// signed with. Without it plugin tokens are neither issued nor accepted.
+func (a *AuthService) SetPluginKey(seedHex string) error {
+	seed, err := hex.DecodeString(strings.TrimPrefix(seedHex, "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to decode plugin token key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("plugin token key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	a.pluginKey = ed25519.NewKeyFromSeed(seed)
+	return nil
+}
+
+// GenerateToken issues a token to the vault with the given public key, once
+// it has signed in with it.
+func (a *AuthService) GenerateToken(publicKey string) (string, error) {
+	if publicKey == "" {
+		return "", errors.New("public key is required")
+	}
 	expirationTime := time.Now().Add(expireDuration).Unix()
 	claims := &Claims{
 		StandardClaims: jwt.StandardClaims{
+			Subject:   publicKey,
 			ExpiresAt: expirationTime,
 		},
+		PublicKey: publicKey,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(a.JWTSecret)
 }
 
+// GeneratePluginToken issues a short lived token for a plugin service account.
+func (a *AuthService) GeneratePluginToken(pluginID string, scopes []string) (string, *Claims, error) {
+	if pluginID == "" {
+		return "", nil, errors.New("plugin id is required")
+	}
+	if a.pluginKey == nil {
+		return "", nil, errors.New("plugin token key is not configured")
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   pluginID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(pluginExpireDuration).Unix(),
+		},
+		PluginID: pluginID,
+		Scopes:   scopes,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	signed, err := token.SignedString(a.pluginKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, claims, nil
+}
+
 func (a *AuthService) ValidateToken(tokenStr string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return a.JWTSecret, nil
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			// the shared secret only vouches for vaults
+			if claims.PluginID != "" {
+				return nil, errors.New("plugin token is not signed with the plugin key")
+			}
+			return a.JWTSecret, nil
+		case *jwt.SigningMethodEd25519:
+			if a.pluginKey == nil {
+				return nil, errors.New("plugin token key is not configured")
+			}
+			return a.pluginKey.Public(), nil
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	})
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid or expired token")
@@ -48,9 +131,17 @@ func (a *AuthService) ValidateToken(tokenStr string) (*Claims, error) {
 }
 
 func (a *AuthService) RefreshToken(oldToken string) (string, error) {
-	_, err := a.ValidateToken(oldToken)
+	claims, err := a.ValidateToken(oldToken)
 	if err != nil {
 		return "", err
 	}
-	return a.GenerateToken()
+	// plugin tokens are reissued through their own credentials, so that revocation holds
+	if claims.PluginID != "" {
+		return "", errors.New("plugin tokens cannot be refreshed")
+	}
+	// tokens issued before they were bound to a vault are retired
+	if claims.PublicKey == "" {
+		return "", errors.New("token is not bound to a vault")
+	}
+	return a.GenerateToken(claims.PublicKey)
 }
@@ -1,6 +1,8 @@
 package service_test
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"testing"
 	"time"
 
@@ -28,10 +30,13 @@ func TestGenerateToken(t *testing.T) {
 		},
 	}
 
+	_, err := service.NewAuthService("secret").GenerateToken("")
+	assert.Error(t, err, "tokens are bound to a vault")
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			authService := service.NewAuthService(tc.secret)
-			token, err := authService.GenerateToken()
+			token, err := authService.GenerateToken("vault-public-key")
 
 			if tc.shouldError {
 				assert.Error(t, err)
@@ -58,7 +63,7 @@ func TestValidateToken(t *testing.T) {
 			name: "Valid token",
 			setupToken: func() string {
 				auth := service.NewAuthService(secret)
-				token, _ := auth.GenerateToken()
+				token, _ := auth.GenerateToken("vault-public-key")
 				return token
 			},
 			secret:      secret,
@@ -99,7 +104,7 @@ func TestValidateToken(t *testing.T) {
 			name: "Wrong secret",
 			setupToken: func() string {
 				auth := service.NewAuthService(secret)
-				token, _ := auth.GenerateToken()
+				token, _ := auth.GenerateToken("vault-public-key")
 				return token
 			},
 			secret:      wrongSecret,
@@ -146,7 +151,7 @@ func TestRefreshToken(t *testing.T) {
 			name: "Valid token refresh",
 			setupToken: func() string {
 				auth := service.NewAuthService(secret)
-				token, _ := auth.GenerateToken()
+				token, _ := auth.GenerateToken("vault-public-key")
 				time.Sleep(1 * time.Second)
 				return token
 			},
@@ -173,6 +178,20 @@ func TestRefreshToken(t *testing.T) {
 			},
 			shouldError: true,
 		},
+		{
+			name: "Token without vault refresh",
+			setupToken: func() string {
+				claims := &service.Claims{
+					StandardClaims: jwt.StandardClaims{
+						ExpiresAt: time.Now().Add(time.Hour).Unix(),
+					},
+				}
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				tokenString, _ := token.SignedString([]byte(secret))
+				return tokenString
+			},
+			shouldError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -195,10 +214,95 @@ func TestRefreshToken(t *testing.T) {
 				assert.NotEmpty(t, newToken)
 				assert.NotEqual(t, tokenString, newToken, "Refreshed token should be different from the original")
 
+				// the refreshed token stays bound to the vault
 				claims, validationErr := authService.ValidateToken(newToken)
 				assert.NoError(t, validationErr)
-				assert.NotNil(t, claims)
+				if assert.NotNil(t, claims) {
+					assert.Equal(t, "vault-public-key", claims.PublicKey)
+				}
 			}
 		})
 	}
 }
+
+func newPluginKey(t *testing.T) string {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	assert.NoError(t, err)
+	return hex.EncodeToString(seed)
+}
+
+func TestGeneratePluginToken(t *testing.T) {
+	secret := "plugin-test-secret"
+	authService := service.NewAuthService(secret)
+
+	// plugin tokens aren't issued without the plugin key
+	_, _, err := authService.GeneratePluginToken("plugin-1", nil)
+	assert.Error(t, err)
+	assert.NoError(t, authService.SetPluginKey(newPluginKey(t)))
+
+	token, issued, err := authService.GeneratePluginToken("plugin-1", []string{"transaction:sync"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, issued.Id)
+
+	claims, err := authService.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "plugin-1", claims.PluginID)
+	assert.Equal(t, issued.Id, claims.Id)
+	assert.True(t, claims.HasScope("transaction:sync"))
+	assert.False(t, claims.HasScope("policy:read"))
+
+	// the token doesn't depend on the shared secret, but on the plugin key
+	other := service.NewAuthService("other-secret")
+	_, err = other.ValidateToken(token)
+	assert.Error(t, err)
+	assert.NoError(t, other.SetPluginKey(newPluginKey(t)))
+	_, err = other.ValidateToken(token)
+	assert.Error(t, err)
+
+	// plugin tokens must be reissued rather than refreshed
+	newToken, err := authService.RefreshToken(token)
+	assert.Error(t, err)
+	assert.Empty(t, newToken)
+
+	_, _, err = authService.GeneratePluginToken("", nil)
+	assert.Error(t, err)
+}
+
+func TestPluginTokenSignedWithSecret(t *testing.T) {
+	secret := "plugin-test-secret"
+	authService := service.NewAuthService(secret)
+	assert.NoError(t, authService.SetPluginKey(newPluginKey(t)))
+
+	// knowing the shared secret isn't enough to mint a plugin token
+	claims := &service.Claims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		PluginID: "plugin-1",
+		Scopes:   []string{"transaction:sync"},
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(forged)
+	assert.Error(t, err)
+}
+
+func TestSetPluginKey(t *testing.T) {
+	authService := service.NewAuthService("plugin-test-secret")
+	assert.Error(t, authService.SetPluginKey("not-hex"))
+	assert.Error(t, authService.SetPluginKey("abcd"))
+	assert.NoError(t, authService.SetPluginKey("0x"+newPluginKey(t)))
+}
+
+func TestGeneratePluginTokenUniqueID(t *testing.T) {
+	authService := service.NewAuthService("plugin-test-secret")
+	assert.NoError(t, authService.SetPluginKey(newPluginKey(t)))
+
+	_, first, err := authService.GeneratePluginToken("plugin-1", nil)
+	assert.NoError(t, err)
+	_, second, err := authService.GeneratePluginToken("plugin-1", nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Id, second.Id)
+}
@@ -27,12 +27,12 @@ type DKLSTssService struct {
 	localStateAccessor *relay.LocalStateAccessorImp
 	isKeygenFinished   *atomic.Bool
 	isKeysignFinished  *atomic.Bool
-	blockStorage       *storage.BlockStorage
+	blockStorage       storage.BlockStorage
 	backup             VaultOperation
 }
 
 func NewDKLSTssService(cfg config.Config,
-	blockStorage *storage.BlockStorage,
+	blockStorage storage.BlockStorage,
 	localStateAccessor *relay.LocalStateAccessorImp,
 	backupInterface VaultOperation) (*DKLSTssService, error) {
 	return &DKLSTssService{
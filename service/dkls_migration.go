@@ -163,7 +163,7 @@ func (t *DKLSTssService) ProceeMigration(vault *vaultType.Vault,
 		LibType:       keygenType.LibType_LIB_TYPE_DKLS,
 		ResharePrefix: "",
 	}
-	return t.backup.SaveVaultAndScheduleEmail(newVault, encryptionPassword, email)
+	return t.backup.SaveVaultAndScheduleEmail(newVault, sessionID, encryptionPassword, email)
 }
 
 func (t *DKLSTssService) migrateWithRetry(publicKey string,
@@ -2,33 +2,58 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/vultisig/vultiserver-plugin/internal/scheduler"
 	"github.com/vultisig/vultiserver-plugin/internal/syncer"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
 	"github.com/vultisig/vultiserver-plugin/storage"
 )
 
 type Policy interface {
 	CreatePolicyWithSync(ctx context.Context, policy types.PluginPolicy) (*types.PluginPolicy, error)
 	UpdatePolicyWithSync(ctx context.Context, policy types.PluginPolicy) (*types.PluginPolicy, error)
-	DeletePolicyWithSync(ctx context.Context, policyID, signature string) error
+	DeletePolicyWithSync(ctx context.Context, policyID string, req types.PolicyDeleteRequest) error
 	GetPluginPolicies(ctx context.Context, pluginType, publicKey string) ([]types.PluginPolicy, error)
 	GetPluginPolicy(ctx context.Context, policyID string) (types.PluginPolicy, error)
 	GetPluginPolicyTransactionHistory(ctx context.Context, policyID string) ([]types.TransactionHistory, error)
+	QueryTransactionHistory(ctx context.Context, query types.TransactionHistoryQuery) (*types.TransactionHistoryPage, error)
+	GetPluginPolicyRuns(ctx context.Context, policyID string, take int, skip int) ([]types.PolicyRun, error)
+	GetPluginPolicyTransactionAggregates(ctx context.Context, policyID string) ([]types.TransactionAggregate, error)
 }
 
+// ErrPolicyNonceUsed is returned when a policy signature nonce is replayed.
+var ErrPolicyNonceUsed = errors.New("policy signature nonce already used")
+
+// ErrPolicyDeleted is returned when a deleted policy is changed or signed for.
+var ErrPolicyDeleted = errors.New("policy is deleted")
+
+// ErrPolicyOwnerChanged is returned when an update would move a policy to
+// another vault or plugin.
+var ErrPolicyOwnerChanged = errors.New("policy belongs to another vault or plugin")
+
+// ErrStalePolicy is returned when a synced policy was signed before the one
+// stored.
+var ErrStalePolicy = errors.New("policy signature is older than the stored one")
+
 type PolicyService struct {
 	db        storage.DatabaseStorage
 	syncer    syncer.PolicySyncer
 	scheduler *scheduler.SchedulerService
+	watcher   *watcher.WatcherService
 	logger    *logrus.Logger
+	// receivesSyncs is set on the verifier, whose policies are synced from
+	// plugin servers rather than submitted by the vault
+	receivesSyncs bool
 }
 
-func NewPolicyService(db storage.DatabaseStorage, syncer syncer.PolicySyncer, scheduler *scheduler.SchedulerService, logger *logrus.Logger) (*PolicyService, error) {
+func NewPolicyService(db storage.DatabaseStorage, syncer syncer.PolicySyncer, scheduler *scheduler.SchedulerService, watcher *watcher.WatcherService, logger *logrus.Logger) (*PolicyService, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database storage cannot be nil")
 	}
@@ -36,35 +61,52 @@ func NewPolicyService(db storage.DatabaseStorage, syncer syncer.PolicySyncer, sc
 		db:        db,
 		syncer:    syncer,
 		scheduler: scheduler,
+		watcher:   watcher,
 		logger:    logger,
 	}, nil
 }
 
+// ReceiveSyncs makes the service apply policies synced from plugin servers. A
+// sync may be retried or repaired long after the vault signed it, so its
+// replay is checked against the stored policy rather than the signature
+// expiry, see checkSyncReplayTx.
+func (s *PolicyService) ReceiveSyncs() {
+	s.receivesSyncs = true
+}
+
 func (s *PolicyService) CreatePolicyWithSync(ctx context.Context, policy types.PluginPolicy) (*types.PluginPolicy, error) {
 	// Start transaction
-	tx, err := s.db.Pool().Begin(ctx)
+	tx, err := s.db.BeginTx(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	stored, err := s.checkReplayTx(ctx, tx, policy)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		return stored, nil
+	}
+
 	// Insert policy
 	newPolicy, err := s.db.InsertPluginPolicyTx(ctx, tx, policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert policy: %w", err)
 	}
 
-	// Handle trigger if scheduler exists
-	if s.scheduler != nil {
-		if err := s.scheduler.CreateTimeTrigger(ctx, policy, tx); err != nil {
-			return nil, fmt.Errorf("failed to create time trigger: %w", err)
-		}
+	if err := s.createTriggerTx(ctx, tx, policy); err != nil {
+		return nil, err
+	}
+	if err := s.publishPolicyEventTx(ctx, tx, types.WebhookEventPolicyCreated, *newPolicy); err != nil {
+		return nil, err
 	}
 	// Sync if only syncer exists.
 	if s.syncer != nil {
-		err := s.syncer.CreatePolicySync(policy)
+		err := s.syncer.CreatePolicySyncTx(ctx, tx, policy)
 		if err != nil {
-			return nil, fmt.Errorf("failed to sync create policy with verifier: %w", err)
+			return nil, fmt.Errorf("failed to record create policy sync: %w", err)
 		}
 	}
 
@@ -72,37 +114,46 @@ func (s *PolicyService) CreatePolicyWithSync(ctx context.Context, policy types.P
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	s.flushSync(ctx, policy.ID)
+
 	return newPolicy, nil
 }
 
 func (s *PolicyService) UpdatePolicyWithSync(ctx context.Context, policy types.PluginPolicy) (*types.PluginPolicy, error) {
 	// start transaction
-	tx, err := s.db.Pool().Begin(ctx)
+	tx, err := s.db.BeginTx(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	if err := s.checkStoredPolicyTx(ctx, tx, policy); err != nil {
+		return nil, err
+	}
+	stored, err := s.checkReplayTx(ctx, tx, policy)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		return stored, nil
+	}
+
 	// Update policy with tx
 	updatedPolicy, err := s.db.UpdatePluginPolicyTx(ctx, tx, policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update policy: %w", err)
 	}
 
-	if s.scheduler != nil {
-		trigger, err := s.scheduler.GetTriggerFromPolicy(policy)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get trigger from policy: %w", err)
-		}
-
-		if err := s.db.UpdateTimeTriggerTx(ctx, policy.ID, *trigger, tx); err != nil {
-			return nil, fmt.Errorf("failed to update trigger execution tx: %w", err)
-		}
+	if err := s.updateTriggerTx(ctx, tx, policy); err != nil {
+		return nil, err
+	}
+	if err := s.publishPolicyEventTx(ctx, tx, types.WebhookEventPolicyUpdated, *updatedPolicy); err != nil {
+		return nil, err
 	}
 
 	if s.syncer != nil {
-		if err := s.syncer.UpdatePolicySync(policy); err != nil {
-			return nil, fmt.Errorf("failed to sync update policy with verifier: %w", err)
+		if err := s.syncer.UpdatePolicySyncTx(ctx, tx, policy); err != nil {
+			return nil, fmt.Errorf("failed to record update policy sync: %w", err)
 		}
 	}
 
@@ -110,25 +161,41 @@ func (s *PolicyService) UpdatePolicyWithSync(ctx context.Context, policy types.P
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	s.flushSync(ctx, policy.ID)
+
 	return updatedPolicy, nil
 }
 
-func (s *PolicyService) DeletePolicyWithSync(ctx context.Context, policyID, signature string) error {
+func (s *PolicyService) DeletePolicyWithSync(ctx context.Context, policyID string, req types.PolicyDeleteRequest) error {
+	policy, err := s.db.GetPluginPolicy(ctx, policyID)
+	if err != nil {
+		return fmt.Errorf("failed to get policy: %w", err)
+	}
+	if policy.IsDeleted() {
+		return ErrPolicyDeleted
+	}
 
-	tx, err := s.db.Pool().Begin(ctx)
+	tx, err := s.db.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	err = s.db.DeletePluginPolicyTx(ctx, tx, policyID)
+	if err := s.consumeNonceTx(ctx, tx, policy.PublicKey, req.Nonce, req.Expiry); err != nil {
+		return err
+	}
+
+	err = s.db.DeletePluginPolicyTx(ctx, tx, policyID, req)
 	if err != nil {
 		return fmt.Errorf("failed to delete policy: %w", err)
 	}
+	if err := s.publishPolicyEventTx(ctx, tx, types.WebhookEventPolicyDeleted, policy); err != nil {
+		return err
+	}
 
 	if s.syncer != nil {
-		if err := s.syncer.DeletePolicySync(policyID, signature); err != nil {
-			return fmt.Errorf("failed to sync delete policy with verifier: %w", err)
+		if err := s.syncer.DeletePolicySyncTx(ctx, tx, policyID, req); err != nil {
+			return fmt.Errorf("failed to record delete policy sync: %w", err)
 		}
 	}
 
@@ -136,9 +203,227 @@ func (s *PolicyService) DeletePolicyWithSync(ctx context.Context, policyID, sign
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	s.flushSync(ctx, policyID)
+
+	return nil
+}
+
+// checkStoredPolicyTx returns ErrPolicyDeleted if the policy exists and was
+// deleted, and ErrPolicyOwnerChanged if it belongs to another vault or plugin.
+// The policy stays locked, so it can't be deleted before the transaction
+// ends. Missing policies are left to the caller.
+func (s *PolicyService) checkStoredPolicyTx(ctx context.Context, tx storage.Tx, policy types.PluginPolicy) error {
+	stored, err := s.db.GetPluginPolicyTx(ctx, tx, policy.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get policy: %w", err)
+	}
+	if stored.IsDeleted() {
+		return ErrPolicyDeleted
+	}
+	if stored.PublicKey != policy.PublicKey || stored.PluginID != policy.PluginID {
+		return ErrPolicyOwnerChanged
+	}
+	return nil
+}
+
+// checkReplayTx rejects replayed policy signatures. It returns the stored
+// policy when a sync pushes the signature it already holds, there is nothing
+// to apply then.
+func (s *PolicyService) checkReplayTx(ctx context.Context, tx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	if s.receivesSyncs {
+		return s.checkSyncReplayTx(ctx, tx, policy)
+	}
+	return nil, s.consumeNonceTx(ctx, tx, policy.PublicKey, policy.Nonce, policy.Expiry)
+}
+
+// checkSyncReplayTx is the replay rule of synced policies, keyed by policy
+// and signature. The signature the policy holds may be pushed again by
+// retries and repairs. Other signatures must have an unused nonce and expire
+// no earlier than the stored one, so that a withheld older signature can't
+// roll the policy back.
+func (s *PolicyService) checkSyncReplayTx(ctx context.Context, tx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	stored, err := s.db.GetPluginPolicyTx(ctx, tx, policy.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, s.consumeNonceTx(ctx, tx, policy.PublicKey, policy.Nonce, policy.Expiry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+
+	if stored.IsDeleted() {
+		return nil, ErrPolicyDeleted
+	}
+	if stored.Nonce == policy.Nonce && stored.Signature == policy.Signature {
+		return &stored, nil
+	}
+	if policy.Expiry < stored.Expiry {
+		return nil, ErrStalePolicy
+	}
+	return nil, s.consumeNonceTx(ctx, tx, policy.PublicKey, policy.Nonce, policy.Expiry)
+}
+
+// consumeNonceTx records the nonce of the vault's policy signature, so the
+// signed request can't be replayed. Nonces are kept until the signature expires.
+func (s *PolicyService) consumeNonceTx(ctx context.Context, tx storage.Tx, publicKey, nonce string, expiry int64) error {
+	ok, err := s.db.ConsumePolicyNonceTx(ctx, tx, publicKey, nonce, time.Unix(expiry, 0))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPolicyNonceUsed
+	}
+	return nil
+}
+
+// publishPolicyEventTx notifies the vault's webhook subscribers of the policy
+// change. The signature is left out, it is of no use to subscribers.
+func (s *PolicyService) publishPolicyEventTx(ctx context.Context, tx storage.Tx, eventType types.WebhookEventType, policy types.PluginPolicy) error {
+	policy.Signature = ""
+	err := webhook.PublishTx(ctx, s.db, tx, types.WebhookEvent{
+		Type:      eventType,
+		PublicKey: policy.PublicKey,
+		PolicyID:  policy.ID,
+		Data:      policy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish policy webhook: %w", err)
+	}
 	return nil
 }
 
+// RestorePolicy inserts a policy received from the verifier, without syncing
+// it back. Policies deleted locally are not brought back.
+func (s *PolicyService) RestorePolicy(ctx context.Context, policy types.PluginPolicy) error {
+	tx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.checkStoredPolicyTx(ctx, tx, policy); err != nil {
+		return err
+	}
+
+	if _, err := s.db.InsertPluginPolicyTx(ctx, tx, policy); err != nil {
+		return fmt.Errorf("failed to insert policy: %w", err)
+	}
+	if err := s.createTriggerTx(ctx, tx, policy); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// OverwritePolicy replaces the local policy with the verifier's copy, without
+// syncing it back. Deleted policies are left as they are, completed ones stay
+// completed.
+func (s *PolicyService) OverwritePolicy(ctx context.Context, policy types.PluginPolicy) error {
+	// the status is kept by each side, the verifier's doesn't apply here
+	policy.Status = ""
+
+	tx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.checkStoredPolicyTx(ctx, tx, policy); err != nil {
+		return err
+	}
+
+	if _, err := s.db.UpdatePluginPolicyTx(ctx, tx, policy); err != nil {
+		return fmt.Errorf("failed to update policy: %w", err)
+	}
+	if err := s.updateTriggerTx(ctx, tx, policy); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *PolicyService) createTriggerTx(ctx context.Context, tx storage.Tx, policy types.PluginPolicy) error {
+	policyTrigger, err := watcher.GetPolicyTrigger(policy)
+	if err != nil {
+		return fmt.Errorf("failed to get policy trigger: %w", err)
+	}
+
+	// Policies declaring a trigger are fired by the watcher, the others by the scheduler
+	if policyTrigger != nil {
+		if s.watcher != nil {
+			if err := s.watcher.CreateEventTrigger(ctx, policy, tx); err != nil {
+				return fmt.Errorf("failed to create event trigger: %w", err)
+			}
+		}
+	} else if s.scheduler != nil {
+		if err := s.scheduler.CreateTimeTrigger(ctx, policy, tx); err != nil {
+			return fmt.Errorf("failed to create time trigger: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateTriggerTx replaces the trigger of the other kind when the policy
+// switches between its schedule and an event trigger, so that only the
+// current one fires it.
+func (s *PolicyService) updateTriggerTx(ctx context.Context, tx storage.Tx, policy types.PluginPolicy) error {
+	policyTrigger, err := watcher.GetPolicyTrigger(policy)
+	if err != nil {
+		return fmt.Errorf("failed to get policy trigger: %w", err)
+	}
+
+	if policyTrigger != nil {
+		if err := s.db.DeleteTimeTriggerTx(ctx, tx, policy.ID); err != nil {
+			return fmt.Errorf("failed to delete time trigger: %w", err)
+		}
+		if s.watcher != nil {
+			trigger, err := watcher.GetEventTriggerFromPolicy(policy)
+			if err != nil {
+				return fmt.Errorf("failed to get event trigger from policy: %w", err)
+			}
+
+			if err := s.db.UpdateEventTriggerTx(ctx, policy.ID, *trigger, tx); err != nil {
+				return fmt.Errorf("failed to update event trigger tx: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if err := s.db.DeleteEventTriggerTx(ctx, tx, policy.ID); err != nil {
+		return fmt.Errorf("failed to delete event trigger: %w", err)
+	}
+	if s.scheduler != nil {
+		trigger, err := s.scheduler.GetTriggerFromPolicy(policy)
+		if err != nil {
+			return fmt.Errorf("failed to get trigger from policy: %w", err)
+		}
+
+		if err := s.db.UpdateTimeTriggerTx(ctx, policy.ID, *trigger, tx); err != nil {
+			return fmt.Errorf("failed to update trigger execution tx: %w", err)
+		}
+	}
+	return nil
+}
+
+// flushSync attempts immediate delivery of the committed sync events. Events
+// left undelivered stay in the outbox and are retried by the relay loop.
+func (s *PolicyService) flushSync(ctx context.Context, policyID string) {
+	if s.syncer == nil {
+		return
+	}
+	if err := s.syncer.Flush(ctx, policyID); err != nil {
+		s.logger.WithField("policy_id", policyID).Warnf("Policy sync deferred to relay: %v", err)
+	}
+}
+
 func (s *PolicyService) GetPluginPolicies(ctx context.Context, pluginType, publicKey string) ([]types.PluginPolicy, error) {
 	policies, err := s.db.GetAllPluginPolicies(ctx, pluginType, publicKey)
 	if err != nil {
@@ -169,3 +454,59 @@ func (s *PolicyService) GetPluginPolicyTransactionHistory(ctx context.Context, p
 
 	return history, nil
 }
+
+// QueryTransactionHistory returns a page of the vault's transactions with the
+// totals of every transaction matching the query.
+func (s *PolicyService) QueryTransactionHistory(ctx context.Context, query types.TransactionHistoryQuery) (*types.TransactionHistoryPage, error) {
+	limit := query.Limit
+	// fetch one more transaction to know whether there is a next page
+	query.Limit++
+	history, err := s.db.QueryTransactionHistory(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction history: %w", err)
+	}
+
+	page := &types.TransactionHistoryPage{Transactions: history}
+	if len(history) > limit {
+		page.Transactions = history[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = types.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String()
+	}
+
+	page.Totals, err = s.db.GetTransactionTotals(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction totals: %w", err)
+	}
+
+	return page, nil
+}
+
+func (s *PolicyService) GetPluginPolicyRuns(ctx context.Context, policyID string, take int, skip int) ([]types.PolicyRun, error) {
+	policyUUID, err := uuid.Parse(policyID)
+	if err != nil {
+		return []types.PolicyRun{}, fmt.Errorf("invalid policy_id: %s", policyID)
+	}
+
+	runs, err := s.db.GetPolicyRuns(ctx, policyUUID, take, skip)
+	if err != nil {
+		return []types.PolicyRun{}, fmt.Errorf("failed to get policy runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetPluginPolicyTransactionAggregates returns the sums of the policy's
+// transactions archived by the retention job.
+func (s *PolicyService) GetPluginPolicyTransactionAggregates(ctx context.Context, policyID string) ([]types.TransactionAggregate, error) {
+	policyUUID, err := uuid.Parse(policyID)
+	if err != nil {
+		return []types.TransactionAggregate{}, fmt.Errorf("invalid policy_id: %s", policyID)
+	}
+
+	aggregates, err := s.db.GetTransactionAggregates(ctx, policyUUID)
+	if err != nil {
+		return []types.TransactionAggregate{}, fmt.Errorf("failed to get transaction aggregates: %w", err)
+	}
+
+	return aggregates, nil
+}
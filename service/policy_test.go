@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/scheduler"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/watcher"
+	"github.com/vultisig/vultiserver-plugin/service"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+func signedPolicy(id, nonce string, expiry time.Time) types.PluginPolicy {
+	return types.PluginPolicy{
+		ID:         id,
+		PublicKey:  "vault",
+		PluginID:   "plugin",
+		PluginType: "dca",
+		Signature:  "0xsig-" + nonce,
+		Nonce:      nonce,
+		Expiry:     expiry.Unix(),
+		Policy:     json.RawMessage(`{}`),
+		Active:     true,
+	}
+}
+
+func TestSyncReplay(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policyService, err := service.NewPolicyService(db, nil, nil, nil, logrus.New())
+	require.NoError(t, err)
+	policyService.ReceiveSyncs()
+
+	id := uuid.NewString()
+	// signatures long expired are still applied, syncs may be retried late
+	expiry := time.Now().Add(-48 * time.Hour)
+	created := signedPolicy(id, "1", expiry)
+	_, err = policyService.CreatePolicyWithSync(ctx, created)
+	require.NoError(t, err)
+
+	// retried and repaired syncs push the signature the policy holds
+	_, err = policyService.CreatePolicyWithSync(ctx, created)
+	assert.NoError(t, err)
+	_, err = policyService.UpdatePolicyWithSync(ctx, created)
+	assert.NoError(t, err)
+
+	updated := signedPolicy(id, "2", expiry.Add(time.Minute))
+	_, err = policyService.UpdatePolicyWithSync(ctx, updated)
+	require.NoError(t, err)
+	_, err = policyService.UpdatePolicyWithSync(ctx, updated)
+	assert.NoError(t, err)
+
+	// an older signature can't roll the policy back
+	_, err = policyService.UpdatePolicyWithSync(ctx, created)
+	assert.ErrorIs(t, err, service.ErrStalePolicy)
+	withheld := signedPolicy(id, "3", expiry)
+	_, err = policyService.UpdatePolicyWithSync(ctx, withheld)
+	assert.ErrorIs(t, err, service.ErrStalePolicy)
+	_, err = policyService.UpdatePolicyWithSync(ctx, signedPolicy(id, "1", expiry.Add(time.Hour)))
+	assert.ErrorIs(t, err, service.ErrPolicyNonceUsed)
+
+	// nor be used for another policy
+	_, err = policyService.CreatePolicyWithSync(ctx, signedPolicy(uuid.NewString(), "2", expiry.Add(time.Hour)))
+	assert.ErrorIs(t, err, service.ErrPolicyNonceUsed)
+
+	stored, err := db.GetPluginPolicy(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, updated.Signature, stored.Signature)
+}
+
+func TestSubmittedPolicyReplay(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policyService, err := service.NewPolicyService(db, nil, nil, nil, logrus.New())
+	require.NoError(t, err)
+
+	policy := signedPolicy(uuid.NewString(), "1", time.Now().Add(time.Hour))
+	_, err = policyService.CreatePolicyWithSync(ctx, policy)
+	require.NoError(t, err)
+
+	// policies submitted by the vault never reuse a signature
+	_, err = policyService.UpdatePolicyWithSync(ctx, policy)
+	assert.ErrorIs(t, err, service.ErrPolicyNonceUsed)
+}
+
+func TestUpdatePolicySwitchesTrigger(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	logger := logrus.New()
+	policyService, err := service.NewPolicyService(db, nil,
+		scheduler.NewSchedulerService(db, logger, nil, asynq.RedisClientOpt{}),
+		watcher.NewWatcherService(db, logger, nil, nil),
+		logger)
+	require.NoError(t, err)
+
+	scheduled := json.RawMessage(`{"schedule":{"frequency":"daily","interval":"1","start_time":"2025-01-31T09:30:00Z"}}`)
+	triggered := json.RawMessage(`{"trigger":{"type":"BALANCE","balance":{"chain_id":"1","operator":"lt","threshold":"100"}}}`)
+	assertTriggers := func(timeTriggers, eventTriggers int) {
+		t.Helper()
+		pendingTime, err := db.GetPendingTimeTriggers(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pendingTime, timeTriggers)
+		pendingEvent, err := db.GetPendingEventTriggers(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pendingEvent, eventTriggers)
+	}
+
+	policy := signedPolicy(uuid.NewString(), "1", time.Now().Add(time.Hour))
+	policy.Policy = scheduled
+	_, err = policyService.CreatePolicyWithSync(ctx, policy)
+	require.NoError(t, err)
+	assertTriggers(1, 0)
+
+	// the schedule no longer fires the policy once it declares a trigger
+	policy = signedPolicy(policy.ID, "2", time.Now().Add(time.Hour))
+	policy.Policy = triggered
+	_, err = policyService.UpdatePolicyWithSync(ctx, policy)
+	require.NoError(t, err)
+	assertTriggers(0, 1)
+
+	policy = signedPolicy(policy.ID, "3", time.Now().Add(time.Hour))
+	policy.Policy = scheduled
+	_, err = policyService.UpdatePolicyWithSync(ctx, policy)
+	require.NoError(t, err)
+	assertTriggers(1, 0)
+}
+
+func TestDeletedPolicyStaysDeleted(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policyService, err := service.NewPolicyService(db, nil, nil, nil, logrus.New())
+	require.NoError(t, err)
+
+	// policies unknown locally are restored
+	policy := signedPolicy(uuid.NewString(), "1", time.Now().Add(time.Hour))
+	require.NoError(t, policyService.RestorePolicy(ctx, policy))
+
+	require.NoError(t, policyService.DeletePolicyWithSync(ctx, policy.ID, types.PolicyDeleteRequest{
+		Signature: "0xsig-delete",
+		Nonce:     "2",
+		Expiry:    time.Now().Add(time.Hour).Unix(),
+	}))
+
+	updated := signedPolicy(policy.ID, "3", time.Now().Add(time.Hour))
+	_, err = policyService.UpdatePolicyWithSync(ctx, updated)
+	assert.ErrorIs(t, err, service.ErrPolicyDeleted)
+	assert.ErrorIs(t, policyService.OverwritePolicy(ctx, updated), service.ErrPolicyDeleted)
+	assert.ErrorIs(t, policyService.RestorePolicy(ctx, updated), service.ErrPolicyDeleted)
+
+	stored, err := db.GetPluginPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsDeleted())
+	assert.Equal(t, policy.Signature, stored.Signature)
+}
+
+func TestUpdatePolicyKeepsOwner(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policyService, err := service.NewPolicyService(db, nil, nil, nil, logrus.New())
+	require.NoError(t, err)
+
+	policy := signedPolicy(uuid.NewString(), "1", time.Now().Add(time.Hour))
+	_, err = policyService.CreatePolicyWithSync(ctx, policy)
+	require.NoError(t, err)
+
+	moved := signedPolicy(policy.ID, "2", time.Now().Add(time.Hour))
+	moved.PublicKey = "other-vault"
+	_, err = policyService.UpdatePolicyWithSync(ctx, moved)
+	assert.ErrorIs(t, err, service.ErrPolicyOwnerChanged)
+	assert.ErrorIs(t, policyService.OverwritePolicy(ctx, moved), service.ErrPolicyOwnerChanged)
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/internal/reconcile"
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
+	"github.com/vultisig/vultiserver-plugin/internal/syncer"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const (
+	policyDigestsEndpoint = "/sync/policy/digests"
+	policyEndpoint        = "/plugin/policy"
+)
+
+// ReconcileService compares the plugin's policies and transactions with the
+// verifier's copy and, when asked to, repairs drift in the direction of the
+// side holding a valid vault signature.
+type ReconcileService struct {
+	db            storage.DatabaseStorage
+	syncer        syncer.PolicySyncer
+	policyService *PolicyService
+	logger        *logrus.Logger
+	client        *http.Client
+	verifierAddr  string
+	tokenFunc     func() (string, error)
+	signer        *sigutil.RequestSigner
+	pluginType    string
+	interval      time.Duration
+	repair        bool
+	done          chan struct{}
+}
+
+func NewReconcileService(db storage.DatabaseStorage, syncer syncer.PolicySyncer, policyService *PolicyService, logger *logrus.Logger, verifierHost string, verifierPort int64, tokenFunc func() (string, error), signer *sigutil.RequestSigner, pluginType string, interval time.Duration, repair bool) *ReconcileService {
+	return &ReconcileService{
+		db:            db,
+		syncer:        syncer,
+		policyService: policyService,
+		logger:        logger,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		verifierAddr: fmt.Sprintf("http://%s:%d", verifierHost, verifierPort),
+		tokenFunc:    tokenFunc,
+		signer:       signer,
+		pluginType:   pluginType,
+		interval:     interval,
+		repair:       repair,
+		done:         make(chan struct{}),
+	}
+}
+
+func (s *ReconcileService) Start() {
+	go s.run()
+}
+
+func (s *ReconcileService) Stop() {
+	close(s.done)
+}
+
+func (s *ReconcileService) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := s.Reconcile(context.Background(), s.repair)
+			if err != nil {
+				s.logger.Errorf("Failed to reconcile with verifier: %v", err)
+				continue
+			}
+			s.logReport(report)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *ReconcileService) logReport(report *types.ReconcileReport) {
+	if len(report.Mismatches) == 0 {
+		s.logger.Infof("Reconciliation found no drift across %d policies", report.PoliciesChecked)
+		return
+	}
+	for _, mismatch := range report.Mismatches {
+		s.logger.WithFields(logrus.Fields{
+			"kind":           mismatch.Kind,
+			"policy_id":      mismatch.PolicyID,
+			"tx_hash":        mismatch.TxHash,
+			"fields":         mismatch.Fields,
+			"plugin_value":   mismatch.PluginValue,
+			"verifier_value": mismatch.VerifierValue,
+			"repaired":       mismatch.Repaired,
+			"repair_error":   mismatch.RepairError,
+		}).Warn("Reconciliation mismatch")
+	}
+}
+
+func (s *ReconcileService) Reconcile(ctx context.Context, repair bool) (*types.ReconcileReport, error) {
+	policies, err := s.db.GetPluginPoliciesByType(ctx, s.pluginType)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.db.GetTransactionStatusesByPluginType(ctx, s.pluginType)
+	if err != nil {
+		return nil, err
+	}
+	pluginDigests := reconcile.BuildDigests(policies, entries)
+
+	verifierDigests, err := s.fetchVerifierDigests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &types.ReconcileReport{
+		PluginType:      s.pluginType,
+		PoliciesChecked: len(pluginDigests),
+		Mismatches:      reconcile.Compare(pluginDigests, verifierDigests),
+		RepairRequested: repair,
+	}
+	if !repair {
+		return report, nil
+	}
+
+	policiesByID := make(map[string]types.PluginPolicy, len(policies))
+	for _, policy := range policies {
+		policiesByID[policy.ID] = policy
+	}
+	for i := range report.Mismatches {
+		mismatch := &report.Mismatches[i]
+		detail, err := s.repairMismatch(ctx, *mismatch, policiesByID)
+		if err != nil {
+			mismatch.RepairError = err.Error()
+			continue
+		}
+		mismatch.Repaired = true
+		mismatch.RepairDetail = detail
+	}
+
+	return report, nil
+}
+
+func (s *ReconcileService) repairMismatch(ctx context.Context, mismatch types.ReconcileMismatch, policiesByID map[string]types.PluginPolicy) (string, error) {
+	switch mismatch.Kind {
+	case types.MismatchMissingOnVerifier:
+		policy := policiesByID[mismatch.PolicyID]
+		if !sigutil.VerifyStoredPolicySignature(policy) {
+			return "", fmt.Errorf("plugin policy has no valid vault signature")
+		}
+		if err := s.pushPolicy(ctx, policy, false); err != nil {
+			return "", err
+		}
+		return "pushed policy to verifier", nil
+
+	case types.MismatchMissingOnPlugin:
+		policy, err := s.fetchVerifierPolicy(ctx, mismatch.PolicyID)
+		if err != nil {
+			return "", err
+		}
+		if !sigutil.VerifyStoredPolicySignature(*policy) {
+			return "", fmt.Errorf("verifier policy has no valid vault signature")
+		}
+		if err := s.policyService.RestorePolicy(ctx, *policy); err != nil {
+			return "", err
+		}
+		return "restored policy from verifier", nil
+
+	case types.MismatchPolicy:
+		// the newer copy wins, as long as it holds a valid vault signature
+		policy := policiesByID[mismatch.PolicyID]
+		verifierPolicy, err := s.fetchVerifierPolicy(ctx, mismatch.PolicyID)
+		if err != nil {
+			return "", err
+		}
+		pluginSigned := sigutil.VerifyStoredPolicySignature(policy)
+		verifierSigned := sigutil.VerifyStoredPolicySignature(*verifierPolicy)
+		switch {
+		case pluginSigned && (!verifierSigned || reconcile.ComparePolicyRevisions(policy, *verifierPolicy) >= 0):
+			if err := s.pushPolicy(ctx, policy, true); err != nil {
+				return "", err
+			}
+			return "pushed policy to verifier", nil
+		case verifierSigned:
+			if err := s.policyService.OverwritePolicy(ctx, *verifierPolicy); err != nil {
+				return "", err
+			}
+			return "overwrote policy with verifier copy", nil
+		}
+		return "", fmt.Errorf("neither side holds a valid vault signature")
+
+	case types.MismatchTransaction:
+		// the plugin drives signing, so its transaction record is authoritative
+		// unless the verifier's record is further along
+		if mismatch.PluginValue == "missing" {
+			return "", fmt.Errorf("transaction is unknown to the plugin")
+		}
+		tx, err := s.db.GetTransactionByHash(ctx, mismatch.TxHash)
+		if err != nil {
+			return "", err
+		}
+		action := syncer.UpdateAction
+		if mismatch.VerifierValue == "missing" {
+			action = syncer.CreateAction
+		} else if !reconcile.StatusAdvances(types.TransactionStatus(mismatch.VerifierValue), tx.Status) {
+			return "", fmt.Errorf("verifier transaction is %s, not replaced by %s", mismatch.VerifierValue, tx.Status)
+		}
+		if err := s.pushTransaction(ctx, action, *tx); err != nil {
+			return "", err
+		}
+		return "pushed transaction to verifier", nil
+	}
+
+	return "", fmt.Errorf("unknown mismatch kind: %s", mismatch.Kind)
+}
+
+func (s *ReconcileService) pushPolicy(ctx context.Context, policy types.PluginPolicy, update bool) error {
+	dbTx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if update {
+		err = s.syncer.UpdatePolicySyncTx(ctx, dbTx, policy)
+	} else {
+		err = s.syncer.CreatePolicySyncTx(ctx, dbTx, policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.syncer.Flush(ctx, policy.ID)
+}
+
+func (s *ReconcileService) pushTransaction(ctx context.Context, action syncer.Action, tx types.TransactionHistory) error {
+	dbTx, err := s.db.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer dbTx.Rollback(ctx)
+
+	if err := s.syncer.SyncTransactionTx(ctx, dbTx, action, tx); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.syncer.Flush(ctx, tx.PolicyID.String())
+}
+
+func (s *ReconcileService) fetchVerifierDigests(ctx context.Context) ([]types.PolicyDigest, error) {
+	var digests []types.PolicyDigest
+	endpoint := s.verifierAddr + policyDigestsEndpoint + "?plugin_type=" + url.QueryEscape(s.pluginType)
+	if err := s.getVerifier(ctx, endpoint, &digests); err != nil {
+		return nil, fmt.Errorf("failed to fetch verifier digests: %w", err)
+	}
+	return digests, nil
+}
+
+func (s *ReconcileService) fetchVerifierPolicy(ctx context.Context, policyID string) (*types.PluginPolicy, error) {
+	var policy types.PluginPolicy
+	if err := s.getVerifier(ctx, s.verifierAddr+policyEndpoint+"/"+policyID, &policy); err != nil {
+		return nil, fmt.Errorf("failed to fetch verifier policy: %w", err)
+	}
+	return &policy, nil
+}
+
+func (s *ReconcileService) getVerifier(ctx context.Context, endpoint string, out interface{}) error {
+	token, err := s.tokenFunc()
+	if err != nil {
+		return fmt.Errorf("fail to generate token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("fail to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", token))
+	if s.signer != nil {
+		s.signer.Sign(req, nil)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/reconcile"
+	"github.com/vultisig/vultiserver-plugin/internal/syncer"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+// recordingSyncer records the transactions pushed to the verifier.
+type recordingSyncer struct {
+	pushed []types.TransactionStatus
+}
+
+func (s *recordingSyncer) CreatePolicySyncTx(context.Context, storage.Tx, types.PluginPolicy) error {
+	return nil
+}
+
+func (s *recordingSyncer) UpdatePolicySyncTx(context.Context, storage.Tx, types.PluginPolicy) error {
+	return nil
+}
+
+func (s *recordingSyncer) DeletePolicySyncTx(context.Context, storage.Tx, string, types.PolicyDeleteRequest) error {
+	return nil
+}
+
+func (s *recordingSyncer) SyncTransactionTx(ctx context.Context, dbTx storage.Tx, action syncer.Action, tx types.TransactionHistory) error {
+	s.pushed = append(s.pushed, tx.Status)
+	return nil
+}
+
+func (s *recordingSyncer) Flush(context.Context, string) error { return nil }
+
+func (s *recordingSyncer) Start() {}
+
+func (s *recordingSyncer) Stop() {}
+
+func TestRepairTransactionStatus(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Policy: json.RawMessage(`{}`), Active: true}
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+
+	txID, err := db.CreateTransactionHistory(ctx, types.TransactionHistory{
+		PolicyID: uuid.MustParse(policy.ID),
+		TxHash:   "sighash",
+		Status:   types.StatusPending,
+		TxType:   "SWAP",
+	})
+	require.NoError(t, err)
+
+	// the verifier serves its digests with the status it holds for the transaction
+	var verifierStatus types.TransactionStatus
+	verifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policies, err := db.GetPluginPoliciesByType(r.Context(), "dca")
+		require.NoError(t, err)
+		digests := reconcile.BuildDigests(policies, []types.TransactionStatusEntry{
+			{PolicyID: policy.ID, TxHash: "sighash", Status: verifierStatus},
+		})
+		require.NoError(t, json.NewEncoder(w).Encode(digests))
+	}))
+	t.Cleanup(verifier.Close)
+
+	sync := &recordingSyncer{}
+	s := NewReconcileService(db, sync, nil, logrus.New(), "", 0, func() (string, error) { return "token", nil }, nil, "dca", time.Minute, true)
+	s.verifierAddr = verifier.URL
+
+	reconcileOnce := func(pluginStatus, verifierValue types.TransactionStatus) types.ReconcileMismatch {
+		t.Helper()
+		require.NoError(t, db.UpdateTransactionStatus(ctx, txID, types.TransactionUpdate{Status: pluginStatus}))
+		verifierStatus = verifierValue
+		report, err := s.Reconcile(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, report.Mismatches, 1)
+		return report.Mismatches[0]
+	}
+
+	// the verifier lagging behind the worker is brought forward step by step
+	for _, step := range []struct{ plugin, verifier types.TransactionStatus }{
+		{types.StatusSigned, types.StatusPending},
+		{types.StatusBroadcast, types.StatusSigned},
+	} {
+		mismatch := reconcileOnce(step.plugin, step.verifier)
+		assert.True(t, mismatch.Repaired, "%s over %s: %s", step.plugin, step.verifier, mismatch.RepairError)
+	}
+	assert.Equal(t, []types.TransactionStatus{types.StatusSigned, types.StatusBroadcast}, sync.pushed)
+
+	// a stale record doesn't roll the verifier back
+	mismatch := reconcileOnce(types.StatusPending, types.StatusSigned)
+	assert.False(t, mismatch.Repaired)
+	assert.NotEmpty(t, mismatch.RepairError)
+	assert.Len(t, sync.pushed, 2)
+}
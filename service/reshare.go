@@ -23,6 +23,7 @@ import (
 	"github.com/vultisig/vultiserver-plugin/common"
 	"github.com/vultisig/vultiserver-plugin/internal/tasks"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/vaultbackup"
 	"github.com/vultisig/vultiserver-plugin/relay"
 )
 
@@ -144,20 +145,20 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 		LibType:       keygenType.LibType_LIB_TYPE_GG20,
 		ResharePrefix: newResharePrefix,
 	}
-	return s.SaveVaultAndScheduleEmail(newVault, encryptionPassword, email)
+	return s.SaveVaultAndScheduleEmail(newVault, sessionID, encryptionPassword, email)
 }
 func (s *WorkerService) createVerificationCode(publicKeyECDSA string) (string, error) {
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	code := rnd.Intn(9000) + 1000
 	verificationCode := strconv.Itoa(code)
-	key := fmt.Sprintf("verification_code_%s", publicKeyECDSA)
 	// verification code will be valid for 1 hour
-	if err := s.redis.Set(context.Background(), key, verificationCode, time.Hour); err != nil {
+	if err := s.codes.Set(context.Background(), publicKeyECDSA, verificationCode, time.Hour); err != nil {
 		return "", fmt.Errorf("failed to set cache: %w", err)
 	}
 	return verificationCode, nil
 }
 func (s *WorkerService) SaveVaultAndScheduleEmail(vault *vaultType.Vault,
+	sessionID string,
 	encryptionPassword string,
 	email string) error {
 	vaultData, err := proto.Marshal(vault)
@@ -183,7 +184,8 @@ func (s *WorkerService) SaveVaultAndScheduleEmail(vault *vaultType.Vault,
 	}
 
 	base64VaultContent := base64.StdEncoding.EncodeToString(vaultBackupData)
-	if err := s.blockStorage.UploadFileWithRetry([]byte(base64VaultContent), filePathName, 5); err != nil {
+	backupInfo := vaultbackup.InfoFromVault(vault, sessionID)
+	if _, err := s.backups.Save(context.Background(), vault.PublicKeyEcdsa, []byte(base64VaultContent), backupInfo); err != nil {
 		if err := os.WriteFile(s.cfg.Server.VaultsFilePath+"/"+filePathName, []byte(base64VaultContent), 0644); err != nil {
 			s.logger.Errorf("fail to write file: %s", err)
 		}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/vultisig/vultiserver/contexthelper"
+
+	"github.com/vultisig/vultiserver-plugin/internal/retention"
+)
+
+// HandleTransactionRetention archives and purges finished transactions as
+// configured in the retention section, and writes the report as the result.
+func (s *WorkerService) HandleTransactionRetention(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	defer s.measureTime("worker.retention.latency", time.Now(), []string{})
+
+	job := retention.NewJob(s.db, s.blockStorage, retention.Policy{
+		ArchiveAfter:      s.cfg.Retention.ArchiveAfter,
+		PurgeAfter:        s.cfg.Retention.PurgeAfter,
+		PurgeMetadataKeys: s.cfg.Retention.PurgeMetadataKeys,
+		BatchSize:         s.cfg.Retention.BatchSize,
+		DryRun:            s.cfg.Retention.DryRun,
+	}, s.logger)
+	report, err := job.Run(ctx, time.Now())
+
+	// batches committed before a failure are counted too
+	tags := []string{"dry_run:" + strconv.FormatBool(report.DryRun)}
+	s.countMetric("worker.retention.purged", report.Purged, tags)
+	s.countMetric("worker.retention.archived", report.Archived, tags)
+	s.countMetric("worker.retention.archives", int64(report.Archives), tags)
+	if err != nil {
+		s.incCounter("worker.retention.error", tags)
+		s.logger.Errorf("transaction retention failed: %v", err)
+		return fmt.Errorf("transaction retention failed: %w", err)
+	}
+
+	result, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if _, err := t.ResultWriter().Write(result); err != nil {
+		return fmt.Errorf("t.ResultWriter.Write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *WorkerService) countMetric(name string, value int64, tags []string) {
+	if err := s.sdClient.Count(name, value, tags, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+}
@@ -26,7 +26,7 @@ import (
 
 type VaultOperation interface {
 	BackupVault(req types.VaultCreateRequest, partiesJoined []string, ecdsaPubkey, eddsaPubkey, hexChainCode string, localStateAccessor *relay.LocalStateAccessorImp) error
-	SaveVaultAndScheduleEmail(vault *vaultType.Vault, encryptionPassword, email string) error
+	SaveVaultAndScheduleEmail(vault *vaultType.Vault, sessionID, encryptionPassword, email string) error
 }
 
 func (s *WorkerService) JoinKeyGeneration(req types.VaultCreateRequest) (string, string, error) {
@@ -198,7 +198,7 @@ func (s *WorkerService) BackupVault(req types.VaultCreateRequest,
 	} else {
 		vault.LibType = keygen.LibType_LIB_TYPE_GG20
 	}
-	return s.SaveVaultAndScheduleEmail(vault, req.EncryptionPassword, req.Email)
+	return s.SaveVaultAndScheduleEmail(vault, req.SessionID, req.EncryptionPassword, req.Email)
 }
 
 func (s *WorkerService) createTSSService(serverURL, Session, HexEncryptionKey string, localStateAccessor tss.LocalStateAccessor, createPreParam bool, messageID string) (*tss.ServiceImpl, error) {
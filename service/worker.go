@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
@@ -21,9 +24,14 @@ import (
 
 	"github.com/vultisig/vultiserver-plugin/common"
 	"github.com/vultisig/vultiserver-plugin/config"
+	"github.com/vultisig/vultiserver-plugin/internal/rules"
+	"github.com/vultisig/vultiserver-plugin/internal/sigutil"
 	"github.com/vultisig/vultiserver-plugin/internal/syncer"
 	"github.com/vultisig/vultiserver-plugin/internal/tasks"
+	"github.com/vultisig/vultiserver-plugin/internal/txdecoder"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/internal/vaultbackup"
+	"github.com/vultisig/vultiserver-plugin/internal/webhook"
 	"github.com/vultisig/vultiserver-plugin/plugin"
 	"github.com/vultisig/vultiserver-plugin/plugin/dca"
 	"github.com/vultisig/vultiserver-plugin/plugin/payroll"
@@ -36,20 +44,21 @@ import (
 type WorkerService struct {
 	cfg          config.Config
 	verifierPort int64
-	redis        *storage.RedisStorage
+	signer       *sigutil.RequestSigner
+	codes        storage.Keyspace
 	logger       *logrus.Logger
 	queueClient  *asynq.Client
 	sdClient     *statsd.Client
-	blockStorage *storage.BlockStorage
+	blockStorage storage.BlockStorage
 	inspector    *asynq.Inspector
 	plugin       plugin.Plugin
 	db           storage.DatabaseStorage
 	syncer       syncer.PolicySyncer
-	authService  *AuthService
+	backups      *vaultbackup.Manager
 }
 
 // NewWorker creates a new worker service
-func NewWorker(cfg config.Config, verifierPort int64, queueClient *asynq.Client, sdClient *statsd.Client, syncer syncer.PolicySyncer, authService *AuthService, blockStorage *storage.BlockStorage, inspector *asynq.Inspector) (*WorkerService, error) {
+func NewWorker(cfg config.Config, verifierPort int64, signer *sigutil.RequestSigner, queueClient *asynq.Client, sdClient *statsd.Client, syncer syncer.PolicySyncer, blockStorage storage.BlockStorage, inspector *asynq.Inspector) (*WorkerService, error) {
 	logger := logrus.WithField("service", "worker").Logger
 
 	redis, err := storage.NewRedisStorage(cfg)
@@ -57,7 +66,7 @@ func NewWorker(cfg config.Config, verifierPort int64, queueClient *asynq.Client,
 		return nil, fmt.Errorf("storage.NewRedisStorage failed: %w", err)
 	}
 
-	db, err := postgres.NewPostgresBackend(false, cfg.Server.Database.DSN)
+	db, err := postgres.NewPostgresBackend(false, cfg.Server.Database.DSN, postgres.ReplicaOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("fail to connect to database: %w", err)
 	}
@@ -83,7 +92,7 @@ func NewWorker(cfg config.Config, verifierPort int64, queueClient *asynq.Client,
 	return &WorkerService{
 		cfg:          cfg,
 		db:           db,
-		redis:        redis,
+		codes:        storage.NewKeyspace(redis, storage.KeyspaceVerificationCode),
 		blockStorage: blockStorage,
 		queueClient:  queueClient,
 		sdClient:     sdClient,
@@ -91,8 +100,9 @@ func NewWorker(cfg config.Config, verifierPort int64, queueClient *asynq.Client,
 		plugin:       plugin,
 		logger:       logger,
 		syncer:       syncer,
-		authService:  authService,
+		backups:      vaultbackup.NewManager(db, blockStorage, cfg.BlockStorage.BackupVersions, logger),
 		verifierPort: verifierPort,
+		signer:       signer,
 	}, nil
 }
 
@@ -329,6 +339,11 @@ func (s *WorkerService) HandleReshare(ctx context.Context, t *asynq.Task) error
 	if localState.Vault != nil {
 		// reshare vault
 		vault = localState.Vault
+		backupInfo := vaultbackup.InfoFromVault(vault, req.SessionID)
+		if _, err := s.backups.Snapshot(ctx, req.PublicKey, backupInfo, types.VaultBackupPreReshare); err != nil {
+			s.logger.Errorf("failed to snapshot vault backup: %v", err)
+			return fmt.Errorf("failed to snapshot vault backup: %w", err)
+		}
 	} else {
 		vault = &vaultType.Vault{
 			Name:           req.Name,
@@ -353,7 +368,7 @@ func (s *WorkerService) HandleReshare(ctx context.Context, t *asynq.Task) error
 	return nil
 }
 
-func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Task) error {
+func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Task) (err error) {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
 		return err
 	}
@@ -366,7 +381,32 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 
 	defer s.measureTime("worker.plugin.transaction.latency", time.Now(), []string{})
 
-	// Always update back to PENDING status so the scheduler can enqueue task.
+	// Record the run so that runs failing before any transaction is proposed are visible too
+	var runTxIDs []uuid.UUID
+	runOutcome := types.PolicyRunSucceeded
+	runID, runErr := s.createPolicyRun(ctx, triggerEvent)
+	if runErr != nil {
+		s.logger.Errorf("createPolicyRun failed: %v", runErr)
+	} else {
+		defer func() {
+			var errorMessage *string
+			if err != nil {
+				if runOutcome == types.PolicyRunSucceeded {
+					runOutcome = types.PolicyRunFailed
+				}
+				message := err.Error()
+				errorMessage = &message
+			}
+			if err := s.db.FinishPolicyRun(ctx, runID, runOutcome, errorMessage, runTxIDs); err != nil {
+				s.logger.Errorf("db.FinishPolicyRun failed: %v", err)
+			}
+			if runOutcome == types.PolicyRunFailed {
+				s.publishRunFailed(ctx, triggerEvent.PolicyID, runID, errorMessage)
+			}
+		}()
+	}
+
+	// Always update back to PENDING status so the scheduler or watcher can enqueue task.
 	defer func() {
 		if err := s.db.UpdateTriggerStatus(ctx, triggerEvent.PolicyID, types.StatusTimeTriggerPending); err != nil {
 			s.logger.Errorf("db.UpdateTriggerStatus failed: %v", err)
@@ -374,6 +414,9 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 		if err := s.db.UpdateTimeTriggerLastExecution(ctx, triggerEvent.PolicyID); err != nil {
 			s.logger.Errorf("db.UpdateTimeTriggerLastExecution failed: %v", err)
 		}
+		if err := s.db.UpdateEventTriggerStatus(ctx, triggerEvent.PolicyID, types.StatusTimeTriggerPending); err != nil {
+			s.logger.Errorf("db.UpdateEventTriggerStatus failed: %v", err)
+		}
 	}()
 
 	s.incCounter("worker.plugin.transaction", []string{})
@@ -386,6 +429,11 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 		s.logger.Errorf("db.GetPluginPolicy failed: %v", err)
 		return fmt.Errorf("db.GetPluginPolicy failed: %v: %w", err, asynq.SkipRetry)
 	}
+	if policy.IsDeleted() {
+		runOutcome = types.PolicyRunSkipped
+		s.logger.WithField("policy_id", policy.ID).Info("Policy is deleted, skipping")
+		return nil
+	}
 
 	s.logger.WithFields(logrus.Fields{
 		"policy_id":   policy.ID,
@@ -396,13 +444,14 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 	// Propose transactions to sign
 	signRequests, err := s.plugin.ProposeTransactions(policy)
 	if err != nil {
+		if errors.Is(err, plugin.ErrCompletedPolicy) {
+			runOutcome = types.PolicyRunSkipped
+		}
 		s.logger.Errorf("Failed to create signing request: %v", err)
 		return fmt.Errorf("failed to create signing request: %v: %w", err, asynq.SkipRetry)
 	}
-
-	jwtToken, err := s.authService.GenerateToken()
-	if err != nil {
-		s.logger.Errorf("Failed to generate jwt token: %v", err)
+	if len(signRequests) == 0 {
+		runOutcome = types.PolicyRunSkipped
 	}
 
 	for _, signRequest := range signRequests {
@@ -414,10 +463,9 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 
 		// create transaction with PENDING status
 		metadata := map[string]interface{}{
-			"timestamp":        time.Now(),
-			"plugin_id":        signRequest.PluginID,
-			"public_key":       signRequest.KeysignRequest.PublicKey,
-			"transaction_type": signRequest.TransactionType,
+			"timestamp":  time.Now(),
+			"plugin_id":  signRequest.PluginID,
+			"public_key": signRequest.KeysignRequest.PublicKey,
 		}
 
 		newTx := types.TransactionHistory{
@@ -426,14 +474,17 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 			TxHash:   signRequest.Messages[0],
 			Status:   types.StatusPending,
 			Metadata: metadata,
+			TxType:   signRequest.TransactionType,
 		}
+		setTransactionDetails(&newTx, policy)
 
-		if err := s.upsertAndSyncTransaction(ctx, syncer.CreateAction, &newTx, jwtToken); err != nil {
+		if err := s.upsertAndSyncTransaction(ctx, syncer.CreateAction, &newTx); err != nil {
 			return fmt.Errorf("upsertAndSyncTransaction failed: %w", err)
 		}
+		runTxIDs = append(runTxIDs, newTx.ID)
 
 		// start TSS signing process
-		err = s.initiateTxSignWithVerifier(ctx, signRequest, metadata, newTx, jwtToken)
+		err = s.initiateTxSignWithVerifier(ctx, signRequest, newTx)
 		if err != nil {
 			return err
 		}
@@ -456,33 +507,24 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 		)
 		if err != nil {
 			s.logger.Errorf("Failed to enqueue signing task: %v", err)
+			runOutcome = types.PolicyRunFailed
 			continue
 		}
 
 		s.logger.Infof("Enqueued signing task: %s", ti.ID)
+		newTx.SigningTaskID = ti.ID
 
 		// wait for result with timeout
 		result, err := s.waitForTaskResult(ti.ID, 120*time.Second) // adjust timeout as needed (each policy provider should be able to set it, but there should be an incentive to not retry too much)
 		if err != nil {                                            //do we consider that the signature is always valid if err = nil?
-			metadata["error"] = err.Error()
-			metadata["task_id"] = ti.ID
+			setTransactionError(&newTx, err)
 			newTx.Status = types.StatusSigningFailed
-			newTx.Metadata = metadata
-			if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
+			if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
 				s.logger.Errorf("upsertAndSyncTransaction failed: %v", err)
 			}
 			return err
 		}
 
-		// Update to SIGNED status with result
-		metadata["task_id"] = ti.ID
-		metadata["result"] = result
-		newTx.Status = types.StatusSigned
-		newTx.Metadata = metadata
-		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
-			return fmt.Errorf("upsertAndSyncTransaction failed: %v", err)
-		}
-
 		var signatures map[string]tss.KeysignResponse
 		if err := json.Unmarshal(result, &signatures); err != nil {
 			s.logger.Errorf("Failed to unmarshal signatures: %v", err)
@@ -494,21 +536,38 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 			break
 		}
 
-		err = s.plugin.SigningComplete(ctx, signature, signRequest, policy)
+		// Update to SIGNED status with result
+		metadata["result"] = result
+		newTx.Status = types.StatusSigned
+		newTx.Metadata = metadata
+		if newTx.BroadcastHash, err = broadcastHash(signature, signRequest); err != nil {
+			s.logger.Warnf("Failed to compute broadcast hash: %v", err)
+		}
+		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
+			return fmt.Errorf("upsertAndSyncTransaction failed: %v", err)
+		}
+
+		receipt, err := s.plugin.SigningComplete(ctx, signature, signRequest, policy)
+		if receipt != nil {
+			newTx.BroadcastHash = receipt.TxHash.Hex()
+			if receipt.BlockNumber != nil {
+				blockNumber := receipt.BlockNumber.Uint64()
+				newTx.BlockNumber = &blockNumber
+			}
+		}
 		if err != nil {
 			s.logger.Errorf("Failed to complete signing: %v", err)
 
+			setTransactionError(&newTx, err)
 			newTx.Status = types.StatusRejected
-			newTx.Metadata = metadata
-			if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
+			if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
 				s.logger.Errorf("upsertAndSyncTransaction failed: %v", err)
 			}
 			return fmt.Errorf("fail to complete signing: %w", err)
 		}
 
 		newTx.Status = types.StatusMined
-		newTx.Metadata = metadata
-		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
+		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
 			s.logger.Errorf("upsertAndSyncTransaction failed: %v", err)
 		}
 	}
@@ -516,23 +575,131 @@ func (s *WorkerService) HandlePluginTransaction(ctx context.Context, t *asynq.Ta
 	return nil
 }
 
-func (s *WorkerService) initiateTxSignWithVerifier(ctx context.Context, signRequest types.PluginKeysignRequest, metadata map[string]interface{}, newTx types.TransactionHistory, jwtToken string) error {
+// publishRunFailed notifies the vault's webhook subscribers of a failed run.
+func (s *WorkerService) publishRunFailed(ctx context.Context, policyID string, runID uuid.UUID, errorMessage *string) {
+	policy, err := s.db.GetPluginPolicy(ctx, policyID)
+	if err != nil {
+		s.logger.Errorf("db.GetPluginPolicy failed: %v", err)
+		return
+	}
+
+	err = webhook.Publish(ctx, s.db, types.WebhookEvent{
+		Type:      types.WebhookEventPolicyRunFailed,
+		PublicKey: policy.PublicKey,
+		PolicyID:  policy.ID,
+		Data: map[string]interface{}{
+			"run_id":        runID,
+			"error_message": errorMessage,
+		},
+	})
+	if err != nil {
+		s.logger.Errorf("webhook.Publish failed: %v", err)
+	}
+}
+
+func (s *WorkerService) createPolicyRun(ctx context.Context, triggerEvent types.PluginTriggerEvent) (uuid.UUID, error) {
+	policyUUID, err := uuid.Parse(triggerEvent.PolicyID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid policy_id: %s", triggerEvent.PolicyID)
+	}
+
+	startedAt := time.Now().UTC()
+	triggeredAt := triggerEvent.TriggeredAt
+	if triggeredAt.IsZero() {
+		triggeredAt = startedAt
+	}
+	triggerSource := triggerEvent.TriggerSource
+	if triggerSource == "" {
+		triggerSource = types.TriggerSourceTime
+	}
+
+	return s.db.CreatePolicyRun(ctx, types.PolicyRun{
+		PolicyID:      policyUUID,
+		TriggerSource: triggerSource,
+		TriggeredAt:   triggeredAt,
+		StartedAt:     startedAt,
+		Outcome:       types.PolicyRunRunning,
+	})
+}
+
+// setTransactionDetails records the chain, addresses, token and amount the
+// transaction spends and its gas fee at the gas limit, which the history
+// queries filter and sum by. Transactions that don't parse are recorded
+// without them.
+func setTransactionDetails(tx *types.TransactionHistory, policy types.PluginPolicy) {
+	if from, err := common.DeriveAddress(policy.PublicKey, policy.ChainCodeHex, policy.DerivePath); err == nil {
+		tx.FromAddress = strings.ToLower(from.Hex())
+	}
+
+	parsed, err := txdecoder.ParseTransaction(tx.TxBody)
+	if err != nil {
+		return
+	}
+
+	spend := rules.ExtractSpend(parsed)
+	nonce, gasLimit := parsed.Nonce(), parsed.Gas()
+	tx.ChainID = spend.ChainID
+	tx.Token = spend.Token
+	tx.Amount = spend.Amount.String()
+	tx.ToAddress = spend.Contract
+	tx.Nonce = &nonce
+	tx.GasLimit = &gasLimit
+	tx.GasPrice = parsed.GasPrice().String()
+	tx.GasFee = new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), parsed.GasPrice()).String()
+	tx.Metadata["recipient"] = spend.Recipient
+}
+
+// broadcastHash returns the hash the signed transaction has on chain, unlike
+// the signing hash it was proposed with.
+func broadcastHash(signature tss.KeysignResponse, signRequest types.PluginKeysignRequest) (string, error) {
+	unsigned, err := txdecoder.ParseTransaction(signRequest.Transaction)
+	if err != nil {
+		return "", err
+	}
+
+	signedTx, _, err := sigutil.SignLegacyTx(signature, signRequest.Messages[0], signRequest.Transaction, unsigned.ChainId())
+	if err != nil {
+		return "", err
+	}
+	return signedTx.Hash().Hex(), nil
+}
+
+// setTransactionError records the error of a failed transaction, with its
+// code if the plugin classified it.
+func setTransactionError(tx *types.TransactionHistory, err error) {
+	message := err.Error()
+	tx.ErrorMessage = &message
+
+	var txErr *types.TransactionError
+	if errors.As(err, &txErr) {
+		tx.ErrorCode = txErr.Code
+	}
+}
+
+func (s *WorkerService) initiateTxSignWithVerifier(ctx context.Context, signRequest types.PluginKeysignRequest, newTx types.TransactionHistory) error {
 	signBytes, err := json.Marshal(signRequest)
 	if err != nil {
 		s.logger.Errorf("Failed to marshal sign request: %v", err)
 		return err
 	}
 
-	signResp, err := http.Post(
+	// the verifier only co-signs for the plugin that signed the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
 		fmt.Sprintf("http://localhost:%d/signFromPlugin", s.verifierPort),
-		"application/json",
-		bytes.NewBuffer(signBytes),
+		bytes.NewReader(signBytes),
 	)
 	if err != nil {
-		metadata["error"] = err.Error()
+		return fmt.Errorf("fail to create sign request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.signer != nil {
+		s.signer.Sign(req, signBytes)
+	}
+	signResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		setTransactionError(&newTx, err)
 		newTx.Status = types.StatusSigningFailed
-		newTx.Metadata = metadata
-		if err = s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
+		if err = s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
 			s.logger.Errorf("upsertAndSyncTransaction failed: %v", err)
 		}
 		return err
@@ -546,20 +713,25 @@ func (s *WorkerService) initiateTxSignWithVerifier(ctx context.Context, signRequ
 	}
 
 	if signResp.StatusCode != http.StatusOK {
-		metadata["error"] = string(respBody)
+		errorMessage := string(respBody)
+		newTx.ErrorMessage = &errorMessage
 		newTx.Status = types.StatusSigningFailed
-		newTx.Metadata = metadata
-		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx, jwtToken); err != nil {
+		if err := s.upsertAndSyncTransaction(ctx, syncer.UpdateAction, &newTx); err != nil {
 			s.logger.Errorf("upsertAndSyncTransaction failed: %v", err)
 		}
-		return err
+		return fmt.Errorf("verifier refused to sign: %s: %s", signResp.Status, errorMessage)
+	}
+
+	// the verifier counts the transactions it signed, count them the same way
+	if err := s.db.MarkTransactionSigned(ctx, newTx.ID, signRequest.TransactionType); err != nil {
+		s.logger.Errorf("db.MarkTransactionSigned failed: %v", err)
 	}
 	return nil
 }
 
-func (s *WorkerService) upsertAndSyncTransaction(ctx context.Context, action syncer.Action, tx *types.TransactionHistory, jwtToken string) error {
+func (s *WorkerService) upsertAndSyncTransaction(ctx context.Context, action syncer.Action, tx *types.TransactionHistory) error {
 	s.logger.Info("upsertAndSyncTransaction started with action: ", action)
-	dbTx, err := s.db.Pool().Begin(ctx)
+	dbTx, err := s.db.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -573,18 +745,41 @@ func (s *WorkerService) upsertAndSyncTransaction(ctx context.Context, action syn
 		}
 		tx.ID = txID
 	} else {
-		if err = s.db.UpdateTransactionStatusTx(ctx, dbTx, tx.ID, tx.Status, tx.Metadata); err != nil {
+		if err = s.db.UpdateTransactionStatusTx(ctx, dbTx, tx.ID, tx.Update()); err != nil {
 			return fmt.Errorf("failed to update transaction status: %w", err)
 		}
 	}
 
-	if err = s.syncer.SyncTransaction(action, jwtToken, *tx); err != nil {
-		return fmt.Errorf("failed to sync transaction: %w", err)
+	if err = s.syncer.SyncTransactionTx(ctx, dbTx, action, *tx); err != nil {
+		return fmt.Errorf("failed to record transaction sync: %w", err)
+	}
+
+	publicKey, _ := tx.Metadata["public_key"].(string)
+	err = webhook.PublishTx(ctx, s.db, dbTx, types.WebhookEvent{
+		Type:      types.WebhookEventTransactionStatus,
+		PublicKey: publicKey,
+		PolicyID:  tx.PolicyID.String(),
+		Data: map[string]interface{}{
+			"transaction_id": tx.ID,
+			"tx_hash":        tx.TxHash,
+			"broadcast_hash": tx.BroadcastHash,
+			"status":         tx.Status,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish transaction webhook: %w", err)
 	}
 
 	if err = dbTx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+
+	// the verifier must know the transaction before it is asked to co-sign it.
+	// The change is committed and the relay delivers it later, so a failed
+	// flush isn't the caller's error.
+	if err = s.syncer.Flush(ctx, tx.PolicyID.String()); err != nil {
+		s.logger.WithField("policy_id", tx.PolicyID).Warnf("Failed to sync transaction: %v", err)
+	}
 	return nil
 }
 
@@ -655,6 +850,11 @@ func (s *WorkerService) HandleReshareDKLS(ctx context.Context, t *asynq.Task) er
 	if localState.Vault != nil {
 		// reshare vault
 		vault = localState.Vault
+		backupInfo := vaultbackup.InfoFromVault(vault, req.SessionID)
+		if _, err := s.backups.Snapshot(ctx, req.PublicKey, backupInfo, types.VaultBackupPreReshare); err != nil {
+			s.logger.Errorf("failed to snapshot vault backup: %v", err)
+			return fmt.Errorf("failed to snapshot vault backup: %w", err)
+		}
 	} else {
 		vault = &vaultType.Vault{
 			Name:           req.Name,
@@ -708,6 +908,11 @@ func (s *WorkerService) HandleMigrateDKLS(ctx context.Context, t *asynq.Task) er
 	if localState.Vault == nil {
 		return fmt.Errorf("vault doesn't exist , fail to migrate: %w", asynq.SkipRetry)
 	}
+	backupInfo := vaultbackup.InfoFromVault(localState.Vault, req.SessionID)
+	if _, err := s.backups.Snapshot(ctx, req.PublicKey, backupInfo, types.VaultBackupPreMigrate); err != nil {
+		s.logger.Errorf("failed to snapshot vault backup: %v", err)
+		return fmt.Errorf("failed to snapshot vault backup: %w", err)
+	}
 
 	service, err := NewDKLSTssService(s.cfg, s.blockStorage, localState, s)
 	if err != nil {
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vultisig/mobile-tss-lib/tss"
+
+	"github.com/vultisig/vultiserver-plugin/internal/tasks"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/plugin"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+// proposer is a plugin whose proposals are canned.
+type proposer struct {
+	txs []types.PluginKeysignRequest
+	err error
+}
+
+func (p proposer) FrontendSchema() embed.FS { return embed.FS{} }
+
+func (p proposer) ValidatePluginPolicy(types.PluginPolicy) error { return nil }
+
+func (p proposer) ProposeTransactions(types.PluginPolicy) ([]types.PluginKeysignRequest, error) {
+	return p.txs, p.err
+}
+
+func (p proposer) ValidateProposedTransactions(types.PluginPolicy, []types.PluginKeysignRequest) error {
+	return nil
+}
+
+func (p proposer) SigningComplete(context.Context, tss.KeysignResponse, types.PluginKeysignRequest, types.PluginPolicy) (*gtypes.Receipt, error) {
+	return nil, nil
+}
+
+func (p proposer) MaxTransactionsPerRun(types.PluginPolicy, string) (int64, error) { return 1, nil }
+
+var _ plugin.Plugin = proposer{}
+
+func TestHandlePluginTransactionRecordsRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		plugin       proposer
+		deleted      bool
+		wantErr      bool
+		outcome      types.PolicyRunOutcome
+		errorMessage bool
+	}{
+		{name: "nothing to propose", outcome: types.PolicyRunSkipped},
+		{
+			name:         "completed policy",
+			plugin:       proposer{err: plugin.ErrCompletedPolicy},
+			wantErr:      true,
+			outcome:      types.PolicyRunSkipped,
+			errorMessage: true,
+		},
+		{
+			name:         "proposal failed",
+			plugin:       proposer{err: errors.New("rpc unavailable")},
+			wantErr:      true,
+			outcome:      types.PolicyRunFailed,
+			errorMessage: true,
+		},
+		{name: "deleted policy", deleted: true, outcome: types.PolicyRunSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := memory.NewMemoryBackend()
+			s := &WorkerService{db: db, plugin: tt.plugin, logger: logrus.New()}
+
+			policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+			dbTx, err := db.BeginTx(ctx)
+			require.NoError(t, err)
+			_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+			require.NoError(t, err)
+			if tt.deleted {
+				require.NoError(t, db.DeletePluginPolicyTx(ctx, dbTx, policy.ID, types.PolicyDeleteRequest{}))
+			}
+			require.NoError(t, dbTx.Commit(ctx))
+
+			payload, err := json.Marshal(types.PluginTriggerEvent{PolicyID: policy.ID, TriggerSource: types.TriggerSourceManual})
+			require.NoError(t, err)
+			err = s.HandlePluginTransaction(ctx, asynq.NewTask(tasks.TypePluginTransaction, payload))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			runs, err := db.GetPolicyRuns(ctx, uuid.MustParse(policy.ID), 10, 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			run := runs[0]
+			assert.Equal(t, tt.outcome, run.Outcome)
+			assert.Equal(t, types.TriggerSourceManual, run.TriggerSource)
+			assert.NotNil(t, run.FinishedAt)
+			assert.NotNil(t, run.DurationMs)
+			assert.Equal(t, tt.errorMessage, run.ErrorMessage != nil)
+			assert.Empty(t, run.TransactionIDs)
+		})
+	}
+}
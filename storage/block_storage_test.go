@@ -0,0 +1,83 @@
+package storage_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func newLocalKMS(t *testing.T) *storage.LocalKMS {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	kms, err := storage.NewLocalKMS(key)
+	require.NoError(t, err)
+	return kms
+}
+
+func TestLocalBlockStorage(t *testing.T) {
+	root := t.TempDir()
+	bs, err := storage.NewLocalBlockStorage(root)
+	require.NoError(t, err)
+
+	exist, err := bs.FileExist("vault.bak")
+	require.NoError(t, err)
+	assert.False(t, exist)
+
+	require.NoError(t, bs.UploadFile([]byte("share"), "vault.bak"))
+	exist, err = bs.FileExist("vault.bak")
+	require.NoError(t, err)
+	assert.True(t, exist)
+
+	content, err := bs.GetFile("vault.bak")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("share"), content)
+
+	// names can't escape the root
+	require.NoError(t, bs.UploadFile([]byte("outside"), "../../escape.bak"))
+	content, err = bs.GetFile("escape.bak")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("outside"), content)
+
+	require.NoError(t, bs.DeleteFile("vault.bak"))
+	require.NoError(t, bs.DeleteFile("vault.bak"))
+	_, err = bs.GetFile("vault.bak")
+	require.Error(t, err)
+}
+
+func TestEncryptedBlockStorage(t *testing.T) {
+	local, err := storage.NewLocalBlockStorage(t.TempDir())
+	require.NoError(t, err)
+	bs := storage.NewEncryptedBlockStorage(local, newLocalKMS(t))
+
+	require.NoError(t, bs.UploadFile([]byte("vault share"), "vault.bak"))
+
+	// the stored file doesn't reveal the content
+	stored, err := local.GetFile("vault.bak")
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(stored, []byte("vault share")))
+
+	content, err := bs.GetFile("vault.bak")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("vault share"), content)
+
+	// files stored before encryption was enabled stay readable
+	require.NoError(t, local.UploadFile([]byte("legacy"), "legacy.bak"))
+	content, err = bs.GetFile("legacy.bak")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), content)
+
+	// a file copied under another name doesn't decrypt
+	require.NoError(t, local.UploadFile(stored, "other.bak"))
+	_, err = bs.GetFile("other.bak")
+	require.Error(t, err)
+
+	// nor does it with another key
+	_, err = storage.NewEncryptedBlockStorage(local, newLocalKMS(t)).GetFile("vault.bak")
+	require.Error(t, err)
+}
@@ -2,10 +2,10 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
 )
 
@@ -16,37 +16,118 @@ type DatabaseStorage interface {
 	FindUserByName(ctx context.Context, username string) (*types.UserWithPassword, error)
 
 	GetPluginPolicy(ctx context.Context, id string) (types.PluginPolicy, error)
+	// GetPluginPolicyTx returns the policy, soft-deleted or not, and locks it
+	// for the rest of the transaction.
+	GetPluginPolicyTx(ctx context.Context, dbTx Tx, id string) (types.PluginPolicy, error)
 	GetAllPluginPolicies(ctx context.Context, publicKey string, pluginType string) ([]types.PluginPolicy, error)
-	DeletePluginPolicyTx(ctx context.Context, dbTx pgx.Tx, id string) error
-	InsertPluginPolicyTx(ctx context.Context, dbTx pgx.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error)
-	UpdatePluginPolicyTx(ctx context.Context, dbTx pgx.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error)
+	GetPluginPoliciesByType(ctx context.Context, pluginType string) ([]types.PluginPolicy, error)
+	DeletePluginPolicyTx(ctx context.Context, dbTx Tx, id string, deletion types.PolicyDeleteRequest) error
+	InsertPluginPolicyTx(ctx context.Context, dbTx Tx, policy types.PluginPolicy) (*types.PluginPolicy, error)
+	UpdatePluginPolicyTx(ctx context.Context, dbTx Tx, policy types.PluginPolicy) (*types.PluginPolicy, error)
+	// CompletePluginPolicyTx marks an active policy as completed. The signed
+	// policy is left as it is.
+	CompletePluginPolicyTx(ctx context.Context, dbTx Tx, id string) error
+	ConsumePolicyNonceTx(ctx context.Context, dbTx Tx, publicKey, nonce string, expiresAt time.Time) (bool, error)
 
 	FindPricingById(ctx context.Context, id string) (*types.Pricing, error)
 	CreatePricing(ctx context.Context, pricingDto types.PricingCreateDto) (*types.Pricing, error)
 	DeletePricingById(ctx context.Context, id string) error
 
-	CreateTimeTriggerTx(ctx context.Context, dbTx pgx.Tx, trigger types.TimeTrigger) error
+	CreateTimeTriggerTx(ctx context.Context, dbTx Tx, trigger types.TimeTrigger) error
 	GetPendingTimeTriggers(ctx context.Context) ([]types.TimeTrigger, error)
 	UpdateTimeTriggerLastExecution(ctx context.Context, policyID string) error
-	UpdateTimeTriggerTx(ctx context.Context, policyID string, trigger types.TimeTrigger, dbTx pgx.Tx) error
+	UpdateTimeTriggerTx(ctx context.Context, policyID string, trigger types.TimeTrigger, dbTx Tx) error
 
 	DeleteTimeTrigger(ctx context.Context, policyID string) error
+	DeleteTimeTriggerTx(ctx context.Context, dbTx Tx, policyID string) error
 	UpdateTriggerStatus(ctx context.Context, policyID string, status types.TimeTriggerStatus) error
+	// ClaimTimeTrigger moves the pending time trigger of the policy to running
+	// and reports whether it did, so that two runs of a policy don't overlap.
+	ClaimTimeTrigger(ctx context.Context, policyID string) (bool, error)
 	GetTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error)
 
+	CreateEventTriggerTx(ctx context.Context, dbTx Tx, trigger types.EventTrigger) error
+	UpdateEventTriggerTx(ctx context.Context, policyID string, trigger types.EventTrigger, dbTx Tx) error
+	DeleteEventTrigger(ctx context.Context, policyID string) error
+	DeleteEventTriggerTx(ctx context.Context, dbTx Tx, policyID string) error
+	GetPendingEventTriggers(ctx context.Context) ([]types.EventTrigger, error)
+	GetEventTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error)
+	UpdateEventTriggerStatus(ctx context.Context, policyID string, status types.TimeTriggerStatus) error
+	ClaimEventTrigger(ctx context.Context, policyID string) (bool, error)
+	UpdateEventTriggerLastTriggered(ctx context.Context, policyID string) error
+	UpdateEventTriggerCheckpoint(ctx context.Context, policyID string, block uint64) error
+
 	CountTransactions(ctx context.Context, policyID uuid.UUID, status types.TransactionStatus, txType string) (int64, error)
-	CreateTransactionHistoryTx(ctx context.Context, dbTx pgx.Tx, tx types.TransactionHistory) (uuid.UUID, error)
-	UpdateTransactionStatusTx(ctx context.Context, dbTx pgx.Tx, txID uuid.UUID, status types.TransactionStatus, metadata map[string]interface{}) error
+	CreateTransactionHistoryTx(ctx context.Context, dbTx Tx, tx types.TransactionHistory) (uuid.UUID, error)
+	UpdateTransactionStatusTx(ctx context.Context, dbTx Tx, txID uuid.UUID, update types.TransactionUpdate) error
 	CreateTransactionHistory(ctx context.Context, tx types.TransactionHistory) (uuid.UUID, error)
-	UpdateTransactionStatus(ctx context.Context, txID uuid.UUID, status types.TransactionStatus, metadata map[string]interface{}) error
+	UpdateTransactionStatus(ctx context.Context, txID uuid.UUID, update types.TransactionUpdate) error
 	GetTransactionHistory(ctx context.Context, policyID uuid.UUID, transactionType string, take int, skip int) ([]types.TransactionHistory, error)
+	QueryTransactionHistory(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionHistory, error)
+	GetTransactionTotals(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionTotal, error)
 	GetTransactionByHash(ctx context.Context, txHash string) (*types.TransactionHistory, error)
+	GetTransactionStatusesByPluginType(ctx context.Context, pluginType string) ([]types.TransactionStatusEntry, error)
+	LockVaultTx(ctx context.Context, dbTx Tx, publicKey string) error
+	ReserveTransactionSigningTx(ctx context.Context, dbTx Tx, txID uuid.UUID, policyID uuid.UUID, txType string, since time.Time, limit int64) (bool, error)
+	ReleaseTransactionSigning(ctx context.Context, txID uuid.UUID) error
+	MarkTransactionSigned(ctx context.Context, txID uuid.UUID, txType string) error
+	CountSignedTransactions(ctx context.Context, policyID uuid.UUID, txType string, excludeTxHash string) (int64, error)
+	SetTransactionAttestation(ctx context.Context, txID uuid.UUID, attestation any) error
+	GetTransactionAttestation(ctx context.Context, txHash string) (json.RawMessage, error)
+	GetArchivableTransactionsTx(ctx context.Context, dbTx Tx, before time.Time, limit int) ([]types.ArchivedTransaction, error)
+	ArchiveTransactionsTx(ctx context.Context, dbTx Tx, archive types.TransactionArchive, txIDs []uuid.UUID, aggregates []types.TransactionAggregate) error
+	PurgeTransactionMetadataTx(ctx context.Context, dbTx Tx, before time.Time, keys []string, limit int) (int64, error)
+	GetTransactionAggregates(ctx context.Context, policyID uuid.UUID) ([]types.TransactionAggregate, error)
+
+	CreatePolicyRun(ctx context.Context, run types.PolicyRun) (uuid.UUID, error)
+	FinishPolicyRun(ctx context.Context, runID uuid.UUID, outcome types.PolicyRunOutcome, errorMessage *string, transactionIDs []uuid.UUID) error
+	GetPolicyRuns(ctx context.Context, policyID uuid.UUID, take int, skip int) ([]types.PolicyRun, error)
+
+	InsertSyncEventTx(ctx context.Context, dbTx Tx, event types.SyncEvent) error
+	GetDeliverableSyncEventsTx(ctx context.Context, dbTx Tx, aggregateID string, limit int) ([]types.SyncEvent, error)
+	CountPendingSyncEvents(ctx context.Context, aggregateID string) (int64, error)
+	LeaseSyncEventsTx(ctx context.Context, dbTx Tx, ids []int64, until time.Time) error
+	MarkSyncEventDeliveredTx(ctx context.Context, dbTx Tx, id int64) error
+	RescheduleSyncEventTx(ctx context.Context, dbTx Tx, id int64, lastError string, nextAttemptAt time.Time) error
+	FailSyncEventTx(ctx context.Context, dbTx Tx, id int64, lastError string) error
+
+	CreateWebhookSubscription(ctx context.Context, subscription types.WebhookSubscription) (*types.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context, publicKey string) ([]types.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, publicKey string, id string) error
+	InsertWebhookDeliveriesTx(ctx context.Context, dbTx Tx, event types.WebhookEvent, payload []byte) error
+	GetDueWebhookDeliveriesTx(ctx context.Context, dbTx Tx, limit int) ([]types.WebhookDelivery, error)
+	LeaseWebhookDeliveriesTx(ctx context.Context, dbTx Tx, ids []int64, until time.Time) error
+	MarkWebhookDeliveredTx(ctx context.Context, dbTx Tx, id int64) error
+	RescheduleWebhookDeliveryTx(ctx context.Context, dbTx Tx, id int64, lastError string, nextAttemptAt time.Time) error
+	DeadLetterWebhookDeliveryTx(ctx context.Context, dbTx Tx, id int64, lastError string) error
+	GetWebhookDeadLetters(ctx context.Context, publicKey string, take int, skip int) ([]types.WebhookDeadLetter, error)
+	ReplayWebhookDeadLetter(ctx context.Context, publicKey string, id int64) error
 
-	FindPlugins(ctx context.Context, take int, skip int, sort string) (types.PlugisDto, error)
+	FindPlugins(ctx context.Context, skip int, take int, sort string) (types.PlugisDto, error)
 	FindPluginById(ctx context.Context, id string) (*types.Plugin, error)
 	CreatePlugin(ctx context.Context, pluginDto types.PluginCreateDto) (*types.Plugin, error)
 	UpdatePlugin(ctx context.Context, id string, updates types.PluginUpdateDto) (*types.Plugin, error)
 	DeletePluginById(ctx context.Context, id string) error
 
-	Pool() *pgxpool.Pool
+	CreatePluginToken(ctx context.Context, token types.PluginToken) error
+	GetPluginToken(ctx context.Context, id string) (*types.PluginToken, error)
+	GetPluginTokens(ctx context.Context, pluginID string) ([]types.PluginToken, error)
+	RevokePluginTokens(ctx context.Context, pluginID string, id string) (int64, error)
+
+	CreateSpendingRule(ctx context.Context, dto types.SpendingRuleDto) (*types.SpendingRule, error)
+	UpdateSpendingRule(ctx context.Context, id string, dto types.SpendingRuleDto) (*types.SpendingRule, error)
+	DeleteSpendingRule(ctx context.Context, id string) error
+	GetSpendingRules(ctx context.Context, publicKey string) ([]types.SpendingRule, error)
+	CreateRuleDecision(ctx context.Context, decision types.RuleDecision) error
+	GetRuleDecisions(ctx context.Context, publicKey string, take int, skip int) ([]types.RuleDecision, error)
+	GetVaultSignedTransactionsTx(ctx context.Context, dbTx Tx, publicKey string, since time.Time, excludeTxHash string) ([]types.VaultTransaction, error)
+
+	CreateVaultBackupVersion(ctx context.Context, version types.VaultBackupVersion) (*types.VaultBackupVersion, error)
+	GetVaultBackupVersions(ctx context.Context, publicKey string) ([]types.VaultBackupVersion, error)
+	GetVaultBackupVersion(ctx context.Context, publicKey string, version int) (*types.VaultBackupVersion, error)
+	DeleteVaultBackupVersion(ctx context.Context, id uuid.UUID) error
+
+	// BeginTx starts a transaction for the ...Tx methods. Callers must Commit or
+	// Rollback it.
+	BeginTx(ctx context.Context) (Tx, error)
 }
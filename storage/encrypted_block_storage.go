@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+)
+
+// envelopeHeader starts the files written by EncryptedBlockStorage. Files
+// without it were stored before encryption was enabled.
+var envelopeHeader = []byte("vultisig-envelope-v1\n")
+
+type envelope struct {
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedBlockStorage encrypts files with a fresh data key from the KMS
+// before storing them, so that vault backups are never stored under the user
+// password alone. The file name is authenticated with the content, a file
+// can't be read back under another name.
+type EncryptedBlockStorage struct {
+	BlockStorage
+	kms KMS
+}
+
+func NewEncryptedBlockStorage(bs BlockStorage, kms KMS) *EncryptedBlockStorage {
+	return &EncryptedBlockStorage{BlockStorage: bs, kms: kms}
+}
+
+func (bs *EncryptedBlockStorage) UploadFile(fileContent []byte, fileName string) error {
+	sealed, err := bs.seal(fileContent, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	return bs.BlockStorage.UploadFile(sealed, fileName)
+}
+
+// GetFile decrypts the file, files stored before encryption was enabled are
+// returned as they are.
+func (bs *EncryptedBlockStorage) GetFile(fileName string) ([]byte, error) {
+	content, err := bs.BlockStorage.GetFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(content, envelopeHeader) {
+		return content, nil
+	}
+	plaintext, err := bs.open(content, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (bs *EncryptedBlockStorage) seal(plaintext []byte, fileName string) ([]byte, error) {
+	dataKey, wrappedKey, err := bs.kms.GenerateDataKey(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data, err := json.Marshal(envelope{
+		WrappedKey: wrappedKey,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(fileName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(envelopeHeader), data...), nil
+}
+
+func (bs *EncryptedBlockStorage) open(sealed []byte, fileName string) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(sealed[len(envelopeHeader):], &env); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	dataKey, err := bs.kms.Decrypt(context.Background(), env.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid envelope nonce")
+	}
+	return aead.Open(nil, env.Nonce, env.Ciphertext, []byte(fileName))
+}
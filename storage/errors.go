@@ -0,0 +1,12 @@
+package storage
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by lookups, updates and deletes of rows that
+	// don't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrTxRolledBack is returned by the commit of a transaction in which a
+	// statement failed. The transaction is rolled back instead.
+	ErrTxRolledBack = errors.New("transaction rolled back")
+)
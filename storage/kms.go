@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+
+	"github.com/vultisig/vultiserver-plugin/config"
+)
+
+// dataKeySize is the size of the AES-256 data keys files are encrypted with.
+const dataKeySize = 32
+
+// KMS issues data keys and keeps the key they are wrapped with.
+type KMS interface {
+	// GenerateDataKey returns a new data key and the same key wrapped for
+	// storing next to the data it encrypts.
+	GenerateDataKey(ctx context.Context) (plaintext []byte, wrapped []byte, err error)
+	// Decrypt unwraps a data key returned by GenerateDataKey.
+	Decrypt(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// NewKMS returns the KMS configured for block storage encryption.
+func NewKMS(cfg config.Config) (KMS, error) {
+	encryption := cfg.BlockStorage.Encryption
+	switch encryption.KMS {
+	case "", "local":
+		key, err := hex.DecodeString(encryption.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block storage encryption key: %w", err)
+		}
+		return NewLocalKMS(key)
+	case "aws":
+		return NewAWSKMS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown kms: %s", encryption.KMS)
+	}
+}
+
+// LocalKMS wraps data keys with a key encryption key from the configuration.
+type LocalKMS struct {
+	aead cipher.AEAD
+}
+
+func NewLocalKMS(key []byte) (*LocalKMS, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("key encryption key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKMS{aead: aead}, nil
+}
+
+func (k *LocalKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	plaintext := make([]byte, dataKeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return plaintext, k.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *LocalKMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+	nonce, ciphertext := wrapped[:k.aead.NonceSize()], wrapped[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// AWSKMS generates data keys with an AWS KMS key.
+type AWSKMS struct {
+	client *kms.KMS
+	keyID  string
+}
+
+func NewAWSKMS(cfg config.Config) (*AWSKMS, error) {
+	if cfg.BlockStorage.Encryption.KeyID == "" {
+		return nil, fmt.Errorf("kms key id is required")
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(cfg.BlockStorage.Region),
+		Credentials: credentials.NewStaticCredentials(cfg.BlockStorage.AccessKey, cfg.BlockStorage.SecretKey, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AWSKMS{
+		client: kms.New(sess),
+		keyID:  cfg.BlockStorage.Encryption.KeyID,
+	}, nil
+}
+
+func (k *AWSKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output, err := k.client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (k *AWSKMS) Decrypt(ctx context.Context, wrapped []byte) ([]byte, error) {
+	output, err := k.client.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return output.Plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
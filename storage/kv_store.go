@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrKeyNotFound is returned for keys that are missing or expired.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyValueStore is an expiring key-value store. It holds short-lived state:
+// sessions, verification codes, replay guards and idempotency records.
+type KeyValueStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, expiry time.Duration) error
+	// SetNX sets the key only if it doesn't exist, and reports whether it did.
+	SetNX(ctx context.Context, key string, value string, expiry time.Duration) (bool, error)
+	Expire(ctx context.Context, key string, expiry time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Keyspaces of the key-value store.
+const (
+	KeyspaceSession          = "session"
+	KeyspaceVerificationCode = "verification_code"
+	KeyspaceResend           = "resend"
+	KeyspacePolicyAction     = "policy_action"
+	KeyspacePluginRequest    = "plugin_request"
+	KeyspaceIdempotency      = "idempotency"
+)
+
+// Keyspace is a namespaced view of a KeyValueStore, its keys are prefixed with
+// the namespace so features can't overwrite each other's keys.
+type Keyspace struct {
+	store  KeyValueStore
+	prefix string
+}
+
+func NewKeyspace(store KeyValueStore, namespace string) Keyspace {
+	return Keyspace{
+		store:  store,
+		prefix: namespace + ":",
+	}
+}
+
+// Key returns the key in the underlying store.
+func (k Keyspace) Key(key string) string {
+	return k.prefix + key
+}
+
+func (k Keyspace) Get(ctx context.Context, key string) (string, error) {
+	return k.store.Get(ctx, k.Key(key))
+}
+
+func (k Keyspace) Set(ctx context.Context, key string, value string, expiry time.Duration) error {
+	return k.store.Set(ctx, k.Key(key), value, expiry)
+}
+
+func (k Keyspace) SetNX(ctx context.Context, key string, value string, expiry time.Duration) (bool, error) {
+	return k.store.SetNX(ctx, k.Key(key), value, expiry)
+}
+
+func (k Keyspace) Expire(ctx context.Context, key string, expiry time.Duration) error {
+	return k.store.Expire(ctx, k.Key(key), expiry)
+}
+
+func (k Keyspace) Delete(ctx context.Context, key string) error {
+	return k.store.Delete(ctx, k.Key(key))
+}
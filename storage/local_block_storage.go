@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalBlockStorage stores files in a directory, for development and single
+// node deployments.
+type LocalBlockStorage struct {
+	root string
+}
+
+func NewLocalBlockStorage(root string) (*LocalBlockStorage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("local block storage path is required")
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create block storage directory: %w", err)
+	}
+	return &LocalBlockStorage{root: root}, nil
+}
+
+// path keeps file names, which may contain slashes, inside the root.
+func (bs *LocalBlockStorage) path(fileName string) string {
+	return filepath.Join(bs.root, filepath.Clean("/"+fileName))
+}
+
+func (bs *LocalBlockStorage) FileExist(fileName string) (bool, error) {
+	_, err := os.Stat(bs.path(fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadFile writes the file to a temporary file first so that readers never
+// see it partially written.
+func (bs *LocalBlockStorage) UploadFile(fileContent []byte, fileName string) error {
+	path := bs.path(fileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(fileContent); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func (bs *LocalBlockStorage) GetFile(fileName string) ([]byte, error) {
+	return os.ReadFile(bs.path(fileName))
+}
+
+func (bs *LocalBlockStorage) DeleteFile(fileName string) error {
+	err := os.Remove(bs.path(fileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+type kvEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (e kvEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// KeyValueStore is an in-memory storage.KeyValueStore. Expired keys are
+// dropped when they are next accessed.
+type KeyValueStore struct {
+	mu      sync.Mutex
+	entries map[string]kvEntry
+	now     func() time.Time
+}
+
+var _ storage.KeyValueStore = (*KeyValueStore)(nil)
+
+func NewKeyValueStore() *KeyValueStore {
+	return &KeyValueStore{
+		entries: make(map[string]kvEntry),
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the clock expiries are checked against.
+func (s *KeyValueStore) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+func (s *KeyValueStore) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.lookup(key)
+	if !ok {
+		return "", storage.ErrKeyNotFound
+	}
+	return entry.value, nil
+}
+
+func (s *KeyValueStore) Set(ctx context.Context, key string, value string, expiry time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = kvEntry{value: value, expiresAt: s.expiresAt(expiry)}
+	return nil
+}
+
+func (s *KeyValueStore) SetNX(ctx context.Context, key string, value string, expiry time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(key); ok {
+		return false, nil
+	}
+	s.entries[key] = kvEntry{value: value, expiresAt: s.expiresAt(expiry)}
+	return true, nil
+}
+
+func (s *KeyValueStore) Expire(ctx context.Context, key string, expiry time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.lookup(key)
+	if !ok {
+		return nil
+	}
+	if expiry <= 0 {
+		delete(s.entries, key)
+		return nil
+	}
+	entry.expiresAt = s.expiresAt(expiry)
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *KeyValueStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *KeyValueStore) lookup(key string) (kvEntry, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return kvEntry{}, false
+	}
+	if entry.expired(s.now()) {
+		delete(s.entries, key)
+		return kvEntry{}, false
+	}
+	return entry, true
+}
+
+// expiresAt follows redis: keys set without an expiry are kept forever.
+func (s *KeyValueStore) expiresAt(expiry time.Duration) time.Time {
+	if expiry <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(expiry)
+}
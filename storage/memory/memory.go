@@ -0,0 +1,488 @@
+// Package memory implements storage.DatabaseStorage in memory, with the same
+// semantics as the postgres backend, so that services can be tested without a
+// database. KeyValueStore stands in for redis the same way.
+//
+// Transactions see a snapshot of the data taken by BeginTx plus their own
+// writes. Commit applies the writes to the current data atomically and fails,
+// leaving the data unchanged, if a concurrent commit made one of them produce
+// a different result. Advisory locks and the rows selected FOR UPDATE SKIP
+// LOCKED, such as pending outbox entries, are locked until the transaction
+// ends. Taking a lock refreshes the snapshot, like a postgres statement after
+// pg_advisory_xact_lock sees what the previous holder committed.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+var (
+	errTxClosed  = errors.New("transaction is closed")
+	errTxAborted = errors.New("current transaction is aborted, commands ignored until end of transaction block")
+	// errSerialization is returned by Commit when a concurrent commit changed
+	// the outcome of one of the transaction's writes.
+	errSerialization = errors.New("could not serialize access due to concurrent update")
+)
+
+type MemoryBackend struct {
+	mu    sync.RWMutex
+	state *state
+
+	seqMu     sync.Mutex
+	sequences map[string]int64
+
+	locksMu sync.Mutex
+	locks   map[string]chan struct{}
+}
+
+var _ storage.DatabaseStorage = (*MemoryBackend)(nil)
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		state:     newState(),
+		sequences: make(map[string]int64),
+		locks:     make(map[string]chan struct{}),
+	}
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
+
+func (b *MemoryBackend) BeginTx(ctx context.Context) (storage.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return &memoryTx{
+		backend: b,
+		state:   b.state.clone(),
+	}, nil
+}
+
+// nextID returns the next value of a table's serial column. Like postgres
+// sequences, values used by rolled back transactions are not reused.
+func (b *MemoryBackend) nextID(table string) int64 {
+	b.seqMu.Lock()
+	defer b.seqMu.Unlock()
+
+	b.sequences[table]++
+	return b.sequences[table]
+}
+
+// read runs fn against the committed data.
+func (b *MemoryBackend) read(fn func(s *state) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return fn(b.state)
+}
+
+// write applies op to the committed data. Ops check their constraints before
+// changing anything, so a failed op leaves the data unchanged.
+func (b *MemoryBackend) write(op func(s *state) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return op(b.state)
+}
+
+// readTx runs fn against the transaction's view of the data.
+func (b *MemoryBackend) readTx(dbTx storage.Tx, fn func(s *state) error) error {
+	tx, err := b.memoryTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		return err
+	}
+	if err := fn(tx.state); err != nil {
+		tx.aborted = true
+		return err
+	}
+	return nil
+}
+
+// writeTx applies op to the transaction's view of the data and records it to
+// be applied again on commit. Ops returning a result must fail with
+// errSerialization when applied again with a different result.
+func (b *MemoryBackend) writeTx(dbTx storage.Tx, op func(s *state) error) error {
+	tx, err := b.memoryTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		return err
+	}
+	if err := op(tx.state); err != nil {
+		tx.aborted = true
+		return err
+	}
+	tx.ops = append(tx.ops, op)
+	return nil
+}
+
+// advisoryLockTx takes the lock on key for the rest of the transaction,
+// waiting for the transaction holding it to end. The transaction's view is then
+// taken again from the committed data with its own writes applied on top.
+func (b *MemoryBackend) advisoryLockTx(ctx context.Context, dbTx storage.Tx, key string) error {
+	tx, err := b.memoryTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	held := tx.locks[key]
+	usable := tx.usable()
+	tx.mu.Unlock()
+	if usable != nil {
+		return usable
+	}
+	if held {
+		return nil
+	}
+
+	lock := b.lock(key)
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to acquire lock: %w", ctx.Err())
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		<-lock
+		return err
+	}
+	tx.hold(key, lock)
+	return b.refresh(tx)
+}
+
+// skipLockedTx locks rows like SELECT ... FOR UPDATE SKIP LOCKED. Of the keys
+// listed by candidates, in order, it locks up to limit that no other
+// transaction holds, skipping the others, and returns them. A negative limit
+// locks them all. The candidates are listed from the latest committed data and
+// the view is taken again once the rows are locked, so the caller reads them
+// as the previous holder left them and must check that they still match.
+func (b *MemoryBackend) skipLockedTx(dbTx storage.Tx, table string, candidates func(s *state) []string, limit int) ([]string, error) {
+	tx, err := b.memoryTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.usable(); err != nil {
+		return nil, err
+	}
+	if err := b.refresh(tx); err != nil {
+		return nil, err
+	}
+
+	var locked []string
+	for _, key := range candidates(tx.state) {
+		if limit >= 0 && len(locked) >= limit {
+			break
+		}
+		lockKey := "row:" + table + ":" + key
+		if tx.locks[lockKey] {
+			locked = append(locked, key)
+			continue
+		}
+		lock := b.lock(lockKey)
+		select {
+		case lock <- struct{}{}:
+			tx.hold(lockKey, lock)
+			locked = append(locked, key)
+		default:
+		}
+	}
+	if err := b.refresh(tx); err != nil {
+		return nil, err
+	}
+	return locked, nil
+}
+
+// lock returns the channel holding the lock on key.
+func (b *MemoryBackend) lock(key string) chan struct{} {
+	b.locksMu.Lock()
+	defer b.locksMu.Unlock()
+
+	lock, ok := b.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		b.locks[key] = lock
+	}
+	return lock
+}
+
+// refresh takes the view of tx again from the committed data with its own
+// writes applied on top. tx.mu must be held.
+func (b *MemoryBackend) refresh(tx *memoryTx) error {
+	b.mu.RLock()
+	next := b.state.clone()
+	b.mu.RUnlock()
+	for _, op := range tx.ops {
+		if err := op(next); err != nil {
+			tx.aborted = true
+			return err
+		}
+	}
+	tx.state = next
+	return nil
+}
+
+func (b *MemoryBackend) memoryTx(dbTx storage.Tx) (*memoryTx, error) {
+	tx, ok := dbTx.(*memoryTx)
+	if !ok || tx.backend != b {
+		return nil, fmt.Errorf("transaction of type %T was not started by this memory backend", dbTx)
+	}
+	return tx, nil
+}
+
+type memoryTx struct {
+	backend *MemoryBackend
+
+	mu      sync.Mutex
+	state   *state
+	ops     []func(s *state) error
+	done    bool
+	aborted bool
+	locks   map[string]bool
+	unlocks []func()
+}
+
+// hold records the lock on key, taken for the rest of the transaction.
+// tx.mu must be held.
+func (tx *memoryTx) hold(key string, lock chan struct{}) {
+	if tx.locks == nil {
+		tx.locks = make(map[string]bool)
+	}
+	tx.locks[key] = true
+	tx.unlocks = append(tx.unlocks, func() { <-lock })
+}
+
+// release gives up the transaction's locks.
+func (tx *memoryTx) release() {
+	for _, unlock := range tx.unlocks {
+		unlock()
+	}
+	tx.unlocks = nil
+}
+
+func (tx *memoryTx) usable() error {
+	if tx.done {
+		return errTxClosed
+	}
+	if tx.aborted {
+		return errTxAborted
+	}
+	return nil
+}
+
+func (tx *memoryTx) Commit(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxClosed
+	}
+	tx.done = true
+	defer tx.release()
+	if tx.aborted {
+		return storage.ErrTxRolledBack
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	b := tx.backend
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	next := b.state.clone()
+	for _, op := range tx.ops {
+		if err := op(next); err != nil {
+			return err
+		}
+	}
+	b.state = next
+	return nil
+}
+
+func (tx *memoryTx) Rollback(ctx context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return errTxClosed
+	}
+	tx.done = true
+	tx.release()
+	return nil
+}
+
+type nonceKey struct {
+	publicKey string
+	nonce     string
+}
+
+type eventTriggerRow struct {
+	id int64
+	types.EventTrigger
+}
+
+type transactionRow struct {
+	types.TransactionHistory
+	signedAt    *time.Time
+	signedType  string
+	attestation json.RawMessage
+}
+
+type webhookDeliveryRow struct {
+	types.WebhookDelivery
+	status        types.SyncEventStatus
+	nextAttemptAt time.Time
+	lastError     *string
+	createdAt     time.Time
+	deliveredAt   *time.Time
+}
+
+// state holds the rows of every table. Rows are stored by value and replaced
+// on update, so cloning the maps and slices snapshots the data.
+type state struct {
+	users                map[string]types.UserWithPassword
+	policies             map[string]types.PluginPolicy
+	policyNonces         map[nonceKey]time.Time
+	pricings             map[string]types.Pricing
+	timeTriggers         []types.TimeTrigger
+	eventTriggers        []eventTriggerRow
+	transactions         map[uuid.UUID]transactionRow
+	policyRuns           map[uuid.UUID]types.PolicyRun
+	syncEvents           []types.SyncEvent
+	webhookSubscriptions map[uuid.UUID]types.WebhookSubscription
+	webhookDeliveries    []webhookDeliveryRow
+	webhookDeadLetters   []types.WebhookDeadLetter
+	plugins              map[string]types.Plugin
+	pluginTokens         map[string]types.PluginToken
+	spendingRules        map[uuid.UUID]types.SpendingRule
+	ruleDecisions        []types.RuleDecision
+	vaultBackups         []types.VaultBackupVersion
+	transactionArchives  []types.TransactionArchive
+	aggregates           map[aggregateKey]types.TransactionAggregate
+	archivedAttestations []transactionRow
+}
+
+func newState() *state {
+	return &state{
+		users:                make(map[string]types.UserWithPassword),
+		policies:             make(map[string]types.PluginPolicy),
+		policyNonces:         make(map[nonceKey]time.Time),
+		pricings:             make(map[string]types.Pricing),
+		transactions:         make(map[uuid.UUID]transactionRow),
+		policyRuns:           make(map[uuid.UUID]types.PolicyRun),
+		webhookSubscriptions: make(map[uuid.UUID]types.WebhookSubscription),
+		plugins:              make(map[string]types.Plugin),
+		pluginTokens:         make(map[string]types.PluginToken),
+		spendingRules:        make(map[uuid.UUID]types.SpendingRule),
+		aggregates:           make(map[aggregateKey]types.TransactionAggregate),
+	}
+}
+
+func (s *state) clone() *state {
+	return &state{
+		users:                maps.Clone(s.users),
+		policies:             maps.Clone(s.policies),
+		policyNonces:         maps.Clone(s.policyNonces),
+		pricings:             maps.Clone(s.pricings),
+		timeTriggers:         slices.Clone(s.timeTriggers),
+		eventTriggers:        slices.Clone(s.eventTriggers),
+		transactions:         maps.Clone(s.transactions),
+		policyRuns:           maps.Clone(s.policyRuns),
+		syncEvents:           slices.Clone(s.syncEvents),
+		webhookSubscriptions: maps.Clone(s.webhookSubscriptions),
+		webhookDeliveries:    slices.Clone(s.webhookDeliveries),
+		webhookDeadLetters:   slices.Clone(s.webhookDeadLetters),
+		plugins:              maps.Clone(s.plugins),
+		pluginTokens:         maps.Clone(s.pluginTokens),
+		spendingRules:        maps.Clone(s.spendingRules),
+		ruleDecisions:        slices.Clone(s.ruleDecisions),
+		vaultBackups:         slices.Clone(s.vaultBackups),
+		transactionArchives:  slices.Clone(s.transactionArchives),
+		aggregates:           maps.Clone(s.aggregates),
+		archivedAttestations: slices.Clone(s.archivedAttestations),
+	}
+}
+
+// toJSONMap stores a metadata map the way a JSONB column does, so values read
+// back have JSON types.
+func toJSONMap(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode json: %w", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
+	}
+	return result, nil
+}
+
+func cloneRaw(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	return slices.Clone(raw)
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return slices.Clone(values)
+}
+
+// page applies LIMIT and OFFSET to sorted rows.
+func page[T any](rows []T, take int, skip int) []T {
+	if skip >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[max(skip, 0):]
+	if take >= 0 && take < len(rows) {
+		rows = rows[:take]
+	}
+	return rows
+}
+
+func notFound(message string) error {
+	return fmt.Errorf("%s: %w", message, storage.ErrNotFound)
+}
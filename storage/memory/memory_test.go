@@ -0,0 +1,562 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+	"github.com/vultisig/vultiserver-plugin/storage/memory"
+)
+
+func insertPolicy(t *testing.T, ctx context.Context, db *memory.MemoryBackend, policy types.PluginPolicy) {
+	t.Helper()
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestTransactionVisibility(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+
+	// uncommitted writes are only visible to the transaction
+	_, err = db.GetPluginPolicy(ctx, policy.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, dbTx.Rollback(ctx))
+	_, err = db.GetPluginPolicy(ctx, policy.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	insertPolicy(t, ctx, db, policy)
+	stored, err := db.GetPluginPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, policy.PublicKey, stored.PublicKey)
+
+	// a failed statement aborts the transaction
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.Error(t, err)
+	_, err = db.UpdatePluginPolicyTx(ctx, dbTx, policy)
+	require.Error(t, err)
+	assert.ErrorIs(t, dbTx.Commit(ctx), storage.ErrTxRolledBack)
+}
+
+func TestConcurrentNonceConsumption(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	expiresAt := time.Now().Add(time.Hour)
+
+	first, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	second, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+
+	consumed, err := db.ConsumePolicyNonceTx(ctx, first, "vault", "1", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	consumed, err = db.ConsumePolicyNonceTx(ctx, second, "vault", "1", expiresAt)
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	require.NoError(t, first.Commit(ctx))
+	// the second transaction consumed a nonce the first one committed
+	require.Error(t, second.Commit(ctx))
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	defer dbTx.Rollback(ctx)
+	consumed, err = db.ConsumePolicyNonceTx(ctx, dbTx, "vault", "1", expiresAt)
+	require.NoError(t, err)
+	assert.False(t, consumed)
+}
+
+func TestTransactionHistoryUpsert(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	tx := types.TransactionHistory{
+		PolicyID: uuid.MustParse(policy.ID),
+		TxBody:   "body",
+		TxHash:   "hash",
+		Status:   types.StatusSigned,
+		Metadata: map[string]interface{}{"plugin_id": "dca"},
+		TxType:   "SWAP",
+	}
+	blockNumber := uint64(42)
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	txID, err := db.CreateTransactionHistoryTx(ctx, dbTx, tx)
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateTransactionStatusTx(ctx, dbTx, txID, types.TransactionUpdate{
+		Status:        types.StatusBroadcast,
+		Metadata:      map[string]interface{}{"attempt": 1},
+		SigningTaskID: "task",
+	}))
+	require.NoError(t, db.UpdateTransactionStatusTx(ctx, dbTx, txID, types.TransactionUpdate{
+		Status:      types.StatusMined,
+		BlockNumber: &blockNumber,
+	}))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	// empty details of an update keep the stored ones
+	stored, err := db.GetTransactionByHash(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, types.StatusMined, stored.Status)
+	assert.Equal(t, map[string]interface{}{"plugin_id": "dca", "attempt": float64(1)}, stored.Metadata)
+	assert.Equal(t, "task", stored.SigningTaskID)
+	assert.Equal(t, &blockNumber, stored.BlockNumber)
+
+	// creating a transaction with the same hash resets it to pending
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	upsertedID, err := db.CreateTransactionHistoryTx(ctx, dbTx, tx)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+	assert.Equal(t, txID, upsertedID)
+
+	count, err := db.CountTransactions(ctx, uuid.MustParse(policy.ID), types.StatusPending, "SWAP")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSyncEventOrdering(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	for _, aggregateID := range []string{"a", "a", "b"} {
+		require.NoError(t, db.InsertSyncEventTx(ctx, dbTx, types.SyncEvent{AggregateID: aggregateID, EventType: types.SyncEventPolicyUpdate}))
+	}
+	require.NoError(t, dbTx.Commit(ctx))
+
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	events, err := db.GetDeliverableSyncEventsTx(ctx, dbTx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "a", events[0].AggregateID)
+	assert.Equal(t, "b", events[1].AggregateID)
+
+	// the second event of an aggregate waits for the first one
+	require.NoError(t, db.MarkSyncEventDeliveredTx(ctx, dbTx, events[0].ID))
+	next, err := db.GetDeliverableSyncEventsTx(ctx, dbTx, "a", 10)
+	require.NoError(t, err)
+	require.Len(t, next, 1)
+	assert.Greater(t, next[0].ID, events[0].ID)
+	require.NoError(t, dbTx.Commit(ctx))
+
+	pending, err := db.CountPendingSyncEvents(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+}
+
+func TestSyncEventSkipLocked(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	for _, aggregateID := range []string{"a", "b", "c"} {
+		require.NoError(t, db.InsertSyncEventTx(ctx, dbTx, types.SyncEvent{AggregateID: aggregateID, EventType: types.SyncEventPolicyUpdate}))
+	}
+	require.NoError(t, dbTx.Commit(ctx))
+
+	// concurrent relays are handed disjoint events
+	first, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	locked, err := db.GetDeliverableSyncEventsTx(ctx, first, "", 2)
+	require.NoError(t, err)
+	require.Len(t, locked, 2)
+
+	second, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	skipped, err := db.GetDeliverableSyncEventsTx(ctx, second, "", 10)
+	require.NoError(t, err)
+	require.Len(t, skipped, 1)
+	assert.Equal(t, "c", skipped[0].AggregateID)
+	require.NoError(t, second.Rollback(ctx))
+
+	// the events are handed out again once the first relay is done
+	require.NoError(t, first.Rollback(ctx))
+	third, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	events, err := db.GetDeliverableSyncEventsTx(ctx, third, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+	require.NoError(t, third.Rollback(ctx))
+}
+
+func TestTransactionHashes(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	tx := types.TransactionHistory{
+		PolicyID: uuid.MustParse(policy.ID),
+		TxHash:   "sighash",
+		Status:   types.StatusPending,
+		TxType:   "SWAP",
+	}
+	failedID, err := db.CreateTransactionHistory(ctx, tx)
+	require.NoError(t, err)
+
+	// an active signing hash can't be proposed twice
+	_, err = db.CreateTransactionHistory(ctx, tx)
+	require.Error(t, err)
+
+	// once failed, the signing hash can be proposed again
+	require.NoError(t, db.UpdateTransactionStatus(ctx, failedID, types.TransactionUpdate{Status: types.StatusSigningFailed}))
+	txID, err := db.CreateTransactionHistory(ctx, tx)
+	require.NoError(t, err)
+	assert.NotEqual(t, failedID, txID)
+
+	require.NoError(t, db.UpdateTransactionStatus(ctx, txID, types.TransactionUpdate{
+		Status:        types.StatusSigned,
+		BroadcastHash: "0xonchain",
+	}))
+	for _, hash := range []string{"sighash", "0xonchain"} {
+		stored, err := db.GetTransactionByHash(ctx, hash)
+		require.NoError(t, err)
+		assert.Equal(t, txID, stored.ID)
+		assert.Equal(t, "sighash", stored.TxHash)
+		assert.Equal(t, "0xonchain", stored.BroadcastHash)
+	}
+
+	// broadcast hashes are unique
+	err = db.UpdateTransactionStatus(ctx, failedID, types.TransactionUpdate{
+		Status:        types.StatusSigningFailed,
+		BroadcastHash: "0xonchain",
+	})
+	require.Error(t, err)
+
+	entries, err := db.GetTransactionStatusesByPluginType(ctx, "dca")
+	require.NoError(t, err)
+	assert.Equal(t, []types.TransactionStatusEntry{
+		{PolicyID: policy.ID, TxHash: "sighash", Status: types.StatusSigned},
+	}, entries)
+}
+
+func TestQueryTransactionHistory(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+	other := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "other", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, other)
+
+	transactions := []struct {
+		policy types.PluginPolicy
+		hash   string
+		status types.TransactionStatus
+		token  string
+		amount string
+	}{
+		{policy, "0x1", types.StatusMined, "0xtoken", "100"},
+		{policy, "0x2", types.StatusMined, "0xtoken", "50"},
+		{policy, "0x3", types.StatusSigningFailed, "0xtoken", "70"},
+		{policy, "0x4", types.StatusMined, "native", "5"},
+		{other, "0x5", types.StatusMined, "0xtoken", "1000"},
+	}
+	for _, tx := range transactions {
+		_, err := db.CreateTransactionHistory(ctx, types.TransactionHistory{
+			PolicyID: uuid.MustParse(tx.policy.ID),
+			TxHash:   tx.hash,
+			Status:   tx.status,
+			TxType:   "SWAP",
+			ChainID:  "1",
+			Token:    tx.token,
+			Amount:   tx.amount,
+			GasFee:   "10",
+		})
+		require.NoError(t, err)
+	}
+
+	// page through the vault's transactions
+	query := types.TransactionHistoryQuery{PublicKey: "vault", Token: "0xtoken", Limit: 2}
+	var hashes []string
+	for {
+		history, err := db.QueryTransactionHistory(ctx, query)
+		require.NoError(t, err)
+		for _, tx := range history {
+			hashes = append(hashes, tx.TxHash)
+		}
+		if len(history) < query.Limit {
+			break
+		}
+		last := history[len(history)-1]
+		cursor, err := types.ParseTransactionCursor(types.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String())
+		require.NoError(t, err)
+		query.Cursor = cursor
+	}
+	assert.ElementsMatch(t, []string{"0x1", "0x2", "0x3"}, hashes)
+
+	// failed transactions aren't counted in the totals
+	totals, err := db.GetTransactionTotals(ctx, types.TransactionHistoryQuery{PublicKey: "vault"})
+	require.NoError(t, err)
+	assert.Equal(t, []types.TransactionTotal{
+		{ChainID: "1", Token: "0xtoken", Count: 2, AmountSpent: "150", GasPaid: "20"},
+		{ChainID: "1", Token: "native", Count: 1, AmountSpent: "5", GasPaid: "10"},
+	}, totals)
+
+	history, err := db.QueryTransactionHistory(ctx, types.TransactionHistoryQuery{
+		PublicKey: "vault",
+		Statuses:  []types.TransactionStatus{types.StatusSigningFailed},
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "0x3", history[0].TxHash)
+}
+
+func TestSoftDeletePolicy(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.CreateTransactionHistoryTx(ctx, dbTx, types.TransactionHistory{
+		PolicyID: uuid.MustParse(policy.ID),
+		TxHash:   "hash",
+		Status:   types.StatusMined,
+		TxType:   "SWAP",
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTimeTriggerTx(ctx, dbTx, types.TimeTrigger{
+		PolicyID:       policy.ID,
+		CronExpression: "0 * * * *",
+		Status:         types.StatusTimeTriggerPending,
+	}))
+	require.NoError(t, dbTx.Commit(ctx))
+	_, err = db.CreatePolicyRun(ctx, types.PolicyRun{PolicyID: uuid.MustParse(policy.ID), TriggerSource: "schedule"})
+	require.NoError(t, err)
+
+	deletion := types.PolicyDeleteRequest{Signature: "0xsig", Nonce: "nonce", Expiry: 1}
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.DeletePluginPolicyTx(ctx, dbTx, policy.ID, deletion))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	// the policy is kept with its deletion signature
+	deleted, err := db.GetPluginPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted())
+	assert.False(t, deleted.Active)
+	assert.NotNil(t, deleted.DeletedAt)
+	assert.Equal(t, &deletion, deleted.Deletion)
+
+	policies, err := db.GetPluginPoliciesByType(ctx, "dca")
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+	triggers, err := db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, triggers)
+
+	// history and runs stay queryable
+	history, err := db.GetTransactionHistory(ctx, uuid.MustParse(policy.ID), "SWAP", 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+	runs, err := db.GetPolicyRuns(ctx, uuid.MustParse(policy.ID), 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	// deleted policies can't be updated or deleted again
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = db.UpdatePluginPolicyTx(ctx, dbTx, policy)
+	assert.Error(t, err)
+	require.NoError(t, dbTx.Rollback(ctx))
+
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	assert.ErrorIs(t, db.DeletePluginPolicyTx(ctx, dbTx, policy.ID, deletion), storage.ErrNotFound)
+	require.NoError(t, dbTx.Rollback(ctx))
+}
+
+func TestLockVault(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+
+	holder, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.LockVaultTx(ctx, holder, "vault"))
+	// the lock is re-entrant within its transaction
+	require.NoError(t, db.LockVaultTx(ctx, holder, "vault"))
+
+	other, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	defer other.Rollback(ctx)
+	require.NoError(t, db.LockVaultTx(ctx, other, "other-vault"))
+
+	waiter, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	defer waiter.Rollback(ctx)
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, db.LockVaultTx(timeout, waiter, "vault"))
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- db.LockVaultTx(ctx, waiter, "vault")
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock acquired while held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.NoError(t, holder.Commit(ctx))
+	select {
+	case err := <-locked:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("lock not released on commit")
+	}
+}
+
+func TestCompletePolicy(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Signature: "0xsig", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTimeTriggerTx(ctx, dbTx, types.TimeTrigger{
+		PolicyID:       policy.ID,
+		CronExpression: "0 * * * *",
+		Status:         types.StatusTimeTriggerPending,
+	}))
+	require.NoError(t, dbTx.Commit(ctx))
+	triggers, err := db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, triggers, 1)
+
+	dbTx, err = db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.CompletePluginPolicyTx(ctx, dbTx, policy.ID))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	// the signed policy is unchanged, its triggers no longer run
+	completed, err := db.GetPluginPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.PolicyStatusCompleted, completed.Status)
+	assert.True(t, completed.Active)
+	assert.Equal(t, policy.Signature, completed.Signature)
+	assert.False(t, completed.Runnable())
+	triggers, err = db.GetPendingTimeTriggers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, triggers)
+
+	// updates keep it completed, unless they start it again
+	update := func(policy types.PluginPolicy) types.PluginPolicy {
+		t.Helper()
+		dbTx, err := db.BeginTx(ctx)
+		require.NoError(t, err)
+		updated, err := db.UpdatePluginPolicyTx(ctx, dbTx, policy)
+		require.NoError(t, err)
+		require.NoError(t, dbTx.Commit(ctx))
+		return *updated
+	}
+	completed.Status = ""
+	completed.PolicyVersion = "2"
+	updated := update(completed)
+	assert.Equal(t, types.PolicyStatusCompleted, updated.Status)
+	assert.Equal(t, "2", updated.PolicyVersion)
+
+	completed.Status = types.PolicyStatusActive
+	assert.True(t, update(completed).Runnable())
+}
+
+func TestUpdatePolicyKeepsOwner(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginID: "plugin", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	for _, moved := range []types.PluginPolicy{
+		{ID: policy.ID, PublicKey: "other", PluginID: "plugin", PluginType: "dca"},
+		{ID: policy.ID, PublicKey: "vault", PluginID: "other", PluginType: "dca"},
+	} {
+		dbTx, err := db.BeginTx(ctx)
+		require.NoError(t, err)
+		_, err = db.UpdatePluginPolicyTx(ctx, dbTx, moved)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		require.NoError(t, dbTx.Rollback(ctx))
+	}
+
+	stored, err := db.GetPluginPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "vault", stored.PublicKey)
+	assert.Equal(t, "plugin", stored.PluginID)
+}
+
+func TestClaimTrigger(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	policy := types.PluginPolicy{ID: uuid.NewString(), PublicKey: "vault", PluginType: "dca", Active: true}
+	insertPolicy(t, ctx, db, policy)
+
+	dbTx, err := db.BeginTx(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTimeTriggerTx(ctx, dbTx, types.TimeTrigger{
+		PolicyID:       policy.ID,
+		CronExpression: "0 * * * *",
+		Status:         types.StatusTimeTriggerPending,
+	}))
+	require.NoError(t, dbTx.Commit(ctx))
+
+	// only one of two runs claims the trigger
+	claimed, err := db.ClaimTimeTrigger(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = db.ClaimTimeTrigger(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	require.NoError(t, db.UpdateTriggerStatus(ctx, policy.ID, types.StatusTimeTriggerPending))
+	claimed, err = db.ClaimTimeTrigger(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// the policy has no event trigger to claim
+	claimed, err = db.ClaimEventTrigger(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestUserRole(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryBackend()
+	require.NoError(t, db.AddUser(types.UserWithPassword{ID: "user", Username: "user", Password: "hash"}))
+	require.NoError(t, db.AddUser(types.UserWithPassword{ID: "admin", Username: "admin", Password: "hash", Role: types.UserRoleAdmin}))
+
+	// users are not admins unless granted the role
+	user, err := db.FindUserById(ctx, "user")
+	require.NoError(t, err)
+	assert.False(t, user.IsAdmin())
+	admin, err := db.FindUserById(ctx, "admin")
+	require.NoError(t, err)
+	assert.True(t, admin.IsAdmin())
+}
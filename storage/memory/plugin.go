@@ -0,0 +1,366 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/common"
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// AddUser stores a user, as the users table is seeded outside the service.
+// The password must already be hashed.
+func (b *MemoryBackend) AddUser(user types.UserWithPassword) error {
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+	}
+	if user.Role == "" {
+		user.Role = types.UserRoleUser
+	}
+
+	return b.write(func(s *state) error {
+		for _, existing := range s.users {
+			if existing.Username == user.Username {
+				return fmt.Errorf("user %s already exists", user.Username)
+			}
+		}
+		s.users[user.ID] = user
+		return nil
+	})
+}
+
+func (b *MemoryBackend) FindUserById(ctx context.Context, userId string) (*types.User, error) {
+	var user types.User
+	err := b.read(func(s *state) error {
+		stored, ok := s.users[userId]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		user = types.User{ID: stored.ID, Username: stored.Username, Role: stored.Role, CreatedAt: stored.CreatedAt}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (b *MemoryBackend) FindUserByName(ctx context.Context, username string) (*types.UserWithPassword, error) {
+	var user types.UserWithPassword
+	err := b.read(func(s *state) error {
+		for _, stored := range s.users {
+			if stored.Username == username {
+				user = stored
+				return nil
+			}
+		}
+		return storage.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (b *MemoryBackend) FindPricingById(ctx context.Context, id string) (*types.Pricing, error) {
+	var pricing types.Pricing
+	err := b.read(func(s *state) error {
+		stored, ok := s.pricings[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		pricing = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pricing, nil
+}
+
+func (b *MemoryBackend) CreatePricing(ctx context.Context, pricingDto types.PricingCreateDto) (*types.Pricing, error) {
+	pricing := types.Pricing{
+		ID:     uuid.NewString(),
+		Type:   pricingDto.Type,
+		Amount: pricingDto.Amount,
+		Metric: pricingDto.Metric,
+	}
+	if pricingDto.Frequency != "" {
+		frequency := pricingDto.Frequency
+		pricing.Frequency = &frequency
+	}
+
+	err := b.write(func(s *state) error {
+		s.pricings[pricing.ID] = pricing
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pricing, nil
+}
+
+// DeletePricingById fails while a plugin uses the pricing, as plugins can't
+// be left without one.
+func (b *MemoryBackend) DeletePricingById(ctx context.Context, id string) error {
+	return b.write(func(s *state) error {
+		for _, plugin := range s.plugins {
+			if plugin.PricingID == id {
+				return fmt.Errorf("pricing %s is used by plugin %s", id, plugin.ID)
+			}
+		}
+		delete(s.pricings, id)
+		return nil
+	})
+}
+
+func (b *MemoryBackend) FindPluginById(ctx context.Context, id string) (*types.Plugin, error) {
+	var plugin types.Plugin
+	err := b.read(func(s *state) error {
+		stored, ok := s.plugins[id]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		plugin = clonePlugin(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin, nil
+}
+
+func (b *MemoryBackend) FindPlugins(ctx context.Context, skip int, take int, sort string) (types.PlugisDto, error) {
+	orderBy, orderDirection := common.GetSortingCondition(sort)
+
+	var plugins []types.Plugin
+	err := b.read(func(s *state) error {
+		for _, plugin := range s.plugins {
+			plugins = append(plugins, clonePlugin(plugin))
+		}
+		return nil
+	})
+	if err != nil {
+		return types.PlugisDto{}, err
+	}
+
+	slices.SortFunc(plugins, func(a, b types.Plugin) int {
+		var order int
+		switch orderBy {
+		case "title":
+			order = strings.Compare(a.Title, b.Title)
+		case "updated_at":
+			order = a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			order = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if orderDirection == "DESC" {
+			order = -order
+		}
+		return cmp.Or(order, strings.Compare(a.ID, b.ID))
+	})
+
+	total := len(plugins)
+	plugins = page(plugins, take, skip)
+	if len(plugins) == 0 {
+		return types.PlugisDto{}, nil
+	}
+
+	return types.PlugisDto{
+		Plugins:    plugins,
+		TotalCount: total,
+	}, nil
+}
+
+func (b *MemoryBackend) CreatePlugin(ctx context.Context, pluginDto types.PluginCreateDto) (*types.Plugin, error) {
+	now := time.Now().UTC()
+	plugin := types.Plugin{
+		ID:             uuid.NewString(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Type:           pluginDto.Type,
+		Title:          pluginDto.Title,
+		Description:    pluginDto.Description,
+		Metadata:       cloneRaw(pluginDto.Metadata),
+		ServerEndpoint: pluginDto.ServerEndpoint,
+		PricingID:      pluginDto.PricingID,
+		PublicKey:      pluginDto.PublicKey,
+	}
+
+	err := b.write(func(s *state) error {
+		if _, ok := s.pricings[plugin.PricingID]; !ok {
+			return fmt.Errorf("plugin references unknown pricing %s", plugin.PricingID)
+		}
+		for _, existing := range s.plugins {
+			if existing.Type == plugin.Type {
+				return fmt.Errorf("plugin of type %s already exists", plugin.Type)
+			}
+		}
+		s.plugins[plugin.ID] = plugin
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.FindPluginById(ctx, plugin.ID)
+}
+
+func (b *MemoryBackend) UpdatePlugin(ctx context.Context, id string, updates types.PluginUpdateDto) (*types.Plugin, error) {
+	if updates == (types.PluginUpdateDto{}) {
+		return nil, errors.New("No updates provided")
+	}
+	now := time.Now().UTC()
+
+	err := b.write(func(s *state) error {
+		plugin, ok := s.plugins[id]
+		if !ok {
+			return nil
+		}
+		if updates.PricingID != nil {
+			if _, ok := s.pricings[*updates.PricingID]; !ok {
+				return fmt.Errorf("plugin references unknown pricing %s", *updates.PricingID)
+			}
+			plugin.PricingID = *updates.PricingID
+		}
+		if updates.Title != nil {
+			plugin.Title = *updates.Title
+		}
+		if updates.Description != nil {
+			plugin.Description = *updates.Description
+		}
+		if updates.Metadata != nil {
+			plugin.Metadata = cloneRaw(*updates.Metadata)
+		}
+		if updates.ServerEndpoint != nil {
+			plugin.ServerEndpoint = *updates.ServerEndpoint
+		}
+		if updates.PublicKey != nil {
+			plugin.PublicKey = *updates.PublicKey
+		}
+		plugin.UpdatedAt = now
+		s.plugins[id] = plugin
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.FindPluginById(ctx, id)
+}
+
+// DeletePluginById deletes the plugin with its tokens.
+func (b *MemoryBackend) DeletePluginById(ctx context.Context, id string) error {
+	return b.write(func(s *state) error {
+		for tokenID, token := range s.pluginTokens {
+			if token.PluginID == id {
+				delete(s.pluginTokens, tokenID)
+			}
+		}
+		delete(s.plugins, id)
+		return nil
+	})
+}
+
+func (b *MemoryBackend) CreatePluginToken(ctx context.Context, token types.PluginToken) error {
+	token.Scopes = nonNilStrings(token.Scopes)
+
+	return b.write(func(s *state) error {
+		if _, ok := s.plugins[token.PluginID]; !ok {
+			return fmt.Errorf("failed to create plugin token: unknown plugin %s", token.PluginID)
+		}
+		if _, ok := s.pluginTokens[token.ID]; ok {
+			return fmt.Errorf("failed to create plugin token: duplicate id %s", token.ID)
+		}
+		s.pluginTokens[token.ID] = token
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetPluginToken(ctx context.Context, id string) (*types.PluginToken, error) {
+	var token types.PluginToken
+	err := b.read(func(s *state) error {
+		stored, ok := s.pluginTokens[id]
+		if !ok {
+			return notFound("failed to get plugin token")
+		}
+		token = clonePluginToken(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (b *MemoryBackend) GetPluginTokens(ctx context.Context, pluginID string) ([]types.PluginToken, error) {
+	now := time.Now()
+
+	var tokens []types.PluginToken
+	err := b.read(func(s *state) error {
+		for _, token := range s.pluginTokens {
+			if token.PluginID == pluginID && token.ExpiresAt.After(now) {
+				tokens = append(tokens, clonePluginToken(token))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(tokens, func(a, b types.PluginToken) int {
+		return b.IssuedAt.Compare(a.IssuedAt)
+	})
+	return tokens, err
+}
+
+// RevokePluginTokens revokes a single token of the plugin, or all of its
+// tokens when id is empty.
+func (b *MemoryBackend) RevokePluginTokens(ctx context.Context, pluginID string, id string) (int64, error) {
+	now := time.Now().UTC()
+
+	var revoked int64
+	err := b.write(func(s *state) error {
+		for tokenID, token := range s.pluginTokens {
+			if token.PluginID != pluginID || (id != "" && tokenID != id) || token.RevokedAt != nil {
+				continue
+			}
+			token.RevokedAt = &now
+			s.pluginTokens[tokenID] = token
+			revoked++
+		}
+		if id != "" && revoked == 0 {
+			return notFound("failed to revoke plugin token")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+func clonePlugin(plugin types.Plugin) types.Plugin {
+	plugin.Metadata = cloneRaw(plugin.Metadata)
+	return plugin
+}
+
+func clonePluginToken(token types.PluginToken) types.PluginToken {
+	token.Scopes = slices.Clone(token.Scopes)
+	return token
+}
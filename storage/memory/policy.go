@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// GetPluginPolicy returns the policy, soft-deleted or not.
+func (b *MemoryBackend) GetPluginPolicy(ctx context.Context, id string) (types.PluginPolicy, error) {
+	var policy types.PluginPolicy
+	err := b.read(func(s *state) error {
+		stored, ok := s.policies[id]
+		if !ok {
+			return notFound("failed to get policy")
+		}
+		policy = clonePolicy(stored)
+		return nil
+	})
+	return policy, err
+}
+
+// GetPluginPolicyTx returns the policy, soft-deleted or not. The policy's lock
+// is held for the rest of the transaction, as a row lock would be.
+func (b *MemoryBackend) GetPluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string) (types.PluginPolicy, error) {
+	if err := b.advisoryLockTx(ctx, dbTx, "policy:"+id); err != nil {
+		return types.PluginPolicy{}, err
+	}
+
+	var policy types.PluginPolicy
+	found := false
+	err := b.readTx(dbTx, func(s *state) error {
+		stored, ok := s.policies[id]
+		if ok {
+			policy = clonePolicy(stored)
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return types.PluginPolicy{}, err
+	}
+	if !found {
+		return types.PluginPolicy{}, notFound("failed to get policy")
+	}
+	return policy, nil
+}
+
+func (b *MemoryBackend) GetAllPluginPolicies(ctx context.Context, publicKey string, pluginType string) ([]types.PluginPolicy, error) {
+	var policies []types.PluginPolicy
+	err := b.read(func(s *state) error {
+		policies = s.findPolicies(func(policy types.PluginPolicy) bool {
+			return policy.PublicKey == publicKey && policy.PluginType == pluginType && !policy.IsDeleted()
+		})
+		return nil
+	})
+	return policies, err
+}
+
+func (b *MemoryBackend) GetPluginPoliciesByType(ctx context.Context, pluginType string) ([]types.PluginPolicy, error) {
+	var policies []types.PluginPolicy
+	err := b.read(func(s *state) error {
+		policies = s.findPolicies(func(policy types.PluginPolicy) bool {
+			return policy.PluginType == pluginType && !policy.IsDeleted()
+		})
+		return nil
+	})
+	return policies, err
+}
+
+func (b *MemoryBackend) InsertPluginPolicyTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	if _, err := uuid.Parse(policy.ID); err != nil {
+		return nil, fmt.Errorf("failed to insert policy: invalid id %q: %w", policy.ID, err)
+	}
+	policy = clonePolicy(policy)
+	policy.Status = types.PolicyStatusActive
+	policy.DeletedAt = nil
+	policy.Deletion = nil
+
+	err := b.writeTx(dbTx, func(s *state) error {
+		if _, ok := s.policies[policy.ID]; ok {
+			return fmt.Errorf("failed to insert policy: duplicate id %s", policy.ID)
+		}
+		s.policies[policy.ID] = policy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := clonePolicy(policy)
+	return &inserted, nil
+}
+
+// UpdatePluginPolicyTx updates the signed fields of the policy. Policies of
+// another vault or plugin are not found, nor are deleted policies. A completed
+// policy stays completed unless the update sets its status to active.
+func (b *MemoryBackend) UpdatePluginPolicyTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	policy = clonePolicy(policy)
+
+	var updated types.PluginPolicy
+	err := b.writeTx(dbTx, func(s *state) error {
+		stored, ok := s.policies[policy.ID]
+		if !ok || stored.IsDeleted() || stored.PublicKey != policy.PublicKey || stored.PluginID != policy.PluginID {
+			return notFound(fmt.Sprintf("policy not found with ID: %s", policy.ID))
+		}
+		stored.PluginType = policy.PluginType
+		stored.ChainCodeHex = policy.ChainCodeHex
+		stored.DerivePath = policy.DerivePath
+		stored.PluginVersion = policy.PluginVersion
+		stored.PolicyVersion = policy.PolicyVersion
+		stored.Signature = policy.Signature
+		stored.Nonce = policy.Nonce
+		stored.Expiry = policy.Expiry
+		stored.Active = policy.Active
+		stored.Policy = policy.Policy
+		if policy.Status == types.PolicyStatusActive {
+			stored.Status = types.PolicyStatusActive
+		}
+		s.policies[policy.ID] = stored
+		updated = clonePolicy(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// CompletePluginPolicyTx marks an active policy as completed. Completed and
+// deleted policies are left as they are.
+func (b *MemoryBackend) CompletePluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		stored, ok := s.policies[id]
+		if !ok {
+			return notFound("failed to complete policy")
+		}
+		if stored.Status == types.PolicyStatusActive {
+			stored.Status = types.PolicyStatusCompleted
+			s.policies[id] = stored
+		}
+		return nil
+	})
+}
+
+// DeletePluginPolicyTx soft-deletes the policy and records the vault's deletion
+// signature. Its triggers are removed, its transactions and runs are kept.
+func (b *MemoryBackend) DeletePluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string, deletion types.PolicyDeleteRequest) error {
+	deletedAt := time.Now()
+	return b.writeTx(dbTx, func(s *state) error {
+		stored, ok := s.policies[id]
+		if !ok || stored.IsDeleted() {
+			return notFound("failed to delete policy")
+		}
+		stored.Status = types.PolicyStatusDeleted
+		stored.Active = false
+		stored.DeletedAt = &deletedAt
+		stored.Deletion = &deletion
+		s.policies[id] = stored
+		s.timeTriggers = slices.DeleteFunc(s.timeTriggers, func(t types.TimeTrigger) bool { return t.PolicyID == id })
+		s.eventTriggers = slices.DeleteFunc(s.eventTriggers, func(t eventTriggerRow) bool { return t.PolicyID == id })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) ConsumePolicyNonceTx(ctx context.Context, dbTx storage.Tx, publicKey, nonce string, expiresAt time.Time) (bool, error) {
+	key := nonceKey{publicKey: publicKey, nonce: nonce}
+
+	consumed := false
+	applied := false
+	err := b.writeTx(dbTx, func(s *state) error {
+		_, used := s.policyNonces[key]
+		if applied && used == consumed {
+			return errSerialization
+		}
+		applied = true
+		if used {
+			return nil
+		}
+		s.policyNonces[key] = expiresAt
+		consumed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return consumed, nil
+}
+
+func (s *state) findPolicies(match func(types.PluginPolicy) bool) []types.PluginPolicy {
+	var policies []types.PluginPolicy
+	for _, policy := range s.policies {
+		if match(policy) {
+			policies = append(policies, clonePolicy(policy))
+		}
+	}
+	slices.SortFunc(policies, func(a, b types.PluginPolicy) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return policies
+}
+
+func clonePolicy(policy types.PluginPolicy) types.PluginPolicy {
+	policy.Policy = cloneRaw(policy.Policy)
+	policy.DeletedAt = clonePtr(policy.DeletedAt)
+	policy.Deletion = clonePtr(policy.Deletion)
+	return policy
+}
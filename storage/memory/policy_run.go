@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func (b *MemoryBackend) CreatePolicyRun(ctx context.Context, run types.PolicyRun) (uuid.UUID, error) {
+	stored := types.PolicyRun{
+		ID:             uuid.New(),
+		PolicyID:       run.PolicyID,
+		TriggerSource:  run.TriggerSource,
+		TriggeredAt:    run.TriggeredAt,
+		StartedAt:      run.StartedAt,
+		Outcome:        run.Outcome,
+		TransactionIDs: []uuid.UUID{},
+	}
+
+	err := b.write(func(s *state) error {
+		if _, ok := s.policies[run.PolicyID.String()]; !ok {
+			return fmt.Errorf("failed to create policy run: unknown policy %s", run.PolicyID)
+		}
+		s.policyRuns[stored.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return stored.ID, nil
+}
+
+func (b *MemoryBackend) FinishPolicyRun(ctx context.Context, runID uuid.UUID, outcome types.PolicyRunOutcome, errorMessage *string, transactionIDs []uuid.UUID) error {
+	finishedAt := time.Now().UTC()
+	if transactionIDs == nil {
+		transactionIDs = []uuid.UUID{}
+	}
+	transactionIDs = slices.Clone(transactionIDs)
+
+	return b.write(func(s *state) error {
+		run, ok := s.policyRuns[runID]
+		if !ok {
+			return nil
+		}
+		run.FinishedAt = &finishedAt
+		run.Outcome = outcome
+		run.ErrorMessage = errorMessage
+		run.TransactionIDs = transactionIDs
+		s.policyRuns[runID] = run
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetPolicyRuns(ctx context.Context, policyID uuid.UUID, take int, skip int) ([]types.PolicyRun, error) {
+	runs := []types.PolicyRun{}
+	err := b.read(func(s *state) error {
+		for _, run := range s.policyRuns {
+			if run.PolicyID != policyID {
+				continue
+			}
+			if run.FinishedAt != nil {
+				duration := run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+				run.DurationMs = &duration
+			}
+			run.TransactionIDs = slices.Clone(run.TransactionIDs)
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	slices.SortFunc(runs, func(a, b types.PolicyRun) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return page(runs, take, skip), err
+}
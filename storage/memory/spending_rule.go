@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (b *MemoryBackend) CreateSpendingRule(ctx context.Context, dto types.SpendingRuleDto) (*types.SpendingRule, error) {
+	now := time.Now().UTC()
+	rule := spendingRuleFromDto(dto)
+	rule.ID = uuid.New()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	err := b.write(func(s *state) error {
+		s.spendingRules[rule.ID] = rule
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created := cloneSpendingRule(rule)
+	return &created, nil
+}
+
+func (b *MemoryBackend) UpdateSpendingRule(ctx context.Context, id string, dto types.SpendingRuleDto) (*types.SpendingRule, error) {
+	ruleID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update spending rule: %w", err)
+	}
+	now := time.Now().UTC()
+
+	var updated types.SpendingRule
+	err = b.write(func(s *state) error {
+		stored, ok := s.spendingRules[ruleID]
+		if !ok {
+			return notFound("failed to update spending rule")
+		}
+		rule := spendingRuleFromDto(dto)
+		rule.ID = stored.ID
+		rule.CreatedAt = stored.CreatedAt
+		rule.UpdatedAt = now
+		s.spendingRules[ruleID] = rule
+		updated = cloneSpendingRule(rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (b *MemoryBackend) DeleteSpendingRule(ctx context.Context, id string) error {
+	ruleID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete spending rule: %w", err)
+	}
+
+	return b.write(func(s *state) error {
+		if _, ok := s.spendingRules[ruleID]; !ok {
+			return notFound("failed to delete spending rule")
+		}
+		delete(s.spendingRules, ruleID)
+		return nil
+	})
+}
+
+// GetSpendingRules returns the rules of a vault, including the ones that apply
+// to every vault, or all rules when publicKey is empty.
+func (b *MemoryBackend) GetSpendingRules(ctx context.Context, publicKey string) ([]types.SpendingRule, error) {
+	rules := []types.SpendingRule{}
+	err := b.read(func(s *state) error {
+		for _, rule := range s.spendingRules {
+			if publicKey == "" || rule.PublicKey == "" || rule.PublicKey == publicKey {
+				rules = append(rules, cloneSpendingRule(rule))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(rules, func(a, b types.SpendingRule) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return rules, err
+}
+
+func (b *MemoryBackend) CreateRuleDecision(ctx context.Context, decision types.RuleDecision) error {
+	decision.ID = uuid.New()
+	decision.Reasons = nonNilStrings(decision.Reasons)
+	decision.RuleIDs = nonNilStrings(decision.RuleIDs)
+	decision.CreatedAt = time.Now().UTC()
+
+	return b.write(func(s *state) error {
+		s.ruleDecisions = append(s.ruleDecisions, decision)
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetRuleDecisions(ctx context.Context, publicKey string, take int, skip int) ([]types.RuleDecision, error) {
+	decisions := []types.RuleDecision{}
+	err := b.read(func(s *state) error {
+		for _, decision := range s.ruleDecisions {
+			if publicKey == "" || decision.PublicKey == publicKey {
+				decision.Reasons = slices.Clone(decision.Reasons)
+				decision.RuleIDs = slices.Clone(decision.RuleIDs)
+				decisions = append(decisions, decision)
+			}
+		}
+		return nil
+	})
+	slices.SortStableFunc(decisions, func(a, b types.RuleDecision) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return page(decisions, take, skip), err
+}
+
+// GetVaultSignedTransactionsTx returns the transactions the verifier signed
+// for any policy of the vault since the given time, leaving out the one with
+// the given hash.
+func (b *MemoryBackend) GetVaultSignedTransactionsTx(ctx context.Context, dbTx storage.Tx, publicKey string, since time.Time, excludeTxHash string) ([]types.VaultTransaction, error) {
+	var transactions []types.VaultTransaction
+	err := b.readTx(dbTx, func(s *state) error {
+		for _, row := range s.transactions {
+			policy, ok := s.policies[row.PolicyID.String()]
+			if !ok || policy.PublicKey != publicKey {
+				continue
+			}
+			if row.signedAt == nil || row.signedAt.Before(since) || row.TxHash == excludeTxHash {
+				continue
+			}
+			transactions = append(transactions, types.VaultTransaction{
+				TxHash:     row.TxHash,
+				TxBody:     row.TxBody,
+				PluginType: policy.PluginType,
+				SignedAt:   *row.signedAt,
+			})
+		}
+		return nil
+	})
+	return transactions, err
+}
+
+func spendingRuleFromDto(dto types.SpendingRuleDto) types.SpendingRule {
+	return types.SpendingRule{
+		PublicKey:                   dto.PublicKey,
+		PluginType:                  dto.PluginType,
+		ChainID:                     dto.ChainID,
+		Token:                       dto.Token,
+		MaxAmountPerTx:              dto.MaxAmountPerTx,
+		MaxAmountPerDay:             dto.MaxAmountPerDay,
+		MaxAmountPerRecipientPerDay: dto.MaxAmountPerRecipientPerDay,
+		MaxTransactionsPerDay:       dto.MaxTransactionsPerDay,
+		MaxAllowance:                dto.MaxAllowance,
+		AllowedTokens:               nonNilStrings(dto.AllowedTokens),
+		AllowedContracts:            nonNilStrings(dto.AllowedContracts),
+		Active:                      dto.Active,
+	}
+}
+
+func cloneSpendingRule(rule types.SpendingRule) types.SpendingRule {
+	rule.AllowedTokens = slices.Clone(rule.AllowedTokens)
+	rule.AllowedContracts = slices.Clone(rule.AllowedContracts)
+	return rule
+}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (b *MemoryBackend) InsertSyncEventTx(ctx context.Context, dbTx storage.Tx, event types.SyncEvent) error {
+	stored := types.SyncEvent{
+		ID:             b.nextID("sync_outbox"),
+		IdempotencyKey: uuid.New(),
+		AggregateID:    event.AggregateID,
+		EventType:      event.EventType,
+		Payload:        cloneRaw(event.Payload),
+		Status:         types.SyncEventStatusPending,
+		NextAttemptAt:  time.Now().UTC(),
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	return b.writeTx(dbTx, func(s *state) error {
+		s.syncEvents = append(s.syncEvents, stored)
+		return nil
+	})
+}
+
+// GetDeliverableSyncEventsTx locks the pending events that are due and have
+// no earlier pending event for the same aggregate. An empty aggregateID
+// selects events of all aggregates. Events locked by another relay are
+// skipped.
+func (b *MemoryBackend) GetDeliverableSyncEventsTx(ctx context.Context, dbTx storage.Tx, aggregateID string, limit int) ([]types.SyncEvent, error) {
+	now := time.Now().UTC()
+	deliverable := func(s *state) []types.SyncEvent {
+		var events []types.SyncEvent
+		blocked := make(map[string]bool)
+		for _, event := range s.sortedSyncEvents() {
+			if event.Status != types.SyncEventStatusPending {
+				continue
+			}
+			first := !blocked[event.AggregateID]
+			blocked[event.AggregateID] = true
+			if !first || event.NextAttemptAt.After(now) {
+				continue
+			}
+			if aggregateID != "" && event.AggregateID != aggregateID {
+				continue
+			}
+			events = append(events, event)
+		}
+		return events
+	}
+
+	locked, err := b.skipLockedTx(dbTx, "sync_outbox", func(s *state) []string {
+		var keys []string
+		for _, event := range deliverable(s) {
+			keys = append(keys, strconv.FormatInt(event.ID, 10))
+		}
+		return keys
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []types.SyncEvent
+	err = b.readTx(dbTx, func(s *state) error {
+		for _, event := range deliverable(s) {
+			if slices.Contains(locked, strconv.FormatInt(event.ID, 10)) {
+				event.Payload = cloneRaw(event.Payload)
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (b *MemoryBackend) CountPendingSyncEvents(ctx context.Context, aggregateID string) (int64, error) {
+	var count int64
+	err := b.read(func(s *state) error {
+		for _, event := range s.syncEvents {
+			if event.Status == types.SyncEventStatusPending && event.AggregateID == aggregateID {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// LeaseSyncEventsTx fails the transaction if another relay leased the events
+// since they were read, which only happens to events that were not locked.
+func (b *MemoryBackend) LeaseSyncEventsTx(ctx context.Context, dbTx storage.Tx, ids []int64, until time.Time) error {
+	now := time.Now().UTC()
+
+	return b.writeTx(dbTx, func(s *state) error {
+		for _, id := range ids {
+			for i := range s.syncEvents {
+				event := &s.syncEvents[i]
+				if event.ID != id || event.Status != types.SyncEventStatusPending {
+					continue
+				}
+				if event.NextAttemptAt.After(now) {
+					return errSerialization
+				}
+				event.NextAttemptAt = until
+			}
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) MarkSyncEventDeliveredTx(ctx context.Context, dbTx storage.Tx, id int64) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.updateSyncEvent(id, func(event *types.SyncEvent) {
+			event.Status = types.SyncEventStatusDelivered
+			event.Attempts++
+			event.LastError = nil
+		})
+		return nil
+	})
+}
+
+func (b *MemoryBackend) RescheduleSyncEventTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string, nextAttemptAt time.Time) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.updateSyncEvent(id, func(event *types.SyncEvent) {
+			event.Attempts++
+			event.LastError = &lastError
+			event.NextAttemptAt = nextAttemptAt
+		})
+		return nil
+	})
+}
+
+func (b *MemoryBackend) FailSyncEventTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.updateSyncEvent(id, func(event *types.SyncEvent) {
+			event.Status = types.SyncEventStatusFailed
+			event.Attempts++
+			event.LastError = &lastError
+		})
+		return nil
+	})
+}
+
+// sortedSyncEvents returns the events by id. Events of concurrent transactions
+// can be committed out of id order.
+func (s *state) sortedSyncEvents() []types.SyncEvent {
+	events := slices.Clone(s.syncEvents)
+	slices.SortFunc(events, func(a, b types.SyncEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return events
+}
+
+func (s *state) updateSyncEvent(id int64, update func(event *types.SyncEvent)) {
+	for i := range s.syncEvents {
+		if s.syncEvents[i].ID == id {
+			update(&s.syncEvents[i])
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// CreateTransactionHistoryTx inserts the transaction or, if one with the same
+// signing hash is still active, resets it to pending with the new details.
+func (b *MemoryBackend) CreateTransactionHistoryTx(ctx context.Context, dbTx storage.Tx, tx types.TransactionHistory) (uuid.UUID, error) {
+	metadata, err := toJSONMap(tx.Metadata)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
+	}
+	if err := checkTransactionAmounts(tx); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
+	}
+	newID := uuid.New()
+	now := time.Now().UTC()
+
+	txID := uuid.Nil
+	err = b.writeTx(dbTx, func(s *state) error {
+		if _, ok := s.policies[tx.PolicyID.String()]; !ok {
+			return fmt.Errorf("failed to create transaction history: unknown policy %s", tx.PolicyID)
+		}
+
+		row, exists := s.activeTransactionByHash(tx.TxHash)
+		if !exists {
+			row = transactionRow{TransactionHistory: types.TransactionHistory{
+				ID:        newID,
+				TxHash:    tx.TxHash,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}}
+		}
+		if txID != uuid.Nil && row.ID != txID {
+			return errSerialization
+		}
+		txID = row.ID
+
+		details := tx
+		details.ID, details.TxHash, details.CreatedAt, details.UpdatedAt = row.ID, row.TxHash, row.CreatedAt, row.UpdatedAt
+		details.Metadata = maps.Clone(metadata)
+		if exists {
+			details.Status = types.StatusPending
+		}
+		row.TransactionHistory = cloneTransaction(details)
+		if err := s.checkBroadcastHash(row.ID, row.BroadcastHash); err != nil {
+			return fmt.Errorf("failed to create transaction history: %w", err)
+		}
+		s.transactions[row.ID] = row
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return txID, nil
+}
+
+func (b *MemoryBackend) UpdateTransactionStatusTx(ctx context.Context, dbTx storage.Tx, txID uuid.UUID, update types.TransactionUpdate) error {
+	metadata, err := toJSONMap(update.Metadata)
+	if err != nil {
+		return err
+	}
+	update.Metadata = metadata
+	now := time.Now().UTC()
+
+	return b.writeTx(dbTx, func(s *state) error {
+		return s.updateTransactionStatus(txID, update, now)
+	})
+}
+
+func (b *MemoryBackend) CreateTransactionHistory(ctx context.Context, tx types.TransactionHistory) (uuid.UUID, error) {
+	metadata, err := toJSONMap(tx.Metadata)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
+	}
+	if err := checkTransactionAmounts(tx); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
+	}
+	now := time.Now().UTC()
+
+	tx.ID = uuid.New()
+	tx.CreatedAt, tx.UpdatedAt = now, now
+	tx.Metadata = metadata
+	row := transactionRow{TransactionHistory: cloneTransaction(tx)}
+	err = b.write(func(s *state) error {
+		if _, ok := s.policies[tx.PolicyID.String()]; !ok {
+			return fmt.Errorf("failed to create transaction history: unknown policy %s", tx.PolicyID)
+		}
+		if _, exists := s.activeTransactionByHash(tx.TxHash); exists {
+			return fmt.Errorf("failed to create transaction history: duplicate tx hash %s", tx.TxHash)
+		}
+		if err := s.checkBroadcastHash(row.ID, row.BroadcastHash); err != nil {
+			return fmt.Errorf("failed to create transaction history: %w", err)
+		}
+		s.transactions[row.ID] = row
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return row.ID, nil
+}
+
+func (b *MemoryBackend) UpdateTransactionStatus(ctx context.Context, txID uuid.UUID, update types.TransactionUpdate) error {
+	metadata, err := toJSONMap(update.Metadata)
+	if err != nil {
+		return err
+	}
+	update.Metadata = metadata
+	now := time.Now().UTC()
+
+	return b.write(func(s *state) error {
+		return s.updateTransactionStatus(txID, update, now)
+	})
+}
+
+func (b *MemoryBackend) GetTransactionHistory(ctx context.Context, policyID uuid.UUID, transactionType string, take int, skip int) ([]types.TransactionHistory, error) {
+	var history []types.TransactionHistory
+	err := b.read(func(s *state) error {
+		var rows []types.TransactionHistory
+		for _, row := range s.transactions {
+			if row.PolicyID == policyID && row.TxType == transactionType {
+				rows = append(rows, cloneTransaction(row.TransactionHistory))
+			}
+		}
+		slices.SortFunc(rows, func(a, b types.TransactionHistory) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+		history = page(rows, take, skip)
+		return nil
+	})
+	return history, err
+}
+
+// GetTransactionByHash returns the latest transaction with the given signing
+// or broadcast hash.
+func (b *MemoryBackend) GetTransactionByHash(ctx context.Context, txHash string) (*types.TransactionHistory, error) {
+	var tx types.TransactionHistory
+	err := b.read(func(s *state) error {
+		row, ok := s.transactionByHash(txHash)
+		if !ok {
+			return notFound(fmt.Sprintf("transaction with Tx Hash %s not found", txHash))
+		}
+		tx = cloneTransaction(row.TransactionHistory)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+// GetTransactionStatusesByPluginType returns the status of the latest attempt
+// of each signing hash.
+func (b *MemoryBackend) GetTransactionStatusesByPluginType(ctx context.Context, pluginType string) ([]types.TransactionStatusEntry, error) {
+	var entries []types.TransactionStatusEntry
+	err := b.read(func(s *state) error {
+		for _, row := range s.transactions {
+			if s.policies[row.PolicyID.String()].PluginType != pluginType {
+				continue
+			}
+			if latest, _ := s.latestTransaction(func(other transactionRow) bool {
+				return other.TxHash == row.TxHash
+			}); latest.ID != row.ID {
+				continue
+			}
+			entries = append(entries, types.TransactionStatusEntry{
+				PolicyID: row.PolicyID.String(),
+				TxHash:   row.TxHash,
+				Status:   row.Status,
+			})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (b *MemoryBackend) CountTransactions(ctx context.Context, policyID uuid.UUID, status types.TransactionStatus, txType string) (int64, error) {
+	var count int64
+	err := b.read(func(s *state) error {
+		for _, row := range s.transactions {
+			if row.PolicyID == policyID && row.Status == status && row.TxType == txType {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (b *MemoryBackend) LockVaultTx(ctx context.Context, dbTx storage.Tx, publicKey string) error {
+	return b.advisoryLockTx(ctx, dbTx, "vault:"+publicKey)
+}
+
+// ReserveTransactionSigningTx counts and reserves within the transaction. The
+// caller holds the vault lock of the policy, a reservation whose count changed
+// by the time it commits fails to serialize.
+func (b *MemoryBackend) ReserveTransactionSigningTx(ctx context.Context, dbTx storage.Tx, txID uuid.UUID, policyID uuid.UUID, txType string, since time.Time, limit int64) (bool, error) {
+	now := time.Now().UTC()
+
+	reserved := false
+	applied := false
+	err := b.writeTx(dbTx, func(s *state) error {
+		var count int64
+		for _, row := range s.transactions {
+			if row.PolicyID == policyID && row.ID != txID && row.signedType == txType &&
+				row.signedAt != nil && !row.signedAt.Before(since) {
+				count++
+			}
+		}
+		allowed := count < limit
+		if applied && allowed != reserved {
+			return errSerialization
+		}
+		applied = true
+		reserved = allowed
+		if !allowed {
+			return nil
+		}
+
+		if row, ok := s.transactions[txID]; ok {
+			row.signedAt = &now
+			row.signedType = txType
+			s.transactions[txID] = row
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return reserved, nil
+}
+
+func (b *MemoryBackend) ReleaseTransactionSigning(ctx context.Context, txID uuid.UUID) error {
+	return b.write(func(s *state) error {
+		if row, ok := s.transactions[txID]; ok {
+			row.signedAt = nil
+			row.signedType = ""
+			s.transactions[txID] = row
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) MarkTransactionSigned(ctx context.Context, txID uuid.UUID, txType string) error {
+	now := time.Now().UTC()
+
+	return b.write(func(s *state) error {
+		if row, ok := s.transactions[txID]; ok && row.signedAt == nil {
+			row.signedAt = &now
+			row.signedType = txType
+			s.transactions[txID] = row
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) CountSignedTransactions(ctx context.Context, policyID uuid.UUID, txType string, excludeTxHash string) (int64, error) {
+	var count int64
+	err := b.read(func(s *state) error {
+		for _, row := range s.transactions {
+			if row.PolicyID == policyID && row.signedType == txType && row.signedAt != nil && row.TxHash != excludeTxHash {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (b *MemoryBackend) SetTransactionAttestation(ctx context.Context, txID uuid.UUID, attestation any) error {
+	data, err := json.Marshal(attestation)
+	if err != nil {
+		return fmt.Errorf("failed to set transaction attestation: %w", err)
+	}
+
+	return b.write(func(s *state) error {
+		if row, ok := s.transactions[txID]; ok {
+			row.attestation = data
+			s.transactions[txID] = row
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetTransactionAttestation(ctx context.Context, txHash string) (json.RawMessage, error) {
+	var attestation json.RawMessage
+	err := b.read(func(s *state) error {
+		row, ok := s.latestTransaction(func(row transactionRow) bool {
+			return matchesHash(row.TransactionHistory, txHash) && row.attestation != nil
+		})
+		for _, archived := range s.archivedAttestations {
+			if matchesHash(archived.TransactionHistory, txHash) && (!ok || archived.CreatedAt.After(row.CreatedAt)) {
+				row, ok = archived, true
+			}
+		}
+		if !ok {
+			return notFound("failed to get transaction attestation")
+		}
+		attestation = cloneRaw(row.attestation)
+		return nil
+	})
+	return attestation, err
+}
+
+func (s *state) transactionByHash(txHash string) (transactionRow, bool) {
+	return s.latestTransaction(func(row transactionRow) bool {
+		return matchesHash(row.TransactionHistory, txHash)
+	})
+}
+
+// activeTransactionByHash returns the transaction with the signing hash that
+// didn't fail, of which there is at most one.
+func (s *state) activeTransactionByHash(txHash string) (transactionRow, bool) {
+	return s.latestTransaction(func(row transactionRow) bool {
+		return row.TxHash == txHash && row.Status != types.StatusSigningFailed && row.Status != types.StatusRejected
+	})
+}
+
+func (s *state) latestTransaction(match func(transactionRow) bool) (transactionRow, bool) {
+	var latest transactionRow
+	found := false
+	for _, row := range s.transactions {
+		if match(row) && (!found || row.CreatedAt.After(latest.CreatedAt)) {
+			latest, found = row, true
+		}
+	}
+	return latest, found
+}
+
+// checkBroadcastHash enforces the uniqueness of broadcast hashes.
+func (s *state) checkBroadcastHash(txID uuid.UUID, broadcastHash string) error {
+	if broadcastHash == "" {
+		return nil
+	}
+	for _, row := range s.transactions {
+		if row.ID != txID && row.BroadcastHash == broadcastHash {
+			return fmt.Errorf("duplicate broadcast hash %s", broadcastHash)
+		}
+	}
+	return nil
+}
+
+func matchesHash(tx types.TransactionHistory, txHash string) bool {
+	return tx.TxHash == txHash || (tx.BroadcastHash != "" && tx.BroadcastHash == txHash)
+}
+
+// updateTransactionStatus merges metadata into the stored metadata like the
+// jsonb || operator, which leaves NULL metadata NULL, and keeps the stored
+// details the update leaves empty.
+func (s *state) updateTransactionStatus(txID uuid.UUID, update types.TransactionUpdate, now time.Time) error {
+	row, ok := s.transactions[txID]
+	if !ok {
+		return nil
+	}
+	row.Status = update.Status
+	if row.Metadata != nil && update.Metadata != nil {
+		merged := maps.Clone(row.Metadata)
+		maps.Copy(merged, update.Metadata)
+		row.Metadata = merged
+	}
+	if update.BroadcastHash != "" {
+		if err := s.checkBroadcastHash(txID, update.BroadcastHash); err != nil {
+			return err
+		}
+		row.BroadcastHash = update.BroadcastHash
+	}
+	if update.SigningTaskID != "" {
+		row.SigningTaskID = update.SigningTaskID
+	}
+	if update.BlockNumber != nil {
+		row.BlockNumber = clonePtr(update.BlockNumber)
+	}
+	if update.ErrorCode != "" {
+		row.ErrorCode = update.ErrorCode
+	}
+	if update.ErrorMessage != nil {
+		row.ErrorMessage = clonePtr(update.ErrorMessage)
+	}
+	row.UpdatedAt = now
+	s.transactions[txID] = row
+	return nil
+}
+
+// checkTransactionAmounts rejects amounts the NUMERIC columns wouldn't take.
+func checkTransactionAmounts(tx types.TransactionHistory) error {
+	for name, value := range map[string]string{"amount": tx.Amount, "gas_price": tx.GasPrice, "gas_fee": tx.GasFee} {
+		if _, ok := new(big.Int).SetString(value, 10); value != "" && !ok {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+	}
+	return nil
+}
+
+// cloneTransaction copies the metadata and pointer fields so that stored rows
+// don't share memory with callers.
+func cloneTransaction(tx types.TransactionHistory) types.TransactionHistory {
+	tx.Metadata = maps.Clone(tx.Metadata)
+	tx.ErrorMessage = clonePtr(tx.ErrorMessage)
+	tx.Nonce = clonePtr(tx.Nonce)
+	tx.GasLimit = clonePtr(tx.GasLimit)
+	tx.BlockNumber = clonePtr(tx.BlockNumber)
+	return tx
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
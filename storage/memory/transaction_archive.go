@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+type aggregateKey struct {
+	policyID uuid.UUID
+	day      time.Time
+	chainID  string
+	token    string
+	txType   string
+	status   types.TransactionStatus
+}
+
+// GetArchivableTransactionsTx locks the finished transactions last updated
+// before the cutoff whose policy no longer runs, oldest first. Transactions
+// locked by another run are skipped.
+func (b *MemoryBackend) GetArchivableTransactionsTx(ctx context.Context, dbTx storage.Tx, before time.Time, limit int) ([]types.ArchivedTransaction, error) {
+	archivable := func(s *state) []transactionRow {
+		var rows []transactionRow
+		for _, row := range s.transactions {
+			if !isFinished(row.Status) || !row.UpdatedAt.Before(before) {
+				continue
+			}
+			// running policies count their transactions
+			if policy, ok := s.policies[row.PolicyID.String()]; ok && policy.Runnable() {
+				continue
+			}
+			rows = append(rows, row)
+		}
+		slices.SortFunc(rows, func(a, b transactionRow) int {
+			return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), slices.Compare(a.ID[:], b.ID[:]))
+		})
+		return rows
+	}
+
+	locked, err := b.skipLockedTx(dbTx, "transaction_history", func(s *state) []string {
+		var keys []string
+		for _, row := range archivable(s) {
+			keys = append(keys, row.ID.String())
+		}
+		return keys
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []types.ArchivedTransaction
+	err = b.readTx(dbTx, func(s *state) error {
+		for _, row := range archivable(s) {
+			if !slices.Contains(locked, row.ID.String()) {
+				continue
+			}
+			transactions = append(transactions, types.ArchivedTransaction{
+				TransactionHistory: cloneTransaction(row.TransactionHistory),
+				SignedAt:           clonePtr(row.signedAt),
+				SignedType:         row.signedType,
+				Attestation:        cloneRaw(row.attestation),
+			})
+		}
+		return nil
+	})
+	return transactions, err
+}
+
+func (b *MemoryBackend) ArchiveTransactionsTx(ctx context.Context, dbTx storage.Tx, archive types.TransactionArchive, txIDs []uuid.UUID, aggregates []types.TransactionAggregate) error {
+	archive.CreatedAt = time.Now().UTC()
+
+	return b.writeTx(dbTx, func(s *state) error {
+		// a concurrent run archived them already and added their aggregates
+		for _, txID := range txIDs {
+			if _, ok := s.transactions[txID]; !ok {
+				return errSerialization
+			}
+		}
+		for _, aggregate := range aggregates {
+			key := aggregateKey{
+				policyID: aggregate.PolicyID,
+				day:      aggregate.Day,
+				chainID:  aggregate.ChainID,
+				token:    aggregate.Token,
+				txType:   aggregate.TxType,
+				status:   aggregate.Status,
+			}
+			stored, ok := s.aggregates[key]
+			if !ok {
+				stored = aggregate
+				stored.Count, stored.Amount, stored.GasFee = 0, "0", "0"
+			}
+			stored.Count += aggregate.Count
+			stored.Amount = sumDecimal(stored.Amount, aggregate.Amount)
+			stored.GasFee = sumDecimal(stored.GasFee, aggregate.GasFee)
+			s.aggregates[key] = stored
+		}
+
+		for _, txID := range txIDs {
+			if row := s.transactions[txID]; row.attestation != nil {
+				s.archivedAttestations = append(s.archivedAttestations, row)
+			}
+			delete(s.transactions, txID)
+		}
+		s.transactionArchives = append(s.transactionArchives, archive)
+		return nil
+	})
+}
+
+// PurgeTransactionMetadataTx locks the transactions to purge, skipping those
+// locked by another run, and fails the transaction if they changed since.
+func (b *MemoryBackend) PurgeTransactionMetadataTx(ctx context.Context, dbTx storage.Tx, before time.Time, keys []string, limit int) (int64, error) {
+	purgeable := func(row transactionRow) bool {
+		return isFinished(row.Status) && row.UpdatedAt.Before(before) && slices.ContainsFunc(keys, func(key string) bool {
+			_, ok := row.Metadata[key]
+			return ok
+		})
+	}
+
+	locked, err := b.skipLockedTx(dbTx, "transaction_history", func(s *state) []string {
+		var ids []string
+		for id, row := range s.transactions {
+			if purgeable(row) {
+				ids = append(ids, id.String())
+			}
+		}
+		slices.Sort(ids)
+		return ids
+	}, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	err = b.writeTx(dbTx, func(s *state) error {
+		for _, key := range locked {
+			id := uuid.MustParse(key)
+			row, ok := s.transactions[id]
+			if !ok || !purgeable(row) {
+				return errSerialization
+			}
+			// rows are shared with transaction snapshots, replace the map
+			row.Metadata = maps.Clone(row.Metadata)
+			for _, key := range keys {
+				delete(row.Metadata, key)
+			}
+			s.transactions[id] = row
+		}
+		return nil
+	})
+	return int64(len(locked)), err
+}
+
+func (b *MemoryBackend) GetTransactionAggregates(ctx context.Context, policyID uuid.UUID) ([]types.TransactionAggregate, error) {
+	aggregates := []types.TransactionAggregate{}
+	err := b.read(func(s *state) error {
+		for key, aggregate := range s.aggregates {
+			if key.policyID == policyID {
+				aggregates = append(aggregates, aggregate)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(aggregates, func(a, b types.TransactionAggregate) int {
+		return cmp.Or(
+			b.Day.Compare(a.Day),
+			strings.Compare(a.ChainID, b.ChainID),
+			strings.Compare(a.Token, b.Token),
+			strings.Compare(a.TxType, b.TxType),
+			strings.Compare(string(a.Status), string(b.Status)),
+		)
+	})
+	return aggregates, nil
+}
+
+func isFinished(status types.TransactionStatus) bool {
+	return slices.Contains(types.FinishedTransactionStatuses, status)
+}
+
+// sumDecimal adds two decimal strings the way NUMERIC columns do.
+func sumDecimal(a, b string) string {
+	sum := new(big.Int)
+	addDecimal(sum, a)
+	addDecimal(sum, b)
+	return sum.String()
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func (b *MemoryBackend) QueryTransactionHistory(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionHistory, error) {
+	history := []types.TransactionHistory{}
+	err := b.read(func(s *state) error {
+		for _, row := range s.transactions {
+			if s.matchesHistoryQuery(row, query) && afterCursor(row.TransactionHistory, query) {
+				history = append(history, cloneTransaction(row.TransactionHistory))
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(history, func(a, b types.TransactionHistory) int {
+		order := cmp.Or(a.CreatedAt.Compare(b.CreatedAt), slices.Compare(a.ID[:], b.ID[:]))
+		if !query.Ascending {
+			order = -order
+		}
+		return order
+	})
+	return page(history, query.Limit, 0), err
+}
+
+func (b *MemoryBackend) GetTransactionTotals(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionTotal, error) {
+	type key struct{ chainID, token string }
+	type sums struct {
+		count  int64
+		amount *big.Int
+		gas    *big.Int
+	}
+
+	totals := make(map[key]*sums)
+	err := b.read(func(s *state) error {
+		for _, row := range s.transactions {
+			if !s.matchesHistoryQuery(row, query) || !slices.Contains(types.SpentTransactionStatuses, row.Status) {
+				continue
+			}
+			if row.Token == "" {
+				continue
+			}
+			k := key{chainID: row.ChainID, token: row.Token}
+			if totals[k] == nil {
+				totals[k] = &sums{amount: new(big.Int), gas: new(big.Int)}
+			}
+			totals[k].count++
+			addDecimal(totals[k].amount, row.Amount)
+			addDecimal(totals[k].gas, row.GasFee)
+		}
+		return nil
+	})
+
+	result := []types.TransactionTotal{}
+	for k, sum := range totals {
+		result = append(result, types.TransactionTotal{
+			ChainID:     k.chainID,
+			Token:       k.token,
+			Count:       sum.count,
+			AmountSpent: sum.amount.String(),
+			GasPaid:     sum.gas.String(),
+		})
+	}
+	slices.SortFunc(result, func(a, b types.TransactionTotal) int {
+		return cmp.Or(cmp.Compare(a.ChainID, b.ChainID), cmp.Compare(a.Token, b.Token))
+	})
+	return result, err
+}
+
+func (s *state) matchesHistoryQuery(row transactionRow, query types.TransactionHistoryQuery) bool {
+	policy, ok := s.policies[row.PolicyID.String()]
+	switch {
+	case !ok || policy.PublicKey != query.PublicKey:
+		return false
+	case query.PolicyID != "" && row.PolicyID.String() != query.PolicyID:
+		return false
+	case len(query.Statuses) > 0 && !slices.Contains(query.Statuses, row.Status):
+		return false
+	case len(query.TransactionTypes) > 0 && !slices.Contains(query.TransactionTypes, row.TxType):
+		return false
+	case query.From != nil && row.CreatedAt.Before(*query.From):
+		return false
+	case query.To != nil && !row.CreatedAt.Before(*query.To):
+		return false
+	case query.Token != "" && row.Token != query.Token:
+		return false
+	case query.ChainID != "" && row.ChainID != query.ChainID:
+		return false
+	case query.TxHash != "" && !matchesHash(row.TransactionHistory, query.TxHash):
+		return false
+	}
+	return true
+}
+
+func afterCursor(tx types.TransactionHistory, query types.TransactionHistoryQuery) bool {
+	if query.Cursor == nil {
+		return true
+	}
+	order := cmp.Or(tx.CreatedAt.Compare(query.Cursor.CreatedAt), slices.Compare(tx.ID[:], query.Cursor.ID[:]))
+	if query.Ascending {
+		return order > 0
+	}
+	return order < 0
+}
+
+// addDecimal adds a decimal string to sum, ignoring unknown values.
+func addDecimal(sum *big.Int, value string) {
+	if value == "" || strings.ContainsAny(value, "+-") {
+		return
+	}
+	if amount, ok := new(big.Int).SetString(value, 10); ok {
+		sum.Add(sum, amount)
+	}
+}
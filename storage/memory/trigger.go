@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (b *MemoryBackend) CreateTimeTriggerTx(ctx context.Context, dbTx storage.Tx, trigger types.TimeTrigger) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		if _, ok := s.policies[trigger.PolicyID]; !ok {
+			return fmt.Errorf("time trigger references unknown policy %s", trigger.PolicyID)
+		}
+		s.timeTriggers = append(s.timeTriggers, trigger)
+		return nil
+	})
+}
+
+func (b *MemoryBackend) DeleteTimeTrigger(ctx context.Context, policyID string) error {
+	return b.write(func(s *state) error {
+		s.timeTriggers = slices.DeleteFunc(s.timeTriggers, func(t types.TimeTrigger) bool { return t.PolicyID == policyID })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetPendingTimeTriggers(ctx context.Context) ([]types.TimeTrigger, error) {
+	now := time.Now().UTC()
+
+	var triggers []types.TimeTrigger
+	err := b.read(func(s *state) error {
+		for _, t := range s.timeTriggers {
+			if t.StartTime.After(now) || (t.EndTime != nil && !t.EndTime.After(now)) {
+				continue
+			}
+			if !s.policies[t.PolicyID].Runnable() || t.Status != types.StatusTimeTriggerPending {
+				continue
+			}
+			if t.LastExecution != nil && !t.LastExecution.Before(now) {
+				continue
+			}
+			triggers = append(triggers, t)
+		}
+		return nil
+	})
+	slices.SortStableFunc(triggers, func(a, b types.TimeTrigger) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+	return triggers, err
+}
+
+func (b *MemoryBackend) UpdateTimeTriggerLastExecution(ctx context.Context, policyID string) error {
+	now := time.Now().UTC()
+	return b.write(func(s *state) error {
+		s.updateTimeTriggers(policyID, func(t *types.TimeTrigger) { t.LastExecution = &now })
+		return nil
+	})
+}
+
+// UpdateTimeTriggerTx inserts the trigger when the policy has none, as when
+// it switches from an event trigger to a schedule.
+func (b *MemoryBackend) UpdateTimeTriggerTx(ctx context.Context, policyID string, trigger types.TimeTrigger, dbTx storage.Tx) error {
+	trigger.PolicyID = policyID
+	return b.writeTx(dbTx, func(s *state) error {
+		found := s.updateTimeTriggers(policyID, func(t *types.TimeTrigger) {
+			t.StartTime = trigger.StartTime
+			t.Frequency = trigger.Frequency
+			t.Interval = trigger.Interval
+			t.CronExpression = trigger.CronExpression
+		})
+		if !found {
+			s.timeTriggers = append(s.timeTriggers, trigger)
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) DeleteTimeTriggerTx(ctx context.Context, dbTx storage.Tx, policyID string) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.timeTriggers = slices.DeleteFunc(s.timeTriggers, func(t types.TimeTrigger) bool { return t.PolicyID == policyID })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error) {
+	var status types.TimeTriggerStatus
+	err := b.read(func(s *state) error {
+		for _, t := range s.timeTriggers {
+			if t.PolicyID == policyID {
+				status = t.Status
+				return nil
+			}
+		}
+		return notFound(fmt.Sprintf("trigger not found for policy_id: %s", policyID))
+	})
+	return status, err
+}
+
+func (b *MemoryBackend) UpdateTriggerStatus(ctx context.Context, policyID string, status types.TimeTriggerStatus) error {
+	return b.write(func(s *state) error {
+		s.updateTimeTriggers(policyID, func(t *types.TimeTrigger) { t.Status = status })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) ClaimTimeTrigger(ctx context.Context, policyID string) (bool, error) {
+	claimed := false
+	err := b.write(func(s *state) error {
+		s.updateTimeTriggers(policyID, func(t *types.TimeTrigger) {
+			if t.Status == types.StatusTimeTriggerPending {
+				t.Status = types.StatusTimeTriggerRunning
+				claimed = true
+			}
+		})
+		return nil
+	})
+	return claimed, err
+}
+
+func (b *MemoryBackend) CreateEventTriggerTx(ctx context.Context, dbTx storage.Tx, trigger types.EventTrigger) error {
+	trigger.Condition = cloneRaw(trigger.Condition)
+	row := eventTriggerRow{id: b.nextID("event_triggers"), EventTrigger: trigger}
+
+	return b.writeTx(dbTx, func(s *state) error {
+		if _, ok := s.policies[trigger.PolicyID]; !ok {
+			return fmt.Errorf("event trigger references unknown policy %s", trigger.PolicyID)
+		}
+		for _, t := range s.eventTriggers {
+			if t.PolicyID == trigger.PolicyID {
+				return fmt.Errorf("event trigger already exists for policy_id: %s", trigger.PolicyID)
+			}
+		}
+		s.eventTriggers = append(s.eventTriggers, row)
+		return nil
+	})
+}
+
+// UpdateEventTriggerTx resets the checkpoint, as the condition may watch
+// another contract now. The trigger is inserted when the policy has none, as
+// when it switches from a schedule to an event trigger.
+func (b *MemoryBackend) UpdateEventTriggerTx(ctx context.Context, policyID string, trigger types.EventTrigger, dbTx storage.Tx) error {
+	trigger.PolicyID = policyID
+	trigger.Condition = cloneRaw(trigger.Condition)
+	row := eventTriggerRow{id: b.nextID("event_triggers"), EventTrigger: trigger}
+
+	return b.writeTx(dbTx, func(s *state) error {
+		found := s.updateEventTriggers(policyID, func(t *types.EventTrigger) {
+			t.TriggerType = trigger.TriggerType
+			t.Condition = trigger.Condition
+			t.Cooldown = trigger.Cooldown
+			t.LastCheckedBlock = nil
+		})
+		if !found {
+			s.eventTriggers = append(s.eventTriggers, row)
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) DeleteEventTriggerTx(ctx context.Context, dbTx storage.Tx, policyID string) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.eventTriggers = slices.DeleteFunc(s.eventTriggers, func(t eventTriggerRow) bool { return t.PolicyID == policyID })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) DeleteEventTrigger(ctx context.Context, policyID string) error {
+	return b.write(func(s *state) error {
+		s.eventTriggers = slices.DeleteFunc(s.eventTriggers, func(t eventTriggerRow) bool { return t.PolicyID == policyID })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetPendingEventTriggers(ctx context.Context) ([]types.EventTrigger, error) {
+	var rows []eventTriggerRow
+	err := b.read(func(s *state) error {
+		for _, t := range s.eventTriggers {
+			if s.policies[t.PolicyID].Runnable() && t.Status == types.StatusTimeTriggerPending {
+				rows = append(rows, t)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(rows, func(a, b eventTriggerRow) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	var triggers []types.EventTrigger
+	for _, row := range rows {
+		trigger := row.EventTrigger
+		trigger.Condition = cloneRaw(trigger.Condition)
+		triggers = append(triggers, trigger)
+	}
+	return triggers, err
+}
+
+func (b *MemoryBackend) GetEventTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error) {
+	var status types.TimeTriggerStatus
+	err := b.read(func(s *state) error {
+		for _, t := range s.eventTriggers {
+			if t.PolicyID == policyID {
+				status = t.Status
+				return nil
+			}
+		}
+		return notFound(fmt.Sprintf("event trigger not found for policy_id: %s", policyID))
+	})
+	return status, err
+}
+
+func (b *MemoryBackend) UpdateEventTriggerStatus(ctx context.Context, policyID string, status types.TimeTriggerStatus) error {
+	return b.write(func(s *state) error {
+		s.updateEventTriggers(policyID, func(t *types.EventTrigger) { t.Status = status })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) ClaimEventTrigger(ctx context.Context, policyID string) (bool, error) {
+	claimed := false
+	err := b.write(func(s *state) error {
+		s.updateEventTriggers(policyID, func(t *types.EventTrigger) {
+			if t.Status == types.StatusTimeTriggerPending {
+				t.Status = types.StatusTimeTriggerRunning
+				claimed = true
+			}
+		})
+		return nil
+	})
+	return claimed, err
+}
+
+func (b *MemoryBackend) UpdateEventTriggerLastTriggered(ctx context.Context, policyID string) error {
+	now := time.Now().UTC()
+	return b.write(func(s *state) error {
+		s.updateEventTriggers(policyID, func(t *types.EventTrigger) { t.LastTriggered = &now })
+		return nil
+	})
+}
+
+func (b *MemoryBackend) UpdateEventTriggerCheckpoint(ctx context.Context, policyID string, block uint64) error {
+	return b.write(func(s *state) error {
+		s.updateEventTriggers(policyID, func(t *types.EventTrigger) { t.LastCheckedBlock = &block })
+		return nil
+	})
+}
+
+// updateTimeTriggers applies update to the triggers of the policy and reports
+// whether it has any.
+func (s *state) updateTimeTriggers(policyID string, update func(t *types.TimeTrigger)) bool {
+	found := false
+	for i := range s.timeTriggers {
+		if s.timeTriggers[i].PolicyID == policyID {
+			update(&s.timeTriggers[i])
+			found = true
+		}
+	}
+	return found
+}
+
+func (s *state) updateEventTriggers(policyID string, update func(t *types.EventTrigger)) bool {
+	found := false
+	for i := range s.eventTriggers {
+		if s.eventTriggers[i].PolicyID == policyID {
+			update(&s.eventTriggers[i].EventTrigger)
+			found = true
+		}
+	}
+	return found
+}
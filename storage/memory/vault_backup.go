@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func (b *MemoryBackend) CreateVaultBackupVersion(ctx context.Context, version types.VaultBackupVersion) (*types.VaultBackupVersion, error) {
+	stored := version
+	stored.ID = uuid.New()
+	stored.Signers = slices.Clone(version.Signers)
+	if stored.Signers == nil {
+		stored.Signers = []string{}
+	}
+	stored.CreatedAt = time.Now().UTC()
+
+	err := b.write(func(s *state) error {
+		stored.Version = 1
+		for _, existing := range s.vaultBackups {
+			if existing.PublicKey == version.PublicKey && existing.Version >= stored.Version {
+				stored.Version = existing.Version + 1
+			}
+		}
+		s.vaultBackups = append(s.vaultBackups, stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cloneVaultBackupVersion(stored), nil
+}
+
+func (b *MemoryBackend) GetVaultBackupVersions(ctx context.Context, publicKey string) ([]types.VaultBackupVersion, error) {
+	versions := []types.VaultBackupVersion{}
+	err := b.read(func(s *state) error {
+		for _, version := range s.vaultBackups {
+			if version.PublicKey == publicKey {
+				versions = append(versions, *cloneVaultBackupVersion(version))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(versions, func(a, b types.VaultBackupVersion) int {
+		return b.Version - a.Version
+	})
+	return versions, nil
+}
+
+func (b *MemoryBackend) GetVaultBackupVersion(ctx context.Context, publicKey string, version int) (*types.VaultBackupVersion, error) {
+	var found *types.VaultBackupVersion
+	err := b.read(func(s *state) error {
+		for _, existing := range s.vaultBackups {
+			if existing.PublicKey == publicKey && existing.Version == version {
+				found = cloneVaultBackupVersion(existing)
+				return nil
+			}
+		}
+		return notFound(fmt.Sprintf("vault backup version %d not found", version))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func (b *MemoryBackend) DeleteVaultBackupVersion(ctx context.Context, id uuid.UUID) error {
+	return b.write(func(s *state) error {
+		s.vaultBackups = slices.DeleteFunc(s.vaultBackups, func(version types.VaultBackupVersion) bool {
+			return version.ID == id
+		})
+		return nil
+	})
+}
+
+func cloneVaultBackupVersion(version types.VaultBackupVersion) *types.VaultBackupVersion {
+	version.Signers = slices.Clone(version.Signers)
+	return &version
+}
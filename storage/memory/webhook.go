@@ -0,0 +1,306 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (b *MemoryBackend) CreateWebhookSubscription(ctx context.Context, subscription types.WebhookSubscription) (*types.WebhookSubscription, error) {
+	now := time.Now().UTC()
+	stored := types.WebhookSubscription{
+		ID:         uuid.New(),
+		PublicKey:  subscription.PublicKey,
+		PolicyID:   subscription.PolicyID,
+		URL:        subscription.URL,
+		Secret:     subscription.Secret,
+		EventTypes: nonNilStrings(subscription.EventTypes),
+		Active:     subscription.Active,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err := b.write(func(s *state) error {
+		s.webhookSubscriptions[stored.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created := stored
+	created.EventTypes = slices.Clone(stored.EventTypes)
+	return &created, nil
+}
+
+func (b *MemoryBackend) GetWebhookSubscriptions(ctx context.Context, publicKey string) ([]types.WebhookSubscription, error) {
+	subscriptions := []types.WebhookSubscription{}
+	err := b.read(func(s *state) error {
+		for _, subscription := range s.webhookSubscriptions {
+			if subscription.PublicKey != publicKey {
+				continue
+			}
+			subscription.Secret = ""
+			subscription.EventTypes = slices.Clone(subscription.EventTypes)
+			subscriptions = append(subscriptions, subscription)
+		}
+		return nil
+	})
+	slices.SortFunc(subscriptions, func(a, b types.WebhookSubscription) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return subscriptions, err
+}
+
+// DeleteWebhookSubscription deletes a subscription of the vault with its
+// deliveries and dead letters.
+func (b *MemoryBackend) DeleteWebhookSubscription(ctx context.Context, publicKey string, id string) error {
+	subscriptionID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return b.write(func(s *state) error {
+		if subscription, ok := s.webhookSubscriptions[subscriptionID]; !ok || subscription.PublicKey != publicKey {
+			return notFound("failed to delete webhook subscription")
+		}
+		delete(s.webhookSubscriptions, subscriptionID)
+		s.webhookDeliveries = slices.DeleteFunc(s.webhookDeliveries, func(d webhookDeliveryRow) bool {
+			return d.SubscriptionID == subscriptionID
+		})
+		s.webhookDeadLetters = slices.DeleteFunc(s.webhookDeadLetters, func(l types.WebhookDeadLetter) bool {
+			return l.SubscriptionID == subscriptionID
+		})
+		return nil
+	})
+}
+
+// InsertWebhookDeliveriesTx queues the event for every active subscription of
+// the vault matching its policy and type.
+func (b *MemoryBackend) InsertWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, event types.WebhookEvent, payload []byte) error {
+	now := time.Now().UTC()
+	// ids are kept for the subscriptions matched first, so that applying the
+	// op again on commit inserts the same rows
+	ids := make(map[uuid.UUID]int64)
+
+	return b.writeTx(dbTx, func(s *state) error {
+		for _, subscription := range s.webhookSubscriptions {
+			if !subscription.Active || subscription.PublicKey != event.PublicKey {
+				continue
+			}
+			if subscription.PolicyID != "" && subscription.PolicyID != event.PolicyID {
+				continue
+			}
+			if len(subscription.EventTypes) > 0 && !slices.Contains(subscription.EventTypes, string(event.Type)) {
+				continue
+			}
+
+			id, ok := ids[subscription.ID]
+			if !ok {
+				id = b.nextID("webhook_deliveries")
+				ids[subscription.ID] = id
+			}
+			s.webhookDeliveries = append(s.webhookDeliveries, webhookDeliveryRow{
+				WebhookDelivery: types.WebhookDelivery{
+					ID:             id,
+					SubscriptionID: subscription.ID,
+					EventID:        event.ID,
+					EventType:      event.Type,
+					Payload:        cloneRaw(payload),
+				},
+				status:        types.SyncEventStatusPending,
+				nextAttemptAt: now,
+				createdAt:     now,
+			})
+		}
+		return nil
+	})
+}
+
+// GetDueWebhookDeliveriesTx locks the pending deliveries that are due.
+// Deliveries locked by another dispatcher are skipped.
+func (b *MemoryBackend) GetDueWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, limit int) ([]types.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	due := func(s *state) []webhookDeliveryRow {
+		var rows []webhookDeliveryRow
+		for _, row := range s.webhookDeliveries {
+			if row.status == types.SyncEventStatusPending && !row.nextAttemptAt.After(now) {
+				rows = append(rows, row)
+			}
+		}
+		slices.SortFunc(rows, func(a, b webhookDeliveryRow) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		return rows
+	}
+
+	locked, err := b.skipLockedTx(dbTx, "webhook_deliveries", func(s *state) []string {
+		var keys []string
+		for _, row := range due(s) {
+			keys = append(keys, strconv.FormatInt(row.ID, 10))
+		}
+		return keys
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []types.WebhookDelivery
+	err = b.readTx(dbTx, func(s *state) error {
+		for _, row := range due(s) {
+			if !slices.Contains(locked, strconv.FormatInt(row.ID, 10)) {
+				continue
+			}
+			subscription := s.webhookSubscriptions[row.SubscriptionID]
+			delivery := row.WebhookDelivery
+			delivery.Payload = cloneRaw(delivery.Payload)
+			delivery.URL = subscription.URL
+			delivery.Secret = subscription.Secret
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// LeaseWebhookDeliveriesTx fails the transaction if another dispatcher leased
+// the deliveries since they were read, which only happens to deliveries that
+// were not locked.
+func (b *MemoryBackend) LeaseWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, ids []int64, until time.Time) error {
+	now := time.Now().UTC()
+
+	return b.writeTx(dbTx, func(s *state) error {
+		for _, id := range ids {
+			for i := range s.webhookDeliveries {
+				row := &s.webhookDeliveries[i]
+				if row.ID != id || row.status != types.SyncEventStatusPending {
+					continue
+				}
+				if row.nextAttemptAt.After(now) {
+					return errSerialization
+				}
+				row.nextAttemptAt = until
+			}
+		}
+		return nil
+	})
+}
+
+func (b *MemoryBackend) MarkWebhookDeliveredTx(ctx context.Context, dbTx storage.Tx, id int64) error {
+	now := time.Now().UTC()
+	return b.writeTx(dbTx, func(s *state) error {
+		s.updateWebhookDelivery(id, func(row *webhookDeliveryRow) {
+			row.status = types.SyncEventStatusDelivered
+			row.Attempts++
+			row.deliveredAt = &now
+			row.lastError = nil
+		})
+		return nil
+	})
+}
+
+func (b *MemoryBackend) RescheduleWebhookDeliveryTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string, nextAttemptAt time.Time) error {
+	return b.writeTx(dbTx, func(s *state) error {
+		s.updateWebhookDelivery(id, func(row *webhookDeliveryRow) {
+			row.Attempts++
+			row.lastError = &lastError
+			row.nextAttemptAt = nextAttemptAt
+		})
+		return nil
+	})
+}
+
+// DeadLetterWebhookDeliveryTx moves a delivery that ran out of attempts to the
+// dead letters.
+func (b *MemoryBackend) DeadLetterWebhookDeliveryTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string) error {
+	deadLetterID := b.nextID("webhook_dead_letters")
+	now := time.Now().UTC()
+
+	return b.writeTx(dbTx, func(s *state) error {
+		i := slices.IndexFunc(s.webhookDeliveries, func(row webhookDeliveryRow) bool { return row.ID == id })
+		if i < 0 {
+			return nil
+		}
+		row := s.webhookDeliveries[i]
+		s.webhookDeliveries = slices.Delete(s.webhookDeliveries, i, i+1)
+		s.webhookDeadLetters = append(s.webhookDeadLetters, types.WebhookDeadLetter{
+			ID:             deadLetterID,
+			SubscriptionID: row.SubscriptionID,
+			EventID:        row.EventID,
+			EventType:      row.EventType,
+			Payload:        row.Payload,
+			Attempts:       row.Attempts + 1,
+			LastError:      lastError,
+			FailedAt:       now,
+		})
+		return nil
+	})
+}
+
+func (b *MemoryBackend) GetWebhookDeadLetters(ctx context.Context, publicKey string, take int, skip int) ([]types.WebhookDeadLetter, error) {
+	deadLetters := []types.WebhookDeadLetter{}
+	err := b.read(func(s *state) error {
+		for _, deadLetter := range s.webhookDeadLetters {
+			subscription, ok := s.webhookSubscriptions[deadLetter.SubscriptionID]
+			if !ok || subscription.PublicKey != publicKey {
+				continue
+			}
+			deadLetter.PublicKey = subscription.PublicKey
+			deadLetter.Payload = cloneRaw(deadLetter.Payload)
+			deadLetters = append(deadLetters, deadLetter)
+		}
+		return nil
+	})
+	slices.SortStableFunc(deadLetters, func(a, b types.WebhookDeadLetter) int {
+		return b.FailedAt.Compare(a.FailedAt)
+	})
+	return page(deadLetters, take, skip), err
+}
+
+// ReplayWebhookDeadLetter queues a dead letter of the vault for delivery again
+// with a fresh attempt count.
+func (b *MemoryBackend) ReplayWebhookDeadLetter(ctx context.Context, publicKey string, id int64) error {
+	deliveryID := b.nextID("webhook_deliveries")
+	now := time.Now().UTC()
+
+	return b.write(func(s *state) error {
+		i := slices.IndexFunc(s.webhookDeadLetters, func(l types.WebhookDeadLetter) bool {
+			subscription, ok := s.webhookSubscriptions[l.SubscriptionID]
+			return l.ID == id && ok && subscription.PublicKey == publicKey
+		})
+		if i < 0 {
+			return notFound("failed to replay webhook dead letter")
+		}
+		deadLetter := s.webhookDeadLetters[i]
+		s.webhookDeadLetters = slices.Delete(s.webhookDeadLetters, i, i+1)
+		s.webhookDeliveries = append(s.webhookDeliveries, webhookDeliveryRow{
+			WebhookDelivery: types.WebhookDelivery{
+				ID:             deliveryID,
+				SubscriptionID: deadLetter.SubscriptionID,
+				EventID:        deadLetter.EventID,
+				EventType:      deadLetter.EventType,
+				Payload:        deadLetter.Payload,
+			},
+			status:        types.SyncEventStatusPending,
+			nextAttemptAt: now,
+			createdAt:     now,
+		})
+		return nil
+	})
+}
+
+func (s *state) updateWebhookDelivery(id int64, update func(row *webhookDeliveryRow)) {
+	for i := range s.webhookDeliveries {
+		if s.webhookDeliveries[i].ID == id {
+			update(&s.webhookDeliveries[i])
+		}
+	}
+}
@@ -3,44 +3,84 @@ package postgres
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/jackc/pgx/v5"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
 )
 
 //go:embed migrations/*
 var embeddedMigrations embed.FS
 
 type PostgresBackend struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	readonly bool
+
+	replicas          []*replica
+	nextReplica       atomic.Uint64
+	maxReplicaLag     time.Duration
+	stopReplicaChecks context.CancelFunc
 }
 
-func NewPostgresBackend(readonly bool, dsn string) (*PostgresBackend, error) {
-	logrus.Info("Connecting to database with DSN: ", dsn)
-	pool, err := pgxpool.New(context.Background(), dsn)
+// NewPostgresBackend connects to the primary at dsn and to the replicas, which
+// serve the reads that tolerate lag. A read-only backend skips migrations and
+// its sessions refuse writes, e.g. when the primary is a promoted standby
+// during disaster recovery.
+func NewPostgresBackend(readonly bool, dsn string, replicas ReplicaOptions) (*PostgresBackend, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database DSN: %w", err)
+	}
+	if readonly {
+		poolConfig.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+	logrus.WithFields(logrus.Fields{
+		"host":     poolConfig.ConnConfig.Host,
+		"database": poolConfig.ConnConfig.Database,
+		"readonly": readonly,
+		"replicas": len(replicas.DSNs),
+	}).Info("Connecting to database")
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	backend := &PostgresBackend{
-		pool: pool,
+		pool:     pool,
+		readonly: readonly,
 	}
 
-	if err := backend.Migrate(); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	if !readonly {
+		if err := backend.Migrate(); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+	if err := backend.connectReplicas(replicas); err != nil {
+		pool.Close()
+		return nil, err
 	}
 
 	return backend, nil
 }
 
+// ReadOnly reports whether the backend refuses writes.
+func (d *PostgresBackend) ReadOnly() bool {
+	return d.readonly
+}
+
 func (d *PostgresBackend) Close() error {
+	d.closeReplicas()
 	d.pool.Close()
 
 	return nil
@@ -61,26 +101,39 @@ func (d *PostgresBackend) Migrate() error {
 	return nil
 }
 
-func (p *PostgresBackend) CreateTransactionHistoryTx(ctx context.Context, dbTx pgx.Tx, tx types.TransactionHistory) (uuid.UUID, error) {
+func (p *PostgresBackend) CreateTransactionHistoryTx(ctx context.Context, dbTx storage.Tx, tx types.TransactionHistory) (uuid.UUID, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
 	query := `
-        INSERT INTO transaction_history (
-            policy_id, tx_body, tx_hash, status, metadata
-        ) VALUES ($1, $2, $3, $4, $5)
-        ON CONFLICT (tx_hash) DO UPDATE SET
+        INSERT INTO transaction_history (` + transactionInsertColumns + `)
+        VALUES (` + transactionInsertValues + `)
+        ON CONFLICT (tx_hash) WHERE status NOT IN ('SIGNING_FAILED', 'REJECTED') DO UPDATE SET
             policy_id = EXCLUDED.policy_id,
             tx_body = EXCLUDED.tx_body,
             status = 'PENDING',
-            metadata = EXCLUDED.metadata
+            metadata = EXCLUDED.metadata,
+            error_message = EXCLUDED.error_message,
+            tx_type = EXCLUDED.tx_type,
+            chain_id = EXCLUDED.chain_id,
+            from_address = EXCLUDED.from_address,
+            to_address = EXCLUDED.to_address,
+            token = EXCLUDED.token,
+            amount = EXCLUDED.amount,
+            nonce = EXCLUDED.nonce,
+            gas_limit = EXCLUDED.gas_limit,
+            gas_price = EXCLUDED.gas_price,
+            gas_fee = EXCLUDED.gas_fee,
+            signing_task_id = EXCLUDED.signing_task_id,
+            block_number = EXCLUDED.block_number,
+            error_code = EXCLUDED.error_code,
+            broadcast_hash = EXCLUDED.broadcast_hash
 		RETURNING id
     `
 	var txID uuid.UUID
-	err := dbTx.QueryRow(ctx, query,
-		tx.PolicyID,
-		tx.TxBody,
-		tx.TxHash,
-		tx.Status,
-		tx.Metadata,
-	).Scan(&txID)
+	err = pgTx.QueryRow(ctx, query, transactionInsertArgs(tx)...).Scan(&txID)
 
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
@@ -89,32 +142,24 @@ func (p *PostgresBackend) CreateTransactionHistoryTx(ctx context.Context, dbTx p
 	return txID, nil
 }
 
-func (p *PostgresBackend) UpdateTransactionStatusTx(ctx context.Context, dbTx pgx.Tx, txID uuid.UUID, status types.TransactionStatus, metadata map[string]interface{}) error {
-	query := `
-        UPDATE transaction_history 
-        SET status = $1, metadata = metadata || $2::jsonb, updated_at = NOW()
-        WHERE id = $3
-    `
+func (p *PostgresBackend) UpdateTransactionStatusTx(ctx context.Context, dbTx storage.Tx, txID uuid.UUID, update types.TransactionUpdate) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
 
-	_, err := dbTx.Exec(ctx, query, status, metadata, txID)
+	_, err = pgTx.Exec(ctx, transactionUpdateQuery, transactionUpdateArgs(txID, update)...)
 	return err
 }
 
 func (p *PostgresBackend) CreateTransactionHistory(ctx context.Context, tx types.TransactionHistory) (uuid.UUID, error) {
 	query := `
-        INSERT INTO transaction_history (
-            policy_id, tx_body, tx_hash, status, metadata
-        ) VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO transaction_history (` + transactionInsertColumns + `)
+        VALUES (` + transactionInsertValues + `)
 				RETURNING id
     `
 	var txID uuid.UUID
-	err := p.pool.QueryRow(ctx, query,
-		tx.PolicyID,
-		tx.TxBody,
-		tx.TxHash,
-		tx.Status,
-		tx.Metadata,
-	).Scan(&txID)
+	err := p.pool.QueryRow(ctx, query, transactionInsertArgs(tx)...).Scan(&txID)
 
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create transaction history: %w", err)
@@ -123,29 +168,23 @@ func (p *PostgresBackend) CreateTransactionHistory(ctx context.Context, tx types
 	return txID, nil
 }
 
-func (p *PostgresBackend) UpdateTransactionStatus(ctx context.Context, txID uuid.UUID, status types.TransactionStatus, metadata map[string]interface{}) error {
-	query := `
-        UPDATE transaction_history 
-        SET status = $1, metadata = metadata || $2::jsonb, updated_at = NOW()
-        WHERE id = $3
-    `
-
-	_, err := p.pool.Exec(ctx, query, status, metadata, txID)
+func (p *PostgresBackend) UpdateTransactionStatus(ctx context.Context, txID uuid.UUID, update types.TransactionUpdate) error {
+	_, err := p.pool.Exec(ctx, transactionUpdateQuery, transactionUpdateArgs(txID, update)...)
 	return err
 
 }
 
 func (p *PostgresBackend) GetTransactionHistory(ctx context.Context, policyID uuid.UUID, transactionType string, take int, skip int) ([]types.TransactionHistory, error) {
 	query := `
-        SELECT id, policy_id, tx_body, tx_hash, status, created_at, updated_at, metadata, error_message
-        FROM transaction_history
-        WHERE policy_id = $1
-        AND metadata->>'transaction_type' = $2
-        ORDER BY created_at DESC
+        SELECT ` + transactionColumns + `
+        FROM transaction_history t
+        WHERE t.policy_id = $1
+        AND t.tx_type = $2
+        ORDER BY t.created_at DESC
 		LIMIT $3 OFFSET $4
     `
 
-	rows, err := p.pool.Query(ctx, query, policyID, transactionType, take, skip)
+	rows, err := p.readPool().Query(ctx, query, policyID, transactionType, take, skip)
 	if err != nil {
 		return nil, err
 	}
@@ -153,18 +192,7 @@ func (p *PostgresBackend) GetTransactionHistory(ctx context.Context, policyID uu
 
 	var history []types.TransactionHistory
 	for rows.Next() {
-		var tx types.TransactionHistory
-		err := rows.Scan(
-			&tx.ID,
-			&tx.PolicyID,
-			&tx.TxBody,
-			&tx.TxHash,
-			&tx.Status,
-			&tx.CreatedAt,
-			&tx.UpdatedAt,
-			&tx.Metadata,
-			&tx.ErrorMessage,
-		)
+		tx, err := scanTransaction(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -174,38 +202,21 @@ func (p *PostgresBackend) GetTransactionHistory(ctx context.Context, policyID uu
 	return history, nil
 }
 
+// GetTransactionByHash returns the latest transaction with the given signing
+// or broadcast hash.
 func (p *PostgresBackend) GetTransactionByHash(ctx context.Context, txHash string) (*types.TransactionHistory, error) {
 	query := `
-        SELECT 
-            id, 
-            policy_id, 
-            tx_body, 
-            tx_hash,
-            status, 
-            created_at, 
-            updated_at, 
-            metadata, 
-            error_message
-        FROM transaction_history
-        WHERE tx_hash = $1
+        SELECT ` + transactionColumns + `
+        FROM transaction_history t
+        WHERE t.tx_hash = $1 OR t.broadcast_hash = $1
+        ORDER BY t.created_at DESC
+        LIMIT 1
     `
 
-	var tx types.TransactionHistory
-	err := p.pool.QueryRow(ctx, query, txHash).Scan(
-		&tx.ID,
-		&tx.PolicyID,
-		&tx.TxBody,
-		&tx.TxHash,
-		&tx.Status,
-		&tx.CreatedAt,
-		&tx.UpdatedAt,
-		&tx.Metadata,
-		&tx.ErrorMessage,
-	)
-
+	tx, err := scanTransaction(p.pool.QueryRow(ctx, query, txHash))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("transaction with Tx Hash %s not found", txHash)
+			return nil, fmt.Errorf("transaction with Tx Hash %s not found: %w", txHash, storage.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
@@ -213,6 +224,41 @@ func (p *PostgresBackend) GetTransactionByHash(ctx context.Context, txHash strin
 	return &tx, nil
 }
 
+// GetTransactionStatusesByPluginType returns the status of the latest attempt
+// of each signing hash.
+func (p *PostgresBackend) GetTransactionStatusesByPluginType(ctx context.Context, pluginType string) ([]types.TransactionStatusEntry, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		SELECT DISTINCT ON (th.tx_hash) th.policy_id, th.tx_hash, th.status
+		FROM transaction_history th
+		JOIN plugin_policies pp ON pp.id = th.policy_id
+		WHERE pp.plugin_type = $1
+		ORDER BY th.tx_hash, th.created_at DESC
+	`
+
+	rows, err := p.pool.Query(ctx, query, pluginType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction statuses: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []types.TransactionStatusEntry
+	for rows.Next() {
+		var entry types.TransactionStatusEntry
+		var policyID uuid.UUID
+		if err := rows.Scan(&policyID, &entry.TxHash, &entry.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction status: %w", err)
+		}
+		entry.PolicyID = policyID.String()
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
 func (p *PostgresBackend) CountTransactions(ctx context.Context, policyID uuid.UUID, status types.TransactionStatus, txType string) (int64, error) {
 	var count int64
 	query := `
@@ -220,7 +266,7 @@ func (p *PostgresBackend) CountTransactions(ctx context.Context, policyID uuid.U
 		FROM transaction_history
 		WHERE policy_id = $1
 		AND status = $2
-		AND metadata->>'transaction_type' = $3
+		AND tx_type = $3
 	`
 	err := p.pool.QueryRow(ctx, query, policyID, status, txType).Scan(&count)
 	if err != nil {
@@ -229,6 +275,206 @@ func (p *PostgresBackend) CountTransactions(ctx context.Context, policyID uuid.U
 	return count, nil
 }
 
-func (p *PostgresBackend) Pool() *pgxpool.Pool {
-	return p.pool
+// LockVaultTx serializes the signing decisions of a vault: the spending rules
+// and the policy limits are checked and reserved under this lock, which is
+// held until the transaction ends.
+func (p *PostgresBackend) LockVaultTx(ctx context.Context, dbTx storage.Tx, publicKey string) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pgTx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('vault:' || $1))`, publicKey); err != nil {
+		return fmt.Errorf("failed to lock vault: %w", err)
+	}
+
+	return nil
+}
+
+// ReserveTransactionSigningTx marks the transaction as signed by the verifier
+// if fewer than limit other transactions of the same type were signed for the
+// policy since the given time. The caller holds the vault lock of the policy,
+// so that concurrent requests can't exceed the limit.
+func (p *PostgresBackend) ReserveTransactionSigningTx(ctx context.Context, dbTx storage.Tx, txID uuid.UUID, policyID uuid.UUID, txType string, since time.Time, limit int64) (bool, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	err = pgTx.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM transaction_history
+		WHERE policy_id = $1
+		AND id <> $2
+		AND signed_type = $3
+		AND signed_at >= $4
+	`, policyID, txID, txType, since).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to count signed transactions: %w", err)
+	}
+	if count >= limit {
+		return false, nil
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE transaction_history
+		SET signed_at = NOW(), signed_type = $2
+		WHERE id = $1
+	`, txID, txType)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve transaction signing: %w", err)
+	}
+
+	return true, nil
+}
+
+func (p *PostgresBackend) ReleaseTransactionSigning(ctx context.Context, txID uuid.UUID) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		UPDATE transaction_history
+		SET signed_at = NULL, signed_type = NULL
+		WHERE id = $1
+	`, txID)
+	if err != nil {
+		return fmt.Errorf("failed to release transaction signing: %w", err)
+	}
+
+	return nil
+}
+
+// MarkTransactionSigned records on the plugin's side that the verifier signed
+// the transaction, so that both sides count the same signed transactions.
+func (p *PostgresBackend) MarkTransactionSigned(ctx context.Context, txID uuid.UUID, txType string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		UPDATE transaction_history
+		SET signed_at = NOW(), signed_type = $2
+		WHERE id = $1
+		AND signed_at IS NULL
+	`, txID, txType)
+	if err != nil {
+		return fmt.Errorf("failed to mark transaction signed: %w", err)
+	}
+
+	return nil
+}
+
+// CountSignedTransactions counts the transactions of a type the verifier
+// signed for the policy, leaving out the one with the given hash. Unlike
+// CountTransactions it doesn't rely on statuses synced from the plugin.
+func (p *PostgresBackend) CountSignedTransactions(ctx context.Context, policyID uuid.UUID, txType string, excludeTxHash string) (int64, error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is nil")
+	}
+
+	var count int64
+	err := p.pool.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM transaction_history
+		WHERE policy_id = $1
+		AND signed_type = $2
+		AND signed_at IS NOT NULL
+		AND tx_hash <> $3
+	`, policyID, txType, excludeTxHash).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count signed transactions: %w", err)
+	}
+	return count, nil
+}
+
+// SetTransactionAttestation stores the verifier's signed approval receipt of
+// the transaction.
+func (p *PostgresBackend) SetTransactionAttestation(ctx context.Context, txID uuid.UUID, attestation any) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		UPDATE transaction_history
+		SET attestation = $2
+		WHERE id = $1
+	`, txID, attestation)
+	if err != nil {
+		return fmt.Errorf("failed to set transaction attestation: %w", err)
+	}
+
+	return nil
+}
+
+// GetTransactionAttestation returns the latest attestation issued for the hash,
+// including those of archived transactions.
+func (p *PostgresBackend) GetTransactionAttestation(ctx context.Context, txHash string) (json.RawMessage, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	var attestation json.RawMessage
+	err := p.pool.QueryRow(ctx, `
+		SELECT attestation, created_at
+		FROM transaction_history
+		WHERE (tx_hash = $1 OR broadcast_hash = $1)
+		AND attestation IS NOT NULL
+		UNION ALL
+		SELECT attestation, created_at
+		FROM archived_attestations
+		WHERE tx_hash = $1 OR broadcast_hash = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, txHash).Scan(&attestation, new(time.Time))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction attestation: %w", mapNotFound(err))
+	}
+
+	return attestation, nil
+}
+
+func (p *PostgresBackend) BeginTx(ctx context.Context) (storage.Tx, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return postgresTx{Tx: tx}, nil
+}
+
+// postgresTx is the storage.Tx started by BeginTx. Its commit reports
+// aborted transactions with storage.ErrTxRolledBack.
+type postgresTx struct {
+	pgx.Tx
+}
+
+func (tx postgresTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	if errors.Is(err, pgx.ErrTxCommitRollback) {
+		return fmt.Errorf("%w: %w", storage.ErrTxRolledBack, err)
+	}
+	return err
+}
+
+// pgxTx returns the pgx transaction behind a storage.Tx started by BeginTx.
+func pgxTx(dbTx storage.Tx) (pgx.Tx, error) {
+	tx, ok := dbTx.(postgresTx)
+	if !ok {
+		return nil, fmt.Errorf("transaction of type %T was not started by the postgres backend", dbTx)
+	}
+	return tx.Tx, nil
+}
+
+// mapNotFound reports a missing row with storage.ErrNotFound, the error the
+// storage interface returns for it.
+func mapNotFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+	return err
 }
@@ -0,0 +1,238 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (p *PostgresBackend) CreateEventTriggerTx(ctx context.Context, dbTx storage.Tx, trigger types.EventTrigger) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO event_triggers
+		(policy_id, trigger_type, condition, cooldown, status)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = pgTx.Exec(ctx, query,
+		trigger.PolicyID,
+		trigger.TriggerType,
+		trigger.Condition,
+		trigger.Cooldown,
+		trigger.Status,
+	)
+
+	return err
+}
+
+// UpdateEventTriggerTx keeps the status and cooldown of the policy's trigger.
+// The trigger is inserted when the policy has none, as when it switches from
+// a schedule to an event trigger.
+func (p *PostgresBackend) UpdateEventTriggerTx(ctx context.Context, policyID string, trigger types.EventTrigger, dbTx storage.Tx) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	// the checkpoint is reset because the condition may watch another contract now
+	query := `
+		UPDATE event_triggers
+		SET trigger_type = $2,
+				condition = $3,
+				cooldown = $4,
+				last_checked_block = NULL
+		WHERE policy_id = $1
+	`
+	tag, err := pgTx.Exec(ctx, query,
+		policyID,
+		trigger.TriggerType,
+		trigger.Condition,
+		trigger.Cooldown,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	trigger.PolicyID = policyID
+	return p.CreateEventTriggerTx(ctx, dbTx, trigger)
+}
+
+func (p *PostgresBackend) DeleteEventTrigger(ctx context.Context, policyID string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	query := `DELETE FROM event_triggers WHERE policy_id = $1`
+	_, err := p.pool.Exec(ctx, query, policyID)
+
+	return err
+}
+
+func (p *PostgresBackend) DeleteEventTriggerTx(ctx context.Context, dbTx storage.Tx, policyID string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM event_triggers WHERE policy_id = $1`
+	_, err = pgTx.Exec(ctx, query, policyID)
+
+	return err
+}
+
+func (p *PostgresBackend) GetPendingEventTriggers(ctx context.Context) ([]types.EventTrigger, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		SELECT t.policy_id, t.trigger_type, t.condition, t.cooldown, t.last_checked_block, t.last_triggered, t.status
+		FROM event_triggers t
+		INNER JOIN plugin_policies p ON t.policy_id = p.id
+		WHERE p.active = true
+		AND p.status = 'ACTIVE'
+		AND t.status = 'PENDING'
+		ORDER BY t.id ASC
+	`
+
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var triggers []types.EventTrigger
+	for rows.Next() {
+		var t types.EventTrigger
+		var lastCheckedBlock *int64
+		err := rows.Scan(
+			&t.PolicyID,
+			&t.TriggerType,
+			&t.Condition,
+			&t.Cooldown,
+			&lastCheckedBlock,
+			&t.LastTriggered,
+			&t.Status)
+		if err != nil {
+			return nil, err
+		}
+		if lastCheckedBlock != nil {
+			block := uint64(*lastCheckedBlock)
+			t.LastCheckedBlock = &block
+		}
+		triggers = append(triggers, t)
+	}
+
+	return triggers, nil
+}
+
+func (p *PostgresBackend) GetEventTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error) {
+	if p.pool == nil {
+		return "", fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		SELECT status
+		FROM event_triggers
+		WHERE policy_id = $1
+	`
+
+	var status types.TimeTriggerStatus
+	err := p.pool.QueryRow(ctx, query, policyID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("event trigger not found for policy_id: %s: %w", policyID, storage.ErrNotFound)
+		}
+		return "", err
+	}
+
+	return status, nil
+}
+
+func (p *PostgresBackend) UpdateEventTriggerStatus(ctx context.Context, policyID string, status types.TimeTriggerStatus) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		UPDATE event_triggers
+		SET status = $2
+		WHERE policy_id = $1
+	`
+
+	_, err := p.pool.Exec(ctx, query, policyID, status)
+	return err
+}
+
+func (p *PostgresBackend) ClaimEventTrigger(ctx context.Context, policyID string) (bool, error) {
+	if p.pool == nil {
+		return false, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		UPDATE event_triggers
+		SET status = $2
+		WHERE policy_id = $1 AND status = $3
+	`
+
+	tag, err := p.pool.Exec(ctx, query, policyID, types.StatusTimeTriggerRunning, types.StatusTimeTriggerPending)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresBackend) UpdateEventTriggerLastTriggered(ctx context.Context, policyID string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		UPDATE event_triggers
+		SET last_triggered = $2
+		WHERE policy_id = $1
+	`
+
+	_, err := p.pool.Exec(ctx, query, policyID, time.Now().UTC())
+	return err
+}
+
+func (p *PostgresBackend) UpdateEventTriggerCheckpoint(ctx context.Context, policyID string, block uint64) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		UPDATE event_triggers
+		SET last_checked_block = $2
+		WHERE policy_id = $1
+	`
+
+	_, err := p.pool.Exec(ctx, query, policyID, int64(block))
+	return err
+}
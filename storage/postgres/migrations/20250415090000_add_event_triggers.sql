@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE event_trigger_type AS ENUM (
+    'PRICE',
+    'BALANCE',
+    'CONTRACT_EVENT'
+);
+
+CREATE TABLE event_triggers (
+    id SERIAL PRIMARY KEY,
+    policy_id UUID NOT NULL UNIQUE REFERENCES plugin_policies(id),
+    trigger_type event_trigger_type NOT NULL,
+    condition JSONB NOT NULL,
+    cooldown INTEGER NOT NULL DEFAULT 0,
+    last_checked_block BIGINT,
+    last_triggered TIMESTAMP,
+    status trigger_status NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_event_triggers_status ON event_triggers(status);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS event_triggers;
+DROP TYPE IF EXISTS event_trigger_type;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE policy_run_outcome AS ENUM (
+    'RUNNING',
+    'SUCCEEDED',
+    'FAILED',
+    'SKIPPED'
+);
+
+CREATE TABLE policy_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    policy_id UUID NOT NULL REFERENCES plugin_policies(id),
+    trigger_source TEXT NOT NULL,
+    triggered_at TIMESTAMP NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    outcome policy_run_outcome NOT NULL,
+    error_message TEXT,
+    transaction_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_policy_runs_policy_id_started_at ON policy_runs(policy_id, started_at DESC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS policy_runs;
+DROP TYPE IF EXISTS policy_run_outcome;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- signed_at and signed_type are written by the verifier only, sync updates from the plugin never touch them
+ALTER TABLE transaction_history ADD COLUMN signed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE transaction_history ADD COLUMN signed_type TEXT;
+CREATE INDEX idx_transaction_history_policy_id_signed_at ON transaction_history(policy_id, signed_at);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_history_policy_id_signed_at;
+ALTER TABLE transaction_history DROP COLUMN IF EXISTS signed_type;
+ALTER TABLE transaction_history DROP COLUMN IF EXISTS signed_at;
+-- +goose StatementEnd
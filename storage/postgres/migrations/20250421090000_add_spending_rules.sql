@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE spending_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- empty public_key and plugin_type apply the rule to every vault and plugin
+    public_key TEXT NOT NULL DEFAULT '',
+    plugin_type TEXT NOT NULL DEFAULT '',
+    chain_id TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL DEFAULT '',
+    -- amounts are decimal strings in base units of token, empty means unlimited
+    max_amount_per_tx TEXT NOT NULL DEFAULT '',
+    max_amount_per_day TEXT NOT NULL DEFAULT '',
+    max_amount_per_recipient_per_day TEXT NOT NULL DEFAULT '',
+    max_transactions_per_day BIGINT NOT NULL DEFAULT 0,
+    allowed_tokens TEXT[] NOT NULL DEFAULT '{}',
+    allowed_contracts TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_spending_rules_public_key ON spending_rules(public_key);
+
+CREATE TABLE rule_decisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    policy_id UUID NOT NULL,
+    tx_hash TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    plugin_type TEXT NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    rule_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_rule_decisions_public_key ON rule_decisions(public_key, created_at DESC);
+CREATE INDEX idx_rule_decisions_tx_hash ON rule_decisions(tx_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rule_decisions;
+DROP TABLE IF EXISTS spending_rules;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE plugin_policies ADD COLUMN signature_nonce TEXT NOT NULL DEFAULT '';
+ALTER TABLE plugin_policies ADD COLUMN signature_expiry BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS policy_signature_nonces (
+    public_key TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (public_key, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_signature_nonces_expires_at ON policy_signature_nonces(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS policy_signature_nonces;
+ALTER TABLE plugin_policies DROP COLUMN IF EXISTS signature_expiry;
+ALTER TABLE plugin_policies DROP COLUMN IF EXISTS signature_nonce;
+-- +goose StatementEnd
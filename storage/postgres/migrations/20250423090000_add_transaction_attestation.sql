@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transaction_history ADD COLUMN attestation JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transaction_history DROP COLUMN IF EXISTS attestation;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_plugin_policies_public_key ON plugin_policies(public_key);
+CREATE INDEX idx_transaction_history_policy_id_created_at ON transaction_history(policy_id, created_at DESC, id DESC);
+CREATE INDEX idx_transaction_history_chain_id_token ON transaction_history((metadata->>'chain_id'), (metadata->>'token'));
+CREATE INDEX idx_transaction_history_transaction_type ON transaction_history((metadata->>'transaction_type'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_history_transaction_type;
+DROP INDEX IF EXISTS idx_transaction_history_chain_id_token;
+DROP INDEX IF EXISTS idx_transaction_history_policy_id_created_at;
+DROP INDEX IF EXISTS idx_plugin_policies_public_key;
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transaction_history
+    ADD COLUMN tx_type TEXT,
+    ADD COLUMN chain_id TEXT,
+    ADD COLUMN from_address TEXT,
+    ADD COLUMN to_address TEXT,
+    ADD COLUMN token TEXT,
+    ADD COLUMN amount NUMERIC(78, 0),
+    ADD COLUMN nonce BIGINT,
+    ADD COLUMN gas_limit BIGINT,
+    ADD COLUMN gas_price NUMERIC(78, 0),
+    ADD COLUMN gas_fee NUMERIC(78, 0),
+    ADD COLUMN signing_task_id TEXT,
+    ADD COLUMN block_number BIGINT,
+    ADD COLUMN error_code TEXT;
+
+-- move the facts kept in metadata to their columns
+UPDATE transaction_history SET
+    tx_type = metadata->>'transaction_type',
+    chain_id = metadata->>'chain_id',
+    token = metadata->>'token',
+    amount = CASE WHEN metadata->>'amount' ~ '^[0-9]+$' THEN (metadata->>'amount')::NUMERIC END,
+    gas_fee = CASE WHEN metadata->>'gas_fee' ~ '^[0-9]+$' THEN (metadata->>'gas_fee')::NUMERIC END,
+    signing_task_id = metadata->>'task_id',
+    error_message = COALESCE(error_message, metadata->>'error'),
+    metadata = metadata - ARRAY['transaction_type', 'chain_id', 'token', 'amount', 'gas_fee', 'task_id', 'error']
+WHERE metadata IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_transaction_history_chain_id_token;
+DROP INDEX IF EXISTS idx_transaction_history_transaction_type;
+CREATE INDEX idx_transaction_history_chain_id_token ON transaction_history(chain_id, token);
+CREATE INDEX idx_transaction_history_policy_id_tx_type ON transaction_history(policy_id, tx_type, status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_history_policy_id_tx_type;
+DROP INDEX IF EXISTS idx_transaction_history_chain_id_token;
+
+UPDATE transaction_history SET
+    metadata = COALESCE(metadata, '{}'::JSONB) || jsonb_strip_nulls(jsonb_build_object(
+        'transaction_type', tx_type,
+        'chain_id', chain_id,
+        'token', token,
+        'amount', amount::TEXT,
+        'gas_fee', gas_fee::TEXT,
+        'task_id', signing_task_id,
+        'error', error_message
+    ));
+
+ALTER TABLE transaction_history
+    DROP COLUMN error_code,
+    DROP COLUMN block_number,
+    DROP COLUMN signing_task_id,
+    DROP COLUMN gas_fee,
+    DROP COLUMN gas_price,
+    DROP COLUMN gas_limit,
+    DROP COLUMN nonce,
+    DROP COLUMN amount,
+    DROP COLUMN token,
+    DROP COLUMN to_address,
+    DROP COLUMN from_address,
+    DROP COLUMN chain_id,
+    DROP COLUMN tx_type;
+
+CREATE INDEX idx_transaction_history_chain_id_token ON transaction_history((metadata->>'chain_id'), (metadata->>'token'));
+CREATE INDEX idx_transaction_history_transaction_type ON transaction_history((metadata->>'transaction_type'));
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transaction_history ADD COLUMN broadcast_hash TEXT;
+
+-- a signing hash can be proposed again once its earlier attempts failed
+ALTER TABLE transaction_history DROP CONSTRAINT unique_tx_hash;
+CREATE UNIQUE INDEX idx_transaction_history_active_tx_hash ON transaction_history(tx_hash)
+    WHERE status NOT IN ('SIGNING_FAILED', 'REJECTED');
+CREATE UNIQUE INDEX idx_transaction_history_broadcast_hash ON transaction_history(broadcast_hash)
+    WHERE broadcast_hash IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_history_broadcast_hash;
+DROP INDEX IF EXISTS idx_transaction_history_active_tx_hash;
+
+-- keep the latest attempt of each signing hash
+DELETE FROM transaction_history t
+USING transaction_history newer
+WHERE t.tx_hash = newer.tx_hash
+AND (t.created_at, t.id) < (newer.created_at, newer.id);
+ALTER TABLE transaction_history
+    ADD CONSTRAINT unique_tx_hash UNIQUE (tx_hash);
+
+ALTER TABLE transaction_history DROP COLUMN broadcast_hash;
+-- +goose StatementEnd
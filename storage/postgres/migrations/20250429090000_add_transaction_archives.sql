@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transaction_archives (
+    id UUID PRIMARY KEY,
+    object_name TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    first_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- archived transactions are deleted, their policies may be gone too
+CREATE TABLE transaction_aggregates (
+    policy_id UUID NOT NULL,
+    day DATE NOT NULL,
+    chain_id TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL DEFAULT '',
+    tx_type TEXT NOT NULL DEFAULT '',
+    status transaction_status NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    amount NUMERIC NOT NULL DEFAULT 0,
+    gas_fee NUMERIC NOT NULL DEFAULT 0,
+    PRIMARY KEY (policy_id, day, chain_id, token, tx_type, status)
+);
+
+CREATE INDEX idx_transaction_history_retention ON transaction_history (updated_at) WHERE status IN ('MINED', 'SIGNING_FAILED', 'REJECTED');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transaction_history_retention;
+DROP TABLE IF EXISTS transaction_aggregates;
+DROP TABLE IF EXISTS transaction_archives;
+-- +goose StatementEnd
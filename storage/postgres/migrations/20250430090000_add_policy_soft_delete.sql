@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE plugin_policies
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'ACTIVE',
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN deletion_signature TEXT,
+    ADD COLUMN deletion_nonce TEXT,
+    ADD COLUMN deletion_expiry BIGINT;
+
+ALTER TABLE plugin_policies
+    ADD CONSTRAINT plugin_policies_status_check CHECK (status IN ('ACTIVE', 'DELETED'));
+
+CREATE INDEX idx_plugin_policies_status ON plugin_policies(plugin_type, status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_plugin_policies_status;
+
+ALTER TABLE plugin_policies
+    DROP CONSTRAINT IF EXISTS plugin_policies_status_check,
+    DROP COLUMN IF EXISTS deletion_expiry,
+    DROP COLUMN IF EXISTS deletion_nonce,
+    DROP COLUMN IF EXISTS deletion_signature,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- attestations outlive the archived transactions they were issued for
+CREATE TABLE archived_attestations (
+    transaction_id UUID PRIMARY KEY,
+    policy_id UUID NOT NULL,
+    tx_hash TEXT NOT NULL,
+    broadcast_hash TEXT,
+    attestation JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_archived_attestations_tx_hash ON archived_attestations (tx_hash);
+CREATE INDEX idx_archived_attestations_broadcast_hash ON archived_attestations (broadcast_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS archived_attestations;
+-- +goose StatementEnd
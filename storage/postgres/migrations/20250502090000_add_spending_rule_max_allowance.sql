@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- approvals only count against max_allowance, in base units of token like the
+-- other amounts, empty means unlimited
+ALTER TABLE spending_rules ADD COLUMN max_allowance TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE spending_rules DROP COLUMN IF EXISTS max_allowance;
+-- +goose StatementEnd
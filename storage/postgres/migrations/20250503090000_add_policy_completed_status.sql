@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE plugin_policies
+    DROP CONSTRAINT IF EXISTS plugin_policies_status_check,
+    ADD CONSTRAINT plugin_policies_status_check CHECK (status IN ('ACTIVE', 'COMPLETED', 'DELETED'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE plugin_policies SET status = 'ACTIVE', active = false WHERE status = 'COMPLETED';
+
+ALTER TABLE plugin_policies
+    DROP CONSTRAINT IF EXISTS plugin_policies_status_check,
+    ADD CONSTRAINT plugin_policies_status_check CHECK (status IN ('ACTIVE', 'DELETED'));
+-- +goose StatementEnd
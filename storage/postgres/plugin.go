@@ -15,6 +15,8 @@ import (
 
 const PLUGINS_TABLE = "plugins"
 
+// FindPluginById reads from the primary, plugin requests and tokens are
+// authenticated against the plugin's keys, which must not lag behind.
 func (p *PostgresBackend) FindPluginById(ctx context.Context, id string) (*types.Plugin, error) {
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 LIMIT 1;`, PLUGINS_TABLE)
 
@@ -25,7 +27,7 @@ func (p *PostgresBackend) FindPluginById(ctx context.Context, id string) (*types
 
 	plugin, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[types.Plugin])
 	if err != nil {
-		return nil, err
+		return nil, mapNotFound(err)
 	}
 
 	return &plugin, nil
@@ -44,7 +46,7 @@ func (p *PostgresBackend) FindPlugins(ctx context.Context, skip int, take int, s
 		ORDER BY %s %s
 		LIMIT $1 OFFSET $2`, PLUGINS_TABLE, orderBy, orderDirection)
 
-	rows, err := p.pool.Query(ctx, query, take, skip)
+	rows, err := p.readPool().Query(ctx, query, take, skip)
 	if err != nil {
 		return types.PlugisDto{}, err
 	}
@@ -67,6 +69,7 @@ func (p *PostgresBackend) FindPlugins(ctx context.Context, skip int, take int, s
 			&plugin.Metadata,
 			&plugin.ServerEndpoint,
 			&plugin.PricingID,
+			&plugin.PublicKey,
 			&totalCount,
 		)
 		if err != nil {
@@ -91,14 +94,16 @@ func (p *PostgresBackend) CreatePlugin(ctx context.Context, pluginDto types.Plug
 		description,
 		metadata,
 		server_endpoint,
-		pricing_id
+		pricing_id,
+		public_key
 	) VALUES (
 		@Type,
 		@Title,
 		@Description,
 		@Metadata,
 		@ServerEndpoint,
-		@PricingID
+		@PricingID,
+		@PublicKey
 	) RETURNING id;`, PLUGINS_TABLE)
 	args := pgx.NamedArgs{
 		"Type":           pluginDto.Type,
@@ -107,6 +112,7 @@ func (p *PostgresBackend) CreatePlugin(ctx context.Context, pluginDto types.Plug
 		"Metadata":       pluginDto.Metadata,
 		"ServerEndpoint": pluginDto.ServerEndpoint,
 		"PricingID":      pluginDto.PricingID,
+		"PublicKey":      pluginDto.PublicKey,
 	}
 
 	var createdId string
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (p *PostgresBackend) CreatePluginToken(ctx context.Context, token types.PluginToken) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO plugin_tokens (id, plugin_id, scopes, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, token.ID, token.PluginID, token.Scopes, token.IssuedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create plugin token: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) GetPluginToken(ctx context.Context, id string) (*types.PluginToken, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	var token types.PluginToken
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, plugin_id, scopes, issued_at, expires_at, revoked_at
+		FROM plugin_tokens
+		WHERE id = $1
+	`, id).Scan(&token.ID, &token.PluginID, &token.Scopes, &token.IssuedAt, &token.ExpiresAt, &token.RevokedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin token: %w", mapNotFound(err))
+	}
+
+	return &token, nil
+}
+
+func (p *PostgresBackend) GetPluginTokens(ctx context.Context, pluginID string) ([]types.PluginToken, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, plugin_id, scopes, issued_at, expires_at, revoked_at
+		FROM plugin_tokens
+		WHERE plugin_id = $1
+		AND expires_at > NOW()
+		ORDER BY issued_at DESC
+	`, pluginID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []types.PluginToken
+	for rows.Next() {
+		var token types.PluginToken
+		err := rows.Scan(&token.ID, &token.PluginID, &token.Scopes, &token.IssuedAt, &token.ExpiresAt, &token.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan plugin token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// RevokePluginTokens revokes a single token of the plugin, or all of its
+// tokens when id is empty.
+func (p *PostgresBackend) RevokePluginTokens(ctx context.Context, pluginID string, id string) (int64, error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is nil")
+	}
+
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE plugin_tokens
+		SET revoked_at = NOW()
+		WHERE plugin_id = $1
+		AND ($2 = '' OR id::text = $2)
+		AND revoked_at IS NULL
+	`, pluginID, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke plugin tokens: %w", err)
+	}
+	if id != "" && tag.RowsAffected() == 0 {
+		return 0, fmt.Errorf("failed to revoke plugin token: %w", storage.ErrNotFound)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -9,22 +9,19 @@ import (
 	"github.com/jackc/pgx/v5"
 
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
 )
 
-func (p *PostgresBackend) GetPluginPolicy(ctx context.Context, id string) (types.PluginPolicy, error) {
-	if p.pool == nil {
-		return types.PluginPolicy{}, fmt.Errorf("database pool is nil")
-	}
+// policyColumns are the columns of plugin_policies read by scanPolicy.
+const policyColumns = `id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type,
+	signature, signature_nonce, signature_expiry, active, policy, status, deleted_at, deletion_signature, deletion_nonce, deletion_expiry`
 
+func scanPolicy(row pgx.Row) (types.PluginPolicy, error) {
 	var policy types.PluginPolicy
 	var policyJSON []byte
-
-	query := `
-        SELECT id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, active, policy 
-        FROM plugin_policies 
-        WHERE id = $1`
-
-	err := p.pool.QueryRow(ctx, query, id).Scan(
+	var deletionSignature, deletionNonce *string
+	var deletionExpiry *int64
+	err := row.Scan(
 		&policy.ID,
 		&policy.PublicKey,
 		&policy.IsEcdsa,
@@ -35,14 +32,69 @@ func (p *PostgresBackend) GetPluginPolicy(ctx context.Context, id string) (types
 		&policy.PolicyVersion,
 		&policy.PluginType,
 		&policy.Signature,
+		&policy.Nonce,
+		&policy.Expiry,
 		&policy.Active,
 		&policyJSON,
+		&policy.Status,
+		&policy.DeletedAt,
+		&deletionSignature,
+		&deletionNonce,
+		&deletionExpiry,
 	)
-
 	if err != nil {
-		return types.PluginPolicy{}, fmt.Errorf("failed to get policy: %w", err)
+		return types.PluginPolicy{}, err
 	}
 	policy.Policy = json.RawMessage(policyJSON)
+	if deletionSignature != nil {
+		policy.Deletion = &types.PolicyDeleteRequest{Signature: *deletionSignature}
+		if deletionNonce != nil {
+			policy.Deletion.Nonce = *deletionNonce
+		}
+		if deletionExpiry != nil {
+			policy.Deletion.Expiry = *deletionExpiry
+		}
+	}
+	return policy, nil
+}
+
+// GetPluginPolicy returns the policy, soft-deleted or not.
+func (p *PostgresBackend) GetPluginPolicy(ctx context.Context, id string) (types.PluginPolicy, error) {
+	if p.pool == nil {
+		return types.PluginPolicy{}, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+        SELECT ` + policyColumns + `
+        FROM plugin_policies
+        WHERE id = $1`
+
+	policy, err := scanPolicy(p.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return types.PluginPolicy{}, fmt.Errorf("failed to get policy: %w", mapNotFound(err))
+	}
+
+	return policy, nil
+}
+
+// GetPluginPolicyTx returns the policy, soft-deleted or not, and locks its row
+// for the rest of the transaction.
+func (p *PostgresBackend) GetPluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string) (types.PluginPolicy, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return types.PluginPolicy{}, err
+	}
+
+	query := `
+        SELECT ` + policyColumns + `
+        FROM plugin_policies
+        WHERE id = $1
+        FOR UPDATE`
+
+	policy, err := scanPolicy(pgTx.QueryRow(ctx, query, id))
+	if err != nil {
+		return types.PluginPolicy{}, fmt.Errorf("failed to get policy: %w", mapNotFound(err))
+	}
 
 	return policy, nil
 }
@@ -53,33 +105,20 @@ func (p *PostgresBackend) GetAllPluginPolicies(ctx context.Context, publicKey st
 	}
 
 	query := `
-  	SELECT id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, active, policy 
+  	SELECT ` + policyColumns + `
 		FROM plugin_policies
 		WHERE public_key = $1
-		AND plugin_type = $2`
+		AND plugin_type = $2
+		AND status <> 'DELETED'`
 
-	rows, err := p.pool.Query(ctx, query, publicKey, pluginType)
+	rows, err := p.readPool().Query(ctx, query, publicKey, pluginType)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var policies []types.PluginPolicy
 	for rows.Next() {
-		var policy types.PluginPolicy
-		err := rows.Scan(
-			&policy.ID,
-			&policy.PublicKey,
-			&policy.IsEcdsa,
-			&policy.ChainCodeHex,
-			&policy.DerivePath,
-			&policy.PluginID,
-			&policy.PluginVersion,
-			&policy.PolicyVersion,
-			&policy.PluginType,
-			&policy.Signature,
-			&policy.Active,
-			&policy.Policy,
-		)
+		policy, err := scanPolicy(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +128,12 @@ func (p *PostgresBackend) GetAllPluginPolicies(ctx context.Context, publicKey st
 	return policies, nil
 }
 
-func (p *PostgresBackend) InsertPluginPolicyTx(ctx context.Context, dbTx pgx.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+func (p *PostgresBackend) InsertPluginPolicyTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
 	policyJSON, err := json.Marshal(policy.Policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal policy: %w", err)
@@ -97,13 +141,11 @@ func (p *PostgresBackend) InsertPluginPolicyTx(ctx context.Context, dbTx pgx.Tx,
 
 	query := `
   	INSERT INTO plugin_policies (
-      id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, active, policy
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-    RETURNING id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, active, policy
-	`
+      id, public_key, is_ecdsa, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, signature_nonce, signature_expiry, active, policy
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    RETURNING ` + policyColumns
 
-	var insertedPolicy types.PluginPolicy
-	err = dbTx.QueryRow(ctx, query,
+	insertedPolicy, err := scanPolicy(pgTx.QueryRow(ctx, query,
 		policy.ID,
 		policy.PublicKey,
 		policy.IsEcdsa,
@@ -114,22 +156,11 @@ func (p *PostgresBackend) InsertPluginPolicyTx(ctx context.Context, dbTx pgx.Tx,
 		policy.PolicyVersion,
 		policy.PluginType,
 		policy.Signature,
+		policy.Nonce,
+		policy.Expiry,
 		policy.Active,
 		policyJSON,
-	).Scan(
-		&insertedPolicy.ID,
-		&insertedPolicy.PublicKey,
-		&insertedPolicy.IsEcdsa,
-		&insertedPolicy.ChainCodeHex,
-		&insertedPolicy.DerivePath,
-		&insertedPolicy.PluginID,
-		&insertedPolicy.PluginVersion,
-		&insertedPolicy.PolicyVersion,
-		&insertedPolicy.PluginType,
-		&insertedPolicy.Signature,
-		&insertedPolicy.Active,
-		&insertedPolicy.Policy,
-	)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert policy: %w", err)
 	}
@@ -137,46 +168,59 @@ func (p *PostgresBackend) InsertPluginPolicyTx(ctx context.Context, dbTx pgx.Tx,
 	return &insertedPolicy, nil
 }
 
-func (p *PostgresBackend) UpdatePluginPolicyTx(ctx context.Context, dbTx pgx.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+// UpdatePluginPolicyTx updates the signed fields of the policy. The vault and
+// the plugin it belongs to can't change, a policy of another vault or plugin
+// is not found, nor are deleted policies. A completed policy stays completed
+// unless the update sets its status to active.
+func (p *PostgresBackend) UpdatePluginPolicyTx(ctx context.Context, dbTx storage.Tx, policy types.PluginPolicy) (*types.PluginPolicy, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
 	policyJSON, err := json.Marshal(policy.Policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal policy: %w", err)
 	}
 
-	// TODO: update other fields
 	query := `
-		UPDATE plugin_policies 
-		SET public_key = $2, 
-				plugin_type = $3, 
-				signature = $4,
-				active = $5,
-				policy = $6
+		UPDATE plugin_policies
+		SET plugin_type = $4,
+				chain_code_hex = $5,
+				derive_path = $6,
+				plugin_version = $7,
+				policy_version = $8,
+				signature = $9,
+				signature_nonce = $10,
+				signature_expiry = $11,
+				active = $12,
+				policy = $13,
+				status = CASE WHEN $14 THEN 'ACTIVE' ELSE status END
 		WHERE id = $1
-		RETURNING id, public_key, plugin_id, plugin_version, policy_version, plugin_type, signature, active, policy
-	`
+		AND public_key = $2
+		AND plugin_id = $3
+		AND status <> 'DELETED'
+		RETURNING ` + policyColumns
 
-	var updatedPolicy types.PluginPolicy
-	err = dbTx.QueryRow(ctx, query,
+	updatedPolicy, err := scanPolicy(pgTx.QueryRow(ctx, query,
 		policy.ID,
 		policy.PublicKey,
+		policy.PluginID,
 		policy.PluginType,
+		policy.ChainCodeHex,
+		policy.DerivePath,
+		policy.PluginVersion,
+		policy.PolicyVersion,
 		policy.Signature,
+		policy.Nonce,
+		policy.Expiry,
 		policy.Active,
 		policyJSON,
-	).Scan(
-		&updatedPolicy.ID,
-		&updatedPolicy.PublicKey,
-		&updatedPolicy.PluginID,
-		&updatedPolicy.PluginVersion,
-		&updatedPolicy.PolicyVersion,
-		&updatedPolicy.PluginType,
-		&updatedPolicy.Signature,
-		&updatedPolicy.Active,
-		&updatedPolicy.Policy,
-	)
+		policy.Status == types.PolicyStatusActive,
+	))
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, fmt.Errorf("policy not found with ID: %s", policy.ID)
+		return nil, fmt.Errorf("policy not found with ID: %s: %w", policy.ID, storage.ErrNotFound)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update policy: %w", err)
@@ -185,28 +229,96 @@ func (p *PostgresBackend) UpdatePluginPolicyTx(ctx context.Context, dbTx pgx.Tx,
 	return &updatedPolicy, nil
 }
 
-func (p *PostgresBackend) DeletePluginPolicyTx(ctx context.Context, dbTx pgx.Tx, id string) error {
-	_, err := dbTx.Exec(ctx, `
-	DELETE FROM transaction_history
-	WHERE policy_id = $1
+// CompletePluginPolicyTx marks an active policy as completed. Completed and
+// deleted policies are left as they are.
+func (p *PostgresBackend) CompletePluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+	UPDATE plugin_policies
+	SET status = 'COMPLETED'
+	WHERE id = $1
+	AND status = 'ACTIVE'
 	`, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete transaction history: %w", err)
+		return fmt.Errorf("failed to complete policy: %w", err)
 	}
-	_, err = dbTx.Exec(ctx, `
+
+	return nil
+}
+
+// DeletePluginPolicyTx soft-deletes the policy and records the vault's deletion
+// signature. Its triggers are removed, its transactions and runs are kept.
+func (p *PostgresBackend) DeletePluginPolicyTx(ctx context.Context, dbTx storage.Tx, id string, deletion types.PolicyDeleteRequest) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := pgTx.Exec(ctx, `
+	UPDATE plugin_policies
+	SET status = 'DELETED',
+		active = false,
+		deleted_at = NOW(),
+		deletion_signature = $2,
+		deletion_nonce = $3,
+		deletion_expiry = $4
+	WHERE id = $1
+	AND status <> 'DELETED'
+	`, id, deletion.Signature, deletion.Nonce, deletion.Expiry)
+	if err != nil {
+		return fmt.Errorf("failed to delete policy: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete policy: %w", storage.ErrNotFound)
+	}
+	_, err = pgTx.Exec(ctx, `
 	DELETE FROM time_triggers
 	WHERE policy_id = $1
 	`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete time triggers: %w", err)
 	}
-	_, err = dbTx.Exec(ctx, `
-	DELETE FROM plugin_policies
-	WHERE id = $1
+	_, err = pgTx.Exec(ctx, `
+	DELETE FROM event_triggers
+	WHERE policy_id = $1
 	`, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete policy: %w", err)
+		return fmt.Errorf("failed to delete event triggers: %w", err)
 	}
 
 	return nil
 }
+
+func (p *PostgresBackend) GetPluginPoliciesByType(ctx context.Context, pluginType string) ([]types.PluginPolicy, error) {
+	if p.pool == nil {
+		return []types.PluginPolicy{}, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+  	SELECT ` + policyColumns + `
+		FROM plugin_policies
+		WHERE plugin_type = $1
+		AND status <> 'DELETED'
+		ORDER BY id`
+
+	rows, err := p.pool.Query(ctx, query, pluginType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []types.PluginPolicy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
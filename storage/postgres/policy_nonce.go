@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// ConsumePolicyNonceTx records the nonce of a policy signature of the vault. It
+// returns false when the nonce was already used.
+func (p *PostgresBackend) ConsumePolicyNonceTx(ctx context.Context, dbTx storage.Tx, publicKey, nonce string, expiresAt time.Time) (bool, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return false, err
+	}
+
+	tag, err := pgTx.Exec(ctx, `
+		INSERT INTO policy_signature_nonces (public_key, nonce, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (public_key, nonce) DO NOTHING
+	`, publicKey, nonce, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume policy nonce: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
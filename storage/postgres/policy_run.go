@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+func (p *PostgresBackend) CreatePolicyRun(ctx context.Context, run types.PolicyRun) (uuid.UUID, error) {
+	if p.pool == nil {
+		return uuid.Nil, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		INSERT INTO policy_runs (policy_id, trigger_source, triggered_at, started_at, outcome)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	var runID uuid.UUID
+	err := p.pool.QueryRow(ctx, query,
+		run.PolicyID,
+		run.TriggerSource,
+		run.TriggeredAt,
+		run.StartedAt,
+		run.Outcome,
+	).Scan(&runID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create policy run: %w", err)
+	}
+
+	return runID, nil
+}
+
+func (p *PostgresBackend) FinishPolicyRun(ctx context.Context, runID uuid.UUID, outcome types.PolicyRunOutcome, errorMessage *string, transactionIDs []uuid.UUID) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	if transactionIDs == nil {
+		transactionIDs = []uuid.UUID{}
+	}
+
+	query := `
+		UPDATE policy_runs
+		SET finished_at = $2,
+				outcome = $3,
+				error_message = $4,
+				transaction_ids = $5
+		WHERE id = $1
+	`
+
+	_, err := p.pool.Exec(ctx, query, runID, time.Now().UTC(), outcome, errorMessage, transactionIDs)
+	if err != nil {
+		return fmt.Errorf("failed to finish policy run: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) GetPolicyRuns(ctx context.Context, policyID uuid.UUID, take int, skip int) ([]types.PolicyRun, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		SELECT id, policy_id, trigger_source, triggered_at, started_at, finished_at,
+			(EXTRACT(EPOCH FROM (finished_at - started_at)) * 1000)::BIGINT,
+			outcome, error_message, transaction_ids
+		FROM policy_runs
+		WHERE policy_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := p.readPool().Query(ctx, query, policyID, take, skip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []types.PolicyRun{}
+	for rows.Next() {
+		var run types.PolicyRun
+		err := rows.Scan(
+			&run.ID,
+			&run.PolicyID,
+			&run.TriggerSource,
+			&run.TriggeredAt,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.DurationMs,
+			&run.Outcome,
+			&run.ErrorMessage,
+			&run.TransactionIDs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan policy run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// testDSNEnv names the database the integration tests run against, they are
+// skipped when it isn't set.
+const testDSNEnv = "POSTGRES_TEST_DSN"
+
+// newTestBackend migrates a fresh schema up to version, all migrations when
+// version is 0, and returns a backend whose sessions use it.
+func newTestBackend(t *testing.T, version int64) *PostgresBackend {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		assert.NoError(t, err)
+	})
+
+	config, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	backend := &PostgresBackend{pool: pool}
+	if version == 0 {
+		require.NoError(t, backend.Migrate())
+	} else {
+		migrateTo(t, backend, version)
+	}
+	return backend
+}
+
+func migrateTo(t *testing.T, backend *PostgresBackend, version int64) {
+	t.Helper()
+
+	goose.SetBaseFS(embeddedMigrations)
+	require.NoError(t, goose.SetDialect("postgres"))
+	require.NoError(t, goose.UpTo(stdlib.OpenDBFromPool(backend.pool), "migrations", version))
+}
+
+func insertTestPolicy(t *testing.T, ctx context.Context, backend *PostgresBackend, publicKey string) uuid.UUID {
+	t.Helper()
+
+	policy := types.PluginPolicy{
+		ID:            uuid.NewString(),
+		PublicKey:     publicKey,
+		ChainCodeHex:  "chaincode",
+		DerivePath:    "m/44'/60'/0'/0/0",
+		PluginID:      "plugin",
+		PluginVersion: "1",
+		PolicyVersion: "1",
+		PluginType:    "dca",
+		Signature:     "0xsig",
+		Policy:        json.RawMessage(`{}`),
+		Active:        true,
+	}
+	dbTx, err := backend.BeginTx(ctx)
+	require.NoError(t, err)
+	_, err = backend.InsertPluginPolicyTx(ctx, dbTx, policy)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+	return uuid.MustParse(policy.ID)
+}
+
+func TestActiveTransactionHashIndex(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestBackend(t, 0)
+	policyID := insertTestPolicy(t, ctx, backend, "vault")
+
+	tx := types.TransactionHistory{
+		PolicyID: policyID,
+		TxBody:   "body",
+		TxHash:   "sighash",
+		Status:   types.StatusPending,
+		TxType:   "SWAP",
+	}
+	failedID, err := backend.CreateTransactionHistory(ctx, tx)
+	require.NoError(t, err)
+
+	// an active signing hash can't be proposed twice
+	_, err = backend.CreateTransactionHistory(ctx, tx)
+	require.Error(t, err)
+
+	// the upsert infers the partial index and retries the active attempt
+	dbTx, err := backend.BeginTx(ctx)
+	require.NoError(t, err)
+	upsertedID, err := backend.CreateTransactionHistoryTx(ctx, dbTx, tx)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.Commit(ctx))
+	assert.Equal(t, failedID, upsertedID)
+
+	// once failed, the signing hash can be proposed again
+	require.NoError(t, backend.UpdateTransactionStatus(ctx, failedID, types.TransactionUpdate{Status: types.StatusSigningFailed}))
+	txID, err := backend.CreateTransactionHistory(ctx, tx)
+	require.NoError(t, err)
+	assert.NotEqual(t, failedID, txID)
+
+	require.NoError(t, backend.UpdateTransactionStatus(ctx, txID, types.TransactionUpdate{
+		Status:        types.StatusSigned,
+		BroadcastHash: "0xonchain",
+	}))
+	stored, err := backend.GetTransactionByHash(ctx, "sighash")
+	require.NoError(t, err)
+	assert.Equal(t, txID, stored.ID)
+
+	// broadcast hashes are unique whatever the status
+	err = backend.UpdateTransactionStatus(ctx, failedID, types.TransactionUpdate{
+		Status:        types.StatusSigningFailed,
+		BroadcastHash: "0xonchain",
+	})
+	require.Error(t, err)
+}
+
+func TestTransactionColumnsBackfill(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestBackend(t, 20250425090000)
+
+	policyID := uuid.New()
+	_, err := backend.pool.Exec(ctx, `
+		INSERT INTO plugin_policies (id, public_key, chain_code_hex, derive_path, plugin_id, plugin_version, policy_version, plugin_type, signature, policy)
+		VALUES ($1, 'vault', 'chaincode', 'm', 'plugin', '1', '1', 'dca', '0xsig', '{}')`, policyID)
+	require.NoError(t, err)
+	metadata := map[string]any{
+		"transaction_type": "SWAP",
+		"chain_id":         "1",
+		"token":            "0xtoken",
+		"amount":           "1000",
+		"gas_fee":          "not a number",
+		"task_id":          "task",
+		"error":            "reverted",
+		"note":             "kept",
+	}
+	_, err = backend.pool.Exec(ctx, `
+		INSERT INTO transaction_history (policy_id, tx_body, tx_hash, status, metadata)
+		VALUES ($1, 'body', 'sighash', 'MINED', $2)`, policyID, metadata)
+	require.NoError(t, err)
+
+	migrateTo(t, backend, 20250426090000)
+
+	var (
+		txType, chainID, token, amount, signingTaskID, errorMessage string
+		gasFee                                                      *string
+		stored                                                      map[string]any
+	)
+	err = backend.pool.QueryRow(ctx, `
+		SELECT tx_type, chain_id, token, amount::TEXT, gas_fee::TEXT, signing_task_id, error_message, metadata
+		FROM transaction_history WHERE tx_hash = 'sighash'`).
+		Scan(&txType, &chainID, &token, &amount, &gasFee, &signingTaskID, &errorMessage, &stored)
+	require.NoError(t, err)
+	assert.Equal(t, "SWAP", txType)
+	assert.Equal(t, "1", chainID)
+	assert.Equal(t, "0xtoken", token)
+	assert.Equal(t, "1000", amount)
+	assert.Nil(t, gasFee, "amounts that aren't integers are dropped")
+	assert.Equal(t, "task", signingTaskID)
+	assert.Equal(t, "reverted", errorMessage)
+	assert.Equal(t, map[string]any{"note": "kept"}, stored)
+}
+
+func TestQueryTransactionHistoryCursor(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestBackend(t, 0)
+	policyID := insertTestPolicy(t, ctx, backend, "vault")
+	otherID := insertTestPolicy(t, ctx, backend, "other")
+
+	var want []string
+	for i := range 5 {
+		hash := fmt.Sprintf("0x%d", i)
+		_, err := backend.CreateTransactionHistory(ctx, types.TransactionHistory{
+			PolicyID: policyID,
+			TxBody:   "body",
+			TxHash:   hash,
+			Status:   types.StatusMined,
+			TxType:   "SWAP",
+			ChainID:  "1",
+			Token:    "0xtoken",
+			Amount:   "100",
+		})
+		require.NoError(t, err)
+		want = append(want, hash)
+	}
+	_, err := backend.CreateTransactionHistory(ctx, types.TransactionHistory{
+		PolicyID: otherID,
+		TxBody:   "body",
+		TxHash:   "0xother",
+		Status:   types.StatusMined,
+		TxType:   "SWAP",
+	})
+	require.NoError(t, err)
+
+	// with one created_at for every row the cursor relies on the id
+	_, err = backend.pool.Exec(ctx, `UPDATE transaction_history SET created_at = NOW()`)
+	require.NoError(t, err)
+
+	for _, ascending := range []bool{true, false} {
+		query := types.TransactionHistoryQuery{PublicKey: "vault", Ascending: ascending, Limit: 2}
+		var hashes []string
+		for {
+			history, err := backend.QueryTransactionHistory(ctx, query)
+			require.NoError(t, err)
+			for _, tx := range history {
+				hashes = append(hashes, tx.TxHash)
+			}
+			if len(history) < query.Limit {
+				break
+			}
+			last := history[len(history)-1]
+			query.Cursor = &types.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		assert.ElementsMatch(t, want, hashes, "ascending: %v", ascending)
+		assert.Len(t, hashes, len(want), "ascending: %v", ascending)
+	}
+}
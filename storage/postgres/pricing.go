@@ -14,14 +14,14 @@ const PRICINGS_TABLE = "pricings"
 func (p *PostgresBackend) FindPricingById(ctx context.Context, id string) (*types.Pricing, error) {
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 LIMIT 1;`, PRICINGS_TABLE)
 
-	rows, err := p.pool.Query(ctx, query, id)
+	rows, err := p.readPool().Query(ctx, query, id)
 	if err != nil {
 		return nil, err
 	}
 
 	pricing, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[types.Pricing])
 	if err != nil {
-		return nil, err
+		return nil, mapNotFound(err)
 	}
 
 	return &pricing, nil
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxReplicaLag        = 5 * time.Second
+	defaultReplicaCheckInterval = 5 * time.Second
+)
+
+// ReplicaOptions configures the read replicas of the backend.
+type ReplicaOptions struct {
+	DSNs []string
+	// MaxLag is the replication lag past which a replica stops serving reads
+	MaxLag time.Duration
+	// CheckInterval is how often the lag of the replicas is measured
+	CheckInterval time.Duration
+}
+
+type replica struct {
+	pool *pgxpool.Pool
+	// usable is set while the replica is reachable and within the lag limit
+	usable atomic.Bool
+}
+
+// replicaLagQuery measures how far the replica's replay is behind. A replica
+// that replayed everything it received is not lagging, even when the primary
+// has been idle since its last commit. A primary reports no lag.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+func (p *PostgresBackend) connectReplicas(opts ReplicaOptions) error {
+	p.maxReplicaLag = opts.MaxLag
+	if p.maxReplicaLag <= 0 {
+		p.maxReplicaLag = defaultMaxReplicaLag
+	}
+	for _, dsn := range opts.DSNs {
+		pool, err := pgxpool.New(context.Background(), dsn)
+		if err != nil {
+			p.closeReplicas()
+			return fmt.Errorf("failed to open replica: %w", err)
+		}
+		p.replicas = append(p.replicas, &replica{pool: pool})
+	}
+	if len(p.replicas) == 0 {
+		return nil
+	}
+
+	interval := opts.CheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopReplicaChecks = cancel
+	p.checkReplicas(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.checkReplicas(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// checkReplicas takes replicas out of the read rotation while they are down or
+// lagging, and brings them back once they caught up.
+func (p *PostgresBackend) checkReplicas(ctx context.Context) {
+	for i, r := range p.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, p.maxReplicaLag)
+		var lagSeconds float64
+		err := r.pool.QueryRow(checkCtx, replicaLagQuery).Scan(&lagSeconds)
+		cancel()
+
+		lag := time.Duration(lagSeconds * float64(time.Second))
+		usable := err == nil && lag <= p.maxReplicaLag
+		if was := r.usable.Swap(usable); was != usable {
+			logger := logrus.WithFields(logrus.Fields{"replica": i, "lag": lag})
+			if usable {
+				logger.Info("Replica is back in the read rotation")
+			} else if err != nil {
+				logger.WithError(err).Warn("Replica is unreachable, reading from the primary")
+			} else {
+				logger.Warn("Replica is lagging, reading from the primary")
+			}
+		}
+	}
+}
+
+// readPool returns the pool for reads that tolerate replication lag: a usable
+// replica in turn, or the primary when there is none.
+func (p *PostgresBackend) readPool() *pgxpool.Pool {
+	n := len(p.replicas)
+	if n == 0 {
+		return p.pool
+	}
+	start := p.nextReplica.Add(1)
+	for i := range uint64(n) {
+		r := p.replicas[(start+i)%uint64(n)]
+		if r.usable.Load() {
+			return r.pool
+		}
+	}
+	return p.pool
+}
+
+func (p *PostgresBackend) closeReplicas() {
+	if p.stopReplicaChecks != nil {
+		p.stopReplicaChecks()
+	}
+	for _, r := range p.replicas {
+		r.pool.Close()
+	}
+	p.replicas = nil
+}
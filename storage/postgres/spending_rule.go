@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const spendingRuleColumns = `id, public_key, plugin_type, chain_id, token, max_amount_per_tx, max_amount_per_day,
+	max_amount_per_recipient_per_day, max_transactions_per_day, max_allowance, allowed_tokens, allowed_contracts, active, created_at, updated_at`
+
+func scanSpendingRule(row pgx.Row) (*types.SpendingRule, error) {
+	var rule types.SpendingRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.PublicKey,
+		&rule.PluginType,
+		&rule.ChainID,
+		&rule.Token,
+		&rule.MaxAmountPerTx,
+		&rule.MaxAmountPerDay,
+		&rule.MaxAmountPerRecipientPerDay,
+		&rule.MaxTransactionsPerDay,
+		&rule.MaxAllowance,
+		&rule.AllowedTokens,
+		&rule.AllowedContracts,
+		&rule.Active,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (p *PostgresBackend) CreateSpendingRule(ctx context.Context, dto types.SpendingRuleDto) (*types.SpendingRule, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	row := p.pool.QueryRow(ctx, `
+		INSERT INTO spending_rules (public_key, plugin_type, chain_id, token, max_amount_per_tx, max_amount_per_day,
+			max_amount_per_recipient_per_day, max_transactions_per_day, allowed_tokens, allowed_contracts, active, max_allowance)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING `+spendingRuleColumns,
+		dto.PublicKey, dto.PluginType, dto.ChainID, dto.Token, dto.MaxAmountPerTx, dto.MaxAmountPerDay,
+		dto.MaxAmountPerRecipientPerDay, dto.MaxTransactionsPerDay, nonNilStrings(dto.AllowedTokens), nonNilStrings(dto.AllowedContracts), dto.Active,
+		dto.MaxAllowance,
+	)
+	rule, err := scanSpendingRule(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spending rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (p *PostgresBackend) UpdateSpendingRule(ctx context.Context, id string, dto types.SpendingRuleDto) (*types.SpendingRule, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	row := p.pool.QueryRow(ctx, `
+		UPDATE spending_rules
+		SET public_key = $2, plugin_type = $3, chain_id = $4, token = $5, max_amount_per_tx = $6, max_amount_per_day = $7,
+			max_amount_per_recipient_per_day = $8, max_transactions_per_day = $9, allowed_tokens = $10, allowed_contracts = $11,
+			active = $12, max_allowance = $13, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+spendingRuleColumns,
+		id, dto.PublicKey, dto.PluginType, dto.ChainID, dto.Token, dto.MaxAmountPerTx, dto.MaxAmountPerDay,
+		dto.MaxAmountPerRecipientPerDay, dto.MaxTransactionsPerDay, nonNilStrings(dto.AllowedTokens), nonNilStrings(dto.AllowedContracts), dto.Active,
+		dto.MaxAllowance,
+	)
+	rule, err := scanSpendingRule(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update spending rule: %w", mapNotFound(err))
+	}
+
+	return rule, nil
+}
+
+func (p *PostgresBackend) DeleteSpendingRule(ctx context.Context, id string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	tag, err := p.pool.Exec(ctx, `DELETE FROM spending_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete spending rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete spending rule: %w", storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetSpendingRules returns the rules of a vault, including the ones that apply
+// to every vault, or all rules when publicKey is empty.
+func (p *PostgresBackend) GetSpendingRules(ctx context.Context, publicKey string) ([]types.SpendingRule, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+spendingRuleColumns+`
+		FROM spending_rules
+		WHERE $1 = '' OR public_key = '' OR public_key = $1
+		ORDER BY created_at
+	`, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []types.SpendingRule{}
+	for rows.Next() {
+		rule, err := scanSpendingRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan spending rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, nil
+}
+
+func (p *PostgresBackend) CreateRuleDecision(ctx context.Context, decision types.RuleDecision) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO rule_decisions (policy_id, tx_hash, public_key, plugin_type, allowed, reasons, rule_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, decision.PolicyID, decision.TxHash, decision.PublicKey, decision.PluginType, decision.Allowed,
+		nonNilStrings(decision.Reasons), nonNilStrings(decision.RuleIDs))
+	if err != nil {
+		return fmt.Errorf("failed to create rule decision: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) GetRuleDecisions(ctx context.Context, publicKey string, take int, skip int) ([]types.RuleDecision, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, policy_id, tx_hash, public_key, plugin_type, allowed, reasons, rule_ids, created_at
+		FROM rule_decisions
+		WHERE $1 = '' OR public_key = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, publicKey, take, skip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule decisions: %w", err)
+	}
+	defer rows.Close()
+
+	decisions := []types.RuleDecision{}
+	for rows.Next() {
+		var decision types.RuleDecision
+		err := rows.Scan(
+			&decision.ID,
+			&decision.PolicyID,
+			&decision.TxHash,
+			&decision.PublicKey,
+			&decision.PluginType,
+			&decision.Allowed,
+			&decision.Reasons,
+			&decision.RuleIDs,
+			&decision.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule decision: %w", err)
+		}
+		decisions = append(decisions, decision)
+	}
+
+	return decisions, nil
+}
+
+// GetVaultSignedTransactionsTx returns the transactions the verifier signed
+// for any policy of the vault since the given time, leaving out the one with
+// the given hash.
+func (p *PostgresBackend) GetVaultSignedTransactionsTx(ctx context.Context, dbTx storage.Tx, publicKey string, since time.Time, excludeTxHash string) ([]types.VaultTransaction, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pgTx.Query(ctx, `
+		SELECT t.tx_hash, t.tx_body, p.plugin_type, t.signed_at
+		FROM transaction_history t
+		JOIN plugin_policies p ON p.id = t.policy_id
+		WHERE p.public_key = $1
+		AND t.signed_at >= $2
+		AND t.tx_hash <> $3
+	`, publicKey, since, excludeTxHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault signed transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []types.VaultTransaction
+	for rows.Next() {
+		var tx types.VaultTransaction
+		if err := rows.Scan(&tx.TxHash, &tx.TxBody, &tx.PluginType, &tx.SignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan vault signed transaction: %w", err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
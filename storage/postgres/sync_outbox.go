@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+func (p *PostgresBackend) InsertSyncEventTx(ctx context.Context, dbTx storage.Tx, event types.SyncEvent) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sync_outbox (aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err = pgTx.Exec(ctx, query, event.AggregateID, event.EventType, event.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to insert sync event: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliverableSyncEventsTx locks the pending events that are due and have no
+// earlier pending event for the same aggregate. An empty aggregateID selects
+// events of all aggregates.
+func (p *PostgresBackend) GetDeliverableSyncEventsTx(ctx context.Context, dbTx storage.Tx, aggregateID string, limit int) ([]types.SyncEvent, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT o.id, o.idempotency_key, o.aggregate_id, o.event_type, o.payload, o.status, o.attempts, o.next_attempt_at, o.last_error, o.created_at
+		FROM sync_outbox o
+		WHERE o.status = 'PENDING'
+		AND o.next_attempt_at <= NOW()
+		AND ($1 = '' OR o.aggregate_id = $1)
+		AND NOT EXISTS (
+			SELECT 1 FROM sync_outbox e
+			WHERE e.aggregate_id = o.aggregate_id
+			AND e.status = 'PENDING'
+			AND e.id < o.id
+		)
+		ORDER BY o.id ASC
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := pgTx.Query(ctx, query, aggregateID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliverable sync events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []types.SyncEvent
+	for rows.Next() {
+		var event types.SyncEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.IdempotencyKey,
+			&event.AggregateID,
+			&event.EventType,
+			&event.Payload,
+			&event.Status,
+			&event.Attempts,
+			&event.NextAttemptAt,
+			&event.LastError,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (p *PostgresBackend) CountPendingSyncEvents(ctx context.Context, aggregateID string) (int64, error) {
+	if p.pool == nil {
+		return 0, fmt.Errorf("database pool is nil")
+	}
+
+	var count int64
+	err := p.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM sync_outbox
+		WHERE status = 'PENDING'
+		AND aggregate_id = $1
+	`, aggregateID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending sync events: %w", err)
+	}
+
+	return count, nil
+}
+
+// LeaseSyncEventsTx holds the claimed events back from other relays until the
+// given time, by which their outcome is expected to be recorded.
+func (p *PostgresBackend) LeaseSyncEventsTx(ctx context.Context, dbTx storage.Tx, ids []int64, until time.Time) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE sync_outbox
+		SET next_attempt_at = $2
+		WHERE id = ANY($1)
+		AND status = 'PENDING'
+	`, ids, until)
+	if err != nil {
+		return fmt.Errorf("failed to lease sync events: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) MarkSyncEventDeliveredTx(ctx context.Context, dbTx storage.Tx, id int64) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE sync_outbox
+		SET status = 'DELIVERED', attempts = attempts + 1, delivered_at = NOW(), last_error = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark sync event delivered: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) RescheduleSyncEventTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string, nextAttemptAt time.Time) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE sync_outbox
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1
+	`, id, lastError, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule sync event: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) FailSyncEventTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE sync_outbox
+		SET status = 'FAILED', attempts = attempts + 1, last_error = $2
+		WHERE id = $1
+	`, id, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to fail sync event: %w", err)
+	}
+
+	return nil
+}
@@ -9,20 +9,26 @@ import (
 	"github.com/jackc/pgx/v5"
 
 	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
 )
 
-func (p *PostgresBackend) CreateTimeTriggerTx(ctx context.Context, tx pgx.Tx, trigger types.TimeTrigger) error {
+func (p *PostgresBackend) CreateTimeTriggerTx(ctx context.Context, dbTx storage.Tx, trigger types.TimeTrigger) error {
 	if p.pool == nil {
 		return fmt.Errorf("database pool is nil")
 	}
 
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO time_triggers 
     (policy_id, cron_expression, start_time, end_time, frequency, interval, status) 
     VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err := tx.Exec(ctx, query,
+	_, err = pgTx.Exec(ctx, query,
 		trigger.PolicyID,
 		trigger.CronExpression,
 		trigger.StartTime,
@@ -46,6 +52,22 @@ func (p *PostgresBackend) DeleteTimeTrigger(ctx context.Context, policyID string
 	return err
 }
 
+func (p *PostgresBackend) DeleteTimeTriggerTx(ctx context.Context, dbTx storage.Tx, policyID string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM time_triggers WHERE policy_id = $1`
+	_, err = pgTx.Exec(ctx, query, policyID)
+
+	return err
+}
+
 func (p *PostgresBackend) GetPendingTimeTriggers(ctx context.Context) ([]types.TimeTrigger, error) {
 	if p.pool == nil {
 		return nil, fmt.Errorf("database pool is nil")
@@ -60,6 +82,7 @@ func (p *PostgresBackend) GetPendingTimeTriggers(ctx context.Context) ([]types.T
 				WHERE t.start_time <= $1
 				AND (t.end_time IS NULL OR t.end_time > $1)
 				AND p.active = true
+				AND p.status = 'ACTIVE'
 				AND t.status = 'PENDING'
 				AND (t.last_execution IS NULL OR t.last_execution < $1)
     )
@@ -109,11 +132,19 @@ func (p *PostgresBackend) UpdateTimeTriggerLastExecution(ctx context.Context, po
 	return err
 }
 
-func (p *PostgresBackend) UpdateTimeTriggerTx(ctx context.Context, policyID string, trigger types.TimeTrigger, tx pgx.Tx) error {
+// UpdateTimeTriggerTx keeps the last execution and status of the policy's
+// trigger. The trigger is inserted when the policy has none, as when it
+// switches from an event trigger to a schedule.
+func (p *PostgresBackend) UpdateTimeTriggerTx(ctx context.Context, policyID string, trigger types.TimeTrigger, dbTx storage.Tx) error {
 	if p.pool == nil {
 		return fmt.Errorf("database pool is nil")
 	}
 
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE time_triggers
 		SET start_time = $2,
@@ -122,14 +153,22 @@ func (p *PostgresBackend) UpdateTimeTriggerTx(ctx context.Context, policyID stri
 				cron_expression = $5
 		WHERE policy_id = $1
 	`
-	_, err := tx.Exec(ctx, query,
+	tag, err := pgTx.Exec(ctx, query,
 		policyID,
 		trigger.StartTime,
 		trigger.Frequency,
 		trigger.Interval,
 		trigger.CronExpression,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	trigger.PolicyID = policyID
+	return p.CreateTimeTriggerTx(ctx, dbTx, trigger)
 }
 
 func (p *PostgresBackend) GetTriggerStatus(ctx context.Context, policyID string) (types.TimeTriggerStatus, error) {
@@ -147,7 +186,7 @@ func (p *PostgresBackend) GetTriggerStatus(ctx context.Context, policyID string)
 	err := p.pool.QueryRow(ctx, query, policyID).Scan(&status)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", fmt.Errorf("trigger not found for policy_id: %s", policyID)
+			return "", fmt.Errorf("trigger not found for policy_id: %s: %w", policyID, storage.ErrNotFound)
 		}
 		return "", err
 	}
@@ -169,3 +208,21 @@ func (p *PostgresBackend) UpdateTriggerStatus(ctx context.Context, policyID stri
 	_, err := p.pool.Exec(ctx, query, policyID, status)
 	return err
 }
+
+func (p *PostgresBackend) ClaimTimeTrigger(ctx context.Context, policyID string) (bool, error) {
+	if p.pool == nil {
+		return false, fmt.Errorf("database pool is nil")
+	}
+
+	query := `
+		UPDATE time_triggers
+		SET status = $2
+		WHERE policy_id = $1 AND status = $3
+	`
+
+	tag, err := p.pool.Exec(ctx, query, policyID, types.StatusTimeTriggerRunning, types.StatusTimeTriggerPending)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
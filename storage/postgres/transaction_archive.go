@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+// GetArchivableTransactionsTx locks the oldest finished transactions last
+// updated before the given time. Only transactions of inactive or deleted
+// policies are archivable, running policies count theirs to schedule the next
+// ones. Transactions locked by another run are skipped.
+func (p *PostgresBackend) GetArchivableTransactionsTx(ctx context.Context, dbTx storage.Tx, before time.Time, limit int) ([]types.ArchivedTransaction, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pgTx.Query(ctx, `
+		SELECT `+transactionColumns+`, t.signed_at, COALESCE(t.signed_type, ''), t.attestation
+		FROM transaction_history t
+		LEFT JOIN plugin_policies p ON p.id = t.policy_id
+		WHERE t.status::text = ANY($1)
+		AND t.updated_at < $2
+		AND (p.id IS NULL OR NOT p.active OR p.status <> 'ACTIVE')
+		ORDER BY t.created_at, t.id
+		LIMIT $3
+		FOR UPDATE OF t SKIP LOCKED
+	`, finishedStatuses(), before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archivable transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []types.ArchivedTransaction
+	for rows.Next() {
+		var tx types.ArchivedTransaction
+		err := rows.Scan(
+			&tx.ID,
+			&tx.PolicyID,
+			&tx.TxBody,
+			&tx.TxHash,
+			&tx.BroadcastHash,
+			&tx.Status,
+			&tx.CreatedAt,
+			&tx.UpdatedAt,
+			&tx.Metadata,
+			&tx.ErrorMessage,
+			&tx.TxType,
+			&tx.ChainID,
+			&tx.FromAddress,
+			&tx.ToAddress,
+			&tx.Token,
+			&tx.Amount,
+			&tx.Nonce,
+			&tx.GasLimit,
+			&tx.GasPrice,
+			&tx.GasFee,
+			&tx.SigningTaskID,
+			&tx.BlockNumber,
+			&tx.ErrorCode,
+			&tx.SignedAt,
+			&tx.SignedType,
+			&tx.Attestation,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan archivable transaction: %w", err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// ArchiveTransactionsTx records the archive, adds the aggregates to the stored
+// ones, keeps the attestations and deletes the archived transactions.
+func (p *PostgresBackend) ArchiveTransactionsTx(ctx context.Context, dbTx storage.Tx, archive types.TransactionArchive, txIDs []uuid.UUID, aggregates []types.TransactionAggregate) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		INSERT INTO transaction_archives (id, object_name, count, first_created_at, last_created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, archive.ID, archive.ObjectName, archive.Count, archive.FirstCreatedAt, archive.LastCreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert transaction archive: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	for _, aggregate := range aggregates {
+		batch.Queue(`
+			INSERT INTO transaction_aggregates (policy_id, day, chain_id, token, tx_type, status, count, amount, gas_fee)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8::NUMERIC, $9::NUMERIC)
+			ON CONFLICT (policy_id, day, chain_id, token, tx_type, status) DO UPDATE
+			SET count = transaction_aggregates.count + EXCLUDED.count,
+				amount = transaction_aggregates.amount + EXCLUDED.amount,
+				gas_fee = transaction_aggregates.gas_fee + EXCLUDED.gas_fee
+		`,
+			aggregate.PolicyID,
+			aggregate.Day,
+			aggregate.ChainID,
+			aggregate.Token,
+			aggregate.TxType,
+			aggregate.Status,
+			aggregate.Count,
+			aggregate.Amount,
+			aggregate.GasFee,
+		)
+	}
+	if err := pgTx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to update transaction aggregates: %w", err)
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		INSERT INTO archived_attestations (transaction_id, policy_id, tx_hash, broadcast_hash, attestation, created_at)
+		SELECT id, policy_id, tx_hash, broadcast_hash, attestation, created_at
+		FROM transaction_history
+		WHERE id = ANY($1)
+		AND attestation IS NOT NULL
+		ON CONFLICT (transaction_id) DO NOTHING
+	`, txIDs)
+	if err != nil {
+		return fmt.Errorf("failed to keep archived attestations: %w", err)
+	}
+
+	_, err = pgTx.Exec(ctx, `DELETE FROM transaction_history WHERE id = ANY($1)`, txIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete archived transactions: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeTransactionMetadataTx removes the keys from the metadata of up to limit
+// finished transactions last updated before the given time, and returns how
+// many were changed.
+func (p *PostgresBackend) PurgeTransactionMetadataTx(ctx context.Context, dbTx storage.Tx, before time.Time, keys []string, limit int) (int64, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := pgTx.Exec(ctx, `
+		UPDATE transaction_history
+		SET metadata = metadata - $1::TEXT[]
+		WHERE id IN (
+			SELECT id
+			FROM transaction_history
+			WHERE status::text = ANY($2)
+			AND updated_at < $3
+			AND metadata ?| $1::TEXT[]
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+	`, keys, finishedStatuses(), before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge transaction metadata: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetTransactionAggregates returns the aggregates of the policy's archived
+// transactions, latest day first.
+func (p *PostgresBackend) GetTransactionAggregates(ctx context.Context, policyID uuid.UUID) ([]types.TransactionAggregate, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.readPool().Query(ctx, `
+		SELECT policy_id, day, chain_id, token, tx_type, status, count, amount::TEXT, gas_fee::TEXT
+		FROM transaction_aggregates
+		WHERE policy_id = $1
+		ORDER BY day DESC, chain_id, token, tx_type, status
+	`, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	aggregates := []types.TransactionAggregate{}
+	for rows.Next() {
+		var aggregate types.TransactionAggregate
+		err := rows.Scan(
+			&aggregate.PolicyID,
+			&aggregate.Day,
+			&aggregate.ChainID,
+			&aggregate.Token,
+			&aggregate.TxType,
+			&aggregate.Status,
+			&aggregate.Count,
+			&aggregate.Amount,
+			&aggregate.GasFee,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction aggregate: %w", err)
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+
+	return aggregates, nil
+}
+
+func finishedStatuses() []string {
+	statuses := make([]string, len(types.FinishedTransactionStatuses))
+	for i, status := range types.FinishedTransactionStatuses {
+		statuses[i] = string(status)
+	}
+	return statuses
+}
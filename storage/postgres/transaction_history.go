@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+// transactionColumns are the columns of transaction_history t read by
+// scanTransaction. Unknown details read as empty strings.
+const transactionColumns = `t.id, t.policy_id, t.tx_body, t.tx_hash, COALESCE(t.broadcast_hash, ''), t.status, t.created_at, t.updated_at,
+	t.metadata, t.error_message, COALESCE(t.tx_type, ''), COALESCE(t.chain_id, ''),
+	COALESCE(t.from_address, ''), COALESCE(t.to_address, ''), COALESCE(t.token, ''),
+	COALESCE(t.amount::TEXT, ''), t.nonce, t.gas_limit, COALESCE(t.gas_price::TEXT, ''),
+	COALESCE(t.gas_fee::TEXT, ''), COALESCE(t.signing_task_id, ''), t.block_number, COALESCE(t.error_code, '')`
+
+const transactionInsertColumns = `policy_id, tx_body, tx_hash, status, metadata, error_message,
+	tx_type, chain_id, from_address, to_address, token, amount, nonce, gas_limit, gas_price, gas_fee,
+	signing_task_id, block_number, error_code, broadcast_hash`
+
+// transactionInsertValues stores empty details as NULL.
+const transactionInsertValues = `$1, $2, $3, $4, $5, $6,
+	NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''),
+	NULLIF($12, '')::NUMERIC, $13, $14, NULLIF($15, '')::NUMERIC, NULLIF($16, '')::NUMERIC,
+	NULLIF($17, ''), $18, NULLIF($19, ''), NULLIF($20, '')`
+
+// transactionUpdateQuery sets the status, merges the metadata and keeps the
+// stored details the update leaves empty.
+const transactionUpdateQuery = `
+	UPDATE transaction_history
+	SET status = $1,
+		metadata = COALESCE(metadata || $2::jsonb, metadata),
+		signing_task_id = COALESCE(NULLIF($3, ''), signing_task_id),
+		block_number = COALESCE($4, block_number),
+		error_code = COALESCE(NULLIF($5, ''), error_code),
+		error_message = COALESCE($6, error_message),
+		broadcast_hash = COALESCE(NULLIF($7, ''), broadcast_hash),
+		updated_at = NOW()
+	WHERE id = $8
+`
+
+func transactionInsertArgs(tx types.TransactionHistory) []any {
+	return []any{
+		tx.PolicyID,
+		tx.TxBody,
+		tx.TxHash,
+		tx.Status,
+		tx.Metadata,
+		tx.ErrorMessage,
+		tx.TxType,
+		tx.ChainID,
+		tx.FromAddress,
+		tx.ToAddress,
+		tx.Token,
+		tx.Amount,
+		tx.Nonce,
+		tx.GasLimit,
+		tx.GasPrice,
+		tx.GasFee,
+		tx.SigningTaskID,
+		tx.BlockNumber,
+		tx.ErrorCode,
+		tx.BroadcastHash,
+	}
+}
+
+func transactionUpdateArgs(txID uuid.UUID, update types.TransactionUpdate) []any {
+	return []any{
+		update.Status,
+		update.Metadata,
+		update.SigningTaskID,
+		update.BlockNumber,
+		update.ErrorCode,
+		update.ErrorMessage,
+		update.BroadcastHash,
+		txID,
+	}
+}
+
+func scanTransaction(row pgx.Row) (types.TransactionHistory, error) {
+	var tx types.TransactionHistory
+	err := row.Scan(
+		&tx.ID,
+		&tx.PolicyID,
+		&tx.TxBody,
+		&tx.TxHash,
+		&tx.BroadcastHash,
+		&tx.Status,
+		&tx.CreatedAt,
+		&tx.UpdatedAt,
+		&tx.Metadata,
+		&tx.ErrorMessage,
+		&tx.TxType,
+		&tx.ChainID,
+		&tx.FromAddress,
+		&tx.ToAddress,
+		&tx.Token,
+		&tx.Amount,
+		&tx.Nonce,
+		&tx.GasLimit,
+		&tx.GasPrice,
+		&tx.GasFee,
+		&tx.SigningTaskID,
+		&tx.BlockNumber,
+		&tx.ErrorCode,
+	)
+	return tx, err
+}
+
+// transactionHistoryFilter builds the WHERE clause of a history query over
+// transaction_history t joined with plugin_policies p. The cursor is left out
+// of the totals.
+func transactionHistoryFilter(query types.TransactionHistoryQuery, withCursor bool) (string, []any) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, "p.public_key = "+arg(query.PublicKey))
+	if query.PolicyID != "" {
+		conditions = append(conditions, "t.policy_id::text = "+arg(query.PolicyID))
+	}
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, len(query.Statuses))
+		for i, status := range query.Statuses {
+			statuses[i] = string(status)
+		}
+		conditions = append(conditions, "t.status::text = ANY("+arg(statuses)+")")
+	}
+	if len(query.TransactionTypes) > 0 {
+		conditions = append(conditions, "t.tx_type = ANY("+arg(query.TransactionTypes)+")")
+	}
+	if query.From != nil {
+		conditions = append(conditions, "t.created_at >= "+arg(*query.From))
+	}
+	if query.To != nil {
+		conditions = append(conditions, "t.created_at < "+arg(*query.To))
+	}
+	if query.Token != "" {
+		conditions = append(conditions, "t.token = "+arg(query.Token))
+	}
+	if query.ChainID != "" {
+		conditions = append(conditions, "t.chain_id = "+arg(query.ChainID))
+	}
+	if query.TxHash != "" {
+		hash := arg(query.TxHash)
+		conditions = append(conditions, fmt.Sprintf("(t.tx_hash = %s OR t.broadcast_hash = %s)", hash, hash))
+	}
+	if withCursor && query.Cursor != nil {
+		operator := "<"
+		if query.Ascending {
+			operator = ">"
+		}
+		conditions = append(conditions, fmt.Sprintf("(t.created_at, t.id) %s (%s, %s)",
+			operator, arg(query.Cursor.CreatedAt), arg(query.Cursor.ID)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// QueryTransactionHistory returns a page of the transactions of a vault's
+// policies, ordered by creation time and id.
+func (p *PostgresBackend) QueryTransactionHistory(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionHistory, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	where, args := transactionHistoryFilter(query, true)
+	direction := "DESC"
+	if query.Ascending {
+		direction = "ASC"
+	}
+	args = append(args, query.Limit)
+
+	rows, err := p.readPool().Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM transaction_history t
+		JOIN plugin_policies p ON p.id = t.policy_id
+		WHERE %s
+		ORDER BY t.created_at %s, t.id %s
+		LIMIT $%d
+	`, transactionColumns, where, direction, direction, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []types.TransactionHistory{}
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		history = append(history, tx)
+	}
+
+	return history, nil
+}
+
+// GetTransactionTotals sums the amount and gas of the spent transactions
+// matching the query per chain and token.
+func (p *PostgresBackend) GetTransactionTotals(ctx context.Context, query types.TransactionHistoryQuery) ([]types.TransactionTotal, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	where, args := transactionHistoryFilter(query, false)
+	spent := make([]string, len(types.SpentTransactionStatuses))
+	for i, status := range types.SpentTransactionStatuses {
+		spent[i] = string(status)
+	}
+	args = append(args, spent)
+
+	rows, err := p.readPool().Query(ctx, fmt.Sprintf(`
+		SELECT COALESCE(t.chain_id, ''), t.token, COUNT(*),
+			COALESCE(SUM(t.amount), 0)::TEXT, COALESCE(SUM(t.gas_fee), 0)::TEXT
+		FROM transaction_history t
+		JOIN plugin_policies p ON p.id = t.policy_id
+		WHERE %s
+		AND t.status::text = ANY($%d)
+		AND t.token IS NOT NULL
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []types.TransactionTotal{}
+	for rows.Next() {
+		var total types.TransactionTotal
+		err := rows.Scan(&total.ChainID, &total.Token, &total.Count, &total.AmountSpent, &total.GasPaid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, nil
+}
@@ -11,7 +11,7 @@ import (
 const USERS_TABLE = "users"
 
 func (p *PostgresBackend) FindUserById(ctx context.Context, userId string) (*types.User, error) {
-	query := fmt.Sprintf(`SELECT id, username, created_at FROM %s WHERE id = $1 LIMIT 1;`, USERS_TABLE)
+	query := fmt.Sprintf(`SELECT id, username, role, created_at FROM %s WHERE id = $1 LIMIT 1;`, USERS_TABLE)
 
 	rows, err := p.pool.Query(ctx, query, userId)
 	if err != nil {
@@ -20,7 +20,7 @@ func (p *PostgresBackend) FindUserById(ctx context.Context, userId string) (*typ
 
 	order, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[types.User])
 	if err != nil {
-		return nil, err
+		return nil, mapNotFound(err)
 	}
 
 	return &order, nil
@@ -36,7 +36,7 @@ func (p *PostgresBackend) FindUserByName(ctx context.Context, username string) (
 
 	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[types.UserWithPassword])
 	if err != nil {
-		return nil, err
+		return nil, mapNotFound(err)
 	}
 
 	return &user, nil
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+)
+
+const vaultBackupVersionColumns = `id, public_key, version, file_name, content_hash, lib_type, signers,
+	reshare_prefix, session_id, reason, created_at`
+
+// CreateVaultBackupVersion records the version, numbered after the latest
+// version of the vault. Concurrent creations for a vault fail on the unique
+// version.
+func (p *PostgresBackend) CreateVaultBackupVersion(ctx context.Context, version types.VaultBackupVersion) (*types.VaultBackupVersion, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	signers := version.Signers
+	if signers == nil {
+		signers = []string{}
+	}
+	row := p.pool.QueryRow(ctx, `
+		INSERT INTO vault_backup_versions (public_key, version, file_name, content_hash, lib_type, signers,
+			reshare_prefix, session_id, reason)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7, $8
+		FROM vault_backup_versions
+		WHERE public_key = $1
+		RETURNING `+vaultBackupVersionColumns,
+		version.PublicKey,
+		version.FileName,
+		version.ContentHash,
+		version.LibType,
+		signers,
+		version.ResharePrefix,
+		version.SessionID,
+		version.Reason,
+	)
+	created, err := scanVaultBackupVersion(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault backup version: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetVaultBackupVersions returns the versions of the vault, latest first.
+func (p *PostgresBackend) GetVaultBackupVersions(ctx context.Context, publicKey string) ([]types.VaultBackupVersion, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+vaultBackupVersionColumns+`
+		FROM vault_backup_versions
+		WHERE public_key = $1
+		ORDER BY version DESC
+	`, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault backup versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []types.VaultBackupVersion{}
+	for rows.Next() {
+		version, err := scanVaultBackupVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vault backup version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (p *PostgresBackend) GetVaultBackupVersion(ctx context.Context, publicKey string, version int) (*types.VaultBackupVersion, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	row := p.pool.QueryRow(ctx, `
+		SELECT `+vaultBackupVersionColumns+`
+		FROM vault_backup_versions
+		WHERE public_key = $1
+		AND version = $2
+	`, publicKey, version)
+	found, err := scanVaultBackupVersion(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("vault backup version %d not found: %w", version, mapNotFound(err))
+		}
+		return nil, fmt.Errorf("failed to get vault backup version: %w", err)
+	}
+
+	return &found, nil
+}
+
+func (p *PostgresBackend) DeleteVaultBackupVersion(ctx context.Context, id uuid.UUID) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	_, err := p.pool.Exec(ctx, `DELETE FROM vault_backup_versions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete vault backup version: %w", err)
+	}
+
+	return nil
+}
+
+func scanVaultBackupVersion(row pgx.Row) (types.VaultBackupVersion, error) {
+	var version types.VaultBackupVersion
+	err := row.Scan(
+		&version.ID,
+		&version.PublicKey,
+		&version.Version,
+		&version.FileName,
+		&version.ContentHash,
+		&version.LibType,
+		&version.Signers,
+		&version.ResharePrefix,
+		&version.SessionID,
+		&version.Reason,
+		&version.CreatedAt,
+	)
+	return version, err
+}
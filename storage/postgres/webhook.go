@@ -0,0 +1,321 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vultisig/vultiserver-plugin/internal/types"
+	"github.com/vultisig/vultiserver-plugin/storage"
+)
+
+const webhookSubscriptionColumns = `id, public_key, policy_id, url, event_types, active, created_at, updated_at`
+
+func (p *PostgresBackend) CreateWebhookSubscription(ctx context.Context, subscription types.WebhookSubscription) (*types.WebhookSubscription, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	created := types.WebhookSubscription{Secret: subscription.Secret}
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (public_key, policy_id, url, secret, event_types, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+webhookSubscriptionColumns,
+		subscription.PublicKey, subscription.PolicyID, subscription.URL, subscription.Secret,
+		nonNilStrings(subscription.EventTypes), subscription.Active,
+	).Scan(
+		&created.ID,
+		&created.PublicKey,
+		&created.PolicyID,
+		&created.URL,
+		&created.EventTypes,
+		&created.Active,
+		&created.CreatedAt,
+		&created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetWebhookSubscriptions returns the subscriptions of a vault without their
+// secrets.
+func (p *PostgresBackend) GetWebhookSubscriptions(ctx context.Context, publicKey string) ([]types.WebhookSubscription, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+webhookSubscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE public_key = $1
+		ORDER BY created_at
+	`, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []types.WebhookSubscription{}
+	for rows.Next() {
+		var subscription types.WebhookSubscription
+		err := rows.Scan(
+			&subscription.ID,
+			&subscription.PublicKey,
+			&subscription.PolicyID,
+			&subscription.URL,
+			&subscription.EventTypes,
+			&subscription.Active,
+			&subscription.CreatedAt,
+			&subscription.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription deletes a subscription of the vault, those of
+// other vaults are not found.
+func (p *PostgresBackend) DeleteWebhookSubscription(ctx context.Context, publicKey string, id string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	tag, err := p.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND public_key = $2`, id, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete webhook subscription: %w", storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// InsertWebhookDeliveriesTx queues the event for every active subscription of
+// the vault matching its policy and type.
+func (p *PostgresBackend) InsertWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, event types.WebhookEvent, payload []byte) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE active
+		AND public_key = $4
+		AND (policy_id = '' OR policy_id = $5)
+		AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+	`, event.ID, event.Type, payload, event.PublicKey, event.PolicyID)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// GetDueWebhookDeliveriesTx locks the pending deliveries that are due.
+func (p *PostgresBackend) GetDueWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, limit int) ([]types.WebhookDelivery, error) {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pgTx.Query(ctx, `
+		SELECT d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = 'PENDING'
+		AND d.next_attempt_at <= NOW()
+		ORDER BY d.id ASC
+		LIMIT $1
+		FOR UPDATE OF d SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []types.WebhookDelivery
+	for rows.Next() {
+		var delivery types.WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Attempts,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// LeaseWebhookDeliveriesTx holds the claimed deliveries back from other
+// dispatchers until the given time, by which their outcome is expected to be
+// recorded.
+func (p *PostgresBackend) LeaseWebhookDeliveriesTx(ctx context.Context, dbTx storage.Tx, ids []int64, until time.Time) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id = ANY($1)
+		AND status = 'PENDING'
+	`, ids, until)
+	if err != nil {
+		return fmt.Errorf("failed to lease webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) MarkWebhookDeliveredTx(ctx context.Context, dbTx storage.Tx, id int64) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'DELIVERED', attempts = attempts + 1, delivered_at = NOW(), last_error = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivered: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) RescheduleWebhookDeliveryTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string, nextAttemptAt time.Time) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1
+	`, id, lastError, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// DeadLetterWebhookDeliveryTx moves a delivery that ran out of attempts to the
+// dead letter table.
+func (p *PostgresBackend) DeadLetterWebhookDeliveryTx(ctx context.Context, dbTx storage.Tx, id int64, lastError string) error {
+	pgTx, err := pgxTx(dbTx)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx.Exec(ctx, `
+		WITH failed AS (
+			DELETE FROM webhook_deliveries
+			WHERE id = $1
+			RETURNING subscription_id, event_id, event_type, payload, attempts
+		)
+		INSERT INTO webhook_dead_letters (subscription_id, event_id, event_type, payload, attempts, last_error)
+		SELECT subscription_id, event_id, event_type, payload, attempts + 1, $2
+		FROM failed
+	`, id, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to dead letter webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresBackend) GetWebhookDeadLetters(ctx context.Context, publicKey string, take int, skip int) ([]types.WebhookDeadLetter, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database pool is nil")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT l.id, l.subscription_id, s.public_key, l.event_id, l.event_type, l.payload, l.attempts, l.last_error, l.failed_at
+		FROM webhook_dead_letters l
+		JOIN webhook_subscriptions s ON s.id = l.subscription_id
+		WHERE s.public_key = $1
+		ORDER BY l.failed_at DESC
+		LIMIT $2 OFFSET $3
+	`, publicKey, take, skip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := []types.WebhookDeadLetter{}
+	for rows.Next() {
+		var deadLetter types.WebhookDeadLetter
+		err := rows.Scan(
+			&deadLetter.ID,
+			&deadLetter.SubscriptionID,
+			&deadLetter.PublicKey,
+			&deadLetter.EventID,
+			&deadLetter.EventType,
+			&deadLetter.Payload,
+			&deadLetter.Attempts,
+			&deadLetter.LastError,
+			&deadLetter.FailedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook dead letter: %w", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayWebhookDeadLetter queues a dead letter of the vault for delivery again
+// with a fresh attempt count.
+func (p *PostgresBackend) ReplayWebhookDeadLetter(ctx context.Context, publicKey string, id int64) error {
+	if p.pool == nil {
+		return fmt.Errorf("database pool is nil")
+	}
+
+	tag, err := p.pool.Exec(ctx, `
+		WITH replayed AS (
+			DELETE FROM webhook_dead_letters l
+			USING webhook_subscriptions s
+			WHERE l.id = $1
+			AND s.id = l.subscription_id
+			AND s.public_key = $2
+			RETURNING l.subscription_id, l.event_id, l.event_type, l.payload
+		)
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT subscription_id, event_id, event_type, payload
+		FROM replayed
+	`, id, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to replay webhook dead letter: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to replay webhook dead letter: %w", storage.ErrNotFound)
+	}
+
+	return nil
+}
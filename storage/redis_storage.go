@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -10,6 +11,8 @@ import (
 	"github.com/vultisig/vultiserver/contexthelper"
 )
 
+var _ KeyValueStore = (*RedisStorage)(nil)
+
 type RedisStorage struct {
 	cfg    config.Config
 	client *redis.Client
@@ -36,7 +39,11 @@ func (r *RedisStorage) Get(ctx context.Context, key string) (string, error) {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
 		return "", err
 	}
-	return r.client.Get(ctx, key).Result()
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrKeyNotFound
+	}
+	return value, err
 }
 func (r *RedisStorage) Set(ctx context.Context, key string, value string, expiry time.Duration) error {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
@@ -44,6 +51,12 @@ func (r *RedisStorage) Set(ctx context.Context, key string, value string, expiry
 	}
 	return r.client.Set(ctx, key, value, expiry).Err()
 }
+func (r *RedisStorage) SetNX(ctx context.Context, key string, value string, expiry time.Duration) (bool, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return false, err
+	}
+	return r.client.SetNX(ctx, key, value, expiry).Result()
+}
 func (r *RedisStorage) Expire(ctx context.Context, key string, expiry time.Duration) error {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
 		return err
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultiserver-plugin/config"
+)
+
+// S3BlockStorage stores files in an S3 compatible bucket.
+type S3BlockStorage struct {
+	cfg      config.Config
+	session  *session.Session
+	s3Client *s3.S3
+	logger   *logrus.Logger
+}
+
+func NewS3BlockStorage(cfg config.Config) (*S3BlockStorage, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String(cfg.BlockStorage.Region),
+		Endpoint:         aws.String(cfg.BlockStorage.Host),
+		Credentials:      credentials.NewStaticCredentials(cfg.BlockStorage.AccessKey, cfg.BlockStorage.SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3BlockStorage{
+		cfg:      cfg,
+		session:  sess,
+		s3Client: s3.New(sess),
+		logger:   logrus.WithField("module", "block_storage").Logger,
+	}, nil
+}
+
+func (bs *S3BlockStorage) FileExist(fileName string) (bool, error) {
+	_, err := bs.s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bs.cfg.BlockStorage.Bucket),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		bs.logger.Error(err)
+		filePathName := filepath.Join(bs.cfg.Server.VaultsFilePath, fileName)
+		_, err := os.Stat(filePathName)
+		return false, err
+	}
+	return true, nil
+}
+func (bs *S3BlockStorage) UploadFile(fileContent []byte, fileName string) error {
+	bs.logger.Infoln("upload file", fileName, "bucket", bs.cfg.BlockStorage.Bucket, "content length", len(fileContent))
+	output, err := bs.s3Client.PutObjectWithContext(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(bs.cfg.BlockStorage.Bucket),
+		Key:           aws.String(fileName),
+		Body:          aws.ReadSeekCloser(bytes.NewReader(fileContent)),
+		ContentLength: aws.Int64(int64(len(fileContent))),
+	})
+	if err != nil {
+		bs.logger.Error(err)
+		return err
+	}
+	if output != nil {
+		bs.logger.Infof("upload file %s success, version id: %s", fileName, aws.StringValue(output.VersionId))
+	}
+	return nil
+}
+
+func (bs *S3BlockStorage) GetFile(fileName string) ([]byte, error) {
+	bs.logger.Infoln("get file", fileName, "bucket", bs.cfg.BlockStorage.Bucket)
+	output, err := bs.s3Client.GetObjectWithContext(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(bs.cfg.BlockStorage.Bucket),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		bs.logger.Error("error getting file: ", err)
+		return nil, err
+	}
+	defer func() {
+		if err := output.Body.Close(); err != nil {
+			bs.logger.Error(err)
+		}
+	}()
+	return io.ReadAll(output.Body)
+}
+func (bs *S3BlockStorage) DeleteFile(fileName string) error {
+	_, err := bs.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bs.cfg.BlockStorage.Bucket),
+		Key:    aws.String(fileName),
+	})
+	if err != nil {
+		bs.logger.Error(err)
+		return err
+	}
+	bs.logger.Infof("delete file %s success", fileName)
+	return nil
+}
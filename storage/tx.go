@@ -0,0 +1,10 @@
+package storage
+
+import "context"
+
+// Tx is a transaction started by DatabaseStorage.BeginTx. Only the backend that
+// started it can run its ...Tx methods on it.
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}